@@ -0,0 +1,152 @@
+package awssecretsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// ErrResourceNotFound indicates that the secret or its version does not exist.
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ErrResourceExists indicates that the secret already exists.
+var ErrResourceExists = errors.New("resource exists")
+
+// api is the part of the AWS Secrets Manager client that the provider uses.
+type api interface {
+	GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, in *secretsmanager.PutSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, in *secretsmanager.CreateSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	DeleteSecret(ctx context.Context, in *secretsmanager.DeleteSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+}
+
+// secretsManager returns the client of AWS Secrets Manager. The default config is loaded on the first call, and again on the next
+// call if it fails.
+func (p *TOTPSecretProvider) secretsManager(ctx context.Context) (api, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	cfg := p.config
+
+	if cfg == nil {
+		c, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not load aws config: %w", err)
+		}
+
+		cfg = &c
+	}
+
+	p.client = secretsmanager.NewFromConfig(*cfg, p.clientOptions...)
+
+	return p.client, nil
+}
+
+func (p *TOTPSecretProvider) getValue(ctx context.Context, versionID, versionStage string) ([]byte, error) {
+	c, err := p.secretsManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := c.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(p.secretID),
+		VersionId:    optional(versionID),
+		VersionStage: optional(versionStage),
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+
+	return out.SecretBinary, nil
+}
+
+func (p *TOTPSecretProvider) putValue(ctx context.Context, value []byte) error {
+	c, err := p.secretsManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(p.secretID),
+		SecretString: aws.String(string(value)),
+	})
+
+	return wrapError(err)
+}
+
+func (p *TOTPSecretProvider) createSecret(ctx context.Context, value []byte) error {
+	c, err := p.secretsManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(p.secretID),
+		SecretString: aws.String(string(value)),
+	})
+
+	return wrapError(err)
+}
+
+func (p *TOTPSecretProvider) deleteSecret(ctx context.Context) error {
+	c, err := p.secretsManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	in := &secretsmanager.DeleteSecretInput{SecretId: aws.String(p.secretID)}
+
+	if p.forceDelete {
+		in.ForceDeleteWithoutRecovery = aws.Bool(true)
+	}
+
+	if p.recoveryWindow > 0 {
+		in.RecoveryWindowInDays = aws.Int64(p.recoveryWindow)
+	}
+
+	_, err = c.DeleteSecret(ctx, in)
+
+	return wrapError(err)
+}
+
+// wrapError wraps the error of the api with ErrResourceNotFound or ErrResourceExists according to its type.
+func wrapError(err error) error {
+	var (
+		notFound *types.ResourceNotFoundException
+		exists   *types.ResourceExistsException
+	)
+
+	switch {
+	case err == nil:
+		return nil
+
+	case errors.As(err, &notFound):
+		return fmt.Errorf("%w: %w", ErrResourceNotFound, err)
+
+	case errors.As(err, &exists):
+		return fmt.Errorf("%w: %w", ErrResourceExists, err)
+	}
+
+	return fmt.Errorf("could not call aws secrets manager: %w", err)
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return aws.String(s)
+}
@@ -0,0 +1,2 @@
+// Package awssecretsmanager provides totp secret storage using AWS Secrets Manager.
+package awssecretsmanager
@@ -0,0 +1,122 @@
+package awssecretsmanager
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+// Option configures the TOTPSecretProvider.
+type Option interface {
+	applyOption(p *TOTPSecretProvider)
+}
+
+type optionFunc func(p *TOTPSecretProvider)
+
+func (f optionFunc) applyOption(p *TOTPSecretProvider) {
+	f(p)
+}
+
+// WithConfig sets the AWS config of the client. Default is the config that is loaded with config.LoadDefaultConfig.
+func WithConfig(cfg aws.Config) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.config = &cfg
+	})
+}
+
+// WithRegion sets the region. Default is the region of the AWS config.
+func WithRegion(region string) Option {
+	return withClientOption(func(o *secretsmanager.Options) {
+		o.Region = region
+	})
+}
+
+// WithEndpoint sets the endpoint of the api, such as a VPC endpoint or a local stand-in. Default is the endpoint of the
+// region.
+func WithEndpoint(endpoint string) Option {
+	return withClientOption(func(o *secretsmanager.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+}
+
+// WithCredentials sets the credentials provider, such as credentials.NewStaticCredentialsProvider. Default is the
+// credentials provider of the AWS config.
+func WithCredentials(c aws.CredentialsProvider) Option {
+	return withClientOption(func(o *secretsmanager.Options) {
+		o.Credentials = c
+	})
+}
+
+// WithHTTPClient sets the http client.
+func WithHTTPClient(hc aws.HTTPClient) Option {
+	return withClientOption(func(o *secretsmanager.Options) {
+		o.HTTPClient = hc
+	})
+}
+
+func withClientOption(fn func(o *secretsmanager.Options)) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.clientOptions = append(p.clientOptions, fn)
+	})
+}
+
+// WithJSONKey reads and writes the TOTP secret at a key of the JSON object that is stored in the secret.
+func WithJSONKey(key string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.jsonKey = key
+	})
+}
+
+// WithVersionID pins the version of the secret that is read. The writes always create a new version.
+func WithVersionID(id string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.versionID = id
+	})
+}
+
+// WithVersionStage pins the staging label of the version that is read, such as "AWSPREVIOUS". Default is "AWSCURRENT".
+func WithVersionStage(stage string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.versionStage = stage
+	})
+}
+
+// WithCacheTTL caches the TOTP secret for a period of time to reduce the number of api calls. Default is 0, no cache.
+func WithCacheTTL(ttl time.Duration) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.ttl = ttl
+	})
+}
+
+// WithForceDelete deletes the secret without a recovery window.
+func WithForceDelete() Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.forceDelete = true
+	})
+}
+
+// WithRecoveryWindow sets the number of days that the deleted secret can be restored. Default is 30 days.
+func WithRecoveryWindow(days int64) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.recoveryWindow = days
+	})
+}
+
+// WithClock sets the clock that is used for expiring the cache.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.clock = c
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.logger = otp.RedactLogger(l)
+	})
+}
@@ -0,0 +1,188 @@
+package awssecretsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/cloudsecret"
+)
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider is a TOTP secret provider that stores the TOTP secret in AWS Secrets Manager. The value of the
+// secret is either the TOTP secret, an otpauth URI, or a JSON object that has the TOTP secret at a key, see WithJSONKey.
+type TOTPSecretProvider struct {
+	secretID     string
+	jsonKey      string
+	versionID    string
+	versionStage string
+
+	config        *aws.Config
+	clientOptions []func(o *secretsmanager.Options)
+
+	mu     sync.Mutex
+	client api
+
+	forceDelete    bool
+	recoveryWindow int64
+
+	clock  clock.Clock
+	cache  *cloudsecret.Cache
+	ttl    time.Duration
+	logger ctxd.Logger
+}
+
+// TOTPSecret returns the TOTP secret from the pinned version of the secret, or the current version if it is not
+// pinned.
+func (p *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	if s, ok := p.cache.Get(); ok {
+		return s
+	}
+
+	value, err := p.getValue(ctx, p.versionID, p.versionStage)
+	if errors.Is(err, ErrResourceNotFound) {
+		return otp.NoTOTPSecret
+	}
+
+	if err != nil {
+		p.logger.Error(ctx, "could not get totp secret from aws secrets manager", "error", err, "name", p.secretID)
+
+		return otp.NoTOTPSecret
+	}
+
+	s, err := cloudsecret.Extract(value, p.jsonKey)
+	if err != nil {
+		p.logger.Error(ctx, "could not parse totp secret from aws secrets manager", "error", err, "name", p.secretID)
+
+		return otp.NoTOTPSecret
+	}
+
+	p.cache.Set(s)
+
+	return s
+}
+
+// SetTOTPSecret puts a new version of the secret, or creates the secret if it does not exist. With a JSON key, the
+// other keys of the current version are kept.
+func (p *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	if err := p.put(ctx, secret); err != nil {
+		p.logger.Error(ctx, "could not persist totp secret to aws secrets manager", "error", err, "name", p.secretID)
+
+		return fmt.Errorf("could not persist totp secret to aws secrets manager: %w", err)
+	}
+
+	return nil
+}
+
+func (p *TOTPSecretProvider) put(ctx context.Context, secret otp.TOTPSecret) error {
+	defer p.cache.Invalidate()
+
+	current, err := p.getValue(ctx, "", "")
+	notFound := errors.Is(err, ErrResourceNotFound)
+
+	if err != nil && !notFound {
+		return err
+	}
+
+	value, err := cloudsecret.Merge(current, p.jsonKey, secret)
+	if err != nil {
+		return err
+	}
+
+	if !notFound {
+		return p.putValue(ctx, value)
+	}
+
+	err = p.createSecret(ctx, value)
+	if !errors.Is(err, ErrResourceExists) {
+		return err
+	}
+
+	// The secret is created concurrently.
+	return p.putValue(ctx, value)
+}
+
+// DeleteTOTPSecret deletes the TOTP secret. With a JSON key, the key is removed in a new version of the secret.
+// Otherwise, the secret is scheduled for deletion, see WithForceDelete and WithRecoveryWindow.
+func (p *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	if err := p.delete(ctx); err != nil {
+		p.logger.Error(ctx, "could not delete totp secret in aws secrets manager", "error", err, "name", p.secretID)
+
+		return fmt.Errorf("could not delete totp secret in aws secrets manager: %w", err)
+	}
+
+	return nil
+}
+
+func (p *TOTPSecretProvider) delete(ctx context.Context) error {
+	defer p.cache.Invalidate()
+
+	if p.jsonKey != "" {
+		current, err := p.getValue(ctx, "", "")
+		if errors.Is(err, ErrResourceNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		value, err := cloudsecret.Merge(current, p.jsonKey, otp.NoTOTPSecret)
+		if err != nil {
+			return err
+		}
+
+		return p.putValue(ctx, value)
+	}
+
+	err := p.deleteSecret(ctx)
+	if errors.Is(err, ErrResourceNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (p *TOTPSecretProvider) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return p
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (p *TOTPSecretProvider) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (p *TOTPSecretProvider) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return p
+}
+
+// TOTPSecretFromSecretsManager returns a TOTP secret provider that stores the TOTP secret in the secret, identified by
+// its name or ARN. By default, the AWS config is loaded with config.LoadDefaultConfig on the first call, so that the
+// region and the credentials are resolved like the other AWS tools, from the environment, the shared config files, the
+// web identity or the instance role. The TOTP secret is not cached.
+func TOTPSecretFromSecretsManager(secretID string, opts ...Option) *TOTPSecretProvider {
+	p := &TOTPSecretProvider{
+		secretID: secretID,
+		clock:    clock.New(),
+		logger:   ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(p)
+	}
+
+	p.cache = cloudsecret.NewCache(p.clock, p.ttl)
+
+	return p
+}
@@ -0,0 +1,485 @@
+//go:build unit || !integration
+
+package awssecretsmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/awssecretsmanager"
+)
+
+type version struct {
+	id     string
+	value  string
+	stages []string
+}
+
+// secretsManager mimics the api of AWS Secrets Manager.
+type secretsManager struct {
+	mu       sync.Mutex
+	secrets  map[string][]*version
+	calls    map[string]int
+	deleted  map[string]map[string]any
+	failWith string
+}
+
+func newSecretsManager() *secretsManager {
+	return &secretsManager{
+		secrets: make(map[string][]*version),
+		calls:   make(map[string]int),
+		deleted: make(map[string]map[string]any),
+	}
+}
+
+func (m *secretsManager) put(id, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.putLocked(id, value)
+}
+
+func (m *secretsManager) putLocked(id, value string) {
+	versions := m.secrets[id]
+
+	for _, v := range versions {
+		for i, s := range v.stages {
+			if s == "AWSCURRENT" {
+				v.stages[i] = "AWSPREVIOUS"
+			} else if s == "AWSPREVIOUS" {
+				v.stages = append(v.stages[:i], v.stages[i+1:]...)
+			}
+		}
+	}
+
+	m.secrets[id] = append(versions, &version{
+		id:     "v" + strconv.Itoa(len(versions)+1),
+		value:  value,
+		stages: []string{"AWSCURRENT"},
+	})
+}
+
+func (m *secretsManager) current(id string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	versions := m.secrets[id]
+	if len(versions) == 0 {
+		return ""
+	}
+
+	return versions[len(versions)-1].value
+}
+
+func (m *secretsManager) count(action string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[action]
+}
+
+func writeError(w http.ResponseWriter, status int, typ, msg string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": typ, "message": msg}) //nolint: errcheck
+}
+
+func (m *secretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(auth, "/us-east-1/secretsmanager/aws4_request") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		writeError(w, http.StatusBadRequest, "IncompleteSignature", "invalid signature")
+
+		return
+	}
+
+	var in map[string]any
+
+	_ = json.NewDecoder(r.Body).Decode(&in) //nolint: errcheck
+
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "secretsmanager.")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[action]++
+
+	if m.failWith != "" {
+		writeError(w, http.StatusBadRequest, m.failWith, "failed")
+
+		return
+	}
+
+	switch action {
+	case "GetSecretValue":
+		m.getSecretValue(w, in)
+
+	case "PutSecretValue":
+		id, _ := in["SecretId"].(string) //nolint: errcheck
+		if _, ok := m.secrets[id]; !ok {
+			writeError(w, http.StatusBadRequest, "ResourceNotFoundException", "secret not found")
+
+			return
+		}
+
+		m.putLocked(id, in["SecretString"].(string)) //nolint: forcetypeassert
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{}`)) //nolint: errcheck
+
+	case "CreateSecret":
+		id, _ := in["Name"].(string) //nolint: errcheck
+		if _, ok := m.secrets[id]; ok {
+			writeError(w, http.StatusBadRequest, "com.amazonaws.secretsmanager#ResourceExistsException", "secret exists")
+
+			return
+		}
+
+		m.putLocked(id, in["SecretString"].(string)) //nolint: forcetypeassert
+		_, _ = w.Write([]byte(`{}`))                 //nolint: errcheck
+
+	case "DeleteSecret":
+		id, _ := in["SecretId"].(string) //nolint: errcheck
+		if _, ok := m.secrets[id]; !ok {
+			writeError(w, http.StatusBadRequest, "ResourceNotFoundException", "secret not found")
+
+			return
+		}
+
+		delete(m.secrets, id)
+		m.deleted[id] = in
+		_, _ = w.Write([]byte(`{}`)) //nolint: errcheck
+
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", "unknown action")
+	}
+}
+
+func (m *secretsManager) getSecretValue(w http.ResponseWriter, in map[string]any) {
+	id, _ := in["SecretId"].(string)         //nolint: errcheck
+	versionID, _ := in["VersionId"].(string) //nolint: errcheck
+	stage, _ := in["VersionStage"].(string)  //nolint: errcheck
+
+	if versionID == "" && stage == "" {
+		stage = "AWSCURRENT"
+	}
+
+	for _, v := range m.secrets[id] {
+		if versionID != "" && v.id != versionID {
+			continue
+		}
+
+		if stage != "" && !contains(v.stages, stage) {
+			continue
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"SecretString": v.value, "VersionId": v.id}) //nolint: errcheck
+
+		return
+	}
+
+	writeError(w, http.StatusBadRequest, "ResourceNotFoundException", "secret not found")
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newProvider(t *testing.T, m *secretsManager, secretID string, opts ...awssecretsmanager.Option) *awssecretsmanager.TOTPSecretProvider {
+	t.Helper()
+
+	srv := httptest.NewServer(m)
+
+	t.Cleanup(srv.Close)
+
+	opts = append([]awssecretsmanager.Option{
+		awssecretsmanager.WithEndpoint(srv.URL),
+		awssecretsmanager.WithRegion("us-east-1"),
+		awssecretsmanager.WithCredentials(credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")),
+		awssecretsmanager.WithClock(clock.Fix(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))),
+	}, opts...)
+
+	return awssecretsmanager.TOTPSecretFromSecretsManager(secretID, opts...)
+}
+
+func TestTOTPSecretProvider_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretsManager()
+	m.put("plain", "NBSWY3DP")
+	m.put("uri", "otpauth://totp/Example:john?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	m.put("json", `{"username":"john","totp":"NBSWY3DP"}`)
+	m.put("rotated", "NBSWY3DP")
+	m.put("rotated", "JBSWY3DPEHPK3PXP")
+	m.put("invalid", `not json`)
+
+	testCases := []struct {
+		scenario string
+		secretID string
+		options  []awssecretsmanager.Option
+		expected otp.TOTPSecret
+	}{
+		{
+			scenario: "plain",
+			secretID: "plain",
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "otpauth uri",
+			secretID: "uri",
+			expected: "otpauth://totp/Example:john?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		},
+		{
+			scenario: "json key",
+			secretID: "json",
+			options:  []awssecretsmanager.Option{awssecretsmanager.WithJSONKey("totp")},
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "missing json key",
+			secretID: "json",
+			options:  []awssecretsmanager.Option{awssecretsmanager.WithJSONKey("otp")},
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "not a json object",
+			secretID: "invalid",
+			options:  []awssecretsmanager.Option{awssecretsmanager.WithJSONKey("totp")},
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "current version",
+			secretID: "rotated",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "pinned version id",
+			secretID: "rotated",
+			options:  []awssecretsmanager.Option{awssecretsmanager.WithVersionID("v1")},
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "pinned version stage",
+			secretID: "rotated",
+			options:  []awssecretsmanager.Option{awssecretsmanager.WithVersionStage("AWSPREVIOUS")},
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "not found",
+			secretID: "unknown",
+			expected: otp.NoTOTPSecret,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			p := newProvider(t, m, tc.secretID, tc.options...)
+
+			assert.Equal(t, tc.expected, p.TOTPSecret(context.Background()))
+		})
+	}
+}
+
+func TestTOTPSecretProvider_TOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretsManager()
+	m.failWith = "AccessDeniedException"
+
+	l := &ctxd.LoggerMock{}
+	p := newProvider(t, m, "plain", awssecretsmanager.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "could not get totp secret from aws secrets manager", l.LoggedEntries[0].Message)
+	assert.Equal(t, "plain", l.LoggedEntries[0].Data["name"])
+
+	var apiErr smithy.APIError
+
+	require.ErrorAs(t, l.LoggedEntries[0].Data["error"].(error), &apiErr) //nolint: forcetypeassert
+	assert.Equal(t, "AccessDeniedException", apiErr.ErrorCode())
+}
+
+func TestTOTPSecretProvider_TOTPSecret_CredentialsError(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	p := newProvider(t, newSecretsManager(), "plain",
+		awssecretsmanager.WithCredentials(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, assert.AnError
+		})),
+		awssecretsmanager.WithLogger(l),
+	)
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	require.ErrorIs(t, l.LoggedEntries[0].Data["error"].(error), assert.AnError) //nolint: forcetypeassert
+}
+
+func TestTOTPSecretProvider_DefaultConfig(t *testing.T) { //nolint: paralleltest
+	dir := t.TempDir()
+
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	m := newSecretsManager()
+	m.put("plain", "NBSWY3DP")
+
+	srv := httptest.NewServer(m)
+
+	t.Cleanup(srv.Close)
+
+	// The region and the credentials are loaded from the environment.
+	p := awssecretsmanager.TOTPSecretFromSecretsManager("plain", awssecretsmanager.WithEndpoint(srv.URL))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+}
+
+func TestTOTPSecretProvider_Cache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretsManager()
+	m.put("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain", awssecretsmanager.WithCacheTTL(time.Minute))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+	assert.Equal(t, 1, m.count("GetSecretValue"))
+
+	// The cache is invalidated on write.
+	require.NoError(t, p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+	assert.Equal(t, 3, m.count("GetSecretValue"))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretsManager()
+
+	p := newProvider(t, m, "plain")
+
+	// The secret is created.
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	assert.Equal(t, "NBSWY3DP", m.current("plain"))
+	assert.Equal(t, 1, m.count("CreateSecret"))
+
+	// A new version is put.
+	require.NoError(t, p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", m.current("plain"))
+	assert.Equal(t, 1, m.count("PutSecretValue"))
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_JSONKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretsManager()
+	m.put("json", `{"username":"john"}`)
+
+	p := newProvider(t, m, "json", awssecretsmanager.WithJSONKey("totp"))
+
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	assert.JSONEq(t, `{"username":"john","totp":"NBSWY3DP"}`, m.current("json"))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	assert.JSONEq(t, `{"username":"john"}`, m.current("json"))
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+	assert.Equal(t, 0, m.count("DeleteSecret"))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretsManager()
+	m.failWith = "AccessDeniedException"
+
+	p := newProvider(t, m, "plain")
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	require.ErrorContains(t, err, "could not persist totp secret to aws secrets manager: ")
+	require.ErrorContains(t, err, "api error AccessDeniedException: failed")
+
+	var respErr *smithyhttp.ResponseError
+
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, http.StatusBadRequest, respErr.HTTPStatusCode())
+}
+
+func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretsManager()
+	m.put("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain", awssecretsmanager.WithRecoveryWindow(7))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+	assert.Equal(t, map[string]any{"SecretId": "plain", "RecoveryWindowInDays": float64(7)}, m.deleted["plain"])
+
+	// Deleting a missing secret is not an error.
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+}
+
+func TestTOTPSecretProvider_ChainTOTPSecretProviders(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretsManager()
+	m.put("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain")
+	c := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret, p)
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), c.TOTPSecret(context.Background()))
+}
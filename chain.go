@@ -0,0 +1,388 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bool64/ctxd"
+)
+
+// ErrTOTPSecretConflict indicates that the members of a chain do not agree on the TOTP secret.
+var ErrTOTPSecretConflict = errors.New("totp secret conflict")
+
+// ProviderRole is the role of a member in a ProviderChain.
+type ProviderRole int
+
+const (
+	// RoleReadWrite members are read from and written to.
+	RoleReadWrite ProviderRole = iota
+	// RoleReadOnly members are only read from.
+	RoleReadOnly
+	// RoleWriteOnly members are only written to.
+	RoleWriteOnly
+)
+
+// ReadStrategy decides how a ProviderChain resolves the TOTP secret from its readable members.
+type ReadStrategy int
+
+const (
+	// ReadFirstNonEmpty returns the first non-empty TOTP secret.
+	ReadFirstNonEmpty ReadStrategy = iota
+	// ReadRequireAgreement returns the TOTP secret only if all the non-empty ones are the same.
+	ReadRequireAgreement
+	// ReadMajority returns the TOTP secret that more than half of the readable members agree on.
+	ReadMajority
+)
+
+// WriteStrategy decides which writable members of a ProviderChain are changed.
+type WriteStrategy int
+
+const (
+	// WriteAll changes all the writable members in order, and stops at the first error.
+	WriteAll WriteStrategy = iota
+	// WriteFirst changes only the first writable member.
+	WriteFirst
+	// WritePrimaryAsyncReplicas changes the first writable member, then the others in the background. The changes of a
+	// replica are applied one at a time, in the order of the writes.
+	WritePrimaryAsyncReplicas
+)
+
+type chainMember struct {
+	role    ProviderRole
+	getter  TOTPSecretGetter
+	setter  TOTPSecretSetter
+	deleter TOTPSecretDeleter
+}
+
+func (m chainMember) readable() bool {
+	return m.role != RoleWriteOnly && m.getter != nil
+}
+
+func (m chainMember) writable() bool {
+	return m.role != RoleReadOnly && m.setter != nil
+}
+
+var _ TOTPSecretProvider = (*ProviderChain)(nil)
+
+// ProviderChain is a TOTP secret provider that combines other providers with a role per member, a read strategy and
+// a write strategy. For example, to read from the environment first then the keyring, but only write to the keyring:
+//
+//	otp.NewProviderChain().
+//		ReadOnly(otp.TOTPSecretFromEnv("OTP_SECRET")).
+//		ReadWrite(keyring.TOTPSecretFromKeyring("john.doe@example.com"))
+type ProviderChain struct {
+	members       []chainMember
+	readStrategy  ReadStrategy
+	writeStrategy WriteStrategy
+	onReplicaErr  func(ctx context.Context, index int, err error)
+	logger        ctxd.Logger
+
+	replicas sync.WaitGroup
+	mu       sync.Mutex
+	queues   map[int]*replicaQueue
+}
+
+// replicaQueue changes a replica in the background, one change at a time and in the order of the writes.
+type replicaQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	running bool
+}
+
+// push adds a change to the queue, and starts a worker if there is none.
+func (q *replicaQueue) push(fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending = append(q.pending, fn)
+
+	if q.running {
+		return
+	}
+
+	q.running = true
+
+	go q.run()
+}
+
+func (q *replicaQueue) run() {
+	for {
+		q.mu.Lock()
+
+		if len(q.pending) == 0 {
+			q.running = false
+			q.mu.Unlock()
+
+			return
+		}
+
+		fn := q.pending[0]
+		q.pending = q.pending[1:]
+
+		q.mu.Unlock()
+
+		fn()
+	}
+}
+
+// ReadOnly adds a member that is only read from.
+func (c *ProviderChain) ReadOnly(g TOTPSecretGetter) *ProviderChain {
+	c.members = append(c.members, chainMember{role: RoleReadOnly, getter: g})
+
+	return c
+}
+
+// WriteOnly adds a member that is only written to. The TOTP secret is deleted only if the member is also a
+// TOTPSecretDeleter.
+func (c *ProviderChain) WriteOnly(s TOTPSecretSetter) *ProviderChain {
+	d, _ := s.(TOTPSecretDeleter) //nolint: errcheck
+
+	c.members = append(c.members, chainMember{role: RoleWriteOnly, setter: s, deleter: d})
+
+	return c
+}
+
+// ReadWrite adds a member that is read from and written to.
+func (c *ProviderChain) ReadWrite(p TOTPSecretProvider) *ProviderChain {
+	c.members = append(c.members, chainMember{role: RoleReadWrite, getter: p, setter: p, deleter: p})
+
+	return c
+}
+
+// Member adds a provider with the given role.
+func (c *ProviderChain) Member(p TOTPSecretProvider, role ProviderRole) *ProviderChain {
+	c.members = append(c.members, chainMember{role: role, getter: p, setter: p, deleter: p})
+
+	return c
+}
+
+// WithReadStrategy sets the read strategy. Default is ReadFirstNonEmpty.
+func (c *ProviderChain) WithReadStrategy(s ReadStrategy) *ProviderChain {
+	c.readStrategy = s
+
+	return c
+}
+
+// WithWriteStrategy sets the write strategy. Default is WriteAll.
+func (c *ProviderChain) WithWriteStrategy(s WriteStrategy) *ProviderChain {
+	c.writeStrategy = s
+
+	return c
+}
+
+// OnReplicaError sets the handler of the errors of the replicas when the write strategy is WritePrimaryAsyncReplicas.
+// The index is the position of the member in the chain.
+func (c *ProviderChain) OnReplicaError(fn func(ctx context.Context, index int, err error)) *ProviderChain {
+	c.onReplicaErr = fn
+
+	return c
+}
+
+// WithLogger sets the logger of the chain. The chain logs which member supplied the TOTP secret, the conflicts and
+// the write errors. The TOTP secrets are redacted from the logs.
+func (c *ProviderChain) WithLogger(l ctxd.Logger) *ProviderChain {
+	c.logger = RedactLogger(l)
+
+	return c
+}
+
+// Wait waits for the replicas to be changed when the write strategy is WritePrimaryAsyncReplicas.
+func (c *ProviderChain) Wait() {
+	c.replicas.Wait()
+}
+
+// TOTPSecret returns the TOTP secret according to the read strategy. It returns NoTOTPSecret if the members do not
+// agree, use ResolveTOTPSecret to get the error.
+func (c *ProviderChain) TOTPSecret(ctx context.Context) TOTPSecret {
+	s, _ := c.ResolveTOTPSecret(ctx) //nolint: errcheck
+
+	return s
+}
+
+// ResolveTOTPSecret returns the TOTP secret according to the read strategy. It returns ErrTOTPSecretConflict if the
+// members do not agree, or ErrNoTOTPSecret if there is no TOTP secret.
+func (c *ProviderChain) ResolveTOTPSecret(ctx context.Context) (TOTPSecret, error) {
+	if c.readStrategy == ReadFirstNonEmpty {
+		for i, m := range c.members {
+			if !m.readable() {
+				continue
+			}
+
+			if s := m.getter.TOTPSecret(ctx); s != NoTOTPSecret {
+				c.logger.Debug(ctx, "found totp secret", "provider", providerName(i, m.getter))
+
+				return s, nil
+			}
+		}
+
+		c.logger.Debug(ctx, "no totp secret found")
+
+		return NoTOTPSecret, ErrNoTOTPSecret
+	}
+
+	var (
+		total     int
+		order     []TOTPSecret
+		counts    = make(map[TOTPSecret]int)
+		suppliers = make(map[TOTPSecret][]string)
+	)
+
+	for i, m := range c.members {
+		if !m.readable() {
+			continue
+		}
+
+		total++
+
+		s := m.getter.TOTPSecret(ctx)
+		if s == NoTOTPSecret {
+			continue
+		}
+
+		if counts[s] == 0 {
+			order = append(order, s)
+		}
+
+		counts[s]++
+		suppliers[s] = append(suppliers[s], providerName(i, m.getter))
+	}
+
+	if len(order) == 0 {
+		c.logger.Debug(ctx, "no totp secret found")
+
+		return NoTOTPSecret, ErrNoTOTPSecret
+	}
+
+	switch c.readStrategy {
+	case ReadRequireAgreement:
+		if len(order) == 1 {
+			c.logger.Debug(ctx, "found totp secret", "providers", suppliers[order[0]])
+
+			return order[0], nil
+		}
+
+	case ReadMajority:
+		for _, s := range order {
+			if counts[s]*2 > total {
+				c.logger.Debug(ctx, "found totp secret", "providers", suppliers[s])
+
+				return s, nil
+			}
+		}
+	}
+
+	c.logger.Warn(ctx, "members do not agree on totp secret", "candidates", len(order), "providers", total)
+
+	return NoTOTPSecret, ErrTOTPSecretConflict
+}
+
+// SetTOTPSecret sets the TOTP secret according to the write strategy.
+func (c *ProviderChain) SetTOTPSecret(ctx context.Context, secret TOTPSecret, issuer string) error {
+	return c.write(ctx, func(ctx context.Context, m chainMember) error {
+		return m.setter.SetTOTPSecret(ctx, secret, issuer)
+	})
+}
+
+// DeleteTOTPSecret deletes the TOTP secret according to the write strategy.
+func (c *ProviderChain) DeleteTOTPSecret(ctx context.Context) error {
+	return c.write(ctx, func(ctx context.Context, m chainMember) error {
+		if m.deleter == nil {
+			return nil
+		}
+
+		return m.deleter.DeleteTOTPSecret(ctx)
+	})
+}
+
+func (c *ProviderChain) write(ctx context.Context, change func(ctx context.Context, m chainMember) error) error {
+	primary := true
+
+	for i, m := range c.members {
+		if !m.writable() {
+			continue
+		}
+
+		switch {
+		case primary || c.writeStrategy == WriteAll:
+			if err := change(ctx, m); err != nil {
+				c.logger.Error(ctx, "could not change totp secret", "error", err, "provider", providerName(i, m.setter))
+
+				return fmt.Errorf("provider #%d: %w", i, err)
+			}
+
+		case c.writeStrategy == WritePrimaryAsyncReplicas:
+			c.replicate(ctx, i, m, change)
+
+		default:
+			return nil
+		}
+
+		primary = false
+	}
+
+	return nil
+}
+
+func (c *ProviderChain) replicate(ctx context.Context, index int, m chainMember, change func(ctx context.Context, m chainMember) error) {
+	ctx = context.WithoutCancel(ctx)
+
+	c.replicas.Add(1)
+
+	c.replicaQueue(index).push(func() {
+		defer c.replicas.Done()
+
+		err := change(ctx, m)
+		if err == nil {
+			return
+		}
+
+		c.logger.Error(ctx, "could not change totp secret of replica", "error", err, "provider", providerName(index, m.setter))
+
+		if c.onReplicaErr != nil {
+			c.onReplicaErr(ctx, index, err)
+		}
+	})
+}
+
+// replicaQueue returns the queue of the replica at the given index, so that its changes are applied in order.
+func (c *ProviderChain) replicaQueue(index int) *replicaQueue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.queues == nil {
+		c.queues = make(map[int]*replicaQueue)
+	}
+
+	q, ok := c.queues[index]
+	if !ok {
+		q = &replicaQueue{}
+		c.queues[index] = q
+	}
+
+	return q
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (c *ProviderChain) TOTPSecretGetter() TOTPSecretGetter {
+	return c
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (c *ProviderChain) TOTPSecretSetter() TOTPSecretSetter {
+	return c
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (c *ProviderChain) TOTPSecretDeleter() TOTPSecretDeleter {
+	return c
+}
+
+// NewProviderChain initiates a new empty ProviderChain that reads the first non-empty TOTP secret and writes to all
+// the writable members.
+func NewProviderChain() *ProviderChain {
+	return &ProviderChain{
+		logger: ctxd.NoOpLogger{},
+	}
+}
@@ -0,0 +1,291 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+func TestProviderChain_Roles(t *testing.T) { //nolint: paralleltest
+	t.Setenv(t.Name(), "NBSWY3DP")
+
+	ctx := context.Background()
+	env := otp.TOTPSecretFromEnv(t.Name())
+	store := otp.ChainTOTPSecretProviders(otp.TOTPSecret("JBSWY3DPEHPK3PXP"))
+	sink := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	c := otp.NewProviderChain().
+		ReadOnly(env).
+		ReadWrite(store).
+		WriteOnly(sink)
+
+	assert.Equal(t, c, c.TOTPSecretGetter())
+	assert.Equal(t, c, c.TOTPSecretSetter())
+	assert.Equal(t, c, c.TOTPSecretDeleter())
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), c.TOTPSecret(ctx))
+
+	require.NoError(t, c.SetTOTPSecret(ctx, "changed", ""))
+
+	// The read-only member is not changed.
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), env.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), store.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), sink.TOTPSecret(ctx))
+
+	// The write-only member is not read.
+	require.NoError(t, env.DeleteTOTPSecret(ctx))
+	require.NoError(t, c.DeleteTOTPSecret(ctx))
+
+	assert.Equal(t, otp.NoTOTPSecret, store.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, sink.TOTPSecret(ctx))
+
+	require.NoError(t, sink.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	actual, err := c.ResolveTOTPSecret(ctx)
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+	assert.Equal(t, otp.NoTOTPSecret, actual)
+}
+
+func TestProviderChain_ReadStrategy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		strategy       otp.ReadStrategy
+		secrets        []otp.TOTPSecret
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "first non empty",
+			strategy:       otp.ReadFirstNonEmpty,
+			secrets:        []otp.TOTPSecret{"", "a", "b"},
+			expectedResult: "a",
+		},
+		{
+			scenario:      "first non empty with no secret",
+			strategy:      otp.ReadFirstNonEmpty,
+			secrets:       []otp.TOTPSecret{"", ""},
+			expectedError: otp.ErrNoTOTPSecret,
+		},
+		{
+			scenario:       "require agreement",
+			strategy:       otp.ReadRequireAgreement,
+			secrets:        []otp.TOTPSecret{"", "a", "a"},
+			expectedResult: "a",
+		},
+		{
+			scenario:      "require agreement with conflict",
+			strategy:      otp.ReadRequireAgreement,
+			secrets:       []otp.TOTPSecret{"a", "a", "b"},
+			expectedError: otp.ErrTOTPSecretConflict,
+		},
+		{
+			scenario:      "require agreement with no secret",
+			strategy:      otp.ReadRequireAgreement,
+			secrets:       []otp.TOTPSecret{""},
+			expectedError: otp.ErrNoTOTPSecret,
+		},
+		{
+			scenario:       "majority",
+			strategy:       otp.ReadMajority,
+			secrets:        []otp.TOTPSecret{"b", "a", "a"},
+			expectedResult: "a",
+		},
+		{
+			scenario:      "no majority",
+			strategy:      otp.ReadMajority,
+			secrets:       []otp.TOTPSecret{"a", "b", "", ""},
+			expectedError: otp.ErrTOTPSecretConflict,
+		},
+		{
+			scenario:      "half is not majority",
+			strategy:      otp.ReadMajority,
+			secrets:       []otp.TOTPSecret{"a", "a", "b", ""},
+			expectedError: otp.ErrTOTPSecretConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := otp.NewProviderChain().WithReadStrategy(tc.strategy)
+
+			for _, s := range tc.secrets {
+				c.ReadOnly(s)
+			}
+
+			// Write-only members do not vote.
+			c.Member(otp.ChainTOTPSecretProviders(otp.TOTPSecret("c")), otp.RoleWriteOnly)
+
+			actual, err := c.ResolveTOTPSecret(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.expectedResult, c.TOTPSecret(context.Background()))
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestProviderChain_WriteFirst(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	first := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	second := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	c := otp.NewProviderChain().
+		ReadOnly(otp.NoTOTPSecret).
+		ReadWrite(first).
+		Member(second, otp.RoleReadWrite).
+		WithWriteStrategy(otp.WriteFirst)
+
+	require.NoError(t, c.SetTOTPSecret(ctx, "changed", ""))
+
+	assert.Equal(t, otp.TOTPSecret("changed"), first.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, second.TOTPSecret(ctx))
+}
+
+func TestProviderChain_WriteError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	last := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	c := otp.NewProviderChain().
+		ReadWrite(otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)).
+		WriteOnly(mock.MockTOTPSecretSetter(func(s *mock.TOTPSecretSetter) {
+			s.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "").Return(assert.AnError)
+		})(t)).
+		ReadWrite(last)
+
+	err := c.SetTOTPSecret(ctx, "changed", "")
+	require.EqualError(t, err, "provider #1: assert.AnError general error for testing")
+
+	assert.Equal(t, otp.NoTOTPSecret, last.TOTPSecret(ctx))
+
+	// The setter is not a deleter.
+	require.NoError(t, c.DeleteTOTPSecret(ctx))
+}
+
+func TestProviderChain_WritePrimaryAsyncReplicas(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	primary := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	replica := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	var (
+		mu          sync.Mutex
+		replicaErrs = make(map[int]error)
+	)
+
+	failing := mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("SetTOTPSecret", mock.Anything, otp.TOTPSecret("changed"), "issuer").
+			Return(func(ctx context.Context, _ otp.TOTPSecret, _ string) error {
+				// The replicas are not canceled with the request.
+				return ctx.Err()
+			})
+
+		p.On("DeleteTOTPSecret", mock.Anything).Return(assert.AnError)
+	})(t)
+
+	c := otp.NewProviderChain().
+		ReadWrite(primary).
+		ReadWrite(failing).
+		ReadWrite(replica).
+		WithWriteStrategy(otp.WritePrimaryAsyncReplicas).
+		OnReplicaError(func(_ context.Context, index int, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			replicaErrs[index] = err
+		})
+
+	require.NoError(t, c.SetTOTPSecret(ctx, "changed", "issuer"))
+
+	cancel()
+	c.Wait()
+
+	assert.Equal(t, otp.TOTPSecret("changed"), primary.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), replica.TOTPSecret(ctx))
+	assert.Empty(t, replicaErrs)
+
+	require.NoError(t, c.DeleteTOTPSecret(ctx))
+
+	c.Wait()
+
+	assert.Equal(t, otp.NoTOTPSecret, primary.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, replica.TOTPSecret(ctx))
+	assert.Equal(t, map[int]error{1: assert.AnError}, replicaErrs)
+}
+
+// slowTOTPSecretProvider is a TOTP secret provider that takes longer to change the first TOTP secret, so that the
+// changes would be reordered if they were not applied one at a time.
+type slowTOTPSecretProvider struct {
+	otp.TOTPSecretProvider
+
+	mu      sync.Mutex
+	changes []otp.TOTPSecret
+}
+
+func (p *slowTOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, issuer string) error {
+	if secret == "first" {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.changes = append(p.changes, secret)
+
+	return p.TOTPSecretProvider.SetTOTPSecret(ctx, secret, issuer)
+}
+
+func (p *slowTOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.changes = append(p.changes, otp.NoTOTPSecret)
+
+	return p.TOTPSecretProvider.DeleteTOTPSecret(ctx)
+}
+
+func TestProviderChain_WritePrimaryAsyncReplicas_Order(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	primary := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	replica := &slowTOTPSecretProvider{TOTPSecretProvider: otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)}
+
+	c := otp.NewProviderChain().
+		ReadWrite(primary).
+		ReadWrite(replica).
+		WithWriteStrategy(otp.WritePrimaryAsyncReplicas)
+
+	require.NoError(t, c.SetTOTPSecret(ctx, "first", ""))
+	require.NoError(t, c.DeleteTOTPSecret(ctx))
+	require.NoError(t, c.SetTOTPSecret(ctx, "second", ""))
+
+	c.Wait()
+
+	assert.Equal(t, []otp.TOTPSecret{"first", otp.NoTOTPSecret, "second"}, replica.changes)
+	assert.Equal(t, otp.TOTPSecret("second"), replica.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("second"), primary.TOTPSecret(ctx))
+}
@@ -0,0 +1,2 @@
+// Package configfile provides totp secret storage in JSON, YAML and TOML configuration files.
+package configfile
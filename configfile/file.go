@@ -0,0 +1,266 @@
+package configfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/atomicfile"
+)
+
+// Format is the format of a configuration file.
+type Format string
+
+// The formats of the configuration files.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// ErrUnsupportedFormat indicates that the format of the configuration file is not supported.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// ErrKeyNotFound indicates that the key path does not exist in the configuration file.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrInvalidKeyPath indicates that the key path does not lead to a string, or goes through a value that is not a map.
+var ErrInvalidKeyPath = errors.New("invalid key path")
+
+// codec reads and edits the documents of a format. The edits keep the rest of the document as it is.
+type codec interface {
+	// get returns the string at the key path.
+	get(data []byte, path []string) (string, error)
+	// set returns the document with the string at the key path. The maps on the way are created if they do not exist.
+	set(data []byte, path []string, value string) ([]byte, error)
+	// delete returns the document without the key.
+	delete(data []byte, path []string) ([]byte, error)
+}
+
+var codecs = map[Format]codec{
+	FormatJSON: jsonCodec{},
+	FormatYAML: yamlCodec{},
+	FormatTOML: tomlCodec{},
+}
+
+var _ otp.TOTPSecretProvider = (*File)(nil)
+
+// File is a TOTP secret provider that stores the TOTP secret, or an otpauth URI, in a configuration file, at a dotted
+// key path such as "vendors.acme.totp". On write, only the value is changed, the comments and the formatting of the
+// rest of the file are kept where the format allows.
+type File struct {
+	config
+
+	path    string
+	keyPath []string
+
+	mu sync.Mutex
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// KeyPath returns the key path of the TOTP secret in the file.
+func (f *File) KeyPath() string {
+	return strings.Join(f.keyPath, ".")
+}
+
+// TOTPSecret returns the TOTP secret from the file. It returns otp.NoTOTPSecret if the file or the key does not exist,
+// or if the file could not be read, see Load for the error.
+func (f *File) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := f.Load(ctx)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, ErrKeyNotFound):
+		f.logger.Debug(ctx, "totp secret does not exist in config file", "path", f.path, "key", f.KeyPath())
+
+	case err != nil:
+		f.logger.Error(ctx, "could not get totp secret from config file", "error", err, "path", f.path, "key", f.KeyPath())
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret at the key path. It returns ErrKeyNotFound if the key does not exist, or an error that
+// wraps os.ErrNotExist if the file does not exist.
+func (f *File) Load(_ context.Context) (otp.TOTPSecret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.codec()
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	value, err := c.get(data, f.keyPath)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	return otp.ParseTOTPSecret(value)
+}
+
+// SetTOTPSecret writes the TOTP secret at the key path. The file and the maps on the way are created if they do not
+// exist.
+func (f *File) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	err := f.update(true, func(c codec, data []byte) ([]byte, error) {
+		out, err := c.set(data, f.keyPath, secret.Reveal())
+		if err != nil {
+			return nil, err
+		}
+
+		// Make sure that the edit is what is expected, before writing the file.
+		if v, err := c.get(out, f.keyPath); err != nil || v != secret.Reveal() {
+			return nil, fmt.Errorf("could not verify the change: %w", errors.Join(ErrInvalidKeyPath, err))
+		}
+
+		return out, nil
+	})
+	if err != nil {
+		f.logger.Error(ctx, "could not persist totp secret to config file", "error", err, "path", f.path, "key", f.KeyPath())
+
+		return fmt.Errorf("could not persist totp secret to config file: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret removes the key from the file. It does nothing if the file or the key does not exist.
+func (f *File) DeleteTOTPSecret(ctx context.Context) error {
+	err := f.update(false, func(c codec, data []byte) ([]byte, error) {
+		out, err := c.delete(data, f.keyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := c.get(out, f.keyPath); !errors.Is(err, ErrKeyNotFound) {
+			return nil, fmt.Errorf("could not verify the change: %w", errors.Join(ErrInvalidKeyPath, err))
+		}
+
+		return out, nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrKeyNotFound) {
+		f.logger.Error(ctx, "could not delete totp secret in config file", "error", err, "path", f.path, "key", f.KeyPath())
+
+		return fmt.Errorf("could not delete totp secret in config file: %w", err)
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (f *File) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return f
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (f *File) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return f
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (f *File) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return f
+}
+
+func (f *File) update(create bool, fn func(c codec, data []byte) ([]byte, error)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.codec()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil && (!create || !errors.Is(err, os.ErrNotExist)) {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	out, err := fn(c, data)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(f.path, out, f.permissions)
+}
+
+func (f *File) codec() (codec, error) {
+	format := f.format
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(f.path)) {
+		case ".json":
+			format = FormatJSON
+
+		case ".yaml", ".yml":
+			format = FormatYAML
+
+		case ".toml":
+			format = FormatTOML
+		}
+	}
+
+	c, ok := codecs[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, f.path)
+	}
+
+	return c, nil
+}
+
+// TOTPSecretFromFile returns a TOTP secret provider that stores the TOTP secret in a configuration file, at a dotted key
+// path such as "vendors.acme.totp". The format is detected from the extension of the file, see WithFormat.
+func TOTPSecretFromFile(path, keyPath string, opts ...Option) *File {
+	return &File{
+		config:  newConfig(opts...),
+		path:    path,
+		keyPath: strings.Split(keyPath, "."),
+	}
+}
+
+// lookup returns the string at the key path of a decoded document.
+func lookup(v any, path []string) (string, error) {
+	for _, k := range path {
+		var (
+			next any
+			ok   bool
+		)
+
+		switch m := v.(type) {
+		case map[string]any:
+			next, ok = m[k]
+
+		case map[any]any:
+			next, ok = m[k]
+
+		default:
+			return "", ErrInvalidKeyPath
+		}
+
+		if !ok {
+			return "", ErrKeyNotFound
+		}
+
+		v = next
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", ErrInvalidKeyPath
+	}
+
+	return s, nil
+}
@@ -0,0 +1,706 @@
+//go:build unit || !integration
+
+package configfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/configfile"
+)
+
+const jsonConfig = `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "NBSWY3DP"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"}
+    },
+    "port": 8080
+}
+`
+
+const yamlConfig = `# Tool configuration.
+name: tool
+vendors:
+  acme:
+    user: john
+    # The TOTP secret.
+    totp: "NBSWY3DP" # quoted
+  globex:
+    uri: otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex
+
+port: 8080
+`
+
+const tomlConfig = `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`
+
+var configs = map[string]string{
+	"config.json": jsonConfig,
+	"config.yaml": yamlConfig,
+	"config.toml": tomlConfig,
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o640))
+	require.NoError(t, os.Chmod(path, 0o640))
+
+	return path
+}
+
+func readConfig(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestFile_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		keyPath        string
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "secret",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			keyPath:        "vendors.globex.uri",
+			expectedResult: "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex",
+		},
+		{
+			scenario:       "key not found",
+			keyPath:        "vendors.initech.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  configfile.ErrKeyNotFound,
+		},
+		{
+			scenario:       "key path to a map",
+			keyPath:        "vendors.acme",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  configfile.ErrInvalidKeyPath,
+		},
+		{
+			scenario:       "key path to a number",
+			keyPath:        "port",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  configfile.ErrInvalidKeyPath,
+		},
+		{
+			scenario:       "key path through a string",
+			keyPath:        "name.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  configfile.ErrInvalidKeyPath,
+		},
+	}
+
+	for name, content := range configs {
+		path := writeConfig(t, name, content)
+
+		for _, tc := range testCases {
+			tc := tc
+
+			t.Run(name+"/"+tc.scenario, func(t *testing.T) {
+				t.Parallel()
+
+				f := configfile.TOTPSecretFromFile(path, tc.keyPath)
+
+				assert.Equal(t, path, f.Path())
+				assert.Equal(t, tc.keyPath, f.KeyPath())
+				assert.Equal(t, tc.expectedResult, f.TOTPSecret(context.Background()))
+
+				actual, err := f.Load(context.Background())
+
+				assert.Equal(t, tc.expectedResult, actual)
+
+				if tc.expectedError == nil {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, tc.expectedError)
+				}
+			})
+		}
+	}
+}
+
+func TestFile_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := configfile.TOTPSecretFromFile(filepath.Join(t.TempDir(), "config.json"), "totp").Load(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = configfile.TOTPSecretFromFile(writeConfig(t, "config.ini", "totp = NBSWY3DP"), "totp").Load(context.Background())
+	require.ErrorIs(t, err, configfile.ErrUnsupportedFormat)
+
+	actual, err := configfile.TOTPSecretFromFile(writeConfig(t, "config", `totp = "NBSWY3DP"`), "totp", configfile.WithFormat(configfile.FormatTOML)).Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), actual)
+
+	for name, content := range map[string]string{
+		"config.json": `{"totp": }`,
+		"config.yaml": "totp: [",
+		"config.toml": "totp = ",
+	} {
+		f := configfile.TOTPSecretFromFile(writeConfig(t, name, content), "totp")
+
+		_, err = f.Load(context.Background())
+		require.Error(t, err)
+		require.Error(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"))
+		require.Error(t, f.DeleteTOTPSecret(context.Background()))
+	}
+}
+
+func TestFile_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		file     string
+		keyPath  string
+		expected string
+	}{
+		{
+			scenario: "json",
+			file:     "config.json",
+			keyPath:  "vendors.acme.totp",
+			expected: `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "JBSWY3DPEHPK3PXP"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"}
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "json new key",
+			file:     "config.json",
+			keyPath:  "vendors.globex.totp",
+			expected: `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "NBSWY3DP"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex", "totp": "JBSWY3DPEHPK3PXP"}
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "json new map",
+			file:     "config.json",
+			keyPath:  "vendors.initech.totp",
+			expected: `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "NBSWY3DP"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"},
+        "initech": {
+            "totp": "JBSWY3DPEHPK3PXP"
+        }
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "yaml",
+			file:     "config.yaml",
+			keyPath:  "vendors.acme.totp",
+			expected: `# Tool configuration.
+name: tool
+vendors:
+  acme:
+    user: john
+    # The TOTP secret.
+    totp: "JBSWY3DPEHPK3PXP" # quoted
+  globex:
+    uri: otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex
+
+port: 8080
+`,
+		},
+		{
+			scenario: "yaml plain",
+			file:     "config.yaml",
+			keyPath:  "vendors.globex.uri",
+			expected: `# Tool configuration.
+name: tool
+vendors:
+  acme:
+    user: john
+    # The TOTP secret.
+    totp: "NBSWY3DP" # quoted
+  globex:
+    uri: JBSWY3DPEHPK3PXP
+
+port: 8080
+`,
+		},
+		{
+			scenario: "yaml new map",
+			file:     "config.yaml",
+			keyPath:  "vendors.initech.totp",
+			expected: `# Tool configuration.
+name: tool
+vendors:
+  acme:
+    user: john
+    # The TOTP secret.
+    totp: "NBSWY3DP" # quoted
+  globex:
+    uri: otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex
+  initech:
+    totp: JBSWY3DPEHPK3PXP
+port: 8080
+`,
+		},
+		{
+			scenario: "toml",
+			file:     "config.toml",
+			keyPath:  "vendors.acme.totp",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'JBSWY3DPEHPK3PXP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml inline table",
+			file:     "config.toml",
+			keyPath:  "vendors.globex.inline.totp",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "JBSWY3DPEHPK3PXP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml new key",
+			file:     "config.toml",
+			keyPath:  "vendors.acme.backup",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+backup = "JBSWY3DPEHPK3PXP"
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml new top-level key",
+			file:     "config.toml",
+			keyPath:  "totp",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+totp = "JBSWY3DPEHPK3PXP"
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml new table",
+			file:     "config.toml",
+			keyPath:  "vendors.initech.totp",
+			expected: tomlConfig + `
+[vendors.initech]
+totp = "JBSWY3DPEHPK3PXP"
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeConfig(t, tc.file, configs[tc.file])
+			f := configfile.TOTPSecretFromFile(path, tc.keyPath)
+
+			require.NoError(t, f.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+
+			assert.Equal(t, tc.expected, readConfig(t, path))
+			assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), f.TOTPSecret(context.Background()))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		})
+	}
+}
+
+func TestFile_SetTOTPSecret_NewFile(t *testing.T) {
+	t.Parallel()
+
+	const uri = "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+
+	testCases := []struct {
+		file     string
+		expected string
+	}{
+		{
+			file:     "config.json",
+			expected: "{\n  \"vendors\": {\n    \"acme\": {\n      \"totp\": \"" + uri + "\"\n    }\n  }\n}\n",
+		},
+		{
+			file:     "config.yaml",
+			expected: "vendors:\n  acme:\n    totp: " + uri + "\n",
+		},
+		{
+			file:     "config.toml",
+			expected: "[vendors.acme]\ntotp = \"" + uri + "\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.file, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), tc.file)
+			f := configfile.TOTPSecretFromFile(path, "vendors.acme.totp")
+
+			require.NoError(t, f.SetTOTPSecret(context.Background(), uri, "ACME"))
+
+			assert.Equal(t, tc.expected, readConfig(t, path))
+			assert.Equal(t, otp.TOTPSecret(uri), f.TOTPSecret(context.Background()))
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+
+			assert.Equal(t, configfile.DefaultPermissions, info.Mode().Perm())
+		})
+	}
+}
+
+func TestFile_SetTOTPSecret_Symlink(t *testing.T) {
+	t.Parallel()
+
+	target := writeConfig(t, "config.yaml", "vendors:\n  acme:\n    totp: NBSWY3DP\n")
+	link := filepath.Join(t.TempDir(), "config.yaml")
+
+	require.NoError(t, os.Symlink(target, link))
+
+	f := configfile.TOTPSecretFromFile(link, "vendors.acme.totp")
+
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+
+	// The symlink is kept, and its target is replaced.
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+	assert.Equal(t, "vendors:\n  acme:\n    totp: JBSWY3DPEHPK3PXP\n", readConfig(t, target))
+
+	info, err = os.Stat(target)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+}
+
+func TestFile_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	for name, content := range configs {
+		path := writeConfig(t, name, content)
+
+		for _, keyPath := range []string{"vendors.acme", "name.totp", "port"} {
+			l := &ctxd.LoggerMock{}
+			f := configfile.TOTPSecretFromFile(path, keyPath, configfile.WithLogger(l))
+
+			err := f.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME")
+			require.ErrorIs(t, err, configfile.ErrInvalidKeyPath, "%s: %s", name, keyPath)
+
+			assert.Equal(t, content, readConfig(t, path))
+
+			require.Len(t, l.LoggedEntries, 1)
+
+			assert.Equal(t, "error", l.LoggedEntries[0].Level)
+			assert.Equal(t, "could not persist totp secret to config file", l.LoggedEntries[0].Message)
+		}
+	}
+}
+
+func TestFile_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		file     string
+		keyPath  string
+		expected string
+	}{
+		{
+			scenario: "json",
+			file:     "config.json",
+			keyPath:  "vendors.acme.totp",
+			expected: `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"}
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "json first key",
+			file:     "config.json",
+			keyPath:  "name",
+			expected: `{
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "NBSWY3DP"
+        },
+        "globex": {"uri": "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"}
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "json only key",
+			file:     "config.json",
+			keyPath:  "vendors.globex.uri",
+			expected: `{
+    "name": "tool",
+    "vendors": {
+        "acme": {
+            "user": "john",
+            "totp": "NBSWY3DP"
+        },
+        "globex": {}
+    },
+    "port": 8080
+}
+`,
+		},
+		{
+			scenario: "yaml",
+			file:     "config.yaml",
+			keyPath:  "vendors.acme.totp",
+			expected: `# Tool configuration.
+name: tool
+vendors:
+  acme:
+    user: john
+  globex:
+    uri: otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex
+port: 8080
+`,
+		},
+		{
+			scenario: "toml",
+			file:     "config.toml",
+			keyPath:  "vendors.acme.totp",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP", user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml inline table",
+			file:     "config.toml",
+			keyPath:  "vendors.globex.inline.totp",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { user = "john" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+		{
+			scenario: "toml last key of inline table",
+			file:     "config.toml",
+			keyPath:  "vendors.globex.inline.user",
+			expected: `# Tool configuration.
+name = "tool"
+port = 8080
+
+[vendors.acme]
+user = "john"
+# The TOTP secret.
+totp = 'NBSWY3DP' # literal
+
+# Globex.
+[vendors.globex]
+uri = "otpauth://totp/Globex:john?secret=JBSWY3DPEHPK3PXP&issuer=Globex"
+inline = { totp = "NBSWY3DP" }
+
+[[servers]]
+host = "localhost"
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeConfig(t, tc.file, configs[tc.file])
+			f := configfile.TOTPSecretFromFile(path, tc.keyPath)
+
+			require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+
+			assert.Equal(t, tc.expected, readConfig(t, path))
+
+			_, err := f.Load(context.Background())
+			require.ErrorIs(t, err, configfile.ErrKeyNotFound)
+
+			// Deleting a missing key does nothing.
+			require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+			assert.Equal(t, tc.expected, readConfig(t, path))
+		})
+	}
+}
+
+func TestFile_DeleteTOTPSecret_FileNotFound(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	require.NoError(t, configfile.TOTPSecretFromFile(path, "totp").DeleteTOTPSecret(context.Background()))
+
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFile_ChainTOTPSecretProviders(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, "config.yaml", yamlConfig)
+
+	p := otp.ChainTOTPSecretProviders(
+		otp.TOTPSecretFromEnv("CONFIGFILE_TEST_OTP_SECRET"),
+		configfile.TOTPSecretFromFile(path, "vendors.acme.totp"),
+	)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+}
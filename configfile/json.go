@@ -0,0 +1,345 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const defaultJSONIndent = "  "
+
+type jsonCodec struct{}
+
+func (jsonCodec) get(data []byte, path []string) (string, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", ErrKeyNotFound
+	}
+
+	var v any
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("could not decode json: %w", err)
+	}
+
+	return lookup(v, path)
+}
+
+func (jsonCodec) set(data []byte, path []string, value string) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}\n")
+	}
+
+	root, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := root
+
+	for i, k := range path {
+		m := obj.member(k)
+
+		if m == nil {
+			return obj.insert(data, path[i:], value), nil
+		}
+
+		if i == len(path)-1 {
+			// Only the strings and the nulls are replaced.
+			if m.value.kind != '"' && string(data[m.value.start:m.value.end]) != "null" {
+				return nil, ErrInvalidKeyPath
+			}
+
+			return splice(data, m.value.start, m.value.end, quoteJSON(value)), nil
+		}
+
+		if m.value.kind != '{' {
+			return nil, ErrInvalidKeyPath
+		}
+
+		obj = m.value
+	}
+
+	return nil, ErrInvalidKeyPath
+}
+
+func (jsonCodec) delete(data []byte, path []string) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	root, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := root
+
+	for _, k := range path[:len(path)-1] {
+		m := obj.member(k)
+
+		switch {
+		case m == nil:
+			return nil, ErrKeyNotFound
+
+		case m.value.kind != '{':
+			return nil, ErrInvalidKeyPath
+		}
+
+		obj = m.value
+	}
+
+	for i := len(obj.members) - 1; i >= 0; i-- {
+		if obj.members[i].key != path[len(path)-1] {
+			continue
+		}
+
+		// Remove the member with the comma and the spaces that separate it from the previous member, or from the next
+		// member if it is the first one.
+		switch {
+		case i > 0:
+			return splice(data, obj.members[i-1].value.end, obj.members[i].value.end, ""), nil
+
+		case len(obj.members) > 1:
+			return splice(data, obj.members[0].keyStart, obj.members[1].keyStart, ""), nil
+
+		default:
+			return splice(data, obj.start+1, obj.end-1, ""), nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// jsonValue is a value in a JSON document, with its position.
+type jsonValue struct {
+	kind       byte // '{', '[', '"', or 0 for the other values.
+	start, end int
+	members    []jsonMember
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	value    *jsonValue
+}
+
+// member returns the last member with the key, like json.Unmarshal does.
+func (v *jsonValue) member(key string) *jsonMember {
+	for i := len(v.members) - 1; i >= 0; i-- {
+		if v.members[i].key == key {
+			return &v.members[i]
+		}
+	}
+
+	return nil
+}
+
+// insert adds the value at the path in the object. The new member follows the indentation of the other members.
+func (v *jsonValue) insert(data []byte, path []string, value string) []byte {
+	multiline := len(v.members) == 0 || bytes.IndexByte(data[v.start:v.end], '\n') >= 0
+
+	if !multiline {
+		member := newJSONMember(path, value, "", "")
+
+		return splice(data, v.end-1, v.end-1, ", "+member)
+	}
+
+	unit := jsonIndent(data)
+	indent := lineIndent(data, v.start) + unit
+
+	if len(v.members) > 0 {
+		indent = lineIndent(data, v.members[0].keyStart)
+	}
+
+	member := newJSONMember(path, value, indent, unit)
+
+	if len(v.members) > 0 {
+		end := v.members[len(v.members)-1].value.end
+
+		return splice(data, end, end, ",\n"+indent+member)
+	}
+
+	return splice(data, v.start, v.end, "{\n"+indent+member+"\n"+lineIndent(data, v.start)+"}")
+}
+
+func newJSONMember(path []string, value, indent, unit string) string {
+	var sb strings.Builder
+
+	for i, k := range path {
+		sb.WriteString(quoteJSON(k))
+		sb.WriteString(": ")
+
+		if i == len(path)-1 {
+			sb.WriteString(quoteJSON(value))
+
+			break
+		}
+
+		sb.WriteString("{")
+
+		if unit == "" {
+			continue
+		}
+
+		sb.WriteString("\n")
+		sb.WriteString(indent + strings.Repeat(unit, i+1))
+	}
+
+	for i := len(path) - 2; i >= 0; i-- {
+		if unit != "" {
+			sb.WriteString("\n")
+			sb.WriteString(indent + strings.Repeat(unit, i))
+		}
+
+		sb.WriteString("}")
+	}
+
+	return sb.String()
+}
+
+// parseJSON finds the positions of the values in a JSON document. The document must be an object.
+func parseJSON(data []byte) (*jsonValue, error) {
+	if !json.Valid(data) {
+		var v any
+
+		return nil, fmt.Errorf("could not decode json: %w", json.Unmarshal(data, &v))
+	}
+
+	p := jsonParser{data: data}
+	v := p.value()
+
+	if v.kind != '{' {
+		return nil, ErrInvalidKeyPath
+	}
+
+	return v, nil
+}
+
+type jsonParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *jsonParser) skipSpaces() {
+	for p.pos < len(p.data) && strings.IndexByte(" \t\r\n", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// value parses a value of a valid JSON document.
+func (p *jsonParser) value() *jsonValue {
+	p.skipSpaces()
+
+	v := &jsonValue{start: p.pos}
+
+	switch c := p.data[p.pos]; c {
+	case '{', '[':
+		v.kind = c
+		p.pos++
+
+		for {
+			p.skipSpaces()
+
+			if c := p.data[p.pos]; c == '}' || c == ']' {
+				p.pos++
+
+				break
+			}
+
+			if v.kind == '{' {
+				keyStart := p.pos
+				key := p.string()
+
+				p.skipSpaces()
+				p.pos++ // The colon.
+
+				v.members = append(v.members, jsonMember{key: key, keyStart: keyStart, value: p.value()})
+			} else {
+				p.value()
+			}
+
+			p.skipSpaces()
+
+			if p.data[p.pos] == ',' {
+				p.pos++
+			}
+		}
+
+	case '"':
+		v.kind = c
+		p.string()
+
+	default:
+		for p.pos < len(p.data) && strings.IndexByte(",}] \t\r\n", p.data[p.pos]) < 0 {
+			p.pos++
+		}
+	}
+
+	v.end = p.pos
+
+	return v
+}
+
+func (p *jsonParser) string() string {
+	start := p.pos
+
+	for p.pos++; p.data[p.pos] != '"'; p.pos++ {
+		if p.data[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+
+	p.pos++
+
+	var s string
+
+	_ = json.Unmarshal(p.data[start:p.pos], &s) //nolint: errcheck
+
+	return s
+}
+
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	_ = enc.Encode(s) //nolint: errcheck
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonIndent returns the indentation unit of a JSON document.
+func jsonIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+
+	return defaultJSONIndent
+}
+
+// lineIndent returns the indentation of the line at the offset.
+func lineIndent(data []byte, offset int) string {
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := start
+
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+
+	return string(data[start:end])
+}
+
+// splice replaces the bytes between start and end.
+func splice(data []byte, start, end int, s string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(s))
+	out = append(out, data[:start]...)
+	out = append(out, s...)
+
+	return append(out, data[end:]...)
+}
@@ -0,0 +1,366 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+type tomlCodec struct{}
+
+func (tomlCodec) get(data []byte, path []string) (string, error) {
+	var v map[string]any
+
+	if err := toml.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("could not decode toml: %w", err)
+	}
+
+	return lookup(v, path)
+}
+
+func (tomlCodec) set(data []byte, path []string, value string) ([]byte, error) {
+	doc, err := scanTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := doc.entry(path); e != nil {
+		if e.kind != unstable.String {
+			return nil, ErrInvalidKeyPath
+		}
+
+		return splice(data, e.start, e.end, quoteTOML(value, data[e.start] == '\'')), nil
+	}
+
+	table, start, end := doc.section(path)
+	keys := path[len(table):]
+
+	// A new table is added at the end of the document, unless the keys extend a dotted key of the section.
+	if len(keys) > 1 && !doc.extends(path[:len(table)+1], start, end) {
+		var sb strings.Builder
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString("[" + tomlKey(path[:len(path)-1]) + "]\n")
+		sb.WriteString(tomlKey(path[len(path)-1:]) + " = " + quoteTOML(value, false) + "\n")
+
+		return insertLine(data, len(data), sb.String()), nil
+	}
+
+	return insertLine(data, doc.insertionPoint(start, end), tomlKey(keys)+" = "+quoteTOML(value, false)+"\n"), nil
+}
+
+func (tomlCodec) delete(data []byte, path []string) ([]byte, error) {
+	doc, err := scanTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	e := doc.entry(path)
+
+	switch {
+	case e == nil:
+		return nil, ErrKeyNotFound
+
+	case e.kind != unstable.String:
+		return nil, ErrInvalidKeyPath
+
+	case e.inline:
+		return deleteInline(data, e.keyStart, e.end), nil
+	}
+
+	start := bytes.LastIndexByte(data[:e.keyStart], '\n') + 1
+	end := len(data)
+
+	if i := bytes.IndexByte(data[e.end:], '\n'); i >= 0 {
+		end = e.end + i + 1
+	}
+
+	return splice(data, start, end, ""), nil
+}
+
+// tomlDocument is the positions of the keys and the tables in a TOML document.
+type tomlDocument struct {
+	data    []byte
+	entries []tomlEntry
+	tables  []tomlTable
+}
+
+type tomlEntry struct {
+	path     []string
+	keyStart int
+	kind     unstable.Kind
+	// The range of the value, only for the strings.
+	start, end int
+	inline     bool
+}
+
+type tomlTable struct {
+	path  []string
+	start int
+	end   int
+	array bool
+}
+
+func scanTOML(data []byte) (*tomlDocument, error) {
+	doc := &tomlDocument{data: data}
+	p := unstable.Parser{}
+
+	p.Reset(data)
+
+	var table []string
+
+	for p.NextExpression() {
+		e := p.Expression()
+
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			keys, start := tomlKeys(e)
+
+			table = keys
+
+			doc.tables = append(doc.tables, tomlTable{
+				path:  keys,
+				start: bytes.LastIndexByte(data[:start], '\n') + 1,
+				end:   lineEnd(data, start),
+				array: e.Kind == unstable.ArrayTable,
+			})
+
+		case unstable.KeyValue:
+			doc.addEntry(e, table, false)
+		}
+	}
+
+	if err := p.Error(); err != nil {
+		return nil, fmt.Errorf("could not decode toml: %w", err)
+	}
+
+	return doc, nil
+}
+
+func (d *tomlDocument) addEntry(n *unstable.Node, parent []string, inline bool) {
+	keys, keyStart := tomlKeys(n)
+	v := n.Value()
+
+	e := tomlEntry{
+		path:     append(slices.Clone(parent), keys...),
+		keyStart: keyStart,
+		kind:     v.Kind,
+		inline:   inline,
+	}
+
+	if v.Kind == unstable.String {
+		e.start = int(v.Raw.Offset)
+		e.end = int(v.Raw.Offset + v.Raw.Length)
+	}
+
+	d.entries = append(d.entries, e)
+
+	if v.Kind == unstable.InlineTable {
+		for it := v.Children(); it.Next(); {
+			d.addEntry(it.Node(), e.path, true)
+		}
+	}
+}
+
+// entry returns the entry of the key path.
+func (d *tomlDocument) entry(path []string) *tomlEntry {
+	for i := len(d.entries) - 1; i >= 0; i-- {
+		if slices.Equal(d.entries[i].path, path) {
+			return &d.entries[i]
+		}
+	}
+
+	return nil
+}
+
+// section returns the path of the table that the key path belongs to, and the range of the table in the document. The
+// top-level keys are in the table with an empty path, before the first table.
+func (d *tomlDocument) section(path []string) ([]string, int, int) {
+	var (
+		table []string
+		start int
+		end   = len(d.data)
+	)
+
+	if len(d.tables) > 0 {
+		end = d.tables[0].start
+	}
+
+	for i, t := range d.tables {
+		if t.array || len(t.path) >= len(path) || len(t.path) < len(table) || !slices.Equal(t.path, path[:len(t.path)]) {
+			continue
+		}
+
+		table, start, end = t.path, t.end, len(d.data)
+
+		if i+1 < len(d.tables) {
+			end = d.tables[i+1].start
+		}
+	}
+
+	return table, start, end
+}
+
+// extends tells whether a key in the range starts with the path.
+func (d *tomlDocument) extends(path []string, start, end int) bool {
+	for _, e := range d.entries {
+		if !e.inline && e.keyStart >= start && e.keyStart < end && len(e.path) > len(path) && slices.Equal(e.path[:len(path)], path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// insertionPoint returns the offset after the last key of a range, before the blank lines and the comments that
+// precede the next table.
+func (d *tomlDocument) insertionPoint(start, end int) int {
+	for end > start {
+		lineStart := bytes.LastIndexByte(d.data[start:end-1], '\n') + 1 + start
+		line := bytes.TrimSpace(d.data[lineStart:end])
+
+		if len(line) > 0 && line[0] != '#' {
+			break
+		}
+
+		end = lineStart
+	}
+
+	return end
+}
+
+func tomlKeys(n *unstable.Node) ([]string, int) {
+	var (
+		keys  []string
+		start = -1
+	)
+
+	for it := n.Key(); it.Next(); {
+		k := it.Node()
+
+		if start < 0 {
+			start = int(k.Raw.Offset)
+		}
+
+		keys = append(keys, string(k.Data))
+	}
+
+	return keys, start
+}
+
+// insertLine inserts a line at the offset, and makes sure that it starts on a new line.
+func insertLine(data []byte, offset int, line string) []byte {
+	if offset > 0 && data[offset-1] != '\n' {
+		line = "\n" + line
+	}
+
+	return splice(data, offset, offset, line)
+}
+
+// deleteInline removes a key from an inline table, with the comma that separates it from the next or the previous key.
+func deleteInline(data []byte, start, end int) []byte {
+	next := end
+
+	for next < len(data) && (data[next] == ' ' || data[next] == '\t') {
+		next++
+	}
+
+	if next < len(data) && data[next] == ',' {
+		next++
+
+		for next < len(data) && (data[next] == ' ' || data[next] == '\t') {
+			next++
+		}
+
+		return splice(data, start, next, "")
+	}
+
+	prev := start
+
+	for prev > 0 && (data[prev-1] == ' ' || data[prev-1] == '\t') {
+		prev--
+	}
+
+	if prev > 0 && data[prev-1] == ',' {
+		start = prev - 1
+	}
+
+	return splice(data, start, end, "")
+}
+
+func lineEnd(data []byte, offset int) int {
+	if i := bytes.IndexByte(data[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+
+	return len(data)
+}
+
+// tomlKey returns a dotted key, the keys that are not bare are quoted.
+func tomlKey(keys []string) string {
+	quoted := make([]string, len(keys))
+
+	for i, k := range keys {
+		quoted[i] = k
+
+		if k == "" || strings.IndexFunc(k, func(r rune) bool {
+			return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+		}) >= 0 {
+			quoted[i] = quoteTOML(k, false)
+		}
+	}
+
+	return strings.Join(quoted, ".")
+}
+
+// quoteTOML returns a TOML string. A literal string is used if it is preferred and possible.
+func quoteTOML(s string, literal bool) string {
+	if literal && !strings.ContainsFunc(s, func(r rune) bool { return r == '\'' || r < 0x20 || r == 0x7f }) {
+		return "'" + s + "'"
+	}
+
+	var sb strings.Builder
+
+	sb.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+
+		case '\b':
+			sb.WriteString(`\b`)
+
+		case '\t':
+			sb.WriteString(`\t`)
+
+		case '\n':
+			sb.WriteString(`\n`)
+
+		case '\f':
+			sb.WriteString(`\f`)
+
+		case '\r':
+			sb.WriteString(`\r`)
+
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+
+	sb.WriteByte('"')
+
+	return sb.String()
+}
@@ -0,0 +1,292 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultYAMLIndent = 2
+
+type yamlCodec struct{}
+
+func (yamlCodec) get(data []byte, path []string) (string, error) {
+	var v any
+
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("could not decode yaml: %w", err)
+	}
+
+	if v == nil {
+		return "", ErrKeyNotFound
+	}
+
+	return lookup(v, path)
+}
+
+func (yamlCodec) set(data []byte, path []string, value string) ([]byte, error) {
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	n := root.Content[0]
+
+	for i, k := range path {
+		_, v := mappingValue(n, k)
+
+		if v == nil {
+			n.Content = append(n.Content, newYAMLMapping(path[i:], value)...)
+
+			return encodeYAML(data, root)
+		}
+
+		if i < len(path)-1 {
+			if v.Kind != yaml.MappingNode {
+				return nil, ErrInvalidKeyPath
+			}
+
+			n = v
+
+			continue
+		}
+
+		// Only the strings and the nulls are replaced.
+		if v.Kind != yaml.ScalarNode || (v.ShortTag() != "!!str" && v.ShortTag() != "!!null") {
+			return nil, ErrInvalidKeyPath
+		}
+
+		// Replace the value in place if possible, so the rest of the document is kept as it is.
+		if out, ok := spliceYAMLScalar(data, v, value); ok {
+			return out, nil
+		}
+
+		v.Tag = "!!str"
+		v.Value = value
+	}
+
+	return encodeYAML(data, root)
+}
+
+func (yamlCodec) delete(data []byte, path []string) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	root, err := decodeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	n := root.Content[0]
+
+	for _, k := range path[:len(path)-1] {
+		_, v := mappingValue(n, k)
+
+		switch {
+		case v == nil:
+			return nil, ErrKeyNotFound
+
+		case v.Kind != yaml.MappingNode:
+			return nil, ErrInvalidKeyPath
+		}
+
+		n = v
+	}
+
+	i, _ := mappingValue(n, path[len(path)-1])
+	if i < 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	n.Content = append(n.Content[:i], n.Content[i+2:]...)
+
+	return encodeYAML(data, root)
+}
+
+// decodeYAML decodes a YAML document into nodes, so the comments are kept. The document must be a map.
+func decodeYAML(data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("could not decode yaml: %w", err)
+	}
+
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	if root.Content[0].Kind != yaml.MappingNode {
+		return nil, ErrInvalidKeyPath
+	}
+
+	return &root, nil
+}
+
+// encodeYAML encodes the document with the indentation of the original document.
+func encodeYAML(data []byte, root *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent(root.Content[0]))
+
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("could not encode yaml: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("could not encode yaml: %w", err)
+	}
+
+	if bytes.HasPrefix(data, []byte("---\n")) {
+		return append([]byte("---\n"), buf.Bytes()...), nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// yamlIndent returns the indentation of the first nested map of the document.
+func yamlIndent(n *yaml.Node) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+
+		if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 && len(v.Content) > 0 && v.Line > k.Line {
+			return v.Content[0].Column - k.Column
+		}
+	}
+
+	return defaultYAMLIndent
+}
+
+func newYAMLMapping(path []string, value string) []*yaml.Node {
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+
+	for i := len(path) - 1; i > 0; i-- {
+		v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{yamlKey(path[i]), v}}
+	}
+
+	return []*yaml.Node{yamlKey(path[0]), v}
+}
+
+func yamlKey(k string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+}
+
+// mappingValue returns the index of the key and its value in a map.
+func mappingValue(n *yaml.Node, key string) (int, *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i, n.Content[i+1]
+		}
+	}
+
+	return -1, nil
+}
+
+// spliceYAMLScalar replaces a single-line scalar in the document, and keeps its style. It returns false if the scalar
+// could not be replaced in place.
+func spliceYAMLScalar(data []byte, n *yaml.Node, value string) ([]byte, bool) {
+	start, ok := yamlOffset(data, n.Line, n.Column)
+	if !ok {
+		return nil, false
+	}
+
+	end := start
+
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		if data[start] != '"' {
+			return nil, false
+		}
+
+		for end = start + 1; end < len(data) && data[end] != '"' && data[end] != '\n'; end++ {
+			if data[end] == '\\' {
+				end++
+			}
+		}
+
+	case yaml.SingleQuotedStyle:
+		if data[start] != '\'' {
+			return nil, false
+		}
+
+		for end = start + 1; end < len(data) && data[end] != '\n'; end++ {
+			if data[end] == '\'' {
+				if end+1 < len(data) && data[end+1] == '\'' {
+					end++
+
+					continue
+				}
+
+				break
+			}
+		}
+
+	case 0:
+		if n.Value == "" {
+			return nil, false
+		}
+
+		line := data[start:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+
+		if i := bytes.Index(line, []byte(" #")); i >= 0 {
+			line = line[:i]
+		}
+
+		if string(bytes.TrimRight(line, " \t\r")) != n.Value {
+			return nil, false
+		}
+
+		end = start + len(n.Value) - 1
+
+	default:
+		return nil, false
+	}
+
+	if end >= len(data) || data[end] == '\n' {
+		return nil, false
+	}
+
+	out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: n.Style})
+	if err != nil {
+		return nil, false
+	}
+
+	s := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(s, "\n") {
+		return nil, false
+	}
+
+	return splice(data, start, end+1, s), true
+}
+
+// yamlOffset returns the offset of a position in the document. The line and the column start at 1.
+func yamlOffset(data []byte, line, column int) (int, bool) {
+	offset := 0
+
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[offset:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+
+		offset += i + 1
+	}
+
+	for c := 1; c < column; c++ {
+		if offset >= len(data) || data[offset] == '\n' {
+			return 0, false
+		}
+
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+
+	return offset, offset < len(data)
+}
@@ -0,0 +1,2 @@
+// Package dotenv provides totp secret storage in .env files.
+package dotenv
@@ -0,0 +1,205 @@
+package dotenv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/atomicfile"
+)
+
+// ErrVariableNotFound indicates that the variable is not in the .env file.
+var ErrVariableNotFound = errors.New("variable not found")
+
+var _ otp.TOTPSecretProvider = (*File)(nil)
+
+// File is a TOTP secret provider that stores the TOTP secret, or an otpauth URI, in a variable of a .env file. The file
+// supports the export prefixes, the comments, and the single and double quoted values. The last definition of the
+// variable wins, and the values are not expanded.
+//
+// On write, only the lines of the variable are changed. The other lines are kept as they are, in the same order.
+type File struct {
+	config
+
+	path string
+	name string
+
+	mu sync.Mutex
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Name returns the name of the variable.
+func (f *File) Name() string {
+	return f.name
+}
+
+// TOTPSecret returns the TOTP secret from the file. It returns otp.NoTOTPSecret if the file or the variable does not
+// exist, or if the file could not be read, see Load for the error.
+func (f *File) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := f.Load(ctx)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, ErrVariableNotFound):
+		f.logger.Debug(ctx, "totp secret does not exist in dotenv file", "path", f.path, "name", f.name)
+
+	case err != nil:
+		f.logger.Error(ctx, "could not get totp secret from dotenv file", "error", err, "path", f.path, "name", f.name)
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret from the file. It returns ErrVariableNotFound if the variable is not in the file, or an
+// error that wraps os.ErrNotExist if the file does not exist.
+func (f *File) Load(_ context.Context) (otp.TOTPSecret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read dotenv file: %w", err)
+	}
+
+	entries, err := parse(data)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	e := lookup(entries, f.name)
+	if e == nil {
+		return otp.NoTOTPSecret, ErrVariableNotFound
+	}
+
+	return otp.ParseTOTPSecret(e.value)
+}
+
+// SetTOTPSecret writes the TOTP secret to the variable. The value of the last definition is replaced, with its quotes
+// if possible, or the variable is added at the end of the file. The file is created if it does not exist.
+func (f *File) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	err := f.update(true, func(data []byte, entries []entry) []byte {
+		if e := lookup(entries, f.name); e != nil {
+			return splice(data, e.valueStart, e.valueEnd, quote(secret.Reveal(), e.quote))
+		}
+
+		eol := lineBreak(data)
+		line := f.name + "=" + quote(secret.Reveal(), 0) + eol
+
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			line = eol + line
+		}
+
+		return append(data, line...)
+	})
+	if err != nil {
+		f.logger.Error(ctx, "could not persist totp secret to dotenv file", "error", err, "path", f.path, "name", f.name)
+
+		return fmt.Errorf("could not persist totp secret to dotenv file: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret removes the lines of the variable from the file. It does nothing if the file or the variable does
+// not exist.
+func (f *File) DeleteTOTPSecret(ctx context.Context) error {
+	err := f.update(false, func(data []byte, entries []entry) []byte {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].name == f.name {
+				data = splice(data, entries[i].lineStart, entries[i].lineEnd, "")
+			}
+		}
+
+		return data
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		f.logger.Error(ctx, "could not delete totp secret in dotenv file", "error", err, "path", f.path, "name", f.name)
+
+		return fmt.Errorf("could not delete totp secret in dotenv file: %w", err)
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (f *File) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return f
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (f *File) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return f
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (f *File) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return f
+}
+
+func (f *File) update(create bool, fn func(data []byte, entries []entry) []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if err != nil && (!create || !errors.Is(err, os.ErrNotExist)) {
+		return fmt.Errorf("could not read dotenv file: %w", err)
+	}
+
+	entries, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	// Nothing to write, such as when the variable to delete is not in the file.
+	out := fn(data, entries)
+	if data != nil && bytes.Equal(out, data) {
+		return nil
+	}
+
+	return atomicfile.Write(f.path, out, f.permissions)
+}
+
+// TOTPSecretFromFile returns a TOTP secret provider that stores the TOTP secret in a variable of a .env file.
+func TOTPSecretFromFile(path, name string, opts ...Option) *File {
+	return &File{
+		config: newConfig(opts...),
+		path:   path,
+		name:   name,
+	}
+}
+
+// lookup returns the last definition of the variable.
+func lookup(entries []entry, name string) *entry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].name == name {
+			return &entries[i]
+		}
+	}
+
+	return nil
+}
+
+// lineBreak returns the line break of the file.
+func lineBreak(data []byte) string {
+	if bytes.Contains(data, []byte("\r\n")) {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// splice replaces the bytes between start and end.
+func splice(data []byte, start, end int, s string) []byte {
+	out := make([]byte, 0, len(data)-(end-start)+len(s))
+	out = append(out, data[:start]...)
+	out = append(out, s...)
+
+	return append(out, data[end:]...)
+}
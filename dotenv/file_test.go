@@ -0,0 +1,394 @@
+//go:build unit || !integration
+
+package dotenv_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/dotenv"
+)
+
+const uri = "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+
+const env = `# Local settings.
+APP_NAME=tool
+export OTP_SECRET="NBSWY3DP" # the secret
+SINGLE='otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME'
+  UNQUOTED = JBSWY3DPEHPK3PXP   # inline comment
+HASH=abc#def
+EMPTY=
+COMMENT= # nothing
+ESCAPED="a\"b\\c\nd\$e\x"
+MULTI="line1
+line2"
+DUP=first
+DUP=NBSWY3DP
+not a variable
+
+LAST=value`
+
+func writeEnv(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o640))
+	require.NoError(t, os.Chmod(path, 0o640))
+
+	return path
+}
+
+func readEnv(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestFile_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	path := writeEnv(t, env)
+
+	testCases := []struct {
+		scenario       string
+		name           string
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "export and double quotes",
+			name:           "OTP_SECRET",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "single quotes and otpauth uri",
+			name:           "SINGLE",
+			expectedResult: uri,
+		},
+		{
+			scenario:       "unquoted with spaces and comment",
+			name:           "UNQUOTED",
+			expectedResult: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario:       "hash without space",
+			name:           "HASH",
+			expectedResult: "abc#def",
+		},
+		{
+			scenario:       "empty",
+			name:           "EMPTY",
+			expectedResult: otp.NoTOTPSecret,
+		},
+		{
+			scenario:       "only comment",
+			name:           "COMMENT",
+			expectedResult: otp.NoTOTPSecret,
+		},
+		{
+			scenario:       "escapes",
+			name:           "ESCAPED",
+			expectedResult: "a\"b\\c\nd$e\\x",
+		},
+		{
+			scenario:       "multiline",
+			name:           "MULTI",
+			expectedResult: "line1\nline2",
+		},
+		{
+			scenario:       "last definition wins",
+			name:           "DUP",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "no line break at the end",
+			name:           "LAST",
+			expectedResult: "value",
+		},
+		{
+			scenario:       "not found",
+			name:           "MISSING",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  dotenv.ErrVariableNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			f := dotenv.TOTPSecretFromFile(path, tc.name)
+
+			assert.Equal(t, path, f.Path())
+			assert.Equal(t, tc.name, f.Name())
+			assert.Equal(t, tc.expectedResult, f.TOTPSecret(context.Background()))
+
+			actual, err := f.Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestFile_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	f := dotenv.TOTPSecretFromFile(writeEnv(t, "A=1\nB='unterminated\nC=3\n"), "C", dotenv.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+
+	_, err := f.Load(context.Background())
+	require.ErrorIs(t, err, dotenv.ErrInvalidSyntax)
+	require.EqualError(t, err, "invalid dotenv syntax: unterminated quoted value at line 2")
+
+	require.ErrorIs(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), dotenv.ErrInvalidSyntax)
+
+	require.Len(t, l.LoggedEntries, 2)
+
+	assert.Equal(t, "error", l.LoggedEntries[0].Level)
+	assert.Equal(t, "could not get totp secret from dotenv file", l.LoggedEntries[0].Message)
+	assert.Equal(t, "could not persist totp secret to dotenv file", l.LoggedEntries[1].Message)
+
+	f = dotenv.TOTPSecretFromFile(filepath.Join(t.TempDir(), ".env"), "C")
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+
+	_, err = f.Load(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFile_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		content  string
+		name     string
+		secret   otp.TOTPSecret
+		expected string
+	}{
+		{
+			scenario: "double quotes",
+			content:  "A=1\nexport OTP_SECRET=\"NBSWY3DP\" # the secret\nB=2\n",
+			name:     "OTP_SECRET",
+			secret:   "JBSWY3DPEHPK3PXP",
+			expected: "A=1\nexport OTP_SECRET=\"JBSWY3DPEHPK3PXP\" # the secret\nB=2\n",
+		},
+		{
+			scenario: "single quotes",
+			content:  "OTP_SECRET='NBSWY3DP'\n",
+			name:     "OTP_SECRET",
+			secret:   uri,
+			expected: "OTP_SECRET='" + uri + "'\n",
+		},
+		{
+			scenario: "unquoted",
+			content:  "  OTP_SECRET = NBSWY3DP   # inline comment\n",
+			name:     "OTP_SECRET",
+			secret:   "JBSWY3DPEHPK3PXP",
+			expected: "  OTP_SECRET = JBSWY3DPEHPK3PXP   # inline comment\n",
+		},
+		{
+			scenario: "unquoted uri",
+			content:  "OTP_SECRET=NBSWY3DP\n",
+			name:     "OTP_SECRET",
+			secret:   uri,
+			expected: "OTP_SECRET='" + uri + "'\n",
+		},
+		{
+			scenario: "empty",
+			content:  "OTP_SECRET=\nA=1\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "OTP_SECRET=NBSWY3DP\nA=1\n",
+		},
+		{
+			scenario: "escapes",
+			content:  "OTP_SECRET='NBSWY3DP'\n",
+			name:     "OTP_SECRET",
+			secret:   "it's \"$x\"\n",
+			expected: "OTP_SECRET=\"it's \\\"\\$x\\\"\\n\"\n",
+		},
+		{
+			scenario: "multiline",
+			content:  "A=1\nOTP_SECRET=\"line1\nline2\" # comment\nB=2\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "A=1\nOTP_SECRET=\"NBSWY3DP\" # comment\nB=2\n",
+		},
+		{
+			scenario: "last definition",
+			content:  "OTP_SECRET=first\nA=1\nOTP_SECRET=second\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "OTP_SECRET=first\nA=1\nOTP_SECRET=NBSWY3DP\n",
+		},
+		{
+			scenario: "new variable",
+			content:  "# Settings.\nA=1\n\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "# Settings.\nA=1\n\nOTP_SECRET=NBSWY3DP\n",
+		},
+		{
+			scenario: "new variable without line break at the end",
+			content:  "A=1",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "A=1\nOTP_SECRET=NBSWY3DP\n",
+		},
+		{
+			scenario: "new variable with crlf",
+			content:  "A=1\r\nB=2\r\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "A=1\r\nB=2\r\nOTP_SECRET=NBSWY3DP\r\n",
+		},
+		{
+			scenario: "crlf",
+			content:  "OTP_SECRET=first\r\nB=2\r\n",
+			name:     "OTP_SECRET",
+			secret:   "NBSWY3DP",
+			expected: "OTP_SECRET=NBSWY3DP\r\nB=2\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeEnv(t, tc.content)
+			f := dotenv.TOTPSecretFromFile(path, tc.name)
+
+			require.NoError(t, f.SetTOTPSecret(context.Background(), tc.secret, "ACME"))
+
+			assert.Equal(t, tc.expected, readEnv(t, path))
+
+			actual, err := f.Load(context.Background())
+			require.NoError(t, err)
+
+			expected, err := otp.ParseTOTPSecret(tc.secret.Reveal())
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, actual)
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		})
+	}
+}
+
+func TestFile_SetTOTPSecret_NewFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	f := dotenv.TOTPSecretFromFile(path, "OTP_SECRET", dotenv.WithPermissions(0o640))
+
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"))
+
+	assert.Equal(t, "OTP_SECRET=NBSWY3DP\n", readEnv(t, path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+}
+
+func TestFile_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		content  string
+		expected string
+	}{
+		{
+			scenario: "variable",
+			content:  "# Settings.\nA=1\nexport OTP_SECRET=\"NBSWY3DP\" # the secret\nB=2\n",
+			expected: "# Settings.\nA=1\nB=2\n",
+		},
+		{
+			scenario: "all definitions",
+			content:  "OTP_SECRET=first\nA=1\nOTP_SECRET=second",
+			expected: "A=1\n",
+		},
+		{
+			scenario: "multiline",
+			content:  "A=1\nOTP_SECRET=\"line1\nline2\"\nB=2\n",
+			expected: "A=1\nB=2\n",
+		},
+		{
+			scenario: "not found",
+			content:  "A=1\n# OTP_SECRET=NBSWY3DP\n",
+			expected: "A=1\n# OTP_SECRET=NBSWY3DP\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeEnv(t, tc.content)
+			f := dotenv.TOTPSecretFromFile(path, "OTP_SECRET")
+
+			require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+
+			assert.Equal(t, tc.expected, readEnv(t, path))
+
+			_, err := f.Load(context.Background())
+			require.ErrorIs(t, err, dotenv.ErrVariableNotFound)
+		})
+	}
+}
+
+func TestFile_DeleteTOTPSecret_FileNotFound(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".env")
+
+	require.NoError(t, dotenv.TOTPSecretFromFile(path, "OTP_SECRET").DeleteTOTPSecret(context.Background()))
+
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFile_KeepsOtherLines(t *testing.T) {
+	t.Parallel()
+
+	path := writeEnv(t, env)
+	f := dotenv.TOTPSecretFromFile(path, "OTP_SECRET")
+
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+	require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+
+	expected := strings.Replace(env, "export OTP_SECRET=\"NBSWY3DP\" # the secret\n", "", 1)
+
+	assert.Equal(t, expected, readEnv(t, path))
+}
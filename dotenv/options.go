@@ -0,0 +1,56 @@
+package dotenv
+
+import (
+	"os"
+
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp"
+)
+
+// DefaultPermissions is the permissions of the .env files that are created by the provider.
+const DefaultPermissions os.FileMode = 0o600
+
+type config struct {
+	permissions os.FileMode
+	logger      ctxd.Logger
+}
+
+func newConfig(opts ...Option) config {
+	c := config{
+		permissions: DefaultPermissions,
+		logger:      ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(&c)
+	}
+
+	return c
+}
+
+// Option configures the services provided by the dotenv package.
+type Option interface {
+	applyOption(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) applyOption(c *config) {
+	f(c)
+}
+
+// WithPermissions sets the permissions of the .env file when it is created. The permissions of an existing file are
+// kept. Default is DefaultPermissions.
+func WithPermissions(perm os.FileMode) Option {
+	return optionFunc(func(c *config) {
+		c.permissions = perm.Perm()
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.logger = otp.RedactLogger(l)
+	})
+}
@@ -0,0 +1,194 @@
+package dotenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSyntax indicates that the .env file could not be parsed.
+var ErrInvalidSyntax = errors.New("invalid dotenv syntax")
+
+// entry is a variable in a .env file, with its position.
+type entry struct {
+	name  string
+	value string
+	quote byte
+
+	// The range of the line, including the line break, and the range of the value, including the quotes.
+	lineStart, lineEnd   int
+	valueStart, valueEnd int
+}
+
+// parse finds the variables in a .env file. The blank lines, the comments and the lines that are not variables are
+// skipped. The values are not expanded.
+func parse(data []byte) ([]entry, error) {
+	var (
+		entries []entry
+		pos     int
+		line    = 1
+	)
+
+	for pos < len(data) {
+		start := pos
+
+		e, ok, err := parseLine(data, &pos)
+		if err != nil {
+			return nil, fmt.Errorf("%w at line %d", err, line)
+		}
+
+		// Skip to the end of the line.
+		if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+			pos += i + 1
+		} else {
+			pos = len(data)
+		}
+
+		if ok {
+			e.lineStart, e.lineEnd = start, pos
+			entries = append(entries, e)
+		}
+
+		line += bytes.Count(data[start:pos], []byte("\n"))
+	}
+
+	return entries, nil
+}
+
+// parseLine parses the variable at the position, and moves the position to the end of the value. It returns false if the
+// line is not a variable.
+func parseLine(data []byte, pos *int) (entry, bool, error) {
+	var e entry
+
+	p := skipSpaces(data, *pos)
+
+	if rest := data[p:]; bytes.HasPrefix(rest, []byte("export")) && len(rest) > 6 && (rest[6] == ' ' || rest[6] == '\t') {
+		p = skipSpaces(data, p+6)
+	}
+
+	nameStart := p
+
+	for p < len(data) && isNameByte(data[p]) {
+		p++
+	}
+
+	e.name = string(data[nameStart:p])
+	p = skipSpaces(data, p)
+
+	if e.name == "" || p >= len(data) || data[p] != '=' {
+		*pos = p
+
+		return e, false, nil
+	}
+
+	p = skipSpaces(data, p+1)
+	e.valueStart = p
+
+	if p < len(data) && (data[p] == '\'' || data[p] == '"') {
+		e.quote = data[p]
+
+		end, value, err := parseQuoted(data, p)
+		if err != nil {
+			return e, false, err
+		}
+
+		e.value, e.valueEnd, *pos = value, end, end
+
+		return e, true, nil
+	}
+
+	end := p
+
+	for end < len(data) && data[end] != '\n' {
+		// An inline comment starts with a # after a space.
+		if data[end] == '#' && end > 0 && (data[end-1] == ' ' || data[end-1] == '\t') {
+			break
+		}
+
+		end++
+	}
+
+	for end > p && strings.IndexByte(" \t\r", data[end-1]) >= 0 {
+		end--
+	}
+
+	e.value, e.valueEnd, *pos = string(data[p:end]), end, end
+
+	return e, true, nil
+}
+
+// parseQuoted parses a quoted value, that may span several lines. The escape sequences are only decoded in the double
+// quoted values.
+func parseQuoted(data []byte, start int) (int, string, error) {
+	q := data[start]
+
+	var sb strings.Builder
+
+	for p := start + 1; p < len(data); p++ {
+		c := data[p]
+
+		switch {
+		case c == q:
+			return p + 1, sb.String(), nil
+
+		case c == '\\' && q == '"' && p+1 < len(data):
+			p++
+
+			switch data[p] {
+			case 'n':
+				sb.WriteByte('\n')
+
+			case 'r':
+				sb.WriteByte('\r')
+
+			case 't':
+				sb.WriteByte('\t')
+
+			case '\\', '"', '$':
+				sb.WriteByte(data[p])
+
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(data[p])
+			}
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return 0, "", fmt.Errorf("%w: unterminated quoted value", ErrInvalidSyntax)
+}
+
+func skipSpaces(data []byte, p int) int {
+	for p < len(data) && (data[p] == ' ' || data[p] == '\t') {
+		p++
+	}
+
+	return p
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+// quote returns the value for a .env file. The quotes of the previous value are kept if possible, the values that could
+// be misread by a parser or a shell are quoted.
+func quote(value string, q byte) string {
+	if q == 0 && strings.IndexFunc(value, func(r rune) bool { return !isSafeRune(r) }) < 0 {
+		return value
+	}
+
+	if q != '"' && !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+
+	return `"` + r.Replace(value) + `"`
+}
+
+func isSafeRune(r rune) bool {
+	return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("_-./:@%+,=", r)
+}
@@ -0,0 +1,422 @@
+package otp
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+)
+
+const (
+	encryptedTOTPSecretPrefix = "enc:v1:"
+	dataKeySize               = 32
+)
+
+// ErrInvalidEncryptedTOTPSecret indicates that the encrypted TOTP secret is malformed or could not be decrypted.
+var ErrInvalidEncryptedTOTPSecret = errors.New("invalid encrypted totp secret")
+
+// ErrPlaintextTOTPSecret indicates that the TOTP secret in the underlying provider is not encrypted.
+var ErrPlaintextTOTPSecret = errors.New("totp secret is not encrypted")
+
+// ErrUnknownKeyID indicates that the key-encryption key is unknown to the key wrapper.
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// KeyWrapper wraps and unwraps the data keys that encrypt the TOTP secrets with a key-encryption key.
+//
+// A key wrapper that also reports the id of its current key-encryption key with a PrimaryKeyID() string method, such as
+// LocalKeyWrapper, saves the wrapping of a data key when a TOTP secret that is already encrypted with it is
+// re-encrypted.
+type KeyWrapper interface {
+	// WrapKey wraps the data key with the current key-encryption key and returns its id.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey unwraps the data key with the key-encryption key of the given id.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// EncryptTOTPSecret encrypts the TOTP secret with a random data key that is wrapped by the key wrapper. The result
+// embeds the id of the key-encryption key so that it can be decrypted after a key rotation.
+func EncryptTOTPSecret(ctx context.Context, kw KeyWrapper, secret TOTPSecret) (TOTPSecret, error) {
+	dataKey := make([]byte, dataKeySize)
+
+	if _, err := rand.Read(dataKey); err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not encrypt totp secret: %w", err)
+	}
+
+	keyID, wrapped, err := kw.WrapKey(ctx, dataKey)
+	if err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not encrypt totp secret: %w", err)
+	}
+
+	if strings.Contains(keyID, ":") {
+		return NoTOTPSecret, fmt.Errorf("could not encrypt totp secret: invalid key id %q", keyID)
+	}
+
+	ciphertext, err := seal(dataKey, []byte(secret), []byte(keyID))
+	if err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not encrypt totp secret: %w", err)
+	}
+
+	return TOTPSecret(encryptedTOTPSecretPrefix + keyID +
+		":" + base64.RawStdEncoding.EncodeToString(wrapped) +
+		":" + base64.RawStdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// DecryptTOTPSecret decrypts a TOTP secret that is encrypted by EncryptTOTPSecret.
+func DecryptTOTPSecret(ctx context.Context, kw KeyWrapper, secret TOTPSecret) (TOTPSecret, error) {
+	_, plaintext, err := decryptTOTPSecret(ctx, kw, secret)
+
+	return plaintext, err
+}
+
+// IsEncryptedTOTPSecret returns true if the TOTP secret is encrypted by EncryptTOTPSecret.
+func IsEncryptedTOTPSecret(secret TOTPSecret) bool {
+	return strings.HasPrefix(string(secret), encryptedTOTPSecretPrefix)
+}
+
+func decryptTOTPSecret(ctx context.Context, kw KeyWrapper, secret TOTPSecret) (string, TOTPSecret, error) {
+	if !IsEncryptedTOTPSecret(secret) {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w", ErrInvalidEncryptedTOTPSecret)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(string(secret), encryptedTOTPSecretPrefix), ":")
+	if len(parts) != 3 {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w", ErrInvalidEncryptedTOTPSecret)
+	}
+
+	keyID := parts[0]
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w: %w", ErrInvalidEncryptedTOTPSecret, err)
+	}
+
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w: %w", ErrInvalidEncryptedTOTPSecret, err)
+	}
+
+	dataKey, err := kw.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w", err)
+	}
+
+	plaintext, err := open(dataKey, ciphertext, []byte(keyID))
+	if err != nil {
+		return "", NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w: %w", ErrInvalidEncryptedTOTPSecret, err)
+	}
+
+	return keyID, TOTPSecret(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return cipher.NewGCM(block) //nolint: wrapcheck
+}
+
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidEncryptedTOTPSecret
+	}
+
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], additionalData) //nolint: wrapcheck
+}
+
+var _ TOTPSecretProvider = (*EncryptedTOTPSecretProvider)(nil)
+
+// EncryptedTOTPSecretProvider is a TOTP secret provider that encrypts the TOTP secret before persisting it to the
+// underlying provider, and decrypts it after reading it back.
+//
+// Values that are not encrypted, such as the ones that existed before the provider was wrapped, are rejected unless
+// WithAllowPlaintext is set. Use Reencrypt to encrypt them.
+type EncryptedTOTPSecretProvider struct {
+	provider       TOTPSecretProvider
+	keyWrapper     KeyWrapper
+	allowPlaintext bool
+	logger         ctxd.Logger
+}
+
+// TOTPSecret returns the decrypted TOTP secret. It returns NoTOTPSecret if the secret could not be decrypted, see Load
+// for the error.
+func (p *EncryptedTOTPSecretProvider) TOTPSecret(ctx context.Context) TOTPSecret {
+	s, err := p.Load(ctx)
+	if err != nil {
+		p.logger.Error(ctx, "could not get totp secret from encrypted provider", "error", err)
+	}
+
+	return s
+}
+
+// Load returns the decrypted TOTP secret, or NoTOTPSecret if the underlying provider has none. It returns
+// ErrPlaintextTOTPSecret if the secret is not encrypted and WithAllowPlaintext is not set.
+func (p *EncryptedTOTPSecretProvider) Load(ctx context.Context) (TOTPSecret, error) {
+	s := p.provider.TOTPSecret(ctx)
+
+	switch {
+	case s == NoTOTPSecret:
+		return NoTOTPSecret, nil
+
+	case !IsEncryptedTOTPSecret(s):
+		if p.allowPlaintext {
+			return s, nil
+		}
+
+		return NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w", ErrPlaintextTOTPSecret)
+	}
+
+	return DecryptTOTPSecret(ctx, p.keyWrapper, s)
+}
+
+// SetTOTPSecret encrypts the TOTP secret and persists it to the underlying provider.
+func (p *EncryptedTOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret TOTPSecret, issuer string) error {
+	encrypted, err := EncryptTOTPSecret(ctx, p.keyWrapper, secret)
+	if err != nil {
+		return err
+	}
+
+	return p.provider.SetTOTPSecret(ctx, encrypted, issuer)
+}
+
+// DeleteTOTPSecret deletes the TOTP secret in the underlying provider.
+func (p *EncryptedTOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	return p.provider.DeleteTOTPSecret(ctx)
+}
+
+// Reencrypt encrypts the existing TOTP secret with the current key-encryption key if it is not encrypted or if it is
+// encrypted with an older one. It returns true if the secret is rewritten. It is meant for migrations, so it encrypts
+// the plaintext secrets even if WithAllowPlaintext is not set.
+func (p *EncryptedTOTPSecretProvider) Reencrypt(ctx context.Context, issuer string) (bool, error) {
+	s := p.provider.TOTPSecret(ctx)
+	if s == NoTOTPSecret {
+		return false, nil
+	}
+
+	var keyID string
+
+	if IsEncryptedTOTPSecret(s) {
+		if r, ok := p.keyWrapper.(primaryKeyIDReporter); ok && encryptedTOTPSecretKeyID(s) == r.PrimaryKeyID() {
+			return false, nil
+		}
+
+		var err error
+
+		if keyID, s, err = decryptTOTPSecret(ctx, p.keyWrapper, s); err != nil {
+			return false, err
+		}
+	}
+
+	encrypted, err := EncryptTOTPSecret(ctx, p.keyWrapper, s)
+	if err != nil {
+		return false, err
+	}
+
+	if keyID != "" && keyID == encryptedTOTPSecretKeyID(encrypted) {
+		return false, nil
+	}
+
+	if err := p.provider.SetTOTPSecret(ctx, encrypted, issuer); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// primaryKeyIDReporter is a key wrapper that reports the id of its current key-encryption key.
+type primaryKeyIDReporter interface {
+	PrimaryKeyID() string
+}
+
+func encryptedTOTPSecretKeyID(s TOTPSecret) string {
+	keyID, _, _ := strings.Cut(strings.TrimPrefix(string(s), encryptedTOTPSecretPrefix), ":")
+
+	return keyID
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (p *EncryptedTOTPSecretProvider) TOTPSecretGetter() TOTPSecretGetter {
+	return p
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (p *EncryptedTOTPSecretProvider) TOTPSecretSetter() TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (p *EncryptedTOTPSecretProvider) TOTPSecretDeleter() TOTPSecretDeleter {
+	return p
+}
+
+// EncryptTOTPSecretProvider wraps the TOTP secret provider so that the TOTP secret is encrypted at rest.
+func EncryptTOTPSecretProvider(
+	p TOTPSecretProvider,
+	kw KeyWrapper,
+	opts ...EncryptedTOTPSecretProviderOption,
+) *EncryptedTOTPSecretProvider {
+	ep := &EncryptedTOTPSecretProvider{
+		provider:   p,
+		keyWrapper: kw,
+		logger:     ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyEncryptedTOTPSecretProviderOption(ep)
+	}
+
+	return ep
+}
+
+// EncryptedTOTPSecretProviderOption is an option to configure EncryptedTOTPSecretProvider.
+type EncryptedTOTPSecretProviderOption interface {
+	applyEncryptedTOTPSecretProviderOption(p *EncryptedTOTPSecretProvider)
+}
+
+type encryptedTOTPSecretProviderOptionFunc func(p *EncryptedTOTPSecretProvider)
+
+func (f encryptedTOTPSecretProviderOptionFunc) applyEncryptedTOTPSecretProviderOption(p *EncryptedTOTPSecretProvider) {
+	f(p)
+}
+
+// WithAllowPlaintext returns the TOTP secrets that are not encrypted as is, instead of rejecting them. Use it only
+// while migrating the existing secrets, a downgraded or tampered store goes unnoticed otherwise.
+func WithAllowPlaintext() EncryptedTOTPSecretProviderOption {
+	return encryptedTOTPSecretProviderOptionFunc(func(p *EncryptedTOTPSecretProvider) {
+		p.allowPlaintext = true
+	})
+}
+
+var _ KeyWrapper = (*LocalKeyWrapper)(nil)
+
+// LocalKeyWrapper is a KeyWrapper that uses local AES key-encryption keys.
+//
+// New values are always wrapped with the primary key, while the other keys are kept to unwrap the values that were
+// encrypted before a rotation.
+type LocalKeyWrapper struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string][]byte
+}
+
+// WrapKey wraps the data key with the primary key.
+func (w *LocalKeyWrapper) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	wrapped, err := seal(w.keys[w.primary], dataKey, []byte(w.primary))
+	if err != nil {
+		return "", nil, fmt.Errorf("could not wrap key: %w", err)
+	}
+
+	return w.primary, wrapped, nil
+}
+
+// UnwrapKey unwraps the data key with the key of the given id.
+func (w *LocalKeyWrapper) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	key, ok := w.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("could not unwrap key: %w: %s", ErrUnknownKeyID, keyID)
+	}
+
+	dataKey, err := open(key, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// AddKey adds a key that is only used to unwrap the existing values.
+func (w *LocalKeyWrapper) AddKey(keyID string, key []byte) error {
+	if err := validateLocalKey(keyID, key); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keys[keyID] = bytes.Clone(key)
+
+	return nil
+}
+
+// Rotate adds a key and makes it the primary one. The previous keys are kept to unwrap the existing values.
+func (w *LocalKeyWrapper) Rotate(keyID string, key []byte) error {
+	if err := validateLocalKey(keyID, key); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keys[keyID] = bytes.Clone(key)
+	w.primary = keyID
+
+	return nil
+}
+
+// PrimaryKeyID returns the id of the primary key.
+func (w *LocalKeyWrapper) PrimaryKeyID() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.primary
+}
+
+func validateLocalKey(keyID string, key []byte) error {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return fmt.Errorf("invalid key id %q", keyID) //nolint: err113
+	}
+
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("invalid key %q: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// NewLocalKeyWrapper initiates a new LocalKeyWrapper with a primary key. The key must be 16, 24 or 32 bytes long.
+func NewLocalKeyWrapper(keyID string, key []byte) (*LocalKeyWrapper, error) {
+	w := &LocalKeyWrapper{
+		keys: make(map[string][]byte),
+	}
+
+	if err := w.Rotate(keyID, key); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
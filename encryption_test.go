@@ -0,0 +1,390 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+func newLocalKeyWrapper(t *testing.T) *otp.LocalKeyWrapper {
+	t.Helper()
+
+	w, err := otp.NewLocalKeyWrapper("k1", []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	return w
+}
+
+func TestEncryptTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	w := newLocalKeyWrapper(t)
+
+	encrypted, err := otp.EncryptTOTPSecret(ctx, w, "NBSWY3DP")
+	require.NoError(t, err)
+
+	assert.True(t, otp.IsEncryptedTOTPSecret(encrypted))
+	assert.True(t, strings.HasPrefix(string(encrypted), "enc:v1:k1:"))
+	assert.NotContains(t, string(encrypted), "NBSWY3DP")
+
+	decrypted, err := otp.DecryptTOTPSecret(ctx, w, encrypted)
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), decrypted)
+
+	// Each encryption uses a different data key.
+	other, err := otp.EncryptTOTPSecret(ctx, w, "NBSWY3DP")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, encrypted, other)
+}
+
+func TestEncryptTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		mockKeyWrapper mock.KeyWrapperMocker
+		expectedError  string
+	}{
+		{
+			scenario: "could not wrap key",
+			mockKeyWrapper: mock.MockKeyWrapper(func(w *mock.KeyWrapper) {
+				w.On("WrapKey", mock.Anything, mock.Anything).
+					Return("", nil, assert.AnError)
+			}),
+			expectedError: "could not encrypt totp secret: assert.AnError general error for testing",
+		},
+		{
+			scenario: "invalid key id",
+			mockKeyWrapper: mock.MockKeyWrapper(func(w *mock.KeyWrapper) {
+				w.On("WrapKey", mock.Anything, mock.Anything).
+					Return("k:1", []byte("wrapped"), nil)
+			}),
+			expectedError: `could not encrypt totp secret: invalid key id "k:1"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := otp.EncryptTOTPSecret(context.Background(), tc.mockKeyWrapper(t), "NBSWY3DP")
+
+			require.EqualError(t, err, tc.expectedError)
+			assert.Equal(t, otp.NoTOTPSecret, actual)
+		})
+	}
+}
+
+func TestDecryptTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	w := newLocalKeyWrapper(t)
+
+	encrypted, err := otp.EncryptTOTPSecret(ctx, w, "NBSWY3DP")
+	require.NoError(t, err)
+
+	parts := strings.Split(string(encrypted), ":")
+
+	// Flip a bit of the ciphertext, so that it is still well-formed but fails the authentication.
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[4])
+	require.NoError(t, err)
+
+	ciphertext[len(ciphertext)-1] ^= 0x01
+	tampered := base64.RawStdEncoding.EncodeToString(ciphertext)
+
+	testCases := []struct {
+		scenario      string
+		secret        otp.TOTPSecret
+		expectedError error
+	}{
+		{
+			scenario:      "not encrypted",
+			secret:        "NBSWY3DP",
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "missing parts",
+			secret:        "enc:v1:k1:abc",
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "invalid wrapped key",
+			secret:        otp.TOTPSecret(strings.Join([]string{parts[0], parts[1], parts[2], "!", parts[4]}, ":")),
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "invalid ciphertext",
+			secret:        otp.TOTPSecret(strings.Join([]string{parts[0], parts[1], parts[2], parts[3], "!"}, ":")),
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "short ciphertext",
+			secret:        otp.TOTPSecret(strings.Join([]string{parts[0], parts[1], parts[2], parts[3], "YWJj"}, ":")),
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "tampered ciphertext",
+			secret:        otp.TOTPSecret(strings.Join([]string{parts[0], parts[1], parts[2], parts[3], tampered}, ":")),
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "unknown key id",
+			secret:        otp.TOTPSecret(strings.Join([]string{parts[0], parts[1], "k2", parts[3], parts[4]}, ":")),
+			expectedError: otp.ErrUnknownKeyID,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := otp.DecryptTOTPSecret(ctx, w, tc.secret)
+
+			require.ErrorIs(t, err, tc.expectedError)
+			assert.Equal(t, otp.NoTOTPSecret, actual)
+		})
+	}
+}
+
+func TestEncryptedTOTPSecretProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	w := newLocalKeyWrapper(t)
+	p := otp.EncryptTOTPSecretProvider(storage, w)
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", "issuer"))
+
+	assert.True(t, otp.IsEncryptedTOTPSecret(storage.TOTPSecret(ctx)))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, storage.TOTPSecret(ctx))
+}
+
+func TestEncryptedTOTPSecretProvider_Load_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testCases := []struct {
+		scenario       string
+		stored         otp.TOTPSecret
+		options        []otp.EncryptedTOTPSecretProviderOption
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:      "unknown key",
+			stored:        "enc:v1:k2:abc:def",
+			expectedError: otp.ErrUnknownKeyID,
+		},
+		{
+			scenario:      "malformed",
+			stored:        "enc:v1:k1",
+			expectedError: otp.ErrInvalidEncryptedTOTPSecret,
+		},
+		{
+			scenario:      "plaintext",
+			stored:        "NBSWY3DP",
+			expectedError: otp.ErrPlaintextTOTPSecret,
+		},
+		{
+			scenario:       "plaintext is allowed",
+			stored:         "NBSWY3DP",
+			options:        []otp.EncryptedTOTPSecretProviderOption{otp.WithAllowPlaintext()},
+			expectedResult: "NBSWY3DP",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			l := &ctxd.LoggerMock{}
+			opts := append([]otp.EncryptedTOTPSecretProviderOption{otp.WithLogger(l)}, tc.options...)
+			p := otp.EncryptTOTPSecretProvider(otp.ChainTOTPSecretProviders(tc.stored), newLocalKeyWrapper(t), opts...)
+
+			actual, err := p.Load(ctx)
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.expectedResult, p.TOTPSecret(ctx))
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+				assert.Empty(t, l.LoggedEntries)
+
+				return
+			}
+
+			require.ErrorIs(t, err, tc.expectedError)
+			require.Len(t, l.LoggedEntries, 1)
+			assert.Equal(t, "error", l.LoggedEntries[0].Level)
+			assert.Equal(t, "could not get totp secret from encrypted provider", l.LoggedEntries[0].Message)
+		})
+	}
+}
+
+func TestEncryptedTOTPSecretProvider_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	w := mock.MockKeyWrapper(func(w *mock.KeyWrapper) {
+		w.On("WrapKey", mock.Anything, mock.Anything).
+			Return("", nil, assert.AnError)
+	})(t)
+
+	p := otp.EncryptTOTPSecretProvider(mock.NopTOTPSecretProvider(t), w)
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	require.ErrorIs(t, err, assert.AnError)
+}
+
+func TestEncryptedTOTPSecretProvider_Reencrypt(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	w := newLocalKeyWrapper(t)
+	p := otp.EncryptTOTPSecretProvider(storage, w)
+
+	// No secret.
+	changed, err := p.Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Plaintext.
+	require.NoError(t, storage.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	changed, err = p.Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(string(storage.TOTPSecret(ctx)), "enc:v1:k1:"))
+
+	// Up to date.
+	before := storage.TOTPSecret(ctx)
+
+	changed, err = p.Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, before, storage.TOTPSecret(ctx))
+
+	// Rotated.
+	require.NoError(t, w.Rotate("k2", []byte("fedcba9876543210")))
+	assert.Equal(t, "k2", w.PrimaryKeyID())
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+	changed, err = p.Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(string(storage.TOTPSecret(ctx)), "enc:v1:k2:"))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+	// Old key is no longer available.
+	w2, err := otp.NewLocalKeyWrapper("k3", []byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	_, err = otp.EncryptTOTPSecretProvider(storage, w2).Reencrypt(ctx, "")
+	require.ErrorIs(t, err, otp.ErrUnknownKeyID)
+
+	require.NoError(t, w2.AddKey("k2", []byte("fedcba9876543210")))
+
+	changed, err = otp.EncryptTOTPSecretProvider(storage, w2).Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
+
+// primaryKeyWrapper is a key wrapper that reports the id of its current key-encryption key.
+type primaryKeyWrapper struct {
+	otp.KeyWrapper
+
+	primary string
+}
+
+func (w primaryKeyWrapper) PrimaryKeyID() string {
+	return w.primary
+}
+
+func TestEncryptedTOTPSecretProvider_Reencrypt_PrimaryKeyID(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	encrypted, err := otp.EncryptTOTPSecret(ctx, newLocalKeyWrapper(t), "NBSWY3DP")
+	require.NoError(t, err)
+
+	// The TOTP secret is already encrypted with the current key, so no data key is wrapped.
+	w := primaryKeyWrapper{KeyWrapper: mock.MockKeyWrapper()(t), primary: "k1"}
+
+	changed, err := otp.EncryptTOTPSecretProvider(otp.ChainTOTPSecretProviders(encrypted), w).Reencrypt(ctx, "")
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestEncryptedTOTPSecretProvider_Reencrypt_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	p := otp.EncryptTOTPSecretProvider(mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+		p.On("SetTOTPSecret", ctx, mock.Anything, "issuer").Return(assert.AnError)
+	})(t), newLocalKeyWrapper(t))
+
+	changed, err := p.Reencrypt(ctx, "issuer")
+	require.ErrorIs(t, err, assert.AnError)
+	assert.False(t, changed)
+
+	w := mock.MockKeyWrapper(func(w *mock.KeyWrapper) {
+		w.On("WrapKey", mock.Anything, mock.Anything).
+			Return("", nil, assert.AnError)
+	})(t)
+
+	p = otp.EncryptTOTPSecretProvider(otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP")), w)
+
+	changed, err = p.Reencrypt(ctx, "")
+	require.ErrorIs(t, err, assert.AnError)
+	assert.False(t, changed)
+}
+
+func TestLocalKeyWrapper_InvalidKey(t *testing.T) {
+	t.Parallel()
+
+	_, err := otp.NewLocalKeyWrapper("", []byte("0123456789abcdef"))
+	require.EqualError(t, err, `invalid key id ""`)
+
+	_, err = otp.NewLocalKeyWrapper("k:1", []byte("0123456789abcdef"))
+	require.EqualError(t, err, `invalid key id "k:1"`)
+
+	_, err = otp.NewLocalKeyWrapper("k1", []byte("short"))
+	require.EqualError(t, err, `invalid key "k1": crypto/aes: invalid key size 5`)
+
+	w := newLocalKeyWrapper(t)
+
+	require.Error(t, w.AddKey("k2", []byte("short")))
+	require.Error(t, w.Rotate("k2", []byte("short")))
+}
@@ -0,0 +1,109 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvAccountPrefix is the default prefix of the environment variables of EnvAccounts.
+const DefaultEnvAccountPrefix = "OTP_SECRET_"
+
+// ErrInvalidAccount indicates that the account has no letter or digit to name an environment variable after.
+var ErrInvalidAccount = errors.New("invalid account")
+
+// EnvAccounts serves the TOTP secrets of many accounts from the environment. The variable of an account is the prefix
+// followed by the normalized account, such as OTP_SECRET_JOHN_DOE_EXAMPLE_COM for "john.doe@example.com". The variable
+// may hold a bare secret or an otpauth URI, the digits, period and algorithm of the URI are honored by TOTPGenerator
+// and TOTPVerifier.
+type EnvAccounts struct {
+	prefix string
+}
+
+// Prefix returns the prefix of the environment variables.
+func (e EnvAccounts) Prefix() string {
+	return e.prefix
+}
+
+// Env returns the environment variable of the account. It returns ErrInvalidAccount if the account has no letter or
+// digit.
+func (e EnvAccounts) Env(account string) (string, error) {
+	name := NormalizeEnvAccount(account)
+	if name == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAccount, account)
+	}
+
+	return e.prefix + name, nil
+}
+
+// TOTPSecretProvider returns the TOTP secret provider of the account. It returns ErrInvalidAccount if the account has
+// no letter or digit.
+func (e EnvAccounts) TOTPSecretProvider(account string) (EnvTOTPSecret, error) {
+	env, err := e.Env(account)
+	if err != nil {
+		return EnvTOTPSecret{}, err
+	}
+
+	return TOTPSecretFromEnv(env), nil
+}
+
+// Accounts returns the normalized accounts that have a non-empty variable in the environment, sorted by name. The
+// variables that are not in the normalized form, such as OTP_SECRET_john, are ignored because no account maps to
+// them.
+func (e EnvAccounts) Accounts() []string {
+	var accounts []string
+
+	for _, kv := range os.Environ() {
+		env, value, _ := strings.Cut(kv, "=")
+
+		name, ok := strings.CutPrefix(env, e.prefix)
+		if !ok || value == "" || name == "" || name != NormalizeEnvAccount(name) {
+			continue
+		}
+
+		accounts = append(accounts, name)
+	}
+
+	sort.Strings(accounts)
+
+	return accounts
+}
+
+// TOTPSecretsFromEnv returns the TOTP secrets of the accounts from the environment variables that have the prefix,
+// such as DefaultEnvAccountPrefix.
+func TOTPSecretsFromEnv(prefix string) EnvAccounts {
+	return EnvAccounts{
+		prefix: prefix,
+	}
+}
+
+// NormalizeEnvAccount normalizes an account to be a part of an environment variable. The letters are upper-cased, the
+// runs of other characters than ASCII letters and digits are replaced by an underscore, and the leading and trailing
+// underscores are removed. For example, "john.doe@example.com" becomes "JOHN_DOE_EXAMPLE_COM".
+func NormalizeEnvAccount(account string) string {
+	var sb strings.Builder
+
+	sb.Grow(len(account))
+
+	separate := false
+
+	for _, r := range strings.ToUpper(account) {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			separate = sb.Len() > 0
+
+			continue
+		}
+
+		if separate {
+			sb.WriteByte('_')
+
+			separate = false
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+func TestNormalizeEnvAccount(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		account  string
+		expected string
+	}{
+		{account: "", expected: ""},
+		{account: "github", expected: "GITHUB"},
+		{account: "john.doe@example.com", expected: "JOHN_DOE_EXAMPLE_COM"},
+		{account: "  --Acme  Corp--  ", expected: "ACME_CORP"},
+		{account: "JOHN_DOE_EXAMPLE_COM", expected: "JOHN_DOE_EXAMPLE_COM"},
+		{account: "café 2", expected: "CAF_2"},
+		{account: "@#!", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, otp.NormalizeEnvAccount(tc.account), tc.account)
+	}
+}
+
+func TestEnvAccounts_TOTPSecretProvider(t *testing.T) { //nolint: paralleltest
+	t.Setenv("TEST_OTP_SECRET_JOHN_DOE_EXAMPLE_COM", "NBSWY3DP")
+	t.Setenv("TEST_OTP_SECRET_JANE_DOE_EXAMPLE_COM", "")
+
+	ctx := context.Background()
+	e := otp.TOTPSecretsFromEnv("TEST_OTP_SECRET_")
+
+	assert.Equal(t, "TEST_OTP_SECRET_", e.Prefix())
+
+	p, err := e.TOTPSecretProvider("john.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "TEST_OTP_SECRET_JOHN_DOE_EXAMPLE_COM", p.Env())
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+	p, err = e.TOTPSecretProvider("jane.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	require.NoError(t, p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+
+	p, err = e.TOTPSecretProvider("@#!")
+	require.ErrorIs(t, err, otp.ErrInvalidAccount)
+	require.EqualError(t, err, `invalid account: "@#!"`)
+
+	assert.Equal(t, otp.EnvTOTPSecret{}, p)
+}
+
+func TestEnvAccounts_TOTPSecretProvider_URI(t *testing.T) { //nolint: paralleltest
+	t.Setenv("TEST_OTP_SECRET_GITHUB", "otpauth://totp/GitHub:john?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8&period=60")
+
+	p, err := otp.TOTPSecretsFromEnv("TEST_OTP_SECRET_").TOTPSecretProvider("github")
+	require.NoError(t, err)
+
+	c := clock.Fix(time.Unix(119, 0))
+
+	result, err := otp.GenerateTOTP(context.Background(), p, otp.WithClock(c))
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.OTP("94287082"), result)
+
+	require.NoError(t, otp.VerifyTOTP(context.Background(), p, result, otp.WithClock(c)))
+}
+
+func TestEnvAccounts_Accounts(t *testing.T) { //nolint: paralleltest
+	t.Setenv("TEST_OTP_SECRET_JOHN_DOE_EXAMPLE_COM", "NBSWY3DP")
+	t.Setenv("TEST_OTP_SECRET_GITHUB", "otpauth://totp/GitHub:john?secret=NBSWY3DP")
+	t.Setenv("TEST_OTP_SECRET_EMPTY", "")
+	t.Setenv("TEST_OTP_SECRET_lower", "NBSWY3DP")
+	t.Setenv("TEST_OTP_SECRET__LEADING", "NBSWY3DP")
+	t.Setenv("TEST_OTP_SECRET_", "NBSWY3DP")
+	t.Setenv("OTHER_SECRET_GITLAB", "NBSWY3DP")
+
+	e := otp.TOTPSecretsFromEnv("TEST_OTP_SECRET_")
+
+	assert.Equal(t, []string{"GITHUB", "JOHN_DOE_EXAMPLE_COM"}, e.Accounts())
+
+	for _, account := range e.Accounts() {
+		p, err := e.TOTPSecretProvider(account)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+	}
+
+	assert.Empty(t, otp.TOTPSecretsFromEnv("TEST_NO_SUCH_PREFIX_").Accounts())
+}
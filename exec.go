@@ -0,0 +1,288 @@
+package otp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// ErrCommandFailed indicates that the command of an ExecTOTPSecretProvider exited with a non-zero code.
+var ErrCommandFailed = errors.New("command failed")
+
+// DefaultExecTimeout is the default timeout of the commands of an ExecTOTPSecretProvider.
+const DefaultExecTimeout = 30 * time.Second
+
+// Command is a command that is run by an ExecTOTPSecretProvider. The environment variables are added to the ones of
+// the current process.
+type Command struct {
+	Name string
+	Args []string
+	Env  []string
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// CommandError is returned when a command exits with a non-zero code. It wraps ErrCommandFailed and the error that
+// the exit code is mapped to, if any.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+// Error returns the error message.
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%s: %q exited with code %d", ErrCommandFailed, e.Command, e.ExitCode)
+
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+
+	return msg
+}
+
+// Unwrap returns ErrCommandFailed and the mapped error.
+func (e *CommandError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrCommandFailed}
+	}
+
+	return []error{ErrCommandFailed, e.Err}
+}
+
+var _ TOTPSecretProvider = (*ExecTOTPSecretProvider)(nil)
+
+// ExecTOTPSecretProvider is a TOTP secret provider that runs the command line interface of a password manager, such as
+// "op read" or "pass otp uri". The get command prints the TOTP secret, or an otpauth URI, to stdout. The commands that
+// print the current code instead, such as "bw get totp" or "op read" with "?attribute=otp", cannot be used. The set
+// command reads the TOTP secret from stdin, the issuer is in the OTP_ISSUER environment variable. The secret is never
+// passed as an argument.
+//
+// For example, with 1Password, the one-time password field holds the otpauth URI:
+//
+//	otp.NewExecTOTPSecretProvider(otp.Command{
+//		Name: "op",
+//		Args: []string{"read", "op://Private/GitHub/one-time password"},
+//	})
+//
+// With Bitwarden, the TOTP secret is in the item:
+//
+//	otp.NewExecTOTPSecretProvider(otp.Command{
+//		Name: "sh",
+//		Args: []string{"-c", "bw get item GitHub | jq -r .login.totp"},
+//	})
+type ExecTOTPSecretProvider struct {
+	get    Command
+	set    *Command
+	delete *Command
+
+	exitCodes map[int]error
+	timeout   time.Duration
+	logger    ctxd.Logger
+}
+
+// TOTPSecret returns the TOTP secret from the get command. See Load for the error.
+func (p *ExecTOTPSecretProvider) TOTPSecret(ctx context.Context) TOTPSecret {
+	s, err := p.Load(ctx)
+
+	switch {
+	case errors.Is(err, ErrNoTOTPSecret):
+		p.logger.Debug(ctx, "no totp secret from command", "error", err, "command", p.get.Name)
+
+	case err != nil:
+		p.logger.Error(ctx, "could not get totp secret from command", "error", err, "command", p.get.Name)
+	}
+
+	return s
+}
+
+// Load runs the get command and returns the TOTP secret. It returns a CommandError if the command exits with a
+// non-zero code, or ErrNoTOTPSecret if the command prints nothing.
+func (p *ExecTOTPSecretProvider) Load(ctx context.Context) (TOTPSecret, error) {
+	out, err := p.run(ctx, p.get, nil, nil)
+	defer zero.Bytes(out)
+
+	if err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not get totp secret: %w", err)
+	}
+
+	s, err := ParseTOTPSecret(string(out))
+	if err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not get totp secret: %w", err)
+	}
+
+	if s == NoTOTPSecret {
+		return NoTOTPSecret, fmt.Errorf("could not get totp secret: %w", ErrNoTOTPSecret)
+	}
+
+	return s, nil
+}
+
+// SetTOTPSecret runs the set command with the TOTP secret on stdin. It returns ErrTOTPSecretReadOnly if there is no
+// set command.
+func (p *ExecTOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret TOTPSecret, issuer string) error {
+	if p.set == nil {
+		return ErrTOTPSecretReadOnly
+	}
+
+	stdin := []byte(secret.Reveal() + "\n")
+	defer zero.Bytes(stdin)
+
+	if _, err := p.run(ctx, *p.set, stdin, []string{"OTP_ISSUER=" + issuer}); err != nil {
+		p.logger.Error(ctx, "could not set totp secret with command", "error", err, "command", p.set.Name)
+
+		return fmt.Errorf("could not set totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret runs the delete command. It returns ErrTOTPSecretReadOnly if there is no delete command.
+func (p *ExecTOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	if p.delete == nil {
+		return ErrTOTPSecretReadOnly
+	}
+
+	if _, err := p.run(ctx, *p.delete, nil, nil); err != nil {
+		p.logger.Error(ctx, "could not delete totp secret with command", "error", err, "command", p.delete.Name)
+
+		return fmt.Errorf("could not delete totp secret: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ExecTOTPSecretProvider) run(ctx context.Context, c Command, stdin []byte, env []string) ([]byte, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...) //nolint: gosec
+	cmd.Env = append(append(os.Environ(), c.Env...), env...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	err := cmd.Run()
+	out := stdout.Bytes()
+
+	if ctx.Err() != nil {
+		zero.Bytes(out)
+
+		return nil, fmt.Errorf("%q: %w", c.String(), ctx.Err())
+	}
+
+	var exitErr *exec.ExitError
+
+	switch {
+	case errors.As(err, &exitErr):
+		zero.Bytes(out)
+
+		return nil, &CommandError{
+			Command:  c.String(),
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   strings.TrimSpace(stderr.String()),
+			Err:      p.exitCodes[exitErr.ExitCode()],
+		}
+
+	case err != nil:
+		zero.Bytes(out)
+
+		return nil, fmt.Errorf("%q: %w", c.String(), err)
+	}
+
+	return out, nil
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (p *ExecTOTPSecretProvider) TOTPSecretGetter() TOTPSecretGetter {
+	return p
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (p *ExecTOTPSecretProvider) TOTPSecretSetter() TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (p *ExecTOTPSecretProvider) TOTPSecretDeleter() TOTPSecretDeleter {
+	return p
+}
+
+// NewExecTOTPSecretProvider initiates a new ExecTOTPSecretProvider with the get command. By default, it is read-only
+// and the commands time out after DefaultExecTimeout.
+func NewExecTOTPSecretProvider(get Command, opts ...ExecTOTPSecretProviderOption) *ExecTOTPSecretProvider {
+	p := &ExecTOTPSecretProvider{
+		get:       get,
+		exitCodes: make(map[int]error),
+		timeout:   DefaultExecTimeout,
+		logger:    ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyExecTOTPSecretProviderOption(p)
+	}
+
+	return p
+}
+
+// ExecTOTPSecretProviderOption is an option to configure ExecTOTPSecretProvider.
+type ExecTOTPSecretProviderOption interface {
+	applyExecTOTPSecretProviderOption(p *ExecTOTPSecretProvider)
+}
+
+type execTOTPSecretProviderOptionFunc func(p *ExecTOTPSecretProvider)
+
+func (f execTOTPSecretProviderOptionFunc) applyExecTOTPSecretProviderOption(p *ExecTOTPSecretProvider) {
+	f(p)
+}
+
+// WithSetCommand sets the command that persists the TOTP secret. The command reads the secret from stdin.
+func WithSetCommand(c Command) ExecTOTPSecretProviderOption {
+	return execTOTPSecretProviderOptionFunc(func(p *ExecTOTPSecretProvider) {
+		p.set = &c
+	})
+}
+
+// WithDeleteCommand sets the command that deletes the TOTP secret.
+func WithDeleteCommand(c Command) ExecTOTPSecretProviderOption {
+	return execTOTPSecretProviderOptionFunc(func(p *ExecTOTPSecretProvider) {
+		p.delete = &c
+	})
+}
+
+// WithExitCode maps an exit code of the commands to an error, for example, the code that a password manager returns
+// when the item is not found to ErrNoTOTPSecret.
+func WithExitCode(code int, err error) ExecTOTPSecretProviderOption {
+	return execTOTPSecretProviderOptionFunc(func(p *ExecTOTPSecretProvider) {
+		p.exitCodes[code] = err
+	})
+}
+
+// WithExecTimeout sets the timeout of the commands. Zero means no timeout other than the one of the context.
+func WithExecTimeout(d time.Duration) ExecTOTPSecretProviderOption {
+	return execTOTPSecretProviderOptionFunc(func(p *ExecTOTPSecretProvider) {
+		p.timeout = d
+	})
+}
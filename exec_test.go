@@ -0,0 +1,203 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+func shell(t *testing.T, script string, env ...string) otp.Command {
+	t.Helper()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	return otp.Command{Name: "sh", Args: []string{"-c", script}, Env: env}
+}
+
+func TestExecTOTPSecretProvider_Load(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		script         string
+		env            []string
+		opts           []otp.ExecTOTPSecretProviderOption
+		expectedResult otp.TOTPSecret
+		expectedError  string
+		expectedIs     []error
+	}{
+		{
+			scenario:       "bare secret",
+			script:         `echo "  NBSWY3DP  "`,
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			script:         `echo "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example"`,
+			expectedResult: "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example",
+		},
+		{
+			scenario:       "env",
+			script:         `echo "$SECRET"`,
+			env:            []string{"SECRET=NBSWY3DP"},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:      "invalid otpauth uri",
+			script:        `echo "otpauth://hotp/Example:john@example.com?secret=NBSWY3DP&counter=1"`,
+			expectedError: `could not get totp secret: invalid otpauth uri: unsupported type "hotp"`,
+			expectedIs:    []error{otp.ErrInvalidOTPAuthURI},
+		},
+		{
+			scenario:      "empty output",
+			script:        `true`,
+			expectedError: "could not get totp secret: no totp secret",
+			expectedIs:    []error{otp.ErrNoTOTPSecret},
+		},
+		{
+			scenario:      "exit code",
+			script:        `echo "item not found" >&2; exit 3`,
+			expectedError: `could not get totp secret: command failed: "sh -c echo \"item not found\" >&2; exit 3" exited with code 3: item not found`,
+			expectedIs:    []error{otp.ErrCommandFailed},
+		},
+		{
+			scenario:      "mapped exit code",
+			script:        `exit 3`,
+			opts:          []otp.ExecTOTPSecretProviderOption{otp.WithExitCode(3, otp.ErrNoTOTPSecret)},
+			expectedError: `could not get totp secret: command failed: "sh -c exit 3" exited with code 3`,
+			expectedIs:    []error{otp.ErrCommandFailed, otp.ErrNoTOTPSecret},
+		},
+		{
+			scenario:      "timeout",
+			script:        `exec sleep 5`,
+			opts:          []otp.ExecTOTPSecretProviderOption{otp.WithExecTimeout(50 * time.Millisecond)},
+			expectedError: `could not get totp secret: "sh -c exec sleep 5": context deadline exceeded`,
+			expectedIs:    []error{context.DeadlineExceeded},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			p := otp.NewExecTOTPSecretProvider(shell(t, tc.script, tc.env...), tc.opts...)
+
+			actual, err := p.Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.expectedResult, p.TOTPSecret(context.Background()))
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+
+			for _, target := range tc.expectedIs {
+				require.ErrorIs(t, err, target)
+			}
+		})
+	}
+}
+
+func TestExecTOTPSecretProvider_URIParameters(t *testing.T) {
+	t.Parallel()
+
+	const uri = "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&digits=8&algorithm=SHA256"
+
+	p := otp.NewExecTOTPSecretProvider(shell(t, `echo "`+uri+`"`))
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	// The digits and the algorithm of the uri are used.
+	result, err := otp.GenerateTOTP(context.Background(), p, otp.WithClock(c))
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.OTP("28733626"), result)
+}
+
+func TestExecTOTPSecretProvider_CommandNotFound(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	p := otp.NewExecTOTPSecretProvider(otp.Command{Name: "otp-command-not-found"}, otp.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	_, err := p.Load(context.Background())
+	require.ErrorIs(t, err, exec.ErrNotFound)
+
+	require.NotEmpty(t, l.LoggedEntries)
+	assert.Equal(t, "error", l.LoggedEntries[0].Level)
+}
+
+func TestExecTOTPSecretProvider_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	p := otp.NewExecTOTPSecretProvider(otp.Command{Name: "true"})
+
+	require.ErrorIs(t, p.SetTOTPSecret(context.Background(), "NBSWY3DP", ""), otp.ErrTOTPSecretReadOnly)
+	require.ErrorIs(t, p.DeleteTOTPSecret(context.Background()), otp.ErrTOTPSecretReadOnly)
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+}
+
+func TestExecTOTPSecretProvider_SetAndDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := filepath.Join(t.TempDir(), "store")
+	args := filepath.Join(t.TempDir(), "args")
+	env := []string{"STORE=" + store, "ARGS=" + args}
+
+	p := otp.NewExecTOTPSecretProvider(shell(t, `cat "$STORE" 2>/dev/null || true`, env...),
+		otp.WithSetCommand(shell(t, `echo "$0 $*" > "$ARGS"; { read -r secret; echo "$secret $OTP_ISSUER"; } > "$STORE"`, env...)),
+		otp.WithDeleteCommand(shell(t, `rm "$STORE"`, env...)),
+	)
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	err := p.SetTOTPSecret(ctx, "NBSWY3DP", "Example")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(store) //nolint: gosec
+	require.NoError(t, err)
+
+	assert.Equal(t, "NBSWY3DP Example\n", string(data))
+
+	// The secret is never passed as an argument.
+	data, err = os.ReadFile(args) //nolint: gosec
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(data), "NBSWY3DP")
+
+	err = p.DeleteTOTPSecret(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	err = p.DeleteTOTPSecret(ctx)
+	require.ErrorIs(t, err, otp.ErrCommandFailed)
+
+	var cmdErr *otp.CommandError
+
+	require.ErrorAs(t, err, &cmdErr)
+	assert.Equal(t, 1, cmdErr.ExitCode)
+}
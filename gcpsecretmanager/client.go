@@ -0,0 +1,162 @@
+package gcpsecretmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultEndpoint is the default endpoint of the Secret Manager api.
+const DefaultEndpoint = "https://secretmanager.googleapis.com"
+
+// ErrNotFound indicates that the secret or its version does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists indicates that the secret already exists.
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrPermissionDenied indicates that the caller is not allowed to call the api.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// cloudPlatformScope is the OAuth2 scope that is required by the Secret Manager api.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// defaultTokenSource gets the OAuth2 access tokens from the Application Default Credentials, which are found on the
+// first request.
+type defaultTokenSource struct {
+	once sync.Once
+	ts   oauth2.TokenSource
+	err  error
+}
+
+func (s *defaultTokenSource) Token() (*oauth2.Token, error) {
+	s.once.Do(func() {
+		s.ts, s.err = google.DefaultTokenSource(context.Background(), cloudPlatformScope)
+	})
+
+	if s.err != nil {
+		return nil, fmt.Errorf("could not find default credentials: %w", s.err)
+	}
+
+	return s.ts.Token() //nolint: wrapcheck
+}
+
+// APIError is an error returned by the Secret Manager api.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+// Error returns the error message.
+func (e *APIError) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("gcp secret manager: unexpected status code %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("gcp secret manager: %s: %s (status code %d)", e.Status, e.Message, e.StatusCode)
+}
+
+// Unwrap returns ErrNotFound, ErrAlreadyExists or ErrPermissionDenied according to the status code.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+
+	case http.StatusConflict:
+		return ErrAlreadyExists
+
+	case http.StatusForbidden:
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+type payload struct {
+	Data []byte `json:"data"`
+}
+
+type accessSecretVersionResponse struct {
+	Name    string  `json:"name"`
+	Payload payload `json:"payload"`
+}
+
+type addSecretVersionRequest struct {
+	Payload payload `json:"payload"`
+}
+
+type createSecretRequest struct {
+	Replication struct {
+		Automatic struct{} `json:"automatic"`
+	} `json:"replication"`
+}
+
+func (p *TOTPSecretProvider) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("could not encode request: %w", err)
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, body)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if p.tokenSource != nil {
+		token, err := p.tokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("could not get token: %w", err)
+		}
+
+		token.SetAuthHeader(req)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call gcp secret manager: %w", err)
+	}
+
+	defer resp.Body.Close() //nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		var errBody struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
+
+		_ = json.NewDecoder(resp.Body).Decode(&errBody) //nolint: errcheck
+
+		return &APIError{StatusCode: resp.StatusCode, Status: errBody.Error.Status, Message: errBody.Error.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,2 @@
+// Package gcpsecretmanager provides totp secret storage using Google Cloud Secret Manager.
+package gcpsecretmanager
@@ -0,0 +1,84 @@
+package gcpsecretmanager
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+	"golang.org/x/oauth2"
+
+	"go.nhat.io/otp"
+)
+
+// Option configures the TOTPSecretProvider.
+type Option interface {
+	applyOption(p *TOTPSecretProvider)
+}
+
+type optionFunc func(p *TOTPSecretProvider)
+
+func (f optionFunc) applyOption(p *TOTPSecretProvider) {
+	f(p)
+}
+
+// WithEndpoint sets the endpoint of the api, such as a regional endpoint or a local stand-in. Default is
+// DefaultEndpoint.
+func WithEndpoint(endpoint string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.endpoint = strings.TrimSuffix(endpoint, "/")
+	})
+}
+
+// WithTokenSource sets the source of the OAuth2 access tokens. Default is the Application Default Credentials.
+func WithTokenSource(ts oauth2.TokenSource) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.tokenSource = ts
+	})
+}
+
+// WithHTTPClient sets the http client, such as a client that is created by google.DefaultClient. The requests are not
+// authenticated with the Application Default Credentials, unless WithTokenSource is used, so that the http client can
+// call an emulator.
+func WithHTTPClient(hc *http.Client) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.httpClient = hc
+	})
+}
+
+// WithJSONKey reads and writes the TOTP secret at a key of the JSON object that is stored in the secret.
+func WithJSONKey(key string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.jsonKey = key
+	})
+}
+
+// WithVersion pins the version of the secret that is read, such as "3" or an alias. Default is "latest". The writes
+// always add a new version.
+func WithVersion(version string) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.version = version
+	})
+}
+
+// WithCacheTTL caches the TOTP secret for a period of time to reduce the number of api calls. Default is 0, no cache.
+func WithCacheTTL(ttl time.Duration) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.ttl = ttl
+	})
+}
+
+// WithClock sets the clock that is used for expiring the cache.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.clock = c
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.logger = otp.RedactLogger(l)
+	})
+}
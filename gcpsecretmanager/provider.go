@@ -0,0 +1,212 @@
+package gcpsecretmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+	"golang.org/x/oauth2"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/cloudsecret"
+)
+
+const latestVersion = "latest"
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider is a TOTP secret provider that stores the TOTP secret in Google Cloud Secret Manager. The payload
+// of the secret version is either the TOTP secret, an otpauth URI, or a JSON object that has the TOTP secret at a key,
+// see WithJSONKey.
+type TOTPSecretProvider struct {
+	project string
+	secret  string
+	version string
+	jsonKey string
+
+	endpoint    string
+	tokenSource oauth2.TokenSource
+	httpClient  *http.Client
+
+	clock  clock.Clock
+	cache  *cloudsecret.Cache
+	ttl    time.Duration
+	logger ctxd.Logger
+}
+
+func (p *TOTPSecretProvider) secretPath() string {
+	return "/v1/projects/" + url.PathEscape(p.project) + "/secrets/" + url.PathEscape(p.secret)
+}
+
+// TOTPSecret returns the TOTP secret from the pinned version of the secret, or the latest version if it is not pinned.
+func (p *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	if s, ok := p.cache.Get(); ok {
+		return s
+	}
+
+	value, err := p.access(ctx, p.version)
+	if errors.Is(err, ErrNotFound) {
+		return otp.NoTOTPSecret
+	}
+
+	if err != nil {
+		p.logger.Error(ctx, "could not get totp secret from gcp secret manager", "error", err, "project", p.project, "name", p.secret)
+
+		return otp.NoTOTPSecret
+	}
+
+	s, err := cloudsecret.Extract(value, p.jsonKey)
+	if err != nil {
+		p.logger.Error(ctx, "could not parse totp secret from gcp secret manager", "error", err, "project", p.project, "name", p.secret)
+
+		return otp.NoTOTPSecret
+	}
+
+	p.cache.Set(s)
+
+	return s
+}
+
+func (p *TOTPSecretProvider) access(ctx context.Context, version string) ([]byte, error) {
+	var out accessSecretVersionResponse
+
+	if err := p.do(ctx, http.MethodGet, p.secretPath()+"/versions/"+url.PathEscape(version)+":access", nil, &out); err != nil {
+		return nil, err
+	}
+
+	return out.Payload.Data, nil
+}
+
+// SetTOTPSecret adds a new version to the secret, or creates the secret with automatic replication if it does not
+// exist. With a JSON key, the other keys of the latest version are kept.
+func (p *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	if err := p.put(ctx, secret); err != nil {
+		p.logger.Error(ctx, "could not persist totp secret to gcp secret manager", "error", err, "project", p.project, "name", p.secret)
+
+		return fmt.Errorf("could not persist totp secret to gcp secret manager: %w", err)
+	}
+
+	return nil
+}
+
+func (p *TOTPSecretProvider) put(ctx context.Context, secret otp.TOTPSecret) error {
+	defer p.cache.Invalidate()
+
+	current, err := p.access(ctx, latestVersion)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	value, err := cloudsecret.Merge(current, p.jsonKey, secret)
+	if err != nil {
+		return err
+	}
+
+	err = p.addVersion(ctx, value)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	err = p.do(ctx, http.MethodPost, "/v1/projects/"+url.PathEscape(p.project)+"/secrets?secretId="+url.QueryEscape(p.secret),
+		createSecretRequest{}, nil)
+	if err != nil && !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+
+	return p.addVersion(ctx, value)
+}
+
+func (p *TOTPSecretProvider) addVersion(ctx context.Context, value []byte) error {
+	return p.do(ctx, http.MethodPost, p.secretPath()+":addVersion", addSecretVersionRequest{Payload: payload{Data: value}}, nil)
+}
+
+// DeleteTOTPSecret deletes the TOTP secret. With a JSON key, the key is removed in a new version of the secret.
+// Otherwise, the secret and all of its versions are deleted.
+func (p *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	if err := p.delete(ctx); err != nil {
+		p.logger.Error(ctx, "could not delete totp secret in gcp secret manager", "error", err, "project", p.project, "name", p.secret)
+
+		return fmt.Errorf("could not delete totp secret in gcp secret manager: %w", err)
+	}
+
+	return nil
+}
+
+func (p *TOTPSecretProvider) delete(ctx context.Context) error {
+	defer p.cache.Invalidate()
+
+	if p.jsonKey != "" {
+		current, err := p.access(ctx, latestVersion)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		value, err := cloudsecret.Merge(current, p.jsonKey, otp.NoTOTPSecret)
+		if err != nil {
+			return err
+		}
+
+		return p.addVersion(ctx, value)
+	}
+
+	err := p.do(ctx, http.MethodDelete, p.secretPath(), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (p *TOTPSecretProvider) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return p
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (p *TOTPSecretProvider) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (p *TOTPSecretProvider) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return p
+}
+
+// TOTPSecretFromSecretManager returns a TOTP secret provider that stores the TOTP secret in the secret of the project.
+// By default, it reads the latest version, the TOTP secret is not cached, and the requests are authenticated with the
+// Application Default Credentials, unless WithTokenSource or WithHTTPClient is used.
+func TOTPSecretFromSecretManager(project, secret string, opts ...Option) *TOTPSecretProvider {
+	p := &TOTPSecretProvider{
+		project:  project,
+		secret:   secret,
+		version:  latestVersion,
+		endpoint: DefaultEndpoint,
+		clock:    clock.New(),
+		logger:   ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(p)
+	}
+
+	if p.httpClient == nil {
+		p.httpClient = http.DefaultClient
+
+		if p.tokenSource == nil {
+			p.tokenSource = &defaultTokenSource{}
+		}
+	}
+
+	p.cache = cloudsecret.NewCache(p.clock, p.ttl)
+
+	return p
+}
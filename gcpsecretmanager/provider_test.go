@@ -0,0 +1,471 @@
+//go:build unit || !integration
+
+package gcpsecretmanager_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/gcpsecretmanager"
+)
+
+// secretManager mimics the api of Google Cloud Secret Manager.
+type secretManager struct {
+	token string
+
+	mu      sync.Mutex
+	secrets map[string][][]byte
+	calls   map[string]int
+}
+
+func newSecretManager() *secretManager {
+	return &secretManager{
+		token:   "token",
+		secrets: make(map[string][][]byte),
+		calls:   make(map[string]int),
+	}
+}
+
+func (m *secretManager) add(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.secrets[name] = append(m.secrets[name], []byte(value))
+}
+
+func (m *secretManager) latest(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	versions := m.secrets[name]
+	if len(versions) == 0 {
+		return ""
+	}
+
+	return string(versions[len(versions)-1])
+}
+
+func (m *secretManager) count(call string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[call]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v) //nolint: errcheck
+}
+
+func writeError(w http.ResponseWriter, code int, status, msg string) {
+	writeJSON(w, code, map[string]any{"error": map[string]any{"code": code, "message": msg, "status": status}})
+}
+
+func (m *secretManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+m.token {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "missing credentials")
+
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/projects/project/secrets")
+	if !ok {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "permission denied")
+
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		m.calls["create"]++
+
+		name := r.URL.Query().Get("secretId")
+		if _, ok := m.secrets[name]; ok {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "secret already exists")
+
+			return
+		}
+
+		m.secrets[name] = nil
+
+		writeJSON(w, http.StatusOK, map[string]any{"name": "projects/project/secrets/" + name})
+
+	case r.Method == http.MethodPost && strings.HasSuffix(path, ":addVersion"):
+		m.calls["addVersion"]++
+
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "/"), ":addVersion")
+		if _, ok := m.secrets[name]; !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "secret not found")
+
+			return
+		}
+
+		var in struct {
+			Payload struct {
+				Data []byte `json:"data"`
+			} `json:"payload"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&in) //nolint: errcheck
+
+		m.secrets[name] = append(m.secrets[name], in.Payload.Data)
+
+		writeJSON(w, http.StatusOK, map[string]any{"name": "projects/project/secrets/" + name + "/versions/" + strconv.Itoa(len(m.secrets[name]))})
+
+	case r.Method == http.MethodGet && strings.HasSuffix(path, ":access"):
+		m.calls["access"]++
+
+		name, version, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(path, "/"), ":access"), "/versions/")
+		versions := m.secrets[name]
+
+		i := len(versions)
+		if version != "latest" {
+			i, _ = strconv.Atoi(version) //nolint: errcheck
+		}
+
+		if i < 1 || i > len(versions) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "secret version not found")
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"payload": map[string]any{"data": versions[i-1]}})
+
+	case r.Method == http.MethodDelete:
+		m.calls["delete"]++
+
+		name := strings.TrimPrefix(path, "/")
+		if _, ok := m.secrets[name]; !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "secret not found")
+
+			return
+		}
+
+		delete(m.secrets, name)
+
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "no handler for route")
+	}
+}
+
+func newProvider(t *testing.T, m *secretManager, secret string, opts ...gcpsecretmanager.Option) *gcpsecretmanager.TOTPSecretProvider {
+	t.Helper()
+
+	srv := httptest.NewServer(m)
+
+	t.Cleanup(srv.Close)
+
+	opts = append([]gcpsecretmanager.Option{
+		gcpsecretmanager.WithEndpoint(srv.URL),
+		gcpsecretmanager.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})),
+	}, opts...)
+
+	return gcpsecretmanager.TOTPSecretFromSecretManager("project", secret, opts...)
+}
+
+func TestTOTPSecretProvider_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretManager()
+	m.add("plain", "NBSWY3DP")
+	m.add("uri", "otpauth://totp/Example:john?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	m.add("json", `{"username":"john","totp":"NBSWY3DP"}`)
+	m.add("rotated", "NBSWY3DP")
+	m.add("rotated", "JBSWY3DPEHPK3PXP")
+
+	testCases := []struct {
+		scenario string
+		secret   string
+		options  []gcpsecretmanager.Option
+		expected otp.TOTPSecret
+	}{
+		{
+			scenario: "plain",
+			secret:   "plain",
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "otpauth uri",
+			secret:   "uri",
+			expected: "otpauth://totp/Example:john?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		},
+		{
+			scenario: "json key",
+			secret:   "json",
+			options:  []gcpsecretmanager.Option{gcpsecretmanager.WithJSONKey("totp")},
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "missing json key",
+			secret:   "json",
+			options:  []gcpsecretmanager.Option{gcpsecretmanager.WithJSONKey("otp")},
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "not a json object",
+			secret:   "plain",
+			options:  []gcpsecretmanager.Option{gcpsecretmanager.WithJSONKey("totp")},
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "latest version",
+			secret:   "rotated",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "pinned version",
+			secret:   "rotated",
+			options:  []gcpsecretmanager.Option{gcpsecretmanager.WithVersion("1")},
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "not found",
+			secret:   "unknown",
+			expected: otp.NoTOTPSecret,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			p := newProvider(t, m, tc.secret, tc.options...)
+
+			assert.Equal(t, tc.expected, p.TOTPSecret(context.Background()))
+		})
+	}
+}
+
+func TestTOTPSecretProvider_TOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretManager()
+	m.token = "other"
+
+	l := &ctxd.LoggerMock{}
+	p := newProvider(t, m, "plain", gcpsecretmanager.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "could not get totp secret from gcp secret manager", l.LoggedEntries[0].Message)
+	assert.Equal(t, "plain", l.LoggedEntries[0].Data["name"])
+
+	var apiErr *gcpsecretmanager.APIError
+
+	require.ErrorAs(t, l.LoggedEntries[0].Data["error"].(error), &apiErr) //nolint: forcetypeassert
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "UNAUTHENTICATED", apiErr.Status)
+}
+
+func TestTOTPSecretProvider_Cache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretManager()
+	m.add("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain", gcpsecretmanager.WithCacheTTL(time.Minute))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+	assert.Equal(t, 1, m.count("access"))
+
+	// The cache is invalidated on write.
+	require.NoError(t, p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+	assert.Equal(t, 3, m.count("access"))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretManager()
+
+	p := newProvider(t, m, "plain")
+
+	// The secret is created.
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	assert.Equal(t, "NBSWY3DP", m.latest("plain"))
+	assert.Equal(t, 1, m.count("create"))
+
+	// A new version is added.
+	require.NoError(t, p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", m.latest("plain"))
+	assert.Equal(t, 1, m.count("create"))
+	assert.Equal(t, 3, m.count("addVersion"))
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_JSONKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretManager()
+	m.add("json", `{"username":"john"}`)
+
+	p := newProvider(t, m, "json", gcpsecretmanager.WithJSONKey("totp"))
+
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	assert.JSONEq(t, `{"username":"john","totp":"NBSWY3DP"}`, m.latest("json"))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	assert.JSONEq(t, `{"username":"john"}`, m.latest("json"))
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+	assert.Equal(t, 0, m.count("delete"))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretManager()
+	m.token = "other"
+
+	p := newProvider(t, m, "plain")
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+
+	require.EqualError(t, err, "could not persist totp secret to gcp secret manager: gcp secret manager: UNAUTHENTICATED: missing credentials (status code 401)")
+}
+
+func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := newSecretManager()
+	m.add("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain")
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	// Deleting a missing secret is not an error.
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+	assert.Equal(t, 2, m.count("delete"))
+}
+
+func TestTOTPSecretProvider_PermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(newSecretManager())
+
+	t.Cleanup(srv.Close)
+
+	p := gcpsecretmanager.TOTPSecretFromSecretManager("other", "plain",
+		gcpsecretmanager.WithEndpoint(srv.URL),
+		gcpsecretmanager.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})),
+	)
+
+	err := p.DeleteTOTPSecret(context.Background())
+
+	require.ErrorIs(t, err, gcpsecretmanager.ErrPermissionDenied)
+}
+
+// writeServiceAccount writes the credentials of a service account that gets the access tokens from the token url.
+func writeServiceAccount(t *testing.T, tokenURL string) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	b, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "project",
+		"private_key_id": "key",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "otp@project.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+
+	return path
+}
+
+func TestTOTPSecretProvider_DefaultCredentials(t *testing.T) { //nolint: paralleltest
+	m := newSecretManager()
+	m.add("plain", "NBSWY3DP")
+
+	mux := http.NewServeMux()
+	mux.Handle("/", m)
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"access_token": "token", "token_type": "Bearer", "expires_in": 3600})
+	})
+
+	srv := httptest.NewServer(mux)
+
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeServiceAccount(t, srv.URL+"/token"))
+
+	p := gcpsecretmanager.TOTPSecretFromSecretManager("project", "plain", gcpsecretmanager.WithEndpoint(srv.URL))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+}
+
+func TestTOTPSecretProvider_NoDefaultCredentials(t *testing.T) { //nolint: paralleltest
+	srv := httptest.NewServer(newSecretManager())
+
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "credentials.json"))
+
+	p := gcpsecretmanager.TOTPSecretFromSecretManager("project", "plain", gcpsecretmanager.WithEndpoint(srv.URL))
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+
+	require.ErrorContains(t, err, "could not find default credentials")
+}
+
+func TestTOTPSecretProvider_ChainTOTPSecretProviders(t *testing.T) {
+	t.Parallel()
+
+	m := newSecretManager()
+	m.add("plain", "NBSWY3DP")
+
+	p := newProvider(t, m, "plain")
+	c := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret, p)
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), c.TOTPSecret(context.Background()))
+}
@@ -0,0 +1,77 @@
+// Package atomicfile replaces the files atomically.
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrTooManyLinks indicates that the symlinks could not be resolved because there are too many of them, or they form a
+// loop.
+var ErrTooManyLinks = errors.New("too many links")
+
+// Write replaces the file with the data atomically. The data is written to a temporary file in the same directory,
+// synced to the disk, then renamed over the file. If the path is a symlink, its target is replaced and the symlink is
+// kept. The permissions of an existing file are kept, perm is used for a new file.
+func Write(path string, data []byte, perm os.FileMode) error {
+	path, err := resolve(path)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name()) //nolint: errcheck
+
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close() //nolint: errcheck
+
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close() //nolint: errcheck
+
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close() //nolint: errcheck
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// resolve returns the path of the file that the symlinks point to. A symlink to a file that does not exist resolves to
+// that file, so that it is created.
+func resolve(path string) (string, error) {
+	for range 255 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			// Not a symlink, or does not exist.
+			return path, nil //nolint: nilerr
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		path = target
+	}
+
+	return "", &os.PathError{Op: "readlink", Path: path, Err: ErrTooManyLinks}
+}
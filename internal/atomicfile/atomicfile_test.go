@@ -0,0 +1,103 @@
+//go:build unit || !integration
+
+package atomicfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp/internal/atomicfile"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "secret")
+
+	require.NoError(t, atomicfile.Write(path, []byte("NBSWY3DP"), 0o600))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// The permissions of the existing file are kept.
+	require.NoError(t, os.Chmod(path, 0o640))
+	require.NoError(t, atomicfile.Write(path, []byte("JBSWY3DPEHPK3PXP"), 0o600))
+
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", string(data))
+
+	// No temporary file is left.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+
+	assert.Len(t, entries, 1)
+}
+
+func TestWrite_Symlink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "secrets", "secret")
+	link := filepath.Join(dir, "link")
+
+	require.NoError(t, os.Mkdir(filepath.Dir(target), 0o700))
+	require.NoError(t, os.WriteFile(target, []byte("NBSWY3DP"), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join("secrets", "secret"), link))
+
+	require.NoError(t, atomicfile.Write(link, []byte("JBSWY3DPEHPK3PXP"), 0o644))
+
+	// The symlink is kept, and its target is replaced.
+	info, err := os.Lstat(link)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", string(data))
+
+	info, err = os.Stat(target)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestWrite_DanglingSymlink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "secret")
+	link := filepath.Join(dir, "link")
+
+	require.NoError(t, os.Symlink(target, link))
+	require.NoError(t, atomicfile.Write(link, []byte("NBSWY3DP"), 0o600))
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+
+	assert.Equal(t, "NBSWY3DP", string(data))
+}
+
+func TestWrite_SymlinkLoop(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link")
+
+	require.NoError(t, os.Symlink(link, link))
+	require.ErrorIs(t, atomicfile.Write(link, []byte("NBSWY3DP"), 0o600), atomicfile.ErrTooManyLinks)
+}
@@ -0,0 +1,122 @@
+// Package cloudsecret provides the helpers that are shared by the cloud secret manager providers.
+package cloudsecret
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+// ErrNotJSONObject indicates that the value of the secret is not a JSON object.
+var ErrNotJSONObject = errors.New("secret value is not a json object")
+
+// Extract returns the TOTP secret from the value of a secret. If the key is not empty, the value is a JSON object and
+// the TOTP secret is the string at the key. The TOTP secret may be an otpauth URI.
+func Extract(value []byte, key string) (otp.TOTPSecret, error) {
+	if key == "" {
+		return otp.ParseTOTPSecret(string(value))
+	}
+
+	var obj map[string]any
+
+	if err := json.Unmarshal(value, &obj); err != nil || obj == nil {
+		return otp.NoTOTPSecret, ErrNotJSONObject
+	}
+
+	v, ok := obj[key]
+	if !ok {
+		return otp.NoTOTPSecret, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return otp.NoTOTPSecret, fmt.Errorf("%w: key %q is not a string", ErrNotJSONObject, key)
+	}
+
+	return otp.ParseTOTPSecret(s)
+}
+
+// Merge returns the new value of a secret with the TOTP secret at the key, the other keys are kept. If the key is
+// empty, the new value is the TOTP secret. If the secret is otp.NoTOTPSecret, the key is removed.
+func Merge(value []byte, key string, secret otp.TOTPSecret) ([]byte, error) {
+	if key == "" {
+		return []byte(secret.Reveal()), nil
+	}
+
+	obj := make(map[string]any)
+
+	if len(value) > 0 {
+		if err := json.Unmarshal(value, &obj); err != nil || obj == nil {
+			return nil, ErrNotJSONObject
+		}
+	}
+
+	if secret == otp.NoTOTPSecret {
+		delete(obj, key)
+	} else {
+		obj[key] = secret.Reveal()
+	}
+
+	return json.Marshal(obj)
+}
+
+// Cache keeps a TOTP secret for a period of time.
+type Cache struct {
+	clock clock.Clock
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	secret    otp.TOTPSecret
+	expiresAt time.Time
+	valid     bool
+}
+
+// Get returns the TOTP secret if it has not expired.
+func (c *Cache) Get() (otp.TOTPSecret, bool) {
+	if c.ttl <= 0 {
+		return otp.NoTOTPSecret, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.valid || !c.clock.Now().Before(c.expiresAt) {
+		return otp.NoTOTPSecret, false
+	}
+
+	return c.secret, true
+}
+
+// Set keeps the TOTP secret until the TTL expires.
+func (c *Cache) Set(s otp.TOTPSecret) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.secret = s
+	c.expiresAt = c.clock.Now().Add(c.ttl)
+	c.valid = true
+}
+
+// Invalidate discards the TOTP secret.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.secret = otp.NoTOTPSecret
+	c.valid = false
+}
+
+// NewCache initiates a new Cache. A zero TTL disables the cache.
+func NewCache(c clock.Clock, ttl time.Duration) *Cache {
+	return &Cache{clock: c, ttl: ttl}
+}
@@ -0,0 +1,13 @@
+// Package zero zeroes the memory that held secrets.
+package zero
+
+import "runtime"
+
+// Bytes zeroes the bytes, so that the secret does not stay in memory after it is used.
+func Bytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+
+	runtime.KeepAlive(b)
+}
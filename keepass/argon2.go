@@ -0,0 +1,319 @@
+package keepass
+
+import (
+	"encoding/binary"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// The implementation follows RFC 9106 and golang.org/x/crypto/argon2, which does not provide Argon2d.
+
+type argon2Mode uint32
+
+const (
+	argon2d  argon2Mode = 0
+	argon2id argon2Mode = 2
+
+	argon2Version     = 0x13
+	argon2BlockLength = 128
+	argon2SyncPoints  = 4
+)
+
+type argon2Block [argon2BlockLength]uint64
+
+// argon2Key derives a key of keyLen bytes. The memory is in KiB.
+func argon2Key(mode argon2Mode, password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	h0 := argon2InitHash(mode, password, salt, secret, data, time, memory, uint32(threads), keyLen)
+
+	memory = memory / (argon2SyncPoints * uint32(threads)) * (argon2SyncPoints * uint32(threads))
+	if memory < 2*argon2SyncPoints*uint32(threads) {
+		memory = 2 * argon2SyncPoints * uint32(threads)
+	}
+
+	b := argon2InitBlocks(&h0, memory, uint32(threads))
+
+	argon2ProcessBlocks(b, mode, time, memory, uint32(threads))
+
+	return argon2ExtractKey(b, memory, uint32(threads), keyLen)
+}
+
+func argon2InitHash(mode argon2Mode, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+		tmp    [4]byte
+	)
+
+	b2, _ := blake2b.New512(nil) //nolint: errcheck
+
+	binary.LittleEndian.PutUint32(params[0:4], threads)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], memory)
+	binary.LittleEndian.PutUint32(params[12:16], time)
+	binary.LittleEndian.PutUint32(params[16:20], argon2Version)
+	binary.LittleEndian.PutUint32(params[20:24], uint32(mode))
+
+	_, _ = b2.Write(params[:]) //nolint: errcheck
+
+	for _, v := range [][]byte{password, salt, secret, data} {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(v))) //nolint: gosec
+		_, _ = b2.Write(tmp[:])                               //nolint: errcheck
+		_, _ = b2.Write(v)                                    //nolint: errcheck
+	}
+
+	b2.Sum(h0[:0])
+
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []argon2Block {
+	var buf [argon2BlockLength * 8]byte
+
+	b := make([]argon2Block, memory)
+
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			argon2Hash(buf[:], h0[:])
+
+			for k := range b[j+i] {
+				b[j+i][k] = binary.LittleEndian.Uint64(buf[k*8:])
+			}
+		}
+	}
+
+	return b
+}
+
+func argon2ProcessBlocks(b []argon2Block, mode argon2Mode, time, memory, threads uint32) {
+	lanes := memory / threads
+	segments := lanes / argon2SyncPoints
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			for lane := uint32(0); lane < threads; lane++ {
+				argon2ProcessSegment(b, mode, time, memory, threads, lanes, segments, n, slice, lane)
+			}
+		}
+	}
+}
+
+func argon2ProcessSegment(b []argon2Block, mode argon2Mode, time, memory, threads, lanes, segments, n, slice, lane uint32) {
+	var addresses, in, zero argon2Block
+
+	independent := mode == argon2id && n == 0 && slice < argon2SyncPoints/2
+
+	if independent {
+		in[0] = uint64(n)
+		in[1] = uint64(lane)
+		in[2] = uint64(slice)
+		in[3] = uint64(memory)
+		in[4] = uint64(time)
+		in[5] = uint64(mode)
+	}
+
+	index := uint32(0)
+
+	if n == 0 && slice == 0 {
+		index = 2
+
+		if independent {
+			in[6]++
+			argon2ProcessBlock(&addresses, &in, &zero, false)
+			argon2ProcessBlock(&addresses, &addresses, &zero, false)
+		}
+	}
+
+	offset := lane*lanes + slice*segments + index
+
+	for index < segments {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += lanes // Last block in lane.
+		}
+
+		var random uint64
+
+		if independent {
+			if index%argon2BlockLength == 0 {
+				in[6]++
+				argon2ProcessBlock(&addresses, &in, &zero, false)
+				argon2ProcessBlock(&addresses, &addresses, &zero, false)
+			}
+
+			random = addresses[index%argon2BlockLength]
+		} else {
+			random = b[prev][0]
+		}
+
+		newOffset := argon2IndexAlpha(random, lanes, segments, threads, n, slice, lane, index)
+
+		argon2ProcessBlock(&b[offset], &b[prev], &b[newOffset], true)
+
+		index, offset = index+1, offset+1
+	}
+}
+
+func argon2IndexAlpha(random uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+
+	m, s := 3*segments, ((slice+1)%argon2SyncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+
+	if index == 0 || lane == refLane {
+		m--
+	}
+
+	p := random & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * uint64(m)) >> 32
+
+	return refLane*lanes + uint32((uint64(s)+uint64(m)-(p+1))%uint64(lanes)) //nolint: gosec
+}
+
+func argon2ExtractKey(b []argon2Block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range b[(lane*lanes)+lanes-1] {
+			b[memory-1][i] ^= v
+		}
+	}
+
+	var buf [argon2BlockLength * 8]byte
+
+	for i, v := range b[memory-1] {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+
+	key := make([]byte, keyLen)
+
+	argon2Hash(key, buf[:])
+
+	return key
+}
+
+// argon2Hash is the variable-length hash function H' of RFC 9106.
+func argon2Hash(out []byte, in []byte) {
+	var (
+		b2     hash.Hash
+		buffer [blake2b.Size]byte
+	)
+
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil) //nolint: errcheck
+	} else {
+		b2, _ = blake2b.New512(nil) //nolint: errcheck
+	}
+
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out))) //nolint: gosec
+	_, _ = b2.Write(buffer[:4])                                 //nolint: errcheck
+	_, _ = b2.Write(in)                                         //nolint: errcheck
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+
+		return
+	}
+
+	outLen := len(out)
+
+	b2.Sum(buffer[:0])
+	b2.Reset()
+
+	copy(out, buffer[:32])
+	out = out[32:]
+
+	for len(out) > blake2b.Size {
+		_, _ = b2.Write(buffer[:]) //nolint: errcheck
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 {
+		r := ((outLen + 31) / 32) - 2
+		b2, _ = blake2b.New(outLen-32*r, nil) //nolint: errcheck
+	}
+
+	_, _ = b2.Write(buffer[:]) //nolint: errcheck
+	b2.Sum(out[:0])
+}
+
+// argon2ProcessBlock is the compression function G.
+func argon2ProcessBlock(out, in1, in2 *argon2Block, xor bool) {
+	var t argon2Block
+
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+
+	for i := 0; i < argon2BlockLength; i += 16 {
+		blamka(&t[i+0], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+
+	for i := 0; i < argon2BlockLength/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+
+	if xor {
+		for i := range t {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		}
+	} else {
+		for i := range t {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+func blamka(t00, t01, t02, t03, t04, t05, t06, t07, t08, t09, t10, t11, t12, t13, t14, t15 *uint64) {
+	v := [16]uint64{*t00, *t01, *t02, *t03, *t04, *t05, *t06, *t07, *t08, *t09, *t10, *t11, *t12, *t13, *t14, *t15}
+
+	gb := func(a, b, c, d int) {
+		v[a] += v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+		v[d] = rotr64(v[d]^v[a], 32)
+		v[c] += v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+		v[b] = rotr64(v[b]^v[c], 24)
+		v[a] += v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+		v[d] = rotr64(v[d]^v[a], 16)
+		v[c] += v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+		v[b] = rotr64(v[b]^v[c], 63)
+	}
+
+	gb(0, 4, 8, 12)
+	gb(1, 5, 9, 13)
+	gb(2, 6, 10, 14)
+	gb(3, 7, 11, 15)
+	gb(0, 5, 10, 15)
+	gb(1, 6, 11, 12)
+	gb(2, 7, 8, 13)
+	gb(3, 4, 9, 14)
+
+	*t00, *t01, *t02, *t03, *t04, *t05, *t06, *t07 = v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]
+	*t08, *t09, *t10, *t11, *t12, *t13, *t14, *t15 = v[8], v[9], v[10], v[11], v[12], v[13], v[14], v[15]
+}
+
+func rotr64(x uint64, n uint) uint64 {
+	return x>>n | x<<(64-n)
+}
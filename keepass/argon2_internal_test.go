@@ -0,0 +1,39 @@
+//go:build unit || !integration
+
+package keepass
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestArgon2Key_Argon2d(t *testing.T) {
+	t.Parallel()
+
+	// RFC 9106, section 5.1.
+	actual := argon2Key(argon2d,
+		bytes.Repeat([]byte{0x01}, 32),
+		bytes.Repeat([]byte{0x02}, 16),
+		bytes.Repeat([]byte{0x03}, 8),
+		bytes.Repeat([]byte{0x04}, 12),
+		3, 32, 4, 32,
+	)
+
+	assert.Equal(t, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb", hex.EncodeToString(actual))
+}
+
+func TestArgon2Key_Argon2id(t *testing.T) {
+	t.Parallel()
+
+	password := []byte("password")
+	salt := []byte("somesaltsomesalt")
+
+	expected := argon2.IDKey(password, salt, 2, 64, 2, 32)
+	actual := argon2Key(argon2id, password, salt, nil, nil, 2, 64, 2, 32)
+
+	assert.Equal(t, expected, actual)
+}
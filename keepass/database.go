@@ -0,0 +1,293 @@
+package keepass
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/atomicfile"
+)
+
+// ErrNoCredentials indicates that neither a password nor a key file is provided.
+var ErrNoCredentials = errors.New("no password or key file")
+
+// ErrInvalidKeyFile indicates that the key file is malformed.
+var ErrInvalidKeyFile = errors.New("invalid key file")
+
+// ErrEntryNotFound indicates that the entry does not exist in the database.
+var ErrEntryNotFound = errors.New("entry not found")
+
+// Database is a KeePass database. It is decrypted once when it is opened, and again when the file changes on disk. The
+// changes of the entries are written back to the file atomically.
+//
+// The entries are addressed by their path, which is the names of their groups, without the root group, and their
+// title, separated by a slash, such as "Work/GitHub". The entries in the recycle bin are ignored.
+type Database struct {
+	config
+
+	path string
+
+	mu          sync.Mutex
+	info        os.FileInfo
+	db          *kdbx
+	composite   *otp.SecretBuffer
+	transformed *otp.SecretBuffer
+}
+
+// Path returns the path of the database.
+func (d *Database) Path() string {
+	return d.path
+}
+
+// Entry returns the entry at the path.
+func (d *Database) Entry(path string) *Entry {
+	return &Entry{db: d, path: cleanPath(path)}
+}
+
+// Entries returns the paths of the entries that have a TOTP secret.
+func (d *Database) Entries() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	d.walk(func(path string, e *node) bool {
+		if hasTOTP(e) {
+			result = append(result, path)
+		}
+
+		return true
+	})
+
+	return result, nil
+}
+
+// Destroy zeroes the keys of the database in memory. The database can not be used afterward.
+func (d *Database) Destroy() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.composite.Destroy()
+	d.transformed.Destroy()
+
+	d.db = nil
+}
+
+// refresh decrypts the database again if the file has changed since it was read.
+func (d *Database) refresh() error {
+	if d.composite.IsDestroyed() {
+		return otp.ErrSecretBufferDestroyed
+	}
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("could not read keepass database: %w", err)
+	}
+
+	if d.db != nil && sameFile(d.info, info) {
+		return nil
+	}
+
+	return d.load()
+}
+
+func (d *Database) load() error {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return fmt.Errorf("could not read keepass database: %w", err)
+	}
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("could not read keepass database: %w", err)
+	}
+
+	var (
+		db          *kdbx
+		transformed []byte
+	)
+
+	err = d.composite.Use(func(composite []byte) error {
+		db, transformed, err = decodeKDBX(data, composite)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not open keepass database: %w", err)
+	}
+
+	buf, err := otp.NewSecretBufferFromBytes(transformed)
+	if err != nil {
+		return fmt.Errorf("could not open keepass database: %w", err)
+	}
+
+	if d.transformed != nil {
+		d.transformed.Destroy()
+	}
+
+	d.db = db
+	d.info = info
+	d.transformed = buf
+
+	return nil
+}
+
+// save encrypts the database and replaces the file atomically.
+func (d *Database) save() error {
+	var data []byte
+
+	err := d.transformed.Use(func(transformed []byte) (err error) {
+		data, err = d.db.encode(transformed)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode keepass database: %w", err)
+	}
+
+	if err := atomicfile.Write(d.path, data, d.info.Mode().Perm()); err != nil {
+		return fmt.Errorf("could not write keepass database: %w", err)
+	}
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("could not read keepass database: %w", err)
+	}
+
+	d.info = info
+
+	return nil
+}
+
+// walk calls fn with the path of every entry that is not in the recycle bin, in document order, until fn returns
+// false.
+func (d *Database) walk(fn func(path string, e *node) bool) {
+	root := d.db.doc.root.path("Root", "Group")
+	if root == nil {
+		return
+	}
+
+	var recycleBin string
+
+	if meta := d.db.doc.root.child("Meta"); meta != nil {
+		if enabled := meta.child("RecycleBinEnabled"); enabled == nil || !strings.EqualFold(enabled.text(), "false") {
+			if uuid := meta.child("RecycleBinUUID"); uuid != nil {
+				recycleBin = strings.TrimSpace(uuid.text())
+			}
+		}
+	}
+
+	var visit func(prefix string, g *node) bool
+
+	visit = func(prefix string, g *node) bool {
+		for _, e := range g.elements("Entry") {
+			if !fn(prefix+entryTitle(e), e) {
+				return false
+			}
+		}
+
+		for _, sub := range g.elements("Group") {
+			if uuid := sub.child("UUID"); recycleBin != "" && uuid != nil && strings.TrimSpace(uuid.text()) == recycleBin {
+				continue
+			}
+
+			name := ""
+			if n := sub.child("Name"); n != nil {
+				name = n.text()
+			}
+
+			if !visit(prefix+name+"/", sub) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	visit("", root)
+}
+
+// find returns the first entry at the path.
+func (d *Database) find(path string) *node {
+	var result *node
+
+	d.walk(func(p string, e *node) bool {
+		if p == path {
+			result = e
+
+			return false
+		}
+
+		return true
+	})
+
+	return result
+}
+
+// Open opens a KeePass database with a password, a key file, or both.
+func Open(path string, opts ...Option) (*Database, error) {
+	cfg := config{
+		clock:  clock.New(),
+		logger: ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(&cfg)
+	}
+
+	keyFile := cfg.keyFileData
+
+	if cfg.keyFilePath != "" {
+		data, err := readKeyFile(cfg.keyFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		keyFile = data
+	}
+
+	key, err := compositeKey(cfg.password, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	composite, err := otp.NewSecretBufferFromBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not open keepass database: %w", err)
+	}
+
+	d := &Database{
+		config:    cfg,
+		path:      path,
+		composite: composite,
+	}
+
+	d.password = nil
+	d.keyFileData = nil
+
+	if err := d.load(); err != nil {
+		composite.Destroy()
+
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func cleanPath(path string) string {
+	return strings.Trim(path, "/")
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime()) && a.Mode() == b.Mode()
+}
@@ -0,0 +1,366 @@
+//go:build unit || !integration
+
+package keepass_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/keepass"
+)
+
+type fixture struct {
+	file    string
+	options []keepass.Option
+}
+
+const (
+	githubURI        = "otpauth://totp/GitHub:john@example.com?secret=NBSWY3DP&period=30&digits=6&issuer=GitHub"
+	githubUpdatedURI = "otpauth://totp/GitHub:john@example.com?digits=6&issuer=GitHub&period=30&secret=JBSWY3DPEHPK3PXP"
+)
+
+func fixtures() []fixture {
+	return []fixture{
+		{
+			file:    "kdbx3.kdbx",
+			options: []keepass.Option{keepass.WithPassword("password")},
+		},
+		{
+			file:    "kdbx4-aes-kdf.kdbx",
+			options: []keepass.Option{keepass.WithKeyFile("testdata/keyfile.keyx")},
+		},
+		{
+			file:    "kdbx4-argon2d.kdbx",
+			options: []keepass.Option{keepass.WithPassword("password")},
+		},
+		{
+			file:    "kdbx4-argon2id-chacha20.kdbx",
+			options: []keepass.Option{keepass.WithPassword("password"), keepass.WithKeyFile("testdata/keyfile.keyx")},
+		},
+	}
+}
+
+// copyFixture copies the database to a temporary directory, so it can be changed.
+func copyFixture(t *testing.T, file string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), file)
+
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func openFixture(t *testing.T, f fixture, opts ...keepass.Option) *keepass.Database {
+	t.Helper()
+
+	db, err := keepass.Open(copyFixture(t, f.file), append(f.options, opts...)...)
+	require.NoError(t, err)
+
+	t.Cleanup(db.Destroy)
+
+	return db
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range fixtures() {
+		f := f
+		t.Run(f.file, func(t *testing.T) {
+			t.Parallel()
+
+			db := openFixture(t, f)
+
+			actual, err := db.Entries()
+			require.NoError(t, err)
+
+			// The entries without a TOTP secret, and the entries in the recycle bin are not listed.
+			assert.Equal(t, []string{"GitHub", "Legacy", "KeeOtp"}, actual)
+		})
+	}
+}
+
+func TestOpen_Error(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		file          string
+		options       []keepass.Option
+		expectedError error
+	}{
+		{
+			scenario:      "no credentials",
+			file:          "testdata/kdbx3.kdbx",
+			expectedError: keepass.ErrNoCredentials,
+		},
+		{
+			scenario:      "wrong password kdbx 3",
+			file:          "testdata/kdbx3.kdbx",
+			options:       []keepass.Option{keepass.WithPassword("wrong")},
+			expectedError: keepass.ErrInvalidCredentials,
+		},
+		{
+			scenario:      "wrong password kdbx 4",
+			file:          "testdata/kdbx4-argon2d.kdbx",
+			options:       []keepass.Option{keepass.WithPassword("wrong")},
+			expectedError: keepass.ErrInvalidCredentials,
+		},
+		{
+			scenario:      "missing key file",
+			file:          "testdata/kdbx4-argon2id-chacha20.kdbx",
+			options:       []keepass.Option{keepass.WithPassword("password")},
+			expectedError: keepass.ErrInvalidCredentials,
+		},
+		{
+			scenario:      "invalid key file",
+			file:          "testdata/kdbx4-aes-kdf.kdbx",
+			options:       []keepass.Option{keepass.WithKeyFileData([]byte(`<KeyFile><Meta><Version>2.0</Version></Meta><Key><Data Hash="00000000">00</Data></Key></KeyFile>`))},
+			expectedError: keepass.ErrInvalidKeyFile,
+		},
+		{
+			scenario:      "not a database",
+			file:          "testdata/keyfile.keyx",
+			options:       []keepass.Option{keepass.WithPassword("password")},
+			expectedError: keepass.ErrInvalidDatabase,
+		},
+		{
+			scenario:      "file does not exist",
+			file:          "testdata/unknown.kdbx",
+			options:       []keepass.Option{keepass.WithPassword("password")},
+			expectedError: os.ErrNotExist,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := keepass.Open(tc.file, tc.options...)
+
+			require.ErrorIs(t, err, tc.expectedError)
+			assert.Nil(t, db)
+		})
+	}
+}
+
+func TestEntry_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		path     string
+		expected otp.TOTPSecret
+	}{
+		{
+			scenario: "otpauth uri",
+			path:     "GitHub",
+			expected: githubURI,
+		},
+		{
+			scenario: "legacy totp seed",
+			path:     "Legacy",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "keeotp",
+			path:     "/KeeOtp",
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "no totp secret",
+			path:     "Work/AWS",
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "recycle bin",
+			path:     "Recycle Bin/Deleted",
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "not found",
+			path:     "Unknown",
+			expected: otp.NoTOTPSecret,
+		},
+	}
+
+	for _, f := range fixtures() {
+		f := f
+		t.Run(f.file, func(t *testing.T) {
+			t.Parallel()
+
+			db := openFixture(t, f)
+
+			for _, tc := range testCases {
+				assert.Equal(t, tc.expected, db.Entry(tc.path).TOTPSecret(context.Background()), tc.scenario)
+			}
+		})
+	}
+}
+
+func TestEntry_Load_NotFound(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	db := openFixture(t, fixtures()[0], keepass.WithLogger(l))
+	e := db.Entry("Work/Unknown")
+
+	actual, err := e.Load(context.Background())
+
+	require.ErrorIs(t, err, keepass.ErrEntryNotFound)
+	assert.Equal(t, otp.NoTOTPSecret, actual)
+	assert.Equal(t, "Work/Unknown", e.Path())
+
+	assert.Equal(t, otp.NoTOTPSecret, e.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+	assert.Equal(t, "keepass entry does not exist", l.LoggedEntries[0].Message)
+}
+
+func TestEntry_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, f := range fixtures() {
+		f := f
+		t.Run(f.file, func(t *testing.T) {
+			t.Parallel()
+
+			db := openFixture(t, f, keepass.WithClock(clock.Fix(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))))
+
+			require.NoError(t, db.Entry("GitHub").SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+			require.NoError(t, db.Entry("Legacy").SetTOTPSecret(ctx, "NBSWY3DP", ""))
+			require.NoError(t, db.Entry("KeeOtp").SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+			require.NoError(t, db.Entry("Work/AWS").SetTOTPSecret(ctx, "NBSWY3DP", "Amazon"))
+
+			err := db.Entry("Work/Unknown").SetTOTPSecret(ctx, "NBSWY3DP", "")
+			require.ErrorIs(t, err, keepass.ErrEntryNotFound)
+
+			// The changes are written back to the file.
+			reopened, err := keepass.Open(db.Path(), f.options...)
+			require.NoError(t, err)
+
+			defer reopened.Destroy()
+
+			assert.Equal(t, otp.TOTPSecret(githubUpdatedURI), reopened.Entry("GitHub").TOTPSecret(ctx))
+			assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), reopened.Entry("Legacy").TOTPSecret(ctx))
+			assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), reopened.Entry("KeeOtp").TOTPSecret(ctx))
+			assert.Equal(t, otp.TOTPSecret("otpauth://totp/Amazon:admin?digits=6&issuer=Amazon&period=30&secret=NBSWY3DP"), reopened.Entry("Work/AWS").TOTPSecret(ctx))
+
+			entries, err := reopened.Entries()
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"GitHub", "Legacy", "KeeOtp", "Work/AWS"}, entries)
+
+			info, err := os.Stat(db.Path())
+			require.NoError(t, err)
+
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		})
+	}
+}
+
+func TestEntry_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, f := range fixtures() {
+		f := f
+		t.Run(f.file, func(t *testing.T) {
+			t.Parallel()
+
+			db := openFixture(t, f)
+
+			require.NoError(t, db.Entry("GitHub").DeleteTOTPSecret(ctx))
+			require.NoError(t, db.Entry("Legacy").DeleteTOTPSecret(ctx))
+			require.NoError(t, db.Entry("Work/AWS").DeleteTOTPSecret(ctx))
+			require.NoError(t, db.Entry("Unknown").DeleteTOTPSecret(ctx))
+
+			reopened, err := keepass.Open(db.Path(), f.options...)
+			require.NoError(t, err)
+
+			defer reopened.Destroy()
+
+			assert.Equal(t, otp.NoTOTPSecret, reopened.Entry("GitHub").TOTPSecret(ctx))
+			assert.Equal(t, otp.NoTOTPSecret, reopened.Entry("Legacy").TOTPSecret(ctx))
+
+			entries, err := reopened.Entries()
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"KeeOtp"}, entries)
+		})
+	}
+}
+
+func TestDatabase_ReloadOnChange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	f := fixtures()[2]
+	path := copyFixture(t, f.file)
+
+	first, err := keepass.Open(path, f.options...)
+	require.NoError(t, err)
+
+	defer first.Destroy()
+
+	second, err := keepass.Open(path, f.options...)
+	require.NoError(t, err)
+
+	defer second.Destroy()
+
+	assert.Equal(t, otp.TOTPSecret(githubURI), first.Entry("GitHub").TOTPSecret(ctx))
+
+	require.NoError(t, second.Entry("GitHub").SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", ""))
+
+	assert.Equal(t, otp.TOTPSecret(githubUpdatedURI), first.Entry("GitHub").TOTPSecret(ctx))
+
+	// The change of the other database is not lost.
+	require.NoError(t, first.Entry("Legacy").SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	assert.Equal(t, otp.TOTPSecret(githubUpdatedURI), second.Entry("GitHub").TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), second.Entry("Legacy").TOTPSecret(ctx))
+}
+
+func TestDatabase_Destroy(t *testing.T) {
+	t.Parallel()
+
+	db, err := keepass.Open(copyFixture(t, "kdbx3.kdbx"), keepass.WithPassword("password"))
+	require.NoError(t, err)
+
+	db.Destroy()
+
+	_, err = db.Entry("GitHub").Load(context.Background())
+
+	require.ErrorIs(t, err, otp.ErrSecretBufferDestroyed)
+}
+
+func TestEntry_ChainTOTPSecretProviders(t *testing.T) {
+	t.Parallel()
+
+	db := openFixture(t, fixtures()[0])
+	e := db.Entry("GitHub")
+	c := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret, e)
+
+	assert.Equal(t, e, e.TOTPSecretGetter())
+	assert.Equal(t, e, e.TOTPSecretSetter())
+	assert.Equal(t, e, e.TOTPSecretDeleter())
+	assert.Equal(t, otp.TOTPSecret(githubURI), c.TOTPSecret(context.Background()))
+}
@@ -0,0 +1,2 @@
+// Package keepass provides totp secret storage using KeePass (KDBX 3.1 and 4) databases.
+package keepass
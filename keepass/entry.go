@@ -0,0 +1,532 @@
+package keepass
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.nhat.io/otp"
+)
+
+// The fields of an entry that hold the TOTP secret.
+const (
+	// FieldOTP is the field that holds an otpauth URI, as written by KeePassXC, or the KeeOtp format, such as
+	// "key=NBSWY3DP&step=30&size=6".
+	FieldOTP = "otp"
+	// FieldTOTPSeed is the legacy KeePassXC field that holds the TOTP secret.
+	FieldTOTPSeed = "TOTP Seed"
+	// FieldTOTPSettings is the legacy KeePassXC field that holds the period and the digits, such as "30;6", or "30;S"
+	// for the Steam Guard codes.
+	FieldTOTPSettings = "TOTP Settings"
+)
+
+// ErrInvalidTOTPSettings indicates that the parameters of the TOTP secret in an entry are invalid.
+var ErrInvalidTOTPSettings = errors.New("invalid totp settings")
+
+// steamDigits is the number of characters of the Steam Guard codes.
+const steamDigits = 5
+
+// ticksOffset is the number of seconds between 0001-01-01 and 1970-01-01, for the times of KDBX 4.
+const ticksOffset = 62135596800
+
+var _ otp.TOTPSecretProvider = (*Entry)(nil)
+
+// Entry is a TOTP secret provider that stores the TOTP secret in an entry of a KeePass database. It reads the otp field,
+// then the legacy TOTP Seed field. If the period, the digits or the algorithm in the fields are not the defaults, the
+// TOTP secret is returned as an otpauth URI with these parameters.
+type Entry struct {
+	db   *Database
+	path string
+}
+
+// Path returns the path of the entry.
+func (e *Entry) Path() string {
+	return e.path
+}
+
+// TOTPSecret returns the TOTP secret of the entry. It returns otp.NoTOTPSecret if the entry does not exist or has no
+// TOTP secret, or if the database could not be read, see Load for the error.
+func (e *Entry) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := e.Load(ctx)
+
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		e.db.logger.Debug(ctx, "keepass entry does not exist", "path", e.db.path, "entry", e.path)
+
+	case err != nil:
+		e.db.logger.Error(ctx, "could not get totp secret from keepass", "error", err, "path", e.db.path, "entry", e.path)
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret of the entry. It returns ErrEntryNotFound if the entry does not exist.
+func (e *Entry) Load(_ context.Context) (otp.TOTPSecret, error) {
+	e.db.mu.Lock()
+	defer e.db.mu.Unlock()
+
+	if err := e.db.refresh(); err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	n := e.db.find(e.path)
+	if n == nil {
+		return otp.NoTOTPSecret, ErrEntryNotFound
+	}
+
+	return entryTOTPSecret(n)
+}
+
+// SetTOTPSecret sets the TOTP secret of the entry. It updates the field that holds the TOTP secret, or adds an otp
+// field with an otpauth URI. The previous version of the entry is kept in its history. It returns ErrEntryNotFound if
+// the entry does not exist.
+func (e *Entry) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, issuer string) error {
+	err := e.update(func(n *node) (bool, error) {
+		return true, setEntryTOTPSecret(n, secret, issuer)
+	})
+	if err != nil {
+		e.db.logger.Error(ctx, "could not persist totp secret to keepass", "error", err, "path", e.db.path, "entry", e.path)
+
+		return fmt.Errorf("could not persist totp secret to keepass: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret removes the fields that hold the TOTP secret from the entry. The previous version of the entry is
+// kept in its history. It does nothing if the entry does not exist.
+func (e *Entry) DeleteTOTPSecret(ctx context.Context) error {
+	err := e.update(func(n *node) (bool, error) {
+		return deleteEntryTOTPSecret(n), nil
+	})
+	if err != nil && !errors.Is(err, ErrEntryNotFound) {
+		e.db.logger.Error(ctx, "could not delete totp secret in keepass", "error", err, "path", e.db.path, "entry", e.path)
+
+		return fmt.Errorf("could not delete totp secret in keepass: %w", err)
+	}
+
+	return nil
+}
+
+// update changes the entry and saves the database if fn returns true. The database is read again before the change if
+// the file has changed on disk.
+func (e *Entry) update(fn func(n *node) (bool, error)) error {
+	e.db.mu.Lock()
+	defer e.db.mu.Unlock()
+
+	if err := e.db.refresh(); err != nil {
+		return err
+	}
+
+	n := e.db.find(e.path)
+	if n == nil {
+		return ErrEntryNotFound
+	}
+
+	backup := n.clone()
+
+	changed, err := fn(n)
+	if err != nil || !changed {
+		return err
+	}
+
+	e.db.addHistory(n, backup)
+	e.db.touch(n)
+
+	if err := e.db.save(); err != nil {
+		// The file is not changed, read it again on the next call.
+		e.db.db = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (e *Entry) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return e
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (e *Entry) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return e
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (e *Entry) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return e
+}
+
+// addHistory keeps the previous version of the entry in its history, and removes the oldest versions over the maximum
+// number of history items of the database.
+func (d *Database) addHistory(n, backup *node) {
+	if h := backup.child("History"); h != nil {
+		backup.remove(h)
+	}
+
+	history := n.child("History")
+	if history == nil {
+		history = &node{name: "History"}
+		n.children = append(n.children, history)
+	}
+
+	history.children = append(history.children, backup)
+
+	maxItems := -1
+
+	if m := d.db.doc.root.path("Meta", "HistoryMaxItems"); m != nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(m.text())); err == nil {
+			maxItems = v
+		}
+	}
+
+	if maxItems < 0 {
+		return
+	}
+
+	for items := history.elements("Entry"); len(items) > maxItems; items = items[1:] {
+		history.remove(items[0])
+	}
+}
+
+// touch sets the modification time of the entry.
+func (d *Database) touch(n *node) {
+	times := n.child("Times")
+	if times == nil {
+		return
+	}
+
+	now := d.clock.Now().UTC()
+	value := now.Format(time.RFC3339)
+
+	if d.db.major >= 4 {
+		var b [8]byte
+
+		binary.LittleEndian.PutUint64(b[:], uint64(now.Unix()+ticksOffset)) //nolint: gosec
+
+		value = base64.StdEncoding.EncodeToString(b[:])
+	}
+
+	for _, name := range []string{"LastModificationTime", "LastAccessTime"} {
+		if t := times.child(name); t != nil {
+			t.setText(value)
+		}
+	}
+}
+
+func entryTitle(e *node) string {
+	if v := entryField(e, "Title"); v != nil {
+		return v.text()
+	}
+
+	return ""
+}
+
+// entryField returns the value of a field of the entry.
+func entryField(e *node, key string) *node {
+	for _, s := range e.elements("String") {
+		if k := s.child("Key"); k != nil && k.text() == key {
+			return s.child("Value")
+		}
+	}
+
+	return nil
+}
+
+func hasTOTP(e *node) bool {
+	for _, key := range []string{FieldOTP, FieldTOTPSeed} {
+		if v := entryField(e, key); v != nil && strings.TrimSpace(v.text()) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func entryTOTPSecret(e *node) (otp.TOTPSecret, error) {
+	if v := entryField(e, FieldOTP); v != nil && strings.TrimSpace(v.text()) != "" {
+		value := strings.TrimSpace(v.text())
+
+		if otp.IsOTPAuthURI(value) {
+			return otp.TOTPSecretFromURI(value)
+		}
+
+		if q, err := url.ParseQuery(value); err == nil && q.Get("key") != "" {
+			return keeOtpTOTPSecret(e, q)
+		}
+
+		return normalizeSecret(value), nil
+	}
+
+	if v := entryField(e, FieldTOTPSeed); v != nil {
+		settings := ""
+		if s := entryField(e, FieldTOTPSettings); s != nil {
+			settings = strings.TrimSpace(s.text())
+		}
+
+		return legacyTOTPSecret(e, normalizeSecret(v.text()), settings)
+	}
+
+	return otp.NoTOTPSecret, nil
+}
+
+// legacyTOTPSecret returns the TOTP secret of the legacy KeePassXC fields. The settings are the period and the digits,
+// such as "30;6", or "30;S" for the Steam Guard codes. The other parts, such as the time server of KeeTrayTOTP, are
+// ignored.
+func legacyTOTPSecret(e *node, secret otp.TOTPSecret, settings string) (otp.TOTPSecret, error) {
+	if settings == "" {
+		return secret, nil
+	}
+
+	parts := strings.Split(settings, ";")
+	if len(parts) < 2 {
+		return otp.NoTOTPSecret, fmt.Errorf("%w: %q", ErrInvalidTOTPSettings, settings)
+	}
+
+	u := entryTOTPURI(e, secret, "")
+
+	period, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || period <= 0 {
+		return otp.NoTOTPSecret, fmt.Errorf("%w: %q", ErrInvalidTOTPSettings, settings)
+	}
+
+	u.Period = time.Duration(period) * time.Second
+
+	if digits := strings.TrimSpace(parts[1]); strings.EqualFold(digits, "S") {
+		u.Digits = steamDigits
+		u.Encoder = "steam"
+	} else if u.Digits, err = strconv.Atoi(digits); err != nil || u.Digits <= 0 {
+		return otp.NoTOTPSecret, fmt.Errorf("%w: %q", ErrInvalidTOTPSettings, settings)
+	}
+
+	return encodeTOTPURI(u), nil
+}
+
+// keeOtpTOTPSecret returns the TOTP secret of the KeeOtp format, such as "key=NBSWY3DP&step=30&size=6".
+func keeOtpTOTPSecret(e *node, q url.Values) (otp.TOTPSecret, error) {
+	u := entryTOTPURI(e, normalizeSecret(q.Get("key")), "")
+
+	var err error
+
+	if step := q.Get("step"); step != "" {
+		var period int
+		if period, err = strconv.Atoi(step); err != nil || period <= 0 {
+			return otp.NoTOTPSecret, fmt.Errorf("%w: step %q", ErrInvalidTOTPSettings, step)
+		}
+
+		u.Period = time.Duration(period) * time.Second
+	}
+
+	if size := q.Get("size"); size != "" {
+		if u.Digits, err = strconv.Atoi(size); err != nil || u.Digits <= 0 {
+			return otp.NoTOTPSecret, fmt.Errorf("%w: size %q", ErrInvalidTOTPSettings, size)
+		}
+	}
+
+	if mode := q.Get("otpHashMode"); mode != "" {
+		u.Algorithm = strings.ToUpper(mode)
+	}
+
+	return encodeTOTPURI(u), nil
+}
+
+// encodeTOTPURI returns the otpauth URI of the TOTP secret, or the bare TOTP secret if the parameters are the
+// defaults.
+func encodeTOTPURI(u otp.TOTPURI) otp.TOTPSecret {
+	if u.Period == otp.TOTPPeriod && u.Digits == 6 && (u.Algorithm == "" || strings.EqualFold(u.Algorithm, "SHA1")) &&
+		u.Encoder == "" {
+		return u.Secret
+	}
+
+	return u.Encode()
+}
+
+// normalizeSecret removes the spaces in the base32 secret, and converts it to upper case.
+func normalizeSecret(s string) otp.TOTPSecret {
+	return otp.TOTPSecret(strings.ToUpper(strings.Join(strings.Fields(s), "")))
+}
+
+func setEntryTOTPSecret(e *node, secret otp.TOTPSecret, issuer string) error {
+	if otp.IsOTPAuthURI(strings.TrimSpace(secret.Reveal())) {
+		return setEntryTOTPURI(e, secret)
+	}
+
+	if v := entryField(e, FieldOTP); v != nil && strings.TrimSpace(v.text()) != "" {
+		value := strings.TrimSpace(v.text())
+
+		if otp.IsOTPAuthURI(value) {
+			u, err := url.Parse(value)
+			if err != nil {
+				return fmt.Errorf("%w: malformed uri", otp.ErrInvalidOTPAuthURI)
+			}
+
+			q := u.Query()
+			q.Set("secret", secret.Reveal())
+
+			if issuer != "" {
+				q.Set("issuer", issuer)
+			}
+
+			u.RawQuery = q.Encode()
+
+			v.setText(u.String())
+
+			return nil
+		}
+
+		if q, err := url.ParseQuery(value); err == nil && q.Get("key") != "" {
+			q.Set("key", secret.Reveal())
+
+			v.setText(q.Encode())
+
+			return nil
+		}
+	}
+
+	if v := entryField(e, FieldTOTPSeed); v != nil && strings.TrimSpace(v.text()) != "" {
+		v.setText(secret.Reveal())
+
+		return nil
+	}
+
+	uri := totpURI(e, secret, issuer)
+
+	if v := entryField(e, FieldOTP); v != nil {
+		v.setText(uri)
+
+		return nil
+	}
+
+	addEntryField(e, FieldOTP, uri, true)
+
+	return nil
+}
+
+// setEntryTOTPURI sets the otpauth URI in the otp field. If the entry only has the legacy fields, they are kept when
+// they can hold the parameters of the URI, that is, when the algorithm is SHA1.
+func setEntryTOTPURI(e *node, uri otp.TOTPSecret) error {
+	u, err := otp.ParseTOTPURI(uri.Reveal())
+	if err != nil {
+		return err
+	}
+
+	value := strings.TrimSpace(uri.Reveal())
+
+	if v := entryField(e, FieldOTP); v != nil && strings.TrimSpace(v.text()) != "" {
+		v.setText(value)
+
+		return nil
+	}
+
+	if v := entryField(e, FieldTOTPSeed); v != nil && strings.TrimSpace(v.text()) != "" {
+		if strings.EqualFold(u.Algorithm, "SHA1") {
+			v.setText(u.Secret.Reveal())
+
+			settings := fmt.Sprintf("%d;%d", int(u.Period/time.Second), u.Digits)
+			if u.Encoder != "" {
+				settings = fmt.Sprintf("%d;S", int(u.Period/time.Second))
+			}
+
+			if v := entryField(e, FieldTOTPSettings); v != nil {
+				v.setText(settings)
+			} else {
+				addEntryField(e, FieldTOTPSettings, settings, false)
+			}
+
+			return nil
+		}
+
+		// The legacy fields cannot hold the algorithm.
+		removeEntryFields(e, FieldTOTPSeed, FieldTOTPSettings)
+	}
+
+	if v := entryField(e, FieldOTP); v != nil {
+		v.setText(value)
+
+		return nil
+	}
+
+	addEntryField(e, FieldOTP, value, true)
+
+	return nil
+}
+
+// totpURI returns the otpauth URI of the TOTP secret, labeled with the issuer and the username of the entry.
+func totpURI(e *node, secret otp.TOTPSecret, issuer string) string {
+	return entryTOTPURI(e, secret, issuer).Encode().Reveal()
+}
+
+// entryTOTPURI returns the default parameters of the TOTP secret, labeled with the issuer and the username of the
+// entry. The issuer defaults to the title of the entry.
+func entryTOTPURI(e *node, secret otp.TOTPSecret, issuer string) otp.TOTPURI {
+	if issuer == "" {
+		issuer = entryTitle(e)
+	}
+
+	account := entryTitle(e)
+	if v := entryField(e, "UserName"); v != nil && v.text() != "" {
+		account = v.text()
+	}
+
+	return otp.TOTPURI{
+		Issuer:      issuer,
+		AccountName: account,
+		Secret:      secret,
+		Digits:      6,
+		Period:      otp.TOTPPeriod,
+	}
+}
+
+func addEntryField(e *node, key, value string, protected bool) {
+	v := &node{name: "Value", children: []any{xml.CharData(value)}, protected: protected}
+	if protected {
+		v.attrs = []xml.Attr{{Name: xml.Name{Local: "Protected"}, Value: "True"}}
+	}
+
+	s := &node{name: "String", children: []any{
+		&node{name: "Key", children: []any{xml.CharData(key)}},
+		v,
+	}}
+
+	// Add the field after the other fields.
+	at := len(e.children)
+
+	for i, c := range e.children {
+		if c, ok := c.(*node); ok && c.name == "String" {
+			at = i + 1
+		}
+	}
+
+	e.children = append(e.children[:at], append([]any{s}, e.children[at:]...)...)
+}
+
+func deleteEntryTOTPSecret(e *node) bool {
+	return removeEntryFields(e, FieldOTP, FieldTOTPSeed, FieldTOTPSettings)
+}
+
+// removeEntryFields removes the fields from the entry. It returns true if a field is removed.
+func removeEntryFields(e *node, keys ...string) bool {
+	changed := false
+
+	for _, s := range e.elements("String") {
+		k := s.child("Key")
+		if k == nil || !slices.Contains(keys, k.text()) {
+			continue
+		}
+
+		e.remove(s)
+
+		changed = true
+	}
+
+	return changed
+}
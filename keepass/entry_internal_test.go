@@ -0,0 +1,293 @@
+//go:build unit || !integration
+
+package keepass
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+func TestEntry_SetTOTPSecret_KeepsDocument(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/kdbx3.kdbx")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "db.kdbx")
+
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	db, err := Open(path, WithPassword("password"), WithClock(clock.Fix(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))))
+	require.NoError(t, err)
+
+	defer db.Destroy()
+
+	ctx := context.Background()
+
+	for _, s := range []string{"JBSWY3DPEHPK3PXP", "NBSWY3DP", "JBSWY3DPEHPK3PXP"} {
+		require.NoError(t, db.Entry("GitHub").SetTOTPSecret(ctx, otp.TOTPSecret(s), ""))
+	}
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+
+	composite, err := compositeKey(ptr("password"), nil)
+	require.NoError(t, err)
+
+	k, _, err := decodeKDBX(data, composite)
+	require.NoError(t, err)
+
+	e := k.doc.root.path("Root", "Group").elements("Entry")[0]
+
+	// The other fields and elements are kept.
+	assert.Equal(t, "hunter2", entryField(e, "Password").text())
+	assert.True(t, entryField(e, "Password").protected)
+	assert.NotNil(t, e.child("AutoType"))
+
+	var doc bytes.Buffer
+
+	require.NoError(t, k.doc.encode(&doc, noStream{}))
+	assert.Contains(t, doc.String(), "<!-- A comment that is kept. -->")
+
+	// The previous versions are kept in the history, up to HistoryMaxItems.
+	history := e.child("History").elements("Entry")
+
+	require.Len(t, history, 2)
+	assert.Equal(t, "otpauth://totp/GitHub:john@example.com?digits=6&issuer=GitHub&period=30&secret=JBSWY3DPEHPK3PXP", entryField(history[0], "otp").text())
+	assert.Equal(t, "otpauth://totp/GitHub:john@example.com?digits=6&issuer=GitHub&period=30&secret=NBSWY3DP", entryField(history[1], "otp").text())
+	assert.Nil(t, history[0].child("History"))
+
+	assert.Equal(t, "2024-01-02T00:00:00Z", e.path("Times", "LastModificationTime").text())
+	assert.Equal(t, "2024-01-01T00:00:00Z", e.path("Times", "CreationTime").text())
+
+	// The header hash is updated.
+	sum := sha256.Sum256(data[:bytes.Index(data, []byte("\r\n\r\n"))+4])
+
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), k.doc.root.path("Meta", "HeaderHash").text())
+}
+
+func TestTotpURI(t *testing.T) {
+	t.Parallel()
+
+	e := &node{name: "Entry"}
+
+	addEntryField(e, "Title", "AWS", false)
+	addEntryField(e, "UserName", "john doe@example.com", false)
+
+	assert.Equal(t, "otpauth://totp/Amazon:john%20doe@example.com?digits=6&issuer=Amazon&period=30&secret=NBSWY3DP",
+		totpURI(e, "NBSWY3DP", "Amazon"))
+	assert.Equal(t, "otpauth://totp/AWS:john%20doe@example.com?digits=6&issuer=AWS&period=30&secret=NBSWY3DP",
+		totpURI(e, "NBSWY3DP", ""))
+}
+
+func TestEntryTOTPSecret_Settings(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		fields         map[string]string
+		expectedResult otp.TOTPSecret
+		expectedError  string
+	}{
+		{
+			scenario:       "no settings",
+			fields:         map[string]string{FieldTOTPSeed: "nbsw y3dp"},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "default settings",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;6"},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "8 digits",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;8"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=8&issuer=AWS&period=30&secret=NBSWY3DP",
+		},
+		{
+			scenario:       "60 seconds",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "60;6"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=6&issuer=AWS&period=60&secret=NBSWY3DP",
+		},
+		{
+			scenario:       "steam",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;S"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=5&encoder=steam&issuer=AWS&period=30&secret=NBSWY3DP",
+		},
+		{
+			scenario:       "time server",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;8;time.example.com"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=8&issuer=AWS&period=30&secret=NBSWY3DP",
+		},
+		{
+			scenario:      "missing digits",
+			fields:        map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30"},
+			expectedError: `invalid totp settings: "30"`,
+		},
+		{
+			scenario:      "invalid period",
+			fields:        map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "0;6"},
+			expectedError: `invalid totp settings: "0;6"`,
+		},
+		{
+			scenario:      "invalid digits",
+			fields:        map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;X"},
+			expectedError: `invalid totp settings: "30;X"`,
+		},
+		{
+			scenario:       "keeotp",
+			fields:         map[string]string{FieldOTP: "key=NBSWY3DP&step=60&size=8&otpHashMode=sha256"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?algorithm=SHA256&digits=8&issuer=AWS&period=60&secret=NBSWY3DP",
+		},
+		{
+			scenario:       "keeotp default settings",
+			fields:         map[string]string{FieldOTP: "key=NBSWY3DP&step=30&size=6"},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:      "keeotp invalid step",
+			fields:        map[string]string{FieldOTP: "key=NBSWY3DP&step=abc"},
+			expectedError: `invalid totp settings: step "abc"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			e := &node{name: "Entry"}
+
+			addEntryField(e, "Title", "AWS", false)
+			addEntryField(e, "UserName", "john@example.com", false)
+
+			for k, v := range tc.fields {
+				addEntryField(e, k, v, false)
+			}
+
+			actual, err := entryTOTPSecret(e)
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestSetEntryTOTPSecret_URI(t *testing.T) {
+	t.Parallel()
+
+	const uri = "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME&digits=8"
+
+	testCases := []struct {
+		scenario       string
+		fields         map[string]string
+		secret         otp.TOTPSecret
+		expectedFields map[string]string
+		expectedResult otp.TOTPSecret
+	}{
+		{
+			scenario:       "otpauth uri",
+			fields:         map[string]string{FieldOTP: "otpauth://totp/GitHub:john?secret=NBSWY3DP"},
+			secret:         uri,
+			expectedFields: map[string]string{FieldOTP: uri},
+			expectedResult: uri,
+		},
+		{
+			scenario:       "keeotp",
+			fields:         map[string]string{FieldOTP: "key=NBSWY3DP&step=30&size=6"},
+			secret:         uri,
+			expectedFields: map[string]string{FieldOTP: uri},
+			expectedResult: uri,
+		},
+		{
+			scenario:       "legacy",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;6"},
+			secret:         uri,
+			expectedFields: map[string]string{FieldTOTPSeed: "JBSWY3DPEHPK3PXP", FieldTOTPSettings: "30;8"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=8&issuer=AWS&period=30&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario:       "legacy without settings",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP"},
+			secret:         "otpauth://totp/Steam:john?secret=JBSWY3DPEHPK3PXP&encoder=steam",
+			expectedFields: map[string]string{FieldTOTPSeed: "JBSWY3DPEHPK3PXP", FieldTOTPSettings: "30;S"},
+			expectedResult: "otpauth://totp/AWS:john@example.com?digits=5&encoder=steam&issuer=AWS&period=30&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario:       "legacy with default parameters",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "60;8"},
+			secret:         "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP",
+			expectedFields: map[string]string{FieldTOTPSeed: "JBSWY3DPEHPK3PXP", FieldTOTPSettings: "30;6"},
+			expectedResult: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario:       "legacy with another algorithm",
+			fields:         map[string]string{FieldTOTPSeed: "NBSWY3DP", FieldTOTPSettings: "30;6"},
+			secret:         "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256",
+			expectedFields: map[string]string{FieldOTP: "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256"},
+			expectedResult: "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256",
+		},
+		{
+			scenario:       "no totp secret",
+			secret:         uri,
+			expectedFields: map[string]string{FieldOTP: uri},
+			expectedResult: uri,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			e := &node{name: "Entry"}
+
+			addEntryField(e, "Title", "AWS", false)
+			addEntryField(e, "UserName", "john@example.com", false)
+
+			for k, v := range tc.fields {
+				addEntryField(e, k, v, false)
+			}
+
+			require.NoError(t, setEntryTOTPSecret(e, tc.secret, "Amazon"))
+
+			actual := make(map[string]string)
+
+			for _, k := range []string{FieldOTP, FieldTOTPSeed, FieldTOTPSettings} {
+				if v := entryField(e, k); v != nil {
+					actual[k] = v.text()
+				}
+			}
+
+			assert.Equal(t, tc.expectedFields, actual)
+
+			result, err := entryTOTPSecret(e)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
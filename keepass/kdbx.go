@@ -0,0 +1,765 @@
+package keepass
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/chacha20"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// ErrInvalidDatabase indicates that the file is not a KeePass database or is corrupted.
+var ErrInvalidDatabase = errors.New("invalid keepass database")
+
+// ErrUnsupportedVersion indicates that the version of the database is not supported.
+var ErrUnsupportedVersion = errors.New("unsupported keepass database version")
+
+// ErrUnsupportedCipher indicates that the cipher, the key derivation function, or the inner random stream of the
+// database is not supported.
+var ErrUnsupportedCipher = errors.New("unsupported cipher")
+
+// ErrInvalidCredentials indicates that the password or the key file is wrong, or that the database is corrupted.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+const (
+	signature1      uint32 = 0x9AA2D903
+	signature2      uint32 = 0xB54BFB67
+	blockSize              = 1024 * 1024
+	compressionGzip uint32 = 1
+)
+
+// The ids of the outer header fields.
+const (
+	fieldEndOfHeader        = 0
+	fieldCipherID           = 2
+	fieldCompressionFlags   = 3
+	fieldMasterSeed         = 4
+	fieldTransformSeed      = 5
+	fieldTransformRounds    = 6
+	fieldEncryptionIV       = 7
+	fieldProtectedStreamKey = 8
+	fieldStreamStartBytes   = 9
+	fieldInnerRandomStream  = 10
+	fieldKdfParameters      = 11
+)
+
+// The ids of the inner header fields.
+const (
+	innerFieldEndOfHeader = 0
+	innerFieldStreamID    = 1
+	innerFieldStreamKey   = 2
+	innerFieldBinary      = 3
+)
+
+var (
+	cipherAES256   = [16]byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	cipherChaCha20 = [16]byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+)
+
+type headerField struct {
+	id   byte
+	data []byte
+}
+
+// kdbx is a decrypted KeePass database.
+type kdbx struct {
+	major, minor uint16
+
+	// fields are the outer header fields, in the order of the file.
+	fields []headerField
+	// innerFields are the inner header fields of KDBX 4, in the order of the file.
+	innerFields []headerField
+
+	doc *document
+}
+
+func (k *kdbx) field(id byte) []byte {
+	for _, f := range k.fields {
+		if f.id == id {
+			return f.data
+		}
+	}
+
+	return nil
+}
+
+func setField(fields []headerField, id byte, data []byte) []headerField {
+	for i, f := range fields {
+		if f.id == id {
+			fields[i].data = data
+
+			return fields
+		}
+	}
+
+	// Keep the end of header at the end.
+	n := len(fields)
+	if n > 0 && fields[n-1].id == fieldEndOfHeader {
+		return append(fields[:n-1], headerField{id: id, data: data}, fields[n-1])
+	}
+
+	return append(fields, headerField{id: id, data: data})
+}
+
+func uint32Field(fields []headerField, id byte) uint32 {
+	for _, f := range fields {
+		if f.id == id && len(f.data) >= 4 {
+			return binary.LittleEndian.Uint32(f.data)
+		}
+	}
+
+	return 0
+}
+
+// transformKey derives the transformed key from the composite key with the key derivation function of the database.
+func (k *kdbx) transformKey(composite []byte) ([]byte, error) {
+	if k.major < 4 {
+		rounds := k.field(fieldTransformRounds)
+		if len(rounds) != 8 {
+			return nil, fmt.Errorf("%w: missing transform rounds", ErrInvalidDatabase)
+		}
+
+		return aesKDF(composite, k.field(fieldTransformSeed), binary.LittleEndian.Uint64(rounds))
+	}
+
+	params, err := decodeVariantDictionary(k.field(fieldKdfParameters))
+	if err != nil {
+		return nil, err
+	}
+
+	return params.transformKey(composite)
+}
+
+func readHeaderFields(r *bytes.Reader, sizeLen int) ([]headerField, error) {
+	var fields []headerField
+
+	for {
+		id, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		var size uint32
+
+		if sizeLen == 2 {
+			var s uint16
+
+			err = binary.Read(r, binary.LittleEndian, &s)
+			size = uint32(s)
+		} else {
+			err = binary.Read(r, binary.LittleEndian, &size)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		if int64(size) > int64(r.Len()) {
+			return nil, fmt.Errorf("%w: truncated header", ErrInvalidDatabase)
+		}
+
+		data := make([]byte, size)
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		fields = append(fields, headerField{id: id, data: data})
+
+		if id == fieldEndOfHeader {
+			return fields, nil
+		}
+	}
+}
+
+func writeHeaderFields(w *bytes.Buffer, fields []headerField, sizeLen int) {
+	for _, f := range fields {
+		w.WriteByte(f.id)
+
+		if sizeLen == 2 {
+			_ = binary.Write(w, binary.LittleEndian, uint16(len(f.data))) //nolint: errcheck,gosec
+		} else {
+			_ = binary.Write(w, binary.LittleEndian, uint32(len(f.data))) //nolint: errcheck,gosec
+		}
+
+		w.Write(f.data)
+	}
+}
+
+// decodeKDBX decrypts a KeePass database with the composite key. It returns the database and the transformed key.
+func decodeKDBX(data []byte, composite []byte) (*kdbx, []byte, error) {
+	r := bytes.NewReader(data)
+
+	var sig [3]uint32
+
+	if err := binary.Read(r, binary.LittleEndian, &sig); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+
+	if sig[0] != signature1 || sig[1] != signature2 {
+		return nil, nil, ErrInvalidDatabase
+	}
+
+	k := &kdbx{minor: uint16(sig[2]), major: uint16(sig[2] >> 16)} //nolint: gosec
+
+	sizeLen := 4
+
+	switch k.major {
+	case 3:
+		sizeLen = 2
+
+	case 4:
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %d.%d", ErrUnsupportedVersion, k.major, k.minor)
+	}
+
+	fields, err := readHeaderFields(r, sizeLen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k.fields = fields
+	header := data[:len(data)-r.Len()]
+
+	transformed, err := k.transformKey(composite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if k.major < 4 {
+		err = k.decodeV3(data[len(header):], transformed)
+	} else {
+		err = k.decodeV4(header, data[len(header):], transformed)
+	}
+
+	if err != nil {
+		zero.Bytes(transformed)
+
+		return nil, nil, err
+	}
+
+	return k, transformed, nil
+}
+
+func (k *kdbx) decodeV3(data, transformed []byte) error {
+	masterSeed := k.field(fieldMasterSeed)
+	key := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+
+	defer zero.Bytes(key[:])
+
+	plain, err := decrypt(k.field(fieldCipherID), key[:], k.field(fieldEncryptionIV), data)
+	if err != nil {
+		return err
+	}
+
+	defer zero.Bytes(plain)
+
+	start := k.field(fieldStreamStartBytes)
+	if len(start) == 0 || !bytes.HasPrefix(plain, start) {
+		return ErrInvalidCredentials
+	}
+
+	payload, err := readHashedBlocks(plain[len(start):])
+	if err != nil {
+		return err
+	}
+
+	if payload, err = decompress(uint32Field(k.fields, fieldCompressionFlags), payload); err != nil {
+		return err
+	}
+
+	defer zero.Bytes(payload)
+
+	stream, err := newInnerStream(uint32Field(k.fields, fieldInnerRandomStream), k.field(fieldProtectedStreamKey))
+	if err != nil {
+		return err
+	}
+
+	k.doc, err = decodeDocument(payload, stream)
+
+	return err
+}
+
+func (k *kdbx) decodeV4(header, data, transformed []byte) error {
+	if len(data) < 64 {
+		return fmt.Errorf("%w: truncated header", ErrInvalidDatabase)
+	}
+
+	if sum := sha256.Sum256(header); !bytes.Equal(sum[:], data[:32]) {
+		return fmt.Errorf("%w: header hash mismatch", ErrInvalidDatabase)
+	}
+
+	masterSeed := k.field(fieldMasterSeed)
+	hmacKey := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformed...), 0x01))
+
+	defer zero.Bytes(hmacKey[:])
+
+	if !hmac.Equal(headerHMAC(hmacKey[:], header), data[32:64]) {
+		return ErrInvalidCredentials
+	}
+
+	encrypted, err := readHMACBlocks(hmacKey[:], data[64:])
+	if err != nil {
+		return err
+	}
+
+	key := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+
+	defer zero.Bytes(key[:])
+
+	plain, err := decrypt(k.field(fieldCipherID), key[:], k.field(fieldEncryptionIV), encrypted)
+	if err != nil {
+		return err
+	}
+
+	if plain, err = decompress(uint32Field(k.fields, fieldCompressionFlags), plain); err != nil {
+		return err
+	}
+
+	defer zero.Bytes(plain)
+
+	r := bytes.NewReader(plain)
+
+	if k.innerFields, err = readHeaderFields(r, 4); err != nil {
+		return err
+	}
+
+	var streamKey []byte
+
+	for _, f := range k.innerFields {
+		if f.id == innerFieldStreamKey {
+			streamKey = f.data
+		}
+	}
+
+	stream, err := newInnerStream(uint32Field(k.innerFields, innerFieldStreamID), streamKey)
+	if err != nil {
+		return err
+	}
+
+	k.doc, err = decodeDocument(plain[len(plain)-r.Len():], stream)
+
+	return err
+}
+
+// encode encrypts the database with the transformed key. The master seed, the encryption iv and the key of the inner
+// random stream are regenerated, the key derivation parameters are kept.
+func (k *kdbx) encode(transformed []byte) ([]byte, error) {
+	masterSeed, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := randomBytes(len(k.field(fieldEncryptionIV)))
+	if err != nil {
+		return nil, err
+	}
+
+	k.fields = setField(k.fields, fieldMasterSeed, masterSeed)
+	k.fields = setField(k.fields, fieldEncryptionIV, iv)
+
+	if k.major < 4 {
+		return k.encodeV3(transformed)
+	}
+
+	return k.encodeV4(transformed)
+}
+
+func (k *kdbx) writeSignature(w *bytes.Buffer) {
+	_ = binary.Write(w, binary.LittleEndian, [3]uint32{signature1, signature2, uint32(k.major)<<16 | uint32(k.minor)}) //nolint: errcheck
+}
+
+func (k *kdbx) encodeV3(transformed []byte) ([]byte, error) {
+	streamKey, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := randomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	k.fields = setField(k.fields, fieldProtectedStreamKey, streamKey)
+	k.fields = setField(k.fields, fieldStreamStartBytes, start)
+
+	var header bytes.Buffer
+
+	k.writeSignature(&header)
+	writeHeaderFields(&header, k.fields, 2)
+
+	if h := k.doc.root.path("Meta", "HeaderHash"); h != nil {
+		sum := sha256.Sum256(header.Bytes())
+
+		h.setText(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	stream, err := newInnerStream(uint32Field(k.fields, fieldInnerRandomStream), streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bytes.Buffer
+
+	if err := k.doc.encode(&doc, stream); err != nil {
+		return nil, err
+	}
+
+	payload, err := compress(uint32Field(k.fields, fieldCompressionFlags), doc.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	plain := append(bytes.Clone(start), writeHashedBlocks(payload)...)
+
+	key := sha256.Sum256(append(bytes.Clone(k.field(fieldMasterSeed)), transformed...))
+
+	defer zero.Bytes(key[:])
+
+	encrypted, err := encrypt(k.field(fieldCipherID), key[:], k.field(fieldEncryptionIV), plain)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(header.Bytes(), encrypted...), nil
+}
+
+func (k *kdbx) encodeV4(transformed []byte) ([]byte, error) {
+	streamID := uint32Field(k.innerFields, innerFieldStreamID)
+
+	streamKey, err := randomBytes(64)
+	if err != nil {
+		return nil, err
+	}
+
+	k.innerFields = setField(k.innerFields, innerFieldStreamKey, streamKey)
+
+	var header bytes.Buffer
+
+	k.writeSignature(&header)
+	writeHeaderFields(&header, k.fields, 4)
+
+	stream, err := newInnerStream(streamID, streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var inner bytes.Buffer
+
+	writeHeaderFields(&inner, k.innerFields, 4)
+
+	if err := k.doc.encode(&inner, stream); err != nil {
+		return nil, err
+	}
+
+	payload, err := compress(uint32Field(k.fields, fieldCompressionFlags), inner.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	masterSeed := k.field(fieldMasterSeed)
+	key := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+	hmacKey := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformed...), 0x01))
+
+	defer zero.Bytes(key[:])
+	defer zero.Bytes(hmacKey[:])
+
+	encrypted, err := encrypt(k.field(fieldCipherID), key[:], k.field(fieldEncryptionIV), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(header.Bytes())
+	sum := sha256.Sum256(header.Bytes())
+
+	out.Write(sum[:])
+	out.Write(headerHMAC(hmacKey[:], header.Bytes()))
+	out.Write(writeHMACBlocks(hmacKey[:], encrypted))
+
+	return out.Bytes(), nil
+}
+
+func decrypt(cipherID, key, iv, data []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cipherID, cipherAES256[:]):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+			return nil, ErrInvalidCredentials
+		}
+
+		plain := make([]byte, len(data))
+
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+		pad := int(plain[len(plain)-1])
+		if pad == 0 || pad > aes.BlockSize {
+			return nil, ErrInvalidCredentials
+		}
+
+		return plain[:len(plain)-pad], nil
+
+	case bytes.Equal(cipherID, cipherChaCha20[:]):
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		plain := make([]byte, len(data))
+
+		c.XORKeyStream(plain, data)
+
+		return plain, nil
+	}
+
+	return nil, fmt.Errorf("%w: %x", ErrUnsupportedCipher, cipherID)
+}
+
+func encrypt(cipherID, key, iv, data []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cipherID, cipherAES256[:]):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		pad := aes.BlockSize - len(data)%aes.BlockSize
+		out := append(bytes.Clone(data), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+
+		return out, nil
+
+	case bytes.Equal(cipherID, cipherChaCha20[:]):
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, len(data))
+
+		c.XORKeyStream(out, data)
+
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("%w: %x", ErrUnsupportedCipher, cipherID)
+}
+
+// readHashedBlocks reads the hashed block stream of KDBX 3.1.
+func readHashedBlocks(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	r := bytes.NewReader(data)
+
+	for {
+		var head struct {
+			Index uint32
+			Hash  [32]byte
+			Size  uint32
+		}
+
+		if err := binary.Read(r, binary.LittleEndian, &head); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		if head.Size == 0 {
+			return out.Bytes(), nil
+		}
+
+		if int64(head.Size) > int64(r.Len()) {
+			return nil, fmt.Errorf("%w: truncated block", ErrInvalidDatabase)
+		}
+
+		block := make([]byte, head.Size)
+
+		_, _ = io.ReadFull(r, block) //nolint: errcheck
+
+		if sha256.Sum256(block) != head.Hash {
+			return nil, fmt.Errorf("%w: block hash mismatch", ErrInvalidDatabase)
+		}
+
+		out.Write(block)
+	}
+}
+
+func writeHashedBlocks(data []byte) []byte {
+	var out bytes.Buffer
+
+	index := uint32(0)
+
+	for len(data) > 0 {
+		n := min(len(data), blockSize)
+
+		_ = binary.Write(&out, binary.LittleEndian, index)                   //nolint: errcheck
+		_ = binary.Write(&out, binary.LittleEndian, sha256.Sum256(data[:n])) //nolint: errcheck
+		_ = binary.Write(&out, binary.LittleEndian, uint32(n))               //nolint: errcheck,gosec
+
+		out.Write(data[:n])
+
+		data = data[n:]
+		index++
+	}
+
+	_ = binary.Write(&out, binary.LittleEndian, index)      //nolint: errcheck
+	_ = binary.Write(&out, binary.LittleEndian, [32]byte{}) //nolint: errcheck
+	_ = binary.Write(&out, binary.LittleEndian, uint32(0))  //nolint: errcheck
+
+	return out.Bytes()
+}
+
+func hmacBlockKey(key []byte, index uint64) [sha512.Size]byte {
+	var idx [8]byte
+
+	binary.LittleEndian.PutUint64(idx[:], index)
+
+	return sha512.Sum512(append(idx[:], key...))
+}
+
+func headerHMAC(key, header []byte) []byte {
+	blockKey := hmacBlockKey(key, math.MaxUint64)
+
+	defer zero.Bytes(blockKey[:])
+
+	h := hmac.New(sha256.New, blockKey[:])
+
+	h.Write(header)
+
+	return h.Sum(nil)
+}
+
+func blockHMAC(key []byte, index uint64, data []byte) []byte {
+	blockKey := hmacBlockKey(key, index)
+
+	defer zero.Bytes(blockKey[:])
+
+	h := hmac.New(sha256.New, blockKey[:])
+
+	_ = binary.Write(h, binary.LittleEndian, index)             //nolint: errcheck
+	_ = binary.Write(h, binary.LittleEndian, uint32(len(data))) //nolint: errcheck,gosec
+
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// readHMACBlocks reads the HMAC block stream of KDBX 4.
+func readHMACBlocks(key, data []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	r := bytes.NewReader(data)
+
+	for index := uint64(0); ; index++ {
+		var head struct {
+			HMAC [32]byte
+			Size uint32
+		}
+
+		if err := binary.Read(r, binary.LittleEndian, &head); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+		}
+
+		if int64(head.Size) > int64(r.Len()) {
+			return nil, fmt.Errorf("%w: truncated block", ErrInvalidDatabase)
+		}
+
+		block := make([]byte, head.Size)
+
+		_, _ = io.ReadFull(r, block) //nolint: errcheck
+
+		if !hmac.Equal(blockHMAC(key, index, block), head.HMAC[:]) {
+			return nil, fmt.Errorf("%w: block hmac mismatch", ErrInvalidDatabase)
+		}
+
+		if head.Size == 0 {
+			return out.Bytes(), nil
+		}
+
+		out.Write(block)
+	}
+}
+
+func writeHMACBlocks(key, data []byte) []byte {
+	var out bytes.Buffer
+
+	for index := uint64(0); ; index++ {
+		n := min(len(data), blockSize)
+
+		out.Write(blockHMAC(key, index, data[:n]))
+
+		_ = binary.Write(&out, binary.LittleEndian, uint32(n)) //nolint: errcheck,gosec
+
+		out.Write(data[:n])
+
+		if n == 0 {
+			return out.Bytes()
+		}
+
+		data = data[n:]
+	}
+}
+
+func decompress(flags uint32, data []byte) ([]byte, error) {
+	if flags != compressionGzip {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDatabase, err)
+	}
+
+	return out, nil
+}
+
+func compress(flags uint32, data []byte) ([]byte, error) {
+	if flags != compressionGzip {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+
+	w := gzip.NewWriter(&out)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("could not generate random bytes: %w", err)
+	}
+
+	return b, nil
+}
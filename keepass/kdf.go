@@ -0,0 +1,153 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+var (
+	kdfAES      = [16]byte{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8a, 0x4f, 0xea}
+	kdfArgon2d  = [16]byte{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0c, 0x0a}
+	kdfArgon2id = [16]byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+// The value types of the variant dictionary.
+const (
+	variantEnd       = 0x00
+	variantUInt32    = 0x04
+	variantUInt64    = 0x05
+	variantByteArray = 0x42
+)
+
+// variantDictionary is the dictionary of the key derivation parameters in KDBX 4.
+type variantDictionary map[string][]byte
+
+func decodeVariantDictionary(data []byte) (variantDictionary, error) {
+	r := bytes.NewReader(data)
+	d := make(variantDictionary)
+
+	var version uint16
+
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil || version>>8 != 1 {
+		return nil, fmt.Errorf("%w: invalid kdf parameters", ErrInvalidDatabase)
+	}
+
+	for {
+		typ, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid kdf parameters", ErrInvalidDatabase)
+		}
+
+		if typ == variantEnd {
+			return d, nil
+		}
+
+		key, err := readVariantItem(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readVariantItem(r)
+		if err != nil {
+			return nil, err
+		}
+
+		d[string(key)] = value
+	}
+}
+
+func readVariantItem(r *bytes.Reader) ([]byte, error) {
+	var size int32
+
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil || size < 0 || int64(size) > int64(r.Len()) {
+		return nil, fmt.Errorf("%w: invalid kdf parameters", ErrInvalidDatabase)
+	}
+
+	b := make([]byte, size)
+
+	_, _ = io.ReadFull(r, b) //nolint: errcheck
+
+	return b, nil
+}
+
+func (d variantDictionary) uint64(key string) (uint64, bool) {
+	switch v := d[key]; len(v) {
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(v)), true
+
+	case 8:
+		return binary.LittleEndian.Uint64(v), true
+	}
+
+	return 0, false
+}
+
+func (d variantDictionary) transformKey(composite []byte) ([]byte, error) {
+	id := d["$UUID"]
+
+	switch {
+	case bytes.Equal(id, kdfAES[:]):
+		rounds, ok := d.uint64("R")
+		if !ok {
+			return nil, fmt.Errorf("%w: missing aes-kdf rounds", ErrInvalidDatabase)
+		}
+
+		return aesKDF(composite, d["S"], rounds)
+
+	case bytes.Equal(id, kdfArgon2d[:]), bytes.Equal(id, kdfArgon2id[:]):
+		mode := argon2d
+		if bytes.Equal(id, kdfArgon2id[:]) {
+			mode = argon2id
+		}
+
+		iterations, ok1 := d.uint64("I")
+		memory, ok2 := d.uint64("M")
+		parallelism, ok3 := d.uint64("P")
+		version, _ := d.uint64("V") //nolint: errcheck
+
+		if !ok1 || !ok2 || !ok3 || len(d["S"]) == 0 {
+			return nil, fmt.Errorf("%w: missing argon2 parameters", ErrInvalidDatabase)
+		}
+
+		if version != argon2Version {
+			return nil, fmt.Errorf("%w: argon2 version %#x", ErrUnsupportedCipher, version)
+		}
+
+		if iterations == 0 || iterations > math.MaxUint32 || memory/1024 > math.MaxUint32 || parallelism == 0 || parallelism > math.MaxUint8 {
+			return nil, fmt.Errorf("%w: invalid argon2 parameters", ErrInvalidDatabase)
+		}
+
+		return argon2Key(mode, composite, d["S"], d["K"], d["A"],
+			uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil //nolint: gosec
+	}
+
+	return nil, fmt.Errorf("%w: kdf %x", ErrUnsupportedCipher, id)
+}
+
+// aesKDF encrypts the composite key with the seed for a number of rounds, then hashes it.
+func aesKDF(composite, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid aes-kdf seed: %w", ErrInvalidDatabase, err)
+	}
+
+	key := bytes.Clone(composite)
+
+	defer zero.Bytes(key)
+
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(key[:16], key[:16])
+		block.Encrypt(key[16:], key[16:])
+	}
+
+	sum := sha256.Sum256(key)
+
+	return sum[:], nil
+}
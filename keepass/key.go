@@ -0,0 +1,120 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// compositeKey returns the composite key of the password and the key file.
+func compositeKey(password *string, keyFile []byte) ([]byte, error) {
+	if password == nil && keyFile == nil {
+		return nil, ErrNoCredentials
+	}
+
+	h := sha256.New()
+
+	if password != nil {
+		p := sha256.Sum256([]byte(*password))
+
+		h.Write(p[:])
+		zero.Bytes(p[:])
+	}
+
+	if keyFile != nil {
+		k, err := keyFileKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Write(k)
+		zero.Bytes(k)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// keyFileKey returns the key of a key file. The key file is either an XML key file (version 1.0 or 2.0), 32 raw bytes,
+// 64 hex characters, or any other file that is hashed.
+func keyFileKey(data []byte) ([]byte, error) {
+	if k, ok, err := xmlKeyFileKey(data); ok || err != nil {
+		return k, err
+	}
+
+	switch len(data) {
+	case 32:
+		return bytes.Clone(data), nil
+
+	case 64:
+		if k, err := hex.DecodeString(string(data)); err == nil {
+			return k, nil
+		}
+	}
+
+	h := sha256.Sum256(data)
+
+	return h[:], nil
+}
+
+func xmlKeyFileKey(data []byte) ([]byte, bool, error) {
+	var kf struct {
+		XMLName xml.Name `xml:"KeyFile"`
+		Meta    struct {
+			Version string `xml:"Version"`
+		} `xml:"Meta"`
+		Key struct {
+			Data struct {
+				Hash  string `xml:"Hash,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"Data"`
+		} `xml:"Key"`
+	}
+
+	if err := xml.Unmarshal(data, &kf); err != nil {
+		return nil, false, nil //nolint: nilerr
+	}
+
+	switch {
+	case strings.HasPrefix(kf.Meta.Version, "1."):
+		k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kf.Key.Data.Value))
+		if err != nil {
+			return nil, true, fmt.Errorf("%w: %w", ErrInvalidKeyFile, err)
+		}
+
+		return k, true, nil
+
+	case strings.HasPrefix(kf.Meta.Version, "2."):
+		k, err := hex.DecodeString(strings.Join(strings.Fields(kf.Key.Data.Value), ""))
+		if err != nil {
+			return nil, true, fmt.Errorf("%w: %w", ErrInvalidKeyFile, err)
+		}
+
+		if kf.Key.Data.Hash != "" {
+			sum := sha256.Sum256(k)
+
+			if !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Key.Data.Hash) {
+				return nil, true, fmt.Errorf("%w: hash mismatch", ErrInvalidKeyFile)
+			}
+		}
+
+		return k, true, nil
+	}
+
+	return nil, true, fmt.Errorf("%w: unsupported version %q", ErrInvalidKeyFile, kf.Meta.Version)
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path) //nolint: gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,63 @@
+package keepass
+
+import (
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+type config struct {
+	password    *string
+	keyFilePath string
+	keyFileData []byte
+
+	clock  clock.Clock
+	logger ctxd.Logger
+}
+
+// Option configures the Database.
+type Option interface {
+	applyOption(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) applyOption(c *config) {
+	f(c)
+}
+
+// WithPassword unlocks the database with the password.
+func WithPassword(password string) Option {
+	return optionFunc(func(c *config) {
+		c.password = &password
+	})
+}
+
+// WithKeyFile unlocks the database with the key file.
+func WithKeyFile(path string) Option {
+	return optionFunc(func(c *config) {
+		c.keyFilePath = path
+	})
+}
+
+// WithKeyFileData unlocks the database with the content of a key file.
+func WithKeyFileData(data []byte) Option {
+	return optionFunc(func(c *config) {
+		c.keyFileData = data
+	})
+}
+
+// WithClock sets the clock that is used for the modification time of the entries.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.clock = c
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.logger = otp.RedactLogger(l)
+	})
+}
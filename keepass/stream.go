@@ -0,0 +1,83 @@
+package keepass
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// The ids of the inner random streams.
+const (
+	innerStreamNone     uint32 = 0
+	innerStreamSalsa20  uint32 = 2
+	innerStreamChaCha20 uint32 = 3
+)
+
+// salsa20IV is the fixed nonce of the Salsa20 inner random stream.
+var salsa20IV = [8]byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+
+// innerStream encrypts the protected values in the XML document.
+type innerStream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+type noStream struct{}
+
+func (noStream) XORKeyStream(dst, src []byte) {
+	copy(dst, src)
+}
+
+// salsa20Stream is a Salsa20 key stream that keeps its position between the calls.
+type salsa20Stream struct {
+	key     [32]byte
+	counter [16]byte
+	block   [64]byte
+	pos     int
+}
+
+func (s *salsa20Stream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.pos == len(s.block) {
+			var zeros [64]byte
+
+			salsa.XORKeyStream(s.block[:], zeros[:], &s.counter, &s.key)
+
+			binary.LittleEndian.PutUint64(s.counter[8:], binary.LittleEndian.Uint64(s.counter[8:])+1)
+
+			s.pos = 0
+		}
+
+		dst[i] = src[i] ^ s.block[s.pos]
+		s.pos++
+	}
+}
+
+func newInnerStream(id uint32, key []byte) (innerStream, error) {
+	switch id {
+	case innerStreamNone:
+		return noStream{}, nil
+
+	case innerStreamSalsa20:
+		s := &salsa20Stream{key: sha256.Sum256(key), pos: 64}
+
+		copy(s.counter[:8], salsa20IV[:])
+
+		return s, nil
+
+	case innerStreamChaCha20:
+		h := sha512.Sum512(key)
+
+		c, err := chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+		if err != nil {
+			return nil, fmt.Errorf("could not create inner random stream: %w", err)
+		}
+
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("%w: inner random stream %d", ErrUnsupportedCipher, id)
+}
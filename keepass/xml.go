@@ -0,0 +1,343 @@
+package keepass
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// node is an element of the XML document. The document is kept as a tree, so the elements that the package does not
+// know are written back as they are.
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []any // *node, xml.CharData, xml.Comment, xml.ProcInst or xml.Directive.
+
+	// protected is true if the value is encrypted with the inner random stream in the file. The value is kept in
+	// plaintext in the tree.
+	protected bool
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c, ok := c.(*node); ok && c.name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (n *node) elements(name string) []*node {
+	var result []*node
+
+	for _, c := range n.children {
+		if c, ok := c.(*node); ok && c.name == name {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+func (n *node) path(names ...string) *node {
+	for _, name := range names {
+		if n = n.child(name); n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+func (n *node) text() string {
+	var sb strings.Builder
+
+	for _, c := range n.children {
+		if c, ok := c.(xml.CharData); ok {
+			sb.Write(c)
+		}
+	}
+
+	return sb.String()
+}
+
+func (n *node) setText(s string) {
+	n.children = []any{xml.CharData(s)}
+}
+
+func (n *node) remove(c *node) {
+	for i, v := range n.children {
+		if v == c {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+
+			return
+		}
+	}
+}
+
+func (n *node) clone() *node {
+	c := &node{
+		name:      n.name,
+		attrs:     append([]xml.Attr(nil), n.attrs...),
+		children:  make([]any, 0, len(n.children)),
+		protected: n.protected,
+	}
+
+	for _, v := range n.children {
+		if v, ok := v.(*node); ok {
+			c.children = append(c.children, v.clone())
+
+			continue
+		}
+
+		c.children = append(c.children, v)
+	}
+
+	return c
+}
+
+func isProtected(attrs []xml.Attr) bool {
+	for _, a := range attrs {
+		if a.Name.Local == "Protected" && strings.EqualFold(a.Value, "true") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// document is the XML document of the database.
+type document struct {
+	prolog []any
+	root   *node
+}
+
+// decodeDocument decodes the XML document and decrypts the protected values with the inner random stream, in
+// document order.
+func decodeDocument(data []byte, stream innerStream) (*document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	doc := &document{}
+
+	var stack []*node
+
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not decode xml: %w", err)
+		}
+
+		tok = xml.CopyToken(tok)
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: qualifiedName(t.Name), attrs: t.Attr}
+
+			if len(stack) == 0 {
+				if doc.root != nil {
+					return nil, fmt.Errorf("could not decode xml: %w", ErrInvalidDatabase)
+				}
+
+				doc.root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("could not decode xml: %w", ErrInvalidDatabase)
+			}
+
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if isProtected(n.attrs) {
+				if err := unprotect(n, stream); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			if len(stack) == 0 {
+				if doc.root == nil {
+					doc.prolog = append(doc.prolog, t)
+				}
+
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, t)
+		}
+	}
+
+	if doc.root == nil || len(stack) != 0 {
+		return nil, fmt.Errorf("could not decode xml: %w", ErrInvalidDatabase)
+	}
+
+	return doc, nil
+}
+
+func unprotect(n *node, stream innerStream) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(n.text()))
+	if err != nil {
+		return fmt.Errorf("could not decode protected value: %w", err)
+	}
+
+	stream.XORKeyStream(data, data)
+
+	n.setText(string(data))
+	n.protected = true
+
+	zero.Bytes(data)
+
+	return nil
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+// encode writes the XML document and encrypts the protected values with the inner random stream, in document order.
+func (d *document) encode(w *bytes.Buffer, stream innerStream) error {
+	for _, t := range d.prolog {
+		if err := encodeToken(w, t); err != nil {
+			return err
+		}
+	}
+
+	if err := encodeNode(w, d.root, stream); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func encodeNode(w *bytes.Buffer, n *node, stream innerStream) error {
+	w.WriteByte('<')
+	w.WriteString(n.name)
+
+	for _, a := range n.attrs {
+		w.WriteByte(' ')
+		w.WriteString(qualifiedName(a.Name))
+		w.WriteString(`="`)
+
+		escape(w, a.Value, true)
+		w.WriteByte('"')
+	}
+
+	if n.protected {
+		data := []byte(n.text())
+
+		stream.XORKeyStream(data, data)
+
+		w.WriteByte('>')
+		w.WriteString(base64.StdEncoding.EncodeToString(data))
+		w.WriteString("</" + n.name + ">")
+
+		return nil
+	}
+
+	if len(n.children) == 0 {
+		w.WriteString(" />")
+
+		return nil
+	}
+
+	w.WriteByte('>')
+
+	for _, c := range n.children {
+		if c, ok := c.(*node); ok {
+			if err := encodeNode(w, c, stream); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := encodeToken(w, c); err != nil {
+			return err
+		}
+	}
+
+	w.WriteString("</" + n.name + ">")
+
+	return nil
+}
+
+func encodeToken(w *bytes.Buffer, t any) error {
+	switch t := t.(type) {
+	case xml.CharData:
+		escape(w, string(t), false)
+
+	case xml.Comment:
+		w.WriteString("<!--")
+		w.Write(t)
+		w.WriteString("-->")
+
+	case xml.ProcInst:
+		w.WriteString("<?" + t.Target)
+
+		if len(t.Inst) > 0 {
+			w.WriteByte(' ')
+			w.Write(t.Inst)
+		}
+
+		w.WriteString("?>")
+
+	case xml.Directive:
+		w.WriteString("<!")
+		w.Write(t)
+		w.WriteByte('>')
+	}
+
+	return nil
+}
+
+// escape escapes the special characters. Unlike xml.EscapeText, the whitespaces are kept as they are, so the formatting
+// of the document does not change.
+func escape(w *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			w.WriteString("&amp;")
+		case r == '<':
+			w.WriteString("&lt;")
+		case r == '>':
+			w.WriteString("&gt;")
+		case r == '"' && attr:
+			w.WriteString("&quot;")
+		case (r == '\n' || r == '\r' || r == '\t') && attr:
+			fmt.Fprintf(w, "&#x%X;", r)
+		default:
+			w.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package keyctl provides totp secret storage using the Linux kernel keyring.
+package keyctl
@@ -0,0 +1,169 @@
+//go:build linux
+
+package keyctl
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"go.nhat.io/otp"
+)
+
+const keyType = "user"
+
+// keyPerm grants all the permissions to the possessor and the owner of the key, so the key of the user keyring can be
+// read by the processes of the user that do not possess it.
+const keyPerm = 0x3f3f0000
+
+func keyringID(k Keyring) (int, error) {
+	switch k {
+	case KeyringSession:
+		return unix.KEY_SPEC_SESSION_KEYRING, nil
+
+	case KeyringUser:
+		return unix.KEY_SPEC_USER_KEYRING, nil
+
+	case KeyringPersistent:
+		// The persistent keyring is linked to the process keyring, so the process possesses it.
+		id, err := unix.KeyctlInt(unix.KEYCTL_GET_PERSISTENT, -1, unix.KEY_SPEC_PROCESS_KEYRING, 0, 0)
+		if err != nil {
+			return 0, fmt.Errorf("could not get persistent keyring: %w", err)
+		}
+
+		return id, nil
+	}
+
+	return 0, fmt.Errorf("unknown keyring: %d", k)
+}
+
+// isNoKey returns true if the key does not exist, has expired or has been revoked.
+func isNoKey(err error) bool {
+	return errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED)
+}
+
+func searchKey(k Keyring, description string) (int, int, error) {
+	ring, err := keyringID(k)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	id, err := unix.KeyctlSearch(ring, keyType, description, 0)
+	if err != nil {
+		return 0, ring, err
+	}
+
+	return id, ring, nil
+}
+
+func readKey(k Keyring, description string) (otp.TOTPSecret, error) {
+	id, _, err := searchKey(k, description)
+	if isNoKey(err) {
+		return otp.NoTOTPSecret, nil
+	}
+
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	buf, err := otp.NewSecretBuffer(size)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	defer buf.Destroy()
+
+	var secret otp.TOTPSecret
+
+	err = buf.Use(func(b []byte) error {
+		n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, b, 0)
+		if err != nil {
+			return err
+		}
+
+		secret = otp.TOTPSecret(b[:min(n, size)])
+
+		return nil
+	})
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	return secret, nil
+}
+
+func addKey(k Keyring, description string, secret otp.TOTPSecret, timeout time.Duration) (err error) {
+	ring, err := keyringID(k)
+	if err != nil {
+		return err
+	}
+
+	payload := []byte(secret)
+
+	// The key is set up in the process keyring, which the process possesses, then linked to the keyring, where it
+	// replaces the key if it exists. The keys of the user keyring are not possessed unless the user keyring is linked
+	// to the session keyring.
+	id, err := unix.AddKey(keyType, description, payload, unix.KEY_SPEC_PROCESS_KEYRING)
+
+	for i := range payload {
+		payload[i] = 0
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The key is unlinked from the process keyring once it is linked to the keyring, or if it could not be set up.
+	defer func() {
+		err = errors.Join(err, discardKey(id, unix.KEY_SPEC_PROCESS_KEYRING))
+	}()
+
+	if _, err := unix.KeyctlInt(unix.KEYCTL_SETPERM, id, keyPerm, 0, 0); err != nil {
+		return fmt.Errorf("could not set permissions: %w", err)
+	}
+
+	if timeout > 0 {
+		if _, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, int((timeout+time.Second-1)/time.Second), 0, 0); err != nil {
+			return fmt.Errorf("could not set timeout: %w", err)
+		}
+	}
+
+	if _, err := unix.KeyctlInt(unix.KEYCTL_LINK, id, ring, 0, 0); err != nil {
+		return fmt.Errorf("could not link key: %w", err)
+	}
+
+	return nil
+}
+
+// discardKey unlinks the key from the keyring.
+func discardKey(id, ring int) error {
+	if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, id, ring, 0, 0); err != nil && !isNoKey(err) {
+		return fmt.Errorf("could not unlink key: %w", err)
+	}
+
+	return nil
+}
+
+func unlinkKey(k Keyring, description string) error {
+	id, ring, err := searchKey(k, description)
+	if isNoKey(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, id, ring, 0, 0); err != nil && !isNoKey(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package keyctl
+
+import (
+	"time"
+
+	"go.nhat.io/otp"
+)
+
+func readKey(Keyring, string) (otp.TOTPSecret, error) {
+	return otp.NoTOTPSecret, ErrUnsupported
+}
+
+func addKey(Keyring, string, otp.TOTPSecret, time.Duration) error {
+	return ErrUnsupported
+}
+
+func unlinkKey(Keyring, string) error {
+	return ErrUnsupported
+}
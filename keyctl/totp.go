@@ -0,0 +1,171 @@
+package keyctl
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp"
+)
+
+const descriptionPrefix = "go.nhat.io/totp:"
+
+// ErrUnsupported indicates that the kernel keyring is not supported on the platform.
+var ErrUnsupported = errors.New("kernel keyring is not supported")
+
+// Keyring is a kernel keyring, see keyrings(7).
+type Keyring int
+
+const (
+	// KeyringSession is the session keyring of the process, it is discarded when the session ends.
+	KeyringSession Keyring = iota
+	// KeyringUser is the keyring of the user, it is shared by all the processes of the user.
+	KeyringUser
+	// KeyringPersistent is the persistent keyring of the user, it survives the sessions of the user until it expires.
+	KeyringPersistent
+)
+
+// String returns the name of the keyring.
+func (k Keyring) String() string {
+	switch k {
+	case KeyringSession:
+		return "session"
+
+	case KeyringUser:
+		return "user"
+
+	case KeyringPersistent:
+		return "persistent"
+	}
+
+	return "unknown"
+}
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider is a TOTP secret getter and setter that uses the kernel keyring to store the TOTP secret. The
+// secret is stored as a "user" key, described as "go.nhat.io/totp:<account>". It does not need any daemon.
+type TOTPSecretProvider struct {
+	keyring Keyring
+	timeout time.Duration
+	logger  ctxd.Logger
+
+	account string
+}
+
+func (s *TOTPSecretProvider) description() string {
+	return descriptionPrefix + s.account
+}
+
+// TOTPSecret returns the TOTP secret from the kernel keyring.
+func (s *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	if s.account == "" {
+		return otp.NoTOTPSecret
+	}
+
+	secret, err := readKey(s.keyring, s.description())
+	if err != nil {
+		s.logger.Error(ctx, "could not get totp secret from kernel keyring", "error", err, "keyring", s.keyring, "account", s.account)
+
+		return otp.NoTOTPSecret
+	}
+
+	return secret
+}
+
+// SetTOTPSecret persists the TOTP secret to the kernel keyring. The key expires after the timeout if it is set.
+func (s *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	if s.account == "" {
+		return nil
+	}
+
+	if err := addKey(s.keyring, s.description(), secret, s.timeout); err != nil {
+		s.logger.Error(ctx, "could not persist totp secret to kernel keyring", "error", err, "keyring", s.keyring, "account", s.account)
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret deletes the TOTP secret in the kernel keyring.
+func (s *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	if s.account == "" {
+		return nil
+	}
+
+	if err := unlinkKey(s.keyring, s.description()); err != nil {
+		s.logger.Error(ctx, "could not delete totp secret in kernel keyring", "error", err, "keyring", s.keyring, "account", s.account)
+
+		return err
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (s *TOTPSecretProvider) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return s
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (s *TOTPSecretProvider) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return s
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (s *TOTPSecretProvider) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return s
+}
+
+// TOTPSecretFromKeyring returns a TOTP secret getter and setter that uses the kernel keyring to store the TOTP secret.
+// By default, it uses the session keyring and the secret does not expire.
+func TOTPSecretFromKeyring(account string, opts ...Option) *TOTPSecretProvider {
+	s := &TOTPSecretProvider{
+		keyring: KeyringSession,
+		logger:  ctxd.NoOpLogger{},
+
+		account: account,
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(s)
+	}
+
+	return s
+}
+
+// Option configures TOTPSecretProvider.
+type Option interface {
+	applyOption(s *TOTPSecretProvider)
+}
+
+type optionFunc func(s *TOTPSecretProvider)
+
+func (f optionFunc) applyOption(s *TOTPSecretProvider) {
+	f(s)
+}
+
+// WithKeyring sets the kernel keyring. Default is KeyringSession.
+func WithKeyring(k Keyring) Option {
+	return optionFunc(func(s *TOTPSecretProvider) {
+		s.keyring = k
+	})
+}
+
+// WithTimeout sets the time after which the TOTP secret expires, rounded up to the second. The timeout is set every
+// time the secret is persisted. Default is no timeout.
+func WithTimeout(d time.Duration) Option {
+	return optionFunc(func(s *TOTPSecretProvider) {
+		s.timeout = d
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(s *TOTPSecretProvider) {
+		s.logger = otp.RedactLogger(l)
+	})
+}
@@ -0,0 +1,123 @@
+//go:build linux && (unit || !integration)
+
+package keyctl_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/keyctl"
+)
+
+func account(t *testing.T) string {
+	t.Helper()
+
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func newProvider(t *testing.T, opts ...keyctl.Option) *keyctl.TOTPSecretProvider {
+	t.Helper()
+
+	p := keyctl.TOTPSecretFromKeyring(account(t), opts...)
+
+	t.Cleanup(func() {
+		_ = p.DeleteTOTPSecret(context.Background()) //nolint: errcheck
+	})
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+		t.Skipf("kernel keyring is not available: %s", err)
+	}
+
+	require.NoError(t, err)
+
+	return p
+}
+
+func TestTOTPSecretProvider(t *testing.T) {
+	t.Parallel()
+
+	for _, k := range []keyctl.Keyring{keyctl.KeyringSession, keyctl.KeyringUser, keyctl.KeyringPersistent} {
+		k := k
+
+		t.Run(k.String(), func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			p := newProvider(t, keyctl.WithKeyring(k))
+
+			assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+			err := p.SetTOTPSecret(ctx, "JBSWY3DPEHPK3PXP", "")
+			require.NoError(t, err)
+
+			assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), p.TOTPSecret(ctx))
+
+			err = p.DeleteTOTPSecret(ctx)
+			require.NoError(t, err)
+
+			assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+			// Deleting a missing secret is not an error.
+			err = p.DeleteTOTPSecret(ctx)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTOTPSecretProvider_Timeout(t *testing.T) {
+	t.Parallel()
+
+	p := newProvider(t, keyctl.WithTimeout(time.Second))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+
+	assert.Eventually(t, func() bool {
+		return p.TOTPSecret(context.Background()) == otp.NoTOTPSecret
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestTOTPSecretProvider_NoAccount(t *testing.T) {
+	t.Parallel()
+
+	p := keyctl.TOTPSecretFromKeyring("")
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+	require.NoError(t, p.SetTOTPSecret(context.Background(), "NBSWY3DP", ""))
+	require.NoError(t, p.DeleteTOTPSecret(context.Background()))
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+}
+
+func TestTOTPSecretProvider_UnknownKeyring(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	p := keyctl.TOTPSecretFromKeyring(account(t), keyctl.WithKeyring(keyctl.Keyring(42)), keyctl.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+	require.EqualError(t, p.SetTOTPSecret(context.Background(), "NBSWY3DP", ""), "unknown keyring: 42")
+
+	require.Len(t, l.LoggedEntries, 2)
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+}
+
+func TestKeyring_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "session", keyctl.KeyringSession.String())
+	assert.Equal(t, "user", keyctl.KeyringUser.String())
+	assert.Equal(t, "persistent", keyctl.KeyringPersistent.String())
+	assert.Equal(t, "unknown", keyctl.Keyring(42).String())
+}
@@ -1,6 +1,10 @@
 package keyring
 
-import "github.com/bool64/ctxd"
+import (
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp"
+)
 
 // Option configures the services provided by the keyring package.
 type Option interface {
@@ -11,11 +15,11 @@ type option struct {
 	TOTPSecretProviderOption
 }
 
-// WithLogger sets the logger for the keyring package.
+// WithLogger sets the logger for the keyring package. The TOTP secrets are redacted from the logs.
 func WithLogger(l ctxd.Logger) Option {
 	return option{
 		TOTPSecretProviderOption: totpSecretProviderOptionFunc(func(s *TOTPSecretProvider) {
-			s.logger = l
+			s.logger = otp.RedactLogger(l)
 		}),
 	}
 }
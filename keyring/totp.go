@@ -16,36 +16,75 @@ var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
 
 // TOTPSecretProvider is a TOTP secret getter and setter that uses the keyring to store the TOTP secret.
 type TOTPSecretProvider struct {
-	storage secretstorage.Storage[otp.TOTPSecret]
+	storage secretstorage.Storage[otp.ExportedTOTPSecret]
 	logger  ctxd.Logger
 
 	account   string
-	secret    otp.TOTPSecret
+	secret    *otp.SecretBuffer
 	fetchOnce sync.Once
 }
 
-func (s *TOTPSecretProvider) fetch(ctx context.Context) otp.TOTPSecret {
+func (s *TOTPSecretProvider) fetch(ctx context.Context) *otp.SecretBuffer {
 	if s.account == "" {
-		return otp.NoTOTPSecret
+		return nil
 	}
 
 	secret, err := s.storage.Get(keyringServiceTOTP, s.account)
 	if err != nil {
 		s.logger.Error(ctx, "could not get totp secret from keyring", "error", err, "service", keyringServiceTOTP, "account", s.account)
 
-		return otp.NoTOTPSecret
+		return nil
 	}
 
-	return secret
+	if secret == "" {
+		return nil
+	}
+
+	buf, err := otp.NewSecretBufferFromBytes([]byte(secret))
+	if err != nil {
+		s.logger.Error(ctx, "could not keep totp secret in memory", "error", err, "service", keyringServiceTOTP, "account", s.account)
+
+		return nil
+	}
+
+	return buf
 }
 
-// TOTPSecret returns the TOTP secret from the keyring.
+// TOTPSecret returns the TOTP secret from the keyring. The secret is fetched once and kept in a SecretBuffer, the
+// returned TOTP secret is a copy of it.
 func (s *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
 	s.fetchOnce.Do(func() {
 		s.secret = s.fetch(ctx)
 	})
 
-	return s.secret
+	if s.secret == nil {
+		return otp.NoTOTPSecret
+	}
+
+	return revealSecretBuffer(s.secret)
+}
+
+// revealSecretBuffer returns a copy of the TOTP secret in the buffer, or otp.NoTOTPSecret if the buffer has been
+// destroyed.
+func revealSecretBuffer(b *otp.SecretBuffer) otp.TOTPSecret {
+	secret := otp.NoTOTPSecret
+
+	_ = b.Use(func(b []byte) error { //nolint: errcheck
+		secret = otp.TOTPSecret(b)
+
+		return nil
+	})
+
+	return secret
+}
+
+// Destroy zeroes the TOTP secret kept in memory. The provider returns no TOTP secret afterward.
+func (s *TOTPSecretProvider) Destroy() {
+	s.fetchOnce.Do(func() {})
+
+	if s.secret != nil {
+		s.secret.Destroy()
+	}
 }
 
 // SetTOTPSecret persists the TOTP secret to the keyring.
@@ -54,7 +93,7 @@ func (s *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPS
 		return nil
 	}
 
-	if err := s.storage.Set(keyringServiceTOTP, s.account, secret); err != nil {
+	if err := s.storage.Set(keyringServiceTOTP, s.account, otp.ExportedTOTPSecret(secret)); err != nil {
 		s.logger.Error(ctx, "could not persist totp secret to keyring", "error", err, "service", keyringServiceTOTP, "account", s.account)
 
 		return err
@@ -81,7 +120,7 @@ func (s *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
 // TOTPSecretFromKeyring returns a TOTP secret getter and setter that uses the keyring to store the TOTP secret.
 func TOTPSecretFromKeyring(account string, opts ...TOTPSecretProviderOption) *TOTPSecretProvider {
 	s := &TOTPSecretProvider{
-		storage: secretstorage.NewKeyringStorage[otp.TOTPSecret](),
+		storage: secretstorage.NewKeyringStorage[otp.ExportedTOTPSecret](),
 		logger:  ctxd.NoOpLogger{},
 
 		account: account,
@@ -105,8 +144,9 @@ func (f totpSecretProviderOptionFunc) applyTOTPSecretGetSetterOption(s *TOTPSecr
 	f(s)
 }
 
-// WithStorage sets the storage for the TOTP secret getter and setter.
-func WithStorage(storage secretstorage.Storage[otp.TOTPSecret]) TOTPSecretProviderOption {
+// WithStorage sets the storage for the TOTP secret getter and setter. The TOTP secret is stored as an
+// otp.ExportedTOTPSecret, which is marshaled as is, unlike otp.TOTPSecret.
+func WithStorage(storage secretstorage.Storage[otp.ExportedTOTPSecret]) TOTPSecretProviderOption {
 	return totpSecretProviderOptionFunc(func(s *TOTPSecretProvider) {
 		s.storage = storage
 	})
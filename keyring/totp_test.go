@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	gokeyring "github.com/zalando/go-keyring"
+	"go.nhat.io/secretstorage"
 	mockss "go.nhat.io/secretstorage/mock"
 
 	"go.nhat.io/otp"
@@ -21,39 +23,39 @@ func TestTOTPSecretProvider_TOTPSecret(t *testing.T) {
 
 	testCases := []struct {
 		scenario       string
-		mockStorage    mockss.StorageMocker[otp.TOTPSecret]
+		mockStorage    mockss.StorageMocker[otp.ExportedTOTPSecret]
 		account        string
 		expectedResult otp.TOTPSecret
 	}{
 		{
 			scenario:       "no account",
-			mockStorage:    mockss.MockStorage[otp.TOTPSecret](),
+			mockStorage:    mockss.MockStorage[otp.ExportedTOTPSecret](),
 			account:        "",
 			expectedResult: "",
 		},
 		{
 			scenario: "storage error",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Get", mock.Anything, mock.Anything).
-					Return(otp.NoTOTPSecret, assert.AnError)
+					Return(otp.ExportedTOTPSecret(""), assert.AnError)
 			}),
 			account:        "account",
 			expectedResult: "",
 		},
 		{
 			scenario: "no secret",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Get", "go.nhat.io/totp", "account").
-					Return(otp.NoTOTPSecret, nil)
+					Return(otp.ExportedTOTPSecret(""), nil)
 			}),
 			account:        "account",
 			expectedResult: "",
 		},
 		{
 			scenario: "has secret",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Get", "go.nhat.io/totp", "account").
-					Return(otp.TOTPSecret("secret"), nil)
+					Return(otp.ExportedTOTPSecret("secret"), nil)
 			}),
 			account:        "account",
 			expectedResult: "secret",
@@ -82,18 +84,18 @@ func TestTOTPSecretProvider_SetTOTPSecret(t *testing.T) {
 
 	testCases := []struct {
 		scenario      string
-		mockStorage   mockss.StorageMocker[otp.TOTPSecret]
+		mockStorage   mockss.StorageMocker[otp.ExportedTOTPSecret]
 		account       string
 		expectedError string
 	}{
 		{
 			scenario:    "no account",
-			mockStorage: mockss.MockStorage[otp.TOTPSecret](),
+			mockStorage: mockss.MockStorage[otp.ExportedTOTPSecret](),
 			account:     "",
 		},
 		{
 			scenario: "storage error",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Set", mock.Anything, mock.Anything, mock.Anything).
 					Return(assert.AnError)
 			}),
@@ -102,8 +104,8 @@ func TestTOTPSecretProvider_SetTOTPSecret(t *testing.T) {
 		},
 		{
 			scenario: "success",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
-				s.On("Set", "go.nhat.io/totp", "account", otp.TOTPSecret("secret")).
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
+				s.On("Set", "go.nhat.io/totp", "account", otp.ExportedTOTPSecret("secret")).
 					Return(nil)
 			}),
 			account: "account",
@@ -136,18 +138,18 @@ func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
 
 	testCases := []struct {
 		scenario      string
-		mockStorage   mockss.StorageMocker[otp.TOTPSecret]
+		mockStorage   mockss.StorageMocker[otp.ExportedTOTPSecret]
 		account       string
 		expectedError string
 	}{
 		{
 			scenario:    "no account",
-			mockStorage: mockss.MockStorage[otp.TOTPSecret](),
+			mockStorage: mockss.MockStorage[otp.ExportedTOTPSecret](),
 			account:     "",
 		},
 		{
 			scenario: "storage error",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Delete", mock.Anything, mock.Anything).
 					Return(assert.AnError)
 			}),
@@ -156,7 +158,7 @@ func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
 		},
 		{
 			scenario: "success",
-			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.TOTPSecret]) {
+			mockStorage: mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
 				s.On("Delete", "go.nhat.io/totp", "account").
 					Return(nil)
 			}),
@@ -184,3 +186,63 @@ func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestTOTPSecretProvider_Destroy(t *testing.T) {
+	t.Parallel()
+
+	s := keyring.TOTPSecretFromKeyring("account",
+		keyring.WithStorage(mockss.MockStorage(func(s *mockss.Storage[otp.ExportedTOTPSecret]) {
+			s.On("Get", "go.nhat.io/totp", "account").Once().
+				Return(otp.ExportedTOTPSecret("secret"), nil)
+		})(t)),
+	)
+
+	assert.Equal(t, otp.TOTPSecret("secret"), s.TOTPSecret(context.Background()))
+
+	s.Destroy()
+	s.Destroy()
+
+	assert.Equal(t, otp.NoTOTPSecret, s.TOTPSecret(context.Background()))
+}
+
+func TestTOTPSecretProvider_Keyring(t *testing.T) { //nolint: paralleltest
+	gokeyring.MockInit()
+
+	ctx := context.Background()
+
+	err := keyring.TOTPSecretFromKeyring("john.doe@example.com").SetTOTPSecret(ctx, "NBSWY3DP", "")
+	require.NoError(t, err)
+
+	// The TOTP secret is stored as is.
+	stored, err := gokeyring.Get("go.nhat.io/totp", "john.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "NBSWY3DP", stored)
+
+	p := keyring.TOTPSecretFromKeyring("john.doe@example.com")
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(ctx))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	assert.Equal(t, otp.NoTOTPSecret, keyring.TOTPSecretFromKeyring("john.doe@example.com").TOTPSecret(ctx))
+}
+
+func TestTOTPSecretProvider_KeyringStorage(t *testing.T) { //nolint: paralleltest
+	gokeyring.MockInit()
+
+	ctx := context.Background()
+	storage := secretstorage.NewKeyringStorage[otp.ExportedTOTPSecret]()
+
+	err := keyring.TOTPSecretFromKeyring("jane.doe@example.com", keyring.WithStorage(storage)).SetTOTPSecret(ctx, "NBSWY3DP", "")
+	require.NoError(t, err)
+
+	stored, err := gokeyring.Get("go.nhat.io/totp", "jane.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, "NBSWY3DP", stored)
+
+	actual := keyring.TOTPSecretFromKeyring("jane.doe@example.com", keyring.WithStorage(storage)).TOTPSecret(ctx)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), actual)
+}
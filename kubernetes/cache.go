@@ -0,0 +1,54 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrCacheNotSynced indicates that the cache could not be synced before the context is done.
+var ErrCacheNotSynced = errors.New("cache not synced")
+
+// Cache is an informer-based cache of a Secret, shared by the providers that read from it. Only the Secret is listed and
+// watched, so that the other Secrets of the namespace are not kept in memory.
+type Cache struct {
+	factory informers.SharedInformerFactory
+	lister  corelisters.SecretLister
+	synced  cache.InformerSynced
+}
+
+// Start starts watching the Secret and waits until the cache is synced. The cache stops when the context is done.
+func (c *Cache) Start(ctx context.Context) error {
+	c.factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced) {
+		return ErrCacheNotSynced
+	}
+
+	return nil
+}
+
+// NewCache initiates a new Cache of the Secret with the name in the namespace. The resync period may be zero to disable
+// the resync.
+func NewCache(client kubernetes.Interface, namespace, name string, resync time.Duration) *Cache {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	informer := factory.Core().V1().Secrets()
+
+	return &Cache{
+		factory: factory,
+		lister:  informer.Lister(),
+		synced:  informer.Informer().HasSynced,
+	}
+}
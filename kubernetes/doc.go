@@ -0,0 +1,2 @@
+// Package kubernetes provides totp secret storage using Kubernetes Secrets.
+package kubernetes
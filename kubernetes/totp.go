@@ -0,0 +1,229 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bool64/ctxd"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"go.nhat.io/otp"
+)
+
+// IssuerAnnotation is the annotation of the Secret that keeps the issuer of the TOTP secret.
+const IssuerAnnotation = "otp.nhat.io/issuer"
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider is a TOTP secret provider that stores the TOTP secret in a key of a namespaced Secret. The value
+// is either a bare secret or an otpauth URI. The updates use optimistic concurrency, they are retried with the latest
+// version of the Secret on conflict.
+type TOTPSecretProvider struct {
+	client kubernetes.Interface
+	cache  *Cache
+	logger ctxd.Logger
+
+	namespace string
+	name      string
+	key       string
+}
+
+func (p *TOTPSecretProvider) get(ctx context.Context) (*corev1.Secret, error) {
+	if p.cache != nil {
+		return p.cache.lister.Secrets(p.namespace).Get(p.name)
+	}
+
+	return p.client.CoreV1().Secrets(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+}
+
+// TOTPSecret returns the TOTP secret from the Secret, or from the cache if there is one.
+func (p *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	secret, err := p.get(ctx)
+	if apierrors.IsNotFound(err) {
+		return otp.NoTOTPSecret
+	}
+
+	if err != nil {
+		p.logger.Error(ctx, "could not get totp secret from kubernetes", "error", err, "namespace", p.namespace, "secret", p.name, "key", p.key)
+
+		return otp.NoTOTPSecret
+	}
+
+	s, err := otp.ParseTOTPSecret(string(secret.Data[p.key]))
+	if err != nil {
+		p.logger.Error(ctx, "could not parse totp secret from kubernetes", "error", err, "namespace", p.namespace, "secret", p.name, "key", p.key)
+
+		return otp.NoTOTPSecret
+	}
+
+	return s
+}
+
+// SetTOTPSecret persists the TOTP secret to the key of the Secret. The Secret is created if it does not exist, the
+// other keys are kept.
+func (p *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, issuer string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := p.client.CoreV1().Secrets(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = p.client.CoreV1().Secrets(p.namespace).Create(ctx, p.newSecret(secret, issuer), metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, retry with an update.
+				return apierrors.NewConflict(corev1.Resource("secrets"), p.name, err)
+			}
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s = s.DeepCopy()
+
+		if s.Data == nil {
+			s.Data = make(map[string][]byte)
+		}
+
+		s.Data[p.key] = []byte(secret.Reveal())
+
+		setIssuer(&s.ObjectMeta, issuer)
+
+		// The resource version of the Secret that is read is sent back, the update fails if it has changed since.
+		_, err = p.client.CoreV1().Secrets(p.namespace).Update(ctx, s, metav1.UpdateOptions{})
+
+		return err
+	})
+	if err != nil {
+		p.logger.Error(ctx, "could not persist totp secret to kubernetes", "error", err, "namespace", p.namespace, "secret", p.name, "key", p.key)
+
+		return fmt.Errorf("could not persist totp secret to kubernetes: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret deletes the key of the Secret, the other keys are kept.
+func (p *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, err := p.client.CoreV1().Secrets(p.namespace).Get(ctx, p.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, ok := s.Data[p.key]; !ok {
+			return nil
+		}
+
+		s = s.DeepCopy()
+
+		delete(s.Data, p.key)
+		delete(s.Annotations, IssuerAnnotation)
+
+		_, err = p.client.CoreV1().Secrets(p.namespace).Update(ctx, s, metav1.UpdateOptions{})
+
+		return err
+	})
+	if err != nil {
+		p.logger.Error(ctx, "could not delete totp secret in kubernetes", "error", err, "namespace", p.namespace, "secret", p.name, "key", p.key)
+
+		return fmt.Errorf("could not delete totp secret in kubernetes: %w", err)
+	}
+
+	return nil
+}
+
+func (p *TOTPSecretProvider) newSecret(secret otp.TOTPSecret, issuer string) *corev1.Secret {
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.namespace,
+			Name:      p.name,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{p.key: []byte(secret.Reveal())},
+	}
+
+	setIssuer(&s.ObjectMeta, issuer)
+
+	return s
+}
+
+func setIssuer(m *metav1.ObjectMeta, issuer string) {
+	if issuer == "" {
+		delete(m.Annotations, IssuerAnnotation)
+
+		return
+	}
+
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]string)
+	}
+
+	m.Annotations[IssuerAnnotation] = issuer
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (p *TOTPSecretProvider) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return p
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (p *TOTPSecretProvider) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (p *TOTPSecretProvider) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return p
+}
+
+// TOTPSecretFromSecret returns a TOTP secret provider that stores the TOTP secret in the key of the Secret.
+func TOTPSecretFromSecret(client kubernetes.Interface, namespace, name, key string, opts ...Option) *TOTPSecretProvider {
+	p := &TOTPSecretProvider{
+		client: client,
+		logger: ctxd.NoOpLogger{},
+
+		namespace: namespace,
+		name:      name,
+		key:       key,
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(p)
+	}
+
+	return p
+}
+
+// Option configures TOTPSecretProvider.
+type Option interface {
+	applyOption(p *TOTPSecretProvider)
+}
+
+type optionFunc func(p *TOTPSecretProvider)
+
+func (f optionFunc) applyOption(p *TOTPSecretProvider) {
+	f(p)
+}
+
+// WithCache reads the TOTP secret from the cache instead of the api. The cache must watch the Secret, and must be
+// started. The writes always go to the api.
+func WithCache(c *Cache) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.cache = c
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(p *TOTPSecretProvider) {
+		p.logger = otp.RedactLogger(l)
+	})
+}
@@ -0,0 +1,289 @@
+//go:build unit || !integration
+
+package kubernetes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/kubernetes"
+)
+
+func newSecret(data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "otp",
+			ResourceVersion: "1",
+		},
+		Data: make(map[string][]byte),
+	}
+
+	for k, v := range data {
+		s.Data[k] = []byte(v)
+	}
+
+	return s
+}
+
+func getSecret(t *testing.T, client *fake.Clientset) *corev1.Secret {
+	t.Helper()
+
+	s, err := client.CoreV1().Secrets("default").Get(context.Background(), "otp", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestTOTPSecretProvider_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		objects        []runtime.Object
+		expectedResult otp.TOTPSecret
+	}{
+		{
+			scenario: "no secret",
+		},
+		{
+			scenario: "no key",
+			objects:  []runtime.Object{newSecret(map[string]string{"other": "value"})},
+		},
+		{
+			scenario:       "bare secret",
+			objects:        []runtime.Object{newSecret(map[string]string{"john": "NBSWY3DP\n"})},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			objects:        []runtime.Object{newSecret(map[string]string{"john": "otpauth://totp/john?secret=NBSWY3DP"})},
+			expectedResult: "otpauth://totp/john?secret=NBSWY3DP",
+		},
+		{
+			scenario: "invalid otpauth uri",
+			objects:  []runtime.Object{newSecret(map[string]string{"john": "otpauth://hotp/john?secret=NBSWY3DP"})},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(tc.objects...)
+			p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john")
+
+			assert.Equal(t, tc.expectedResult, p.TOTPSecret(context.Background()))
+		})
+	}
+}
+
+func TestTOTPSecretProvider_TOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("secrets"), "otp", assert.AnError)
+	})
+
+	l := &ctxd.LoggerMock{}
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john", kubernetes.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "could not get totp secret from kubernetes", l.LoggedEntries[0].Message)
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Create(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john")
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "Example")
+	require.NoError(t, err)
+
+	s := getSecret(t, client)
+
+	assert.Equal(t, corev1.SecretTypeOpaque, s.Type)
+	assert.Equal(t, map[string][]byte{"john": []byte("NBSWY3DP")}, s.Data)
+	assert.Equal(t, "Example", s.Annotations[kubernetes.IssuerAnnotation])
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Update(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(newSecret(map[string]string{"jane": "JBSWY3DP", "john": "GEZDGNBV"}))
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john")
+
+	var updates []string
+
+	client.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		s := action.(k8stesting.UpdateAction).GetObject().(*corev1.Secret) //nolint: errcheck, forcetypeassert
+
+		updates = append(updates, s.ResourceVersion)
+
+		return false, nil, nil
+	})
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	require.NoError(t, err)
+
+	s := getSecret(t, client)
+
+	assert.Equal(t, map[string][]byte{"jane": []byte("JBSWY3DP"), "john": []byte("NBSWY3DP")}, s.Data)
+	assert.NotContains(t, s.Annotations, kubernetes.IssuerAnnotation)
+
+	// The update is sent with the resource version that is read.
+	assert.Equal(t, []string{"1"}, updates)
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Conflict(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(newSecret(map[string]string{"jane": "JBSWY3DP"}))
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john")
+
+	var attempts int
+
+	client.PrependReactor("update", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		attempts++
+
+		if attempts > 1 {
+			return false, nil, nil
+		}
+
+		// Someone else updates the Secret in between.
+		s := newSecret(map[string]string{"jane": "GEZDGNBV"})
+		s.ResourceVersion = "2"
+
+		require.NoError(t, client.Tracker().Update(corev1.SchemeGroupVersion.WithResource("secrets"), s, "default"))
+
+		return true, nil, apierrors.NewConflict(corev1.Resource("secrets"), "otp", assert.AnError)
+	})
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, map[string][]byte{"jane": []byte("GEZDGNBV"), "john": []byte("NBSWY3DP")}, getSecret(t, client).Data)
+}
+
+func TestTOTPSecretProvider_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(newSecret(nil))
+	client.PrependReactor("update", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("secrets"), "otp", assert.AnError)
+	})
+
+	l := &ctxd.LoggerMock{}
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john", kubernetes.WithLogger(l))
+
+	err := p.SetTOTPSecret(context.Background(), "NBSWY3DP", "")
+	require.Error(t, err)
+	require.True(t, apierrors.IsForbidden(err))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+}
+
+func TestTOTPSecretProvider_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(newSecret(map[string]string{"jane": "JBSWY3DP", "john": "NBSWY3DP"}))
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john")
+
+	err := p.DeleteTOTPSecret(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]byte{"jane": []byte("JBSWY3DP")}, getSecret(t, client).Data)
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	// Deleting a missing key or a missing Secret is not an error.
+	err = p.DeleteTOTPSecret(context.Background())
+	require.NoError(t, err)
+
+	err = kubernetes.TOTPSecretFromSecret(client, "default", "missing", "john").DeleteTOTPSecret(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, p, p.TOTPSecretGetter())
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+}
+
+func TestTOTPSecretProvider_WithCache(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	client := fake.NewSimpleClientset(newSecret(map[string]string{"john": "GEZDGNBV"}))
+
+	var selectors []string
+
+	client.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selectors = append(selectors, action.(k8stesting.ListAction).GetListRestrictions().Fields.String()) //nolint: forcetypeassert
+
+		return false, nil, nil
+	})
+
+	cache := kubernetes.NewCache(client, "default", "otp", 0)
+	require.NoError(t, cache.Start(ctx))
+
+	// Only the Secret is listed.
+	assert.Equal(t, []string{"metadata.name=otp"}, selectors)
+
+	var gets int
+
+	client.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+
+		return false, nil, nil
+	})
+
+	p := kubernetes.TOTPSecretFromSecret(client, "default", "otp", "john", kubernetes.WithCache(cache))
+
+	assert.Equal(t, otp.TOTPSecret("GEZDGNBV"), p.TOTPSecret(ctx))
+	assert.Zero(t, gets)
+
+	err := p.SetTOTPSecret(ctx, "NBSWY3DP", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, gets)
+
+	// The cache is updated by the watch.
+	assert.Eventually(t, func() bool {
+		return p.TOTPSecret(ctx) == "NBSWY3DP"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, otp.NoTOTPSecret, kubernetes.TOTPSecretFromSecret(client, "default", "missing", "john",
+		kubernetes.WithCache(cache),
+	).TOTPSecret(ctx))
+}
+
+func TestCache_Start_NotSynced(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := kubernetes.NewCache(fake.NewSimpleClientset(), "default", "otp", 0).Start(ctx)
+	require.ErrorIs(t, err, kubernetes.ErrCacheNotSynced)
+}
@@ -0,0 +1,2 @@
+// Package limiter provides brute-force protection for one-time password verification.
+package limiter
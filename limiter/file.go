@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"go.nhat.io/otp/internal/atomicfile"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore is a Store that keeps the states in a JSON file so that they survive restarts.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *FileStore) read() (map[string]State, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]State), nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read limiter state: %w", err)
+	}
+
+	states := make(map[string]State)
+
+	if len(data) == 0 {
+		return states, nil
+	}
+
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("could not read limiter state: %w", err)
+	}
+
+	return states, nil
+}
+
+func (s *FileStore) write(states map[string]State) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("could not write limiter state: %w", err)
+	}
+
+	if err := atomicfile.Write(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("could not write limiter state: %w", err)
+	}
+
+	return nil
+}
+
+// LoadState returns the state of the account.
+func (s *FileStore) LoadState(_ context.Context, account string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return State{}, err
+	}
+
+	return states[account], nil
+}
+
+// SaveState persists the state of the account.
+func (s *FileStore) SaveState(_ context.Context, account string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	states[account] = state
+
+	return s.write(states)
+}
+
+// CompareAndSwapState replaces the state of the account if it has not changed. The swap is atomic within the process,
+// the file is not locked against other processes.
+func (s *FileStore) CompareAndSwapState(_ context.Context, account string, old, next State) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return false, err
+	}
+
+	if !states[account].Equal(old) {
+		return false, nil
+	}
+
+	states[account] = next
+
+	return true, s.write(states)
+}
+
+// DeleteState deletes the state of the account.
+func (s *FileStore) DeleteState(_ context.Context, account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := states[account]; !ok {
+		return nil
+	}
+
+	delete(states, account)
+
+	return s.write(states)
+}
+
+// NewFileStore initiates a new FileStore that keeps the states in the given file.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
@@ -0,0 +1,136 @@
+//go:build unit || !integration
+
+package limiter_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp/limiter"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "limiter.json")
+	state := limiter.State{Failures: 2, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+	s := limiter.NewFileStore(path)
+
+	actual, err := s.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+
+	require.NoError(t, s.SaveState(ctx, "john", state))
+	require.NoError(t, s.SaveState(ctx, "jane", limiter.State{Failures: 1}))
+
+	// The states survive restarts.
+	s = limiter.NewFileStore(path)
+
+	actual, err = s.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.Equal(t, state, actual)
+
+	require.NoError(t, s.DeleteState(ctx, "john"))
+	require.NoError(t, s.DeleteState(ctx, "unknown"))
+
+	actual, err = s.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+
+	actual, err = s.LoadState(ctx, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, 1, actual.Failures)
+}
+
+func TestStore_CompareAndSwapState(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		store    func(t *testing.T) limiter.Store
+	}{
+		{
+			scenario: "memory",
+			store: func(*testing.T) limiter.Store {
+				return limiter.NewMemoryStore(limiter.WithStoreClock(newClock()))
+			},
+		},
+		{
+			scenario: "file",
+			store: func(t *testing.T) limiter.Store {
+				t.Helper()
+
+				return limiter.NewFileStore(filepath.Join(t.TempDir(), "limiter.json"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			s := tc.store(t)
+			first := limiter.State{Failures: 1, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 1, 0, time.UTC)}
+			second := limiter.State{Failures: 2, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 2, 0, time.UTC)}
+
+			swapped, err := s.CompareAndSwapState(ctx, "john", limiter.State{}, first)
+			require.NoError(t, err)
+			assert.True(t, swapped)
+
+			swapped, err = s.CompareAndSwapState(ctx, "john", limiter.State{}, second)
+			require.NoError(t, err)
+			assert.False(t, swapped)
+
+			swapped, err = s.CompareAndSwapState(ctx, "john", first, second)
+			require.NoError(t, err)
+			assert.True(t, swapped)
+
+			actual, err := s.LoadState(ctx, "john")
+			require.NoError(t, err)
+			assert.True(t, second.Equal(actual))
+		})
+	}
+}
+
+func TestFileStore_Corrupted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "limiter.json")
+
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+	s := limiter.NewFileStore(path)
+
+	_, err := s.LoadState(ctx, "john")
+	require.ErrorContains(t, err, "could not read limiter state")
+
+	err = s.SaveState(ctx, "john", limiter.State{Failures: 1})
+	require.ErrorContains(t, err, "could not read limiter state")
+
+	err = s.DeleteState(ctx, "john")
+	require.ErrorContains(t, err, "could not read limiter state")
+}
+
+func TestFileStore_Empty(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "limiter.json")
+
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	actual, err := limiter.NewFileStore(path).LoadState(context.Background(), "john")
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+}
@@ -0,0 +1,302 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = time.Second
+	defaultMaxDelay    = time.Minute
+	defaultLockout     = 15 * time.Minute
+)
+
+// maxSwapRetries is the number of times the limiter retries to record a failure when the state has been changed
+// concurrently.
+const maxSwapRetries = 16
+
+// ErrStateConflict indicates that the state of the account kept changing concurrently while recording a failure.
+var ErrStateConflict = errors.New("limiter state has changed concurrently")
+
+// ErrTooManyAttempts indicates that the account is temporarily blocked because of too many failed attempts.
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+// TooManyAttemptsError is returned when the account is temporarily blocked. It wraps ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the error message.
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+// Unwrap returns ErrTooManyAttempts.
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Limiter tracks the failed verifications per account and blocks the account when there are too many of them.
+//
+// After each failed attempt, the account is blocked for an exponentially growing delay, starting from the base delay
+// and capped at the max delay. Once the number of consecutive failures reaches the max attempts, the account is
+// locked out. The failures are reset after a successful verification or when the lockout expires.
+type Limiter struct {
+	store Store
+	clock clock.Clock
+
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	lockout     time.Duration
+
+	locks accountLocks
+}
+
+// Verify verifies the code of the account using the verifier. It returns a *TooManyAttemptsError if the account is
+// blocked. The verifications of an account are serialized, the ones of different accounts run concurrently. The
+// failures are recorded with Store.CompareAndSwapState so that the limiters that share a store do not lose them.
+func (l *Limiter) Verify(ctx context.Context, account string, v otp.Verifier, code otp.OTP) error {
+	unlock := l.locks.lock(account)
+	defer unlock()
+
+	now := l.clock.Now()
+
+	state, err := l.store.LoadState(ctx, account)
+	if err != nil {
+		return fmt.Errorf("could not load limiter state: %w", err)
+	}
+
+	if now.Before(state.LockedUntil) {
+		return &TooManyAttemptsError{RetryAfter: state.LockedUntil.Sub(now)}
+	}
+
+	verifyErr := v.VerifyOTP(ctx, code)
+	if verifyErr == nil {
+		if state.IsZero() {
+			return nil
+		}
+
+		if err := l.store.DeleteState(ctx, account); err != nil {
+			return fmt.Errorf("could not reset limiter state: %w", err)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(verifyErr, otp.ErrInvalidOTP) {
+		return verifyErr
+	}
+
+	if err := l.recordFailure(ctx, account, state, now); err != nil {
+		return fmt.Errorf("could not save limiter state: %w", err)
+	}
+
+	return verifyErr
+}
+
+// recordFailure increments the failures of the account. If the state has been changed by another limiter since it
+// was loaded, the failure is added to the latest state.
+func (l *Limiter) recordFailure(ctx context.Context, account string, state State, now time.Time) error {
+	for range maxSwapRetries {
+		next := state
+
+		if l.maxAttempts > 0 && next.Failures >= l.maxAttempts {
+			next = State{}
+		}
+
+		next.Failures++
+		next.LockedUntil = now.Add(l.delay(next.Failures))
+
+		swapped, err := l.store.CompareAndSwapState(ctx, account, state, next)
+		if err != nil {
+			return err
+		}
+
+		if swapped {
+			return nil
+		}
+
+		if state, err = l.store.LoadState(ctx, account); err != nil {
+			return err
+		}
+	}
+
+	return ErrStateConflict
+}
+
+func (l *Limiter) delay(failures int) time.Duration {
+	if l.maxAttempts > 0 && failures >= l.maxAttempts {
+		return l.lockout
+	}
+
+	if l.baseDelay <= 0 {
+		return 0
+	}
+
+	d := l.baseDelay
+
+	for i := 1; i < failures; i++ {
+		d *= 2
+
+		if l.maxDelay > 0 && d >= l.maxDelay {
+			return l.maxDelay
+		}
+	}
+
+	return d
+}
+
+// Reset resets the failed attempts of the account.
+func (l *Limiter) Reset(ctx context.Context, account string) error {
+	unlock := l.locks.lock(account)
+	defer unlock()
+
+	if err := l.store.DeleteState(ctx, account); err != nil {
+		return fmt.Errorf("could not reset limiter state: %w", err)
+	}
+
+	return nil
+}
+
+// accountLocks is a set of mutexes keyed by account. The mutex of an account is removed once nobody holds it.
+type accountLocks struct {
+	mu    sync.Mutex
+	locks map[string]*accountLock
+}
+
+type accountLock struct {
+	sync.Mutex
+
+	refs int
+}
+
+// lock locks the account and returns the function to unlock it.
+func (l *accountLocks) lock(account string) func() {
+	l.mu.Lock()
+
+	if l.locks == nil {
+		l.locks = make(map[string]*accountLock)
+	}
+
+	al, ok := l.locks[account]
+	if !ok {
+		al = &accountLock{}
+		l.locks[account] = al
+	}
+
+	al.refs++
+	l.mu.Unlock()
+
+	al.Lock()
+
+	return func() {
+		al.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if al.refs--; al.refs == 0 {
+			delete(l.locks, account)
+		}
+	}
+}
+
+// Verifier returns an otp.Verifier that verifies the codes of the account through the limiter.
+func (l *Limiter) Verifier(account string, v otp.Verifier) otp.Verifier {
+	return &limitedVerifier{
+		limiter:  l,
+		account:  account,
+		verifier: v,
+	}
+}
+
+type limitedVerifier struct {
+	limiter  *Limiter
+	account  string
+	verifier otp.Verifier
+}
+
+func (v *limitedVerifier) VerifyOTP(ctx context.Context, code otp.OTP) error {
+	return v.limiter.Verify(ctx, v.account, v.verifier, code)
+}
+
+// New initiates a new Limiter. By default, it keeps the states in memory, allows 5 attempts with a delay starting from
+// 1 second up to 1 minute between them, and locks the account out for 15 minutes.
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		clock: clock.New(),
+
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		maxDelay:    defaultMaxDelay,
+		lockout:     defaultLockout,
+	}
+
+	for _, opt := range opts {
+		opt.applyLimiterOption(l)
+	}
+
+	if l.store == nil {
+		// The failures are kept until the longest block of the account could have expired.
+		l.store = NewMemoryStore(WithStoreClock(l.clock), WithRetention(max(l.lockout, l.maxDelay)))
+	}
+
+	return l
+}
+
+// Option is an option to configure Limiter.
+type Option interface {
+	applyLimiterOption(l *Limiter)
+}
+
+type optionFunc func(l *Limiter)
+
+func (f optionFunc) applyLimiterOption(l *Limiter) {
+	f(l)
+}
+
+// WithStore sets the store of the failed attempts.
+func WithStore(s Store) Option {
+	return optionFunc(func(l *Limiter) {
+		l.store = s
+	})
+}
+
+// WithClock sets the clock of the limiter.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(l *Limiter) {
+		l.clock = c
+	})
+}
+
+// WithMaxAttempts sets the number of consecutive failures before the account is locked out. Zero disables the lockout.
+func WithMaxAttempts(n int) Option {
+	return optionFunc(func(l *Limiter) {
+		l.maxAttempts = n
+	})
+}
+
+// WithBackoff sets the base and the max delay of the exponential backoff between failed attempts. A zero base delay
+// disables the backoff.
+func WithBackoff(base, limit time.Duration) Option {
+	return optionFunc(func(l *Limiter) {
+		l.baseDelay = base
+		l.maxDelay = limit
+	})
+}
+
+// WithLockout sets how long the account is locked out after too many failures.
+func WithLockout(d time.Duration) Option {
+	return optionFunc(func(l *Limiter) {
+		l.lockout = d
+	})
+}
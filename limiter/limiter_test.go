@@ -0,0 +1,396 @@
+//go:build unit || !integration
+
+package limiter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/limiter"
+	"go.nhat.io/otp/mock"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func verifier(t *testing.T) otp.Verifier {
+	t.Helper()
+
+	return mock.MockVerifier(func(v *mock.Verifier) {
+		v.On("VerifyOTP", mock.Anything, otp.OTP("123456")).Return(nil).Maybe()
+		v.On("VerifyOTP", mock.Anything, mock.Anything).Return(otp.ErrInvalidOTP).Maybe()
+	})(t)
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+
+	var tmaErr *limiter.TooManyAttemptsError
+
+	require.ErrorAs(t, err, &tmaErr)
+	require.ErrorIs(t, err, limiter.ErrTooManyAttempts)
+
+	return tmaErr.RetryAfter
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClock()
+	v := verifier(t)
+	l := limiter.New(
+		limiter.WithClock(c),
+		limiter.WithMaxAttempts(0),
+		limiter.WithBackoff(time.Second, 4*time.Second),
+	)
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		err := l.Verify(ctx, "john", v, "000000")
+		require.ErrorIs(t, err, otp.ErrInvalidOTP)
+
+		err = l.Verify(ctx, "john", v, "123456")
+		assert.Equal(t, expected, retryAfter(t, err))
+
+		c.Add(expected)
+	}
+
+	// Other accounts are not affected.
+	require.NoError(t, l.Verify(ctx, "jane", v, "123456"))
+
+	// Success resets the failures.
+	require.NoError(t, l.Verify(ctx, "john", v, "123456"))
+	require.ErrorIs(t, l.Verify(ctx, "john", v, "000000"), otp.ErrInvalidOTP)
+
+	assert.Equal(t, time.Second, retryAfter(t, l.Verify(ctx, "john", v, "123456")))
+}
+
+func TestLimiter_Lockout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClock()
+	v := verifier(t)
+	l := limiter.New(
+		limiter.WithClock(c),
+		limiter.WithMaxAttempts(3),
+		limiter.WithBackoff(0, 0),
+		limiter.WithLockout(time.Hour),
+	).Verifier("john", v)
+
+	require.ErrorIs(t, l.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+	require.ErrorIs(t, l.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+	require.ErrorIs(t, l.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+
+	err := l.VerifyOTP(ctx, "123456")
+	assert.Equal(t, time.Hour, retryAfter(t, err))
+	assert.EqualError(t, err, "too many attempts, retry after 1h0m0s")
+
+	c.Add(59 * time.Minute)
+
+	assert.Equal(t, time.Minute, retryAfter(t, l.VerifyOTP(ctx, "123456")))
+
+	c.Add(time.Minute)
+
+	// The failures are reset after the lockout.
+	require.ErrorIs(t, l.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+	require.ErrorIs(t, l.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+	require.NoError(t, l.VerifyOTP(ctx, "123456"))
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	v := verifier(t)
+	l := limiter.New(limiter.WithClock(newClock()), limiter.WithMaxAttempts(1))
+
+	require.ErrorIs(t, l.Verify(ctx, "john", v, "000000"), otp.ErrInvalidOTP)
+	require.ErrorIs(t, l.Verify(ctx, "john", v, "123456"), limiter.ErrTooManyAttempts)
+
+	require.NoError(t, l.Reset(ctx, "john"))
+	require.NoError(t, l.Verify(ctx, "john", v, "123456"))
+}
+
+func TestLimiter_VerifierError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	v := mock.MockVerifier(func(v *mock.Verifier) {
+		v.On("VerifyOTP", mock.Anything, mock.Anything).Return(otp.ErrNoTOTPSecret)
+	})(t)
+
+	l := limiter.New(limiter.WithClock(newClock()), limiter.WithMaxAttempts(1))
+
+	// Errors other than invalid otp are not counted.
+	require.ErrorIs(t, l.Verify(ctx, "john", v, "000000"), otp.ErrNoTOTPSecret)
+	require.ErrorIs(t, l.Verify(ctx, "john", v, "000000"), otp.ErrNoTOTPSecret)
+}
+
+type failingStore struct {
+	limiter.Store
+
+	loadErr, saveErr, deleteErr error
+}
+
+func (s failingStore) LoadState(ctx context.Context, account string) (limiter.State, error) {
+	if s.loadErr != nil {
+		return limiter.State{}, s.loadErr
+	}
+
+	return s.Store.LoadState(ctx, account)
+}
+
+func (s failingStore) SaveState(ctx context.Context, account string, state limiter.State) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+
+	return s.Store.SaveState(ctx, account, state)
+}
+
+func (s failingStore) CompareAndSwapState(ctx context.Context, account string, old, next limiter.State) (bool, error) {
+	if s.saveErr != nil {
+		return false, s.saveErr
+	}
+
+	return s.Store.CompareAndSwapState(ctx, account, old, next)
+}
+
+func (s failingStore) DeleteState(ctx context.Context, account string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+
+	return s.Store.DeleteState(ctx, account)
+}
+
+func TestLimiter_StoreError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	v := verifier(t)
+
+	testCases := []struct {
+		scenario      string
+		store         failingStore
+		code          otp.OTP
+		expectedError string
+	}{
+		{
+			scenario:      "load",
+			store:         failingStore{Store: limiter.NewMemoryStore(), loadErr: errors.New("load error")},
+			code:          "123456",
+			expectedError: "could not load limiter state: load error",
+		},
+		{
+			scenario:      "save",
+			store:         failingStore{Store: limiter.NewMemoryStore(), saveErr: errors.New("save error")},
+			code:          "000000",
+			expectedError: "could not save limiter state: save error",
+		},
+		{
+			scenario: "delete",
+			store: func() failingStore {
+				s := limiter.NewMemoryStore(limiter.WithStoreClock(newClock()))
+
+				require.NoError(t, s.SaveState(ctx, "john", limiter.State{Failures: 1, LockedUntil: newClock().Now()}))
+
+				return failingStore{Store: s, deleteErr: errors.New("delete error")}
+			}(),
+			code:          "123456",
+			expectedError: "could not reset limiter state: delete error",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			l := limiter.New(limiter.WithClock(newClock()), limiter.WithStore(tc.store))
+
+			err := l.Verify(ctx, "john", v, tc.code)
+
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+type verifierFunc func(ctx context.Context, code otp.OTP) error
+
+func (f verifierFunc) VerifyOTP(ctx context.Context, code otp.OTP) error {
+	return f(ctx, code)
+}
+
+func TestLimiter_ConcurrentAccounts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	slow := verifierFunc(func(context.Context, otp.OTP) error {
+		close(started)
+		<-release
+
+		return nil
+	})
+
+	l := limiter.New(limiter.WithClock(newClock()), limiter.WithBackoff(0, 0))
+	done := make(chan error)
+
+	go func() {
+		done <- l.Verify(ctx, "john", slow, "123456")
+	}()
+
+	<-started
+
+	// A slow verification of an account does not block the others.
+	require.ErrorIs(t, l.Verify(ctx, "jane", verifier(t), "000000"), otp.ErrInvalidOTP)
+	require.NoError(t, l.Verify(ctx, "jane", verifier(t), "123456"))
+
+	close(release)
+
+	require.NoError(t, <-done)
+}
+
+// racingStore changes the state of the account before the first swap, as if another instance recorded a failure.
+type racingStore struct {
+	limiter.Store
+
+	once sync.Once
+}
+
+func (s *racingStore) CompareAndSwapState(ctx context.Context, account string, old, next limiter.State) (bool, error) {
+	s.once.Do(func() {
+		_ = s.Store.SaveState(ctx, account, limiter.State{Failures: 1, LockedUntil: newClock().Now()}) //nolint: errcheck
+	})
+
+	return s.Store.CompareAndSwapState(ctx, account, old, next)
+}
+
+func TestLimiter_SharedStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClock()
+	store := limiter.NewMemoryStore(limiter.WithStoreClock(c))
+
+	l := limiter.New(limiter.WithClock(c), limiter.WithStore(&racingStore{Store: store}))
+
+	require.ErrorIs(t, l.Verify(ctx, "john", verifier(t), "000000"), otp.ErrInvalidOTP)
+
+	// The failure of the other instance is not lost.
+	state, err := store.LoadState(ctx, "john")
+	require.NoError(t, err)
+
+	assert.Equal(t, limiter.State{Failures: 2, LockedUntil: c.Now().Add(2 * time.Second)}, state)
+}
+
+// conflictingStore never swaps the state.
+type conflictingStore struct {
+	limiter.Store
+}
+
+func (conflictingStore) CompareAndSwapState(context.Context, string, limiter.State, limiter.State) (bool, error) {
+	return false, nil
+}
+
+func TestLimiter_StateConflict(t *testing.T) {
+	t.Parallel()
+
+	l := limiter.New(limiter.WithClock(newClock()), limiter.WithStore(conflictingStore{Store: limiter.NewMemoryStore()}))
+
+	err := l.Verify(context.Background(), "john", verifier(t), "000000")
+
+	require.ErrorIs(t, err, limiter.ErrStateConflict)
+	require.EqualError(t, err, "could not save limiter state: limiter state has changed concurrently")
+}
+
+func TestMemoryStore_Eviction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClock()
+	s := limiter.NewMemoryStore(limiter.WithStoreClock(c), limiter.WithRetention(time.Minute))
+
+	john := limiter.State{Failures: 1, LockedUntil: c.Now().Add(time.Second)}
+	jane := limiter.State{Failures: 2, LockedUntil: c.Now().Add(time.Minute)}
+
+	require.NoError(t, s.SaveState(ctx, "john", john))
+	require.NoError(t, s.SaveState(ctx, "jane", jane))
+
+	// The states are kept while they are locked, and for the retention after that.
+	c.Add(time.Minute)
+
+	actual, err := s.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, john.Equal(actual))
+
+	// The state of john is evicted once it has expired.
+	c.Add(time.Second)
+
+	actual, err = s.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+
+	swapped, err := s.CompareAndSwapState(ctx, "john", john, limiter.State{Failures: 2})
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	// The state of jane is swept, and the failures are counted from scratch.
+	c.Add(time.Minute)
+
+	actual, err = s.LoadState(ctx, "jane")
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+}
+
+func TestLimiter_DefaultStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClock()
+	l := limiter.New(limiter.WithClock(c), limiter.WithMaxAttempts(2), limiter.WithLockout(time.Hour))
+
+	require.ErrorIs(t, l.Verify(ctx, "john", verifier(t), "000000"), otp.ErrInvalidOTP)
+
+	// The failure is kept after the block has expired, so that the account is still locked out at the next failure.
+	c.Add(time.Minute)
+
+	require.ErrorIs(t, l.Verify(ctx, "john", verifier(t), "000000"), otp.ErrInvalidOTP)
+	assert.Equal(t, time.Hour, retryAfter(t, l.Verify(ctx, "john", verifier(t), "000000")))
+
+	// The state is evicted once the lockout has expired for longer than the lockout.
+	c.Add(2 * time.Hour)
+
+	require.NoError(t, l.Verify(ctx, "john", verifier(t), "123456"))
+}
@@ -0,0 +1,139 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.nhat.io/clock"
+)
+
+// defaultRetention is how long the MemoryStore keeps a state after its lock has expired.
+const defaultRetention = defaultLockout
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store that keeps the states in memory. A state is evicted once its lock has expired for longer than
+// the retention, so that the accounts that stop failing do not stay in memory. The expired states are swept at most
+// once per retention period.
+type MemoryStore struct {
+	clock     clock.Clock
+	retention time.Duration
+
+	mu     sync.Mutex
+	states map[string]State
+	swept  time.Time
+}
+
+// expired returns true if the lock of the state has expired for longer than the retention.
+func (s *MemoryStore) expired(state State, now time.Time) bool {
+	return !now.Before(state.LockedUntil.Add(s.retention))
+}
+
+// load returns the state of the account, and evicts the expired states.
+func (s *MemoryStore) load(account string) State {
+	now := s.clock.Now()
+
+	if now.Sub(s.swept) >= s.retention {
+		for a, state := range s.states {
+			if s.expired(state, now) {
+				delete(s.states, a)
+			}
+		}
+
+		s.swept = now
+	}
+
+	state, ok := s.states[account]
+	if ok && s.expired(state, now) {
+		delete(s.states, account)
+
+		return State{}
+	}
+
+	return state
+}
+
+// LoadState returns the state of the account.
+func (s *MemoryStore) LoadState(_ context.Context, account string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(account), nil
+}
+
+// SaveState persists the state of the account.
+func (s *MemoryStore) SaveState(_ context.Context, account string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load(account)
+	s.states[account] = state
+
+	return nil
+}
+
+// CompareAndSwapState replaces the state of the account if it has not changed.
+func (s *MemoryStore) CompareAndSwapState(_ context.Context, account string, old, next State) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.load(account).Equal(old) {
+		return false, nil
+	}
+
+	s.states[account] = next
+
+	return true, nil
+}
+
+// DeleteState deletes the state of the account.
+func (s *MemoryStore) DeleteState(_ context.Context, account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, account)
+
+	return nil
+}
+
+// NewMemoryStore initiates a new MemoryStore. By default, a state is kept for 15 minutes after its lock has expired.
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		clock:     clock.New(),
+		retention: defaultRetention,
+		states:    make(map[string]State),
+	}
+
+	for _, opt := range opts {
+		opt.applyMemoryStoreOption(s)
+	}
+
+	return s
+}
+
+// MemoryStoreOption is an option to configure MemoryStore.
+type MemoryStoreOption interface {
+	applyMemoryStoreOption(s *MemoryStore)
+}
+
+type memoryStoreOptionFunc func(s *MemoryStore)
+
+func (f memoryStoreOptionFunc) applyMemoryStoreOption(s *MemoryStore) {
+	f(s)
+}
+
+// WithRetention sets how long a state is kept after its lock has expired. The failures of the account are forgotten
+// once the state is evicted, so the retention should be at least the lockout of the limiter.
+func WithRetention(d time.Duration) MemoryStoreOption {
+	return memoryStoreOptionFunc(func(s *MemoryStore) {
+		s.retention = d
+	})
+}
+
+// WithStoreClock sets the clock that is used for evicting the states. It should be the clock of the limiter.
+func WithStoreClock(c clock.Clock) MemoryStoreOption {
+	return memoryStoreOptionFunc(func(s *MemoryStore) {
+		s.clock = c
+	})
+}
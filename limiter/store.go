@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// State is the state of the failed attempts of an account.
+type State struct {
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// IsZero returns true if there is no failed attempt.
+func (s State) IsZero() bool {
+	return s.Failures == 0 && s.LockedUntil.IsZero()
+}
+
+// Equal returns true if both states have the same failures and the same lock time.
+func (s State) Equal(other State) bool {
+	return s.Failures == other.Failures && s.LockedUntil.Equal(other.LockedUntil)
+}
+
+// Store persists the state of the failed attempts per account.
+type Store interface {
+	// LoadState returns the state of the account, or a zero state if there is none.
+	LoadState(ctx context.Context, account string) (State, error)
+	// SaveState persists the state of the account.
+	SaveState(ctx context.Context, account string, state State) error
+	// CompareAndSwapState atomically replaces the state of the account with the next one if the current state is equal
+	// to the old one. A zero old state matches an account without state. It returns false if the state has changed.
+	CompareAndSwapState(ctx context.Context, account string, old, next State) (bool, error)
+	// DeleteState deletes the state of the account.
+	DeleteState(ctx context.Context, account string) error
+}
@@ -0,0 +1,263 @@
+package otp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/bool64/ctxd"
+)
+
+// Redacted replaces the sensitive values in the logs.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeyWords are the words of the log keys whose values are redacted.
+var sensitiveKeyWords = map[string]struct{}{
+	"secret":     {},
+	"seed":       {},
+	"otp":        {},
+	"passcode":   {},
+	"password":   {},
+	"token":      {},
+	"credential": {},
+}
+
+// sensitivePatterns match the otpauth URIs, and the secrets in the query strings, such as "secret=NBSWY3DP".
+var sensitivePatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{pattern: regexp.MustCompile(`(?i)otpauth://[^\s"'<>]+`), replacement: Redacted},
+	{pattern: regexp.MustCompile(`(?i)(secret=)[^&\s"'<>]+`), replacement: "${1}" + Redacted},
+}
+
+// RedactLogger returns a logger that redacts the TOTP secrets, the one-time passwords, the otpauth URIs and the values
+// of the sensitive keys, such as "secret", before passing the fields of the context and the log line to the logger. The
+// otpauth URIs and the secrets of the query strings in the strings and the errors are also redacted.
+func RedactLogger(l ctxd.Logger) ctxd.Logger {
+	if _, ok := l.(redactedLogger); ok {
+		return l
+	}
+
+	return redactedLogger{upstream: l}
+}
+
+type redactedLogger struct {
+	upstream ctxd.Logger
+}
+
+func (l redactedLogger) redact(ctx context.Context, keysAndValues []any) (context.Context, []any) {
+	if fields := ctxd.Fields(ctx); len(fields) > 0 {
+		ctx = ctxd.AddFields(ctxd.ClearFields(ctx), RedactKeysAndValues(fields)...)
+	}
+
+	return ctx, RedactKeysAndValues(keysAndValues)
+}
+
+func (l redactedLogger) Debug(ctx context.Context, msg string, keysAndValues ...any) {
+	ctx, keysAndValues = l.redact(ctx, keysAndValues)
+
+	l.upstream.Debug(ctx, msg, keysAndValues...)
+}
+
+func (l redactedLogger) Info(ctx context.Context, msg string, keysAndValues ...any) {
+	ctx, keysAndValues = l.redact(ctx, keysAndValues)
+
+	l.upstream.Info(ctx, msg, keysAndValues...)
+}
+
+func (l redactedLogger) Important(ctx context.Context, msg string, keysAndValues ...any) {
+	ctx, keysAndValues = l.redact(ctx, keysAndValues)
+
+	l.upstream.Important(ctx, msg, keysAndValues...)
+}
+
+func (l redactedLogger) Warn(ctx context.Context, msg string, keysAndValues ...any) {
+	ctx, keysAndValues = l.redact(ctx, keysAndValues)
+
+	l.upstream.Warn(ctx, msg, keysAndValues...)
+}
+
+func (l redactedLogger) Error(ctx context.Context, msg string, keysAndValues ...any) {
+	ctx, keysAndValues = l.redact(ctx, keysAndValues)
+
+	l.upstream.Error(ctx, msg, keysAndValues...)
+}
+
+// RedactKeysAndValues returns a copy of the loosely-typed key-value pairs with the sensitive values redacted. The otpauth
+// URIs and the secrets of the query strings, such as "secret=NBSWY3DP", are redacted in the strings and in the messages
+// of the errors.
+func RedactKeysAndValues(keysAndValues []any) []any {
+	result := make([]any, len(keysAndValues))
+
+	for i := 0; i < len(keysAndValues); i++ {
+		result[i] = keysAndValues[i]
+
+		if i%2 == 1 && isSensitiveKey(keysAndValues[i-1]) {
+			result[i] = Redacted
+
+			continue
+		}
+
+		if isSensitiveValue(keysAndValues[i]) {
+			result[i] = Redacted
+
+			continue
+		}
+
+		switch v := keysAndValues[i].(type) {
+		case string:
+			if s, ok := redactString(v); ok {
+				result[i] = s
+			}
+
+		case error:
+			if s, ok := redactString(v.Error()); ok {
+				result[i] = redactedError{msg: s, err: v}
+			}
+		}
+	}
+
+	return result
+}
+
+// redactString replaces the otpauth URIs and the secrets of the query strings in the string. It returns false if
+// nothing is replaced.
+func redactString(s string) (string, bool) {
+	redacted := s
+
+	for _, p := range sensitivePatterns {
+		redacted = p.pattern.ReplaceAllString(redacted, p.replacement)
+	}
+
+	return redacted, redacted != s
+}
+
+// redactedError is an error whose message is redacted. The original error is still available with errors.Is and
+// errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e redactedError) Error() string {
+	return e.msg
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+func isSensitiveKey(key any) bool {
+	k, ok := key.(string)
+	if !ok {
+		return false
+	}
+
+	for _, w := range keyWords(k) {
+		if _, ok := sensitiveKeyWords[w]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyWords splits the key into lowercase words at the non-alphanumeric characters and at the camelCase boundaries, such
+// as "totpSecret" or "TOTPSecret" into "totp" and "secret".
+func keyWords(k string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(k)
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+
+			continue
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			// A word starts at "S" in "totpSecret", and in "TOTPSecret".
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				flush()
+			}
+		}
+
+		word = append(word, r)
+	}
+
+	flush()
+
+	return words
+}
+
+func isSensitiveValue(v any) bool {
+	switch v := v.(type) {
+	case TOTPSecret, *TOTPSecret, OTP, *OTP:
+		return true
+
+	case string:
+		return IsOTPAuthURI(v)
+	}
+
+	return false
+}
+
+// NewSlogLogger returns a ctxd.Logger that writes to the slog.Logger. The fields of the context are added to the log
+// line. Important messages are logged at the info level.
+func NewSlogLogger(l *slog.Logger) ctxd.Logger {
+	return slogLogger{logger: l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) log(ctx context.Context, level slog.Level, msg string, keysAndValues []any) {
+	if fields := ctxd.Fields(ctx); len(fields) > 0 {
+		keysAndValues = append(append(make([]any, 0, len(fields)+len(keysAndValues)), fields...), keysAndValues...)
+	}
+
+	l.logger.Log(ctx, level, msg, keysAndValues...)
+}
+
+func (l slogLogger) Debug(ctx context.Context, msg string, keysAndValues ...any) {
+	l.log(ctx, slog.LevelDebug, msg, keysAndValues)
+}
+
+func (l slogLogger) Info(ctx context.Context, msg string, keysAndValues ...any) {
+	l.log(ctx, slog.LevelInfo, msg, keysAndValues)
+}
+
+func (l slogLogger) Important(ctx context.Context, msg string, keysAndValues ...any) {
+	l.log(ctx, slog.LevelInfo, msg, keysAndValues)
+}
+
+func (l slogLogger) Warn(ctx context.Context, msg string, keysAndValues ...any) {
+	l.log(ctx, slog.LevelWarn, msg, keysAndValues)
+}
+
+func (l slogLogger) Error(ctx context.Context, msg string, keysAndValues ...any) {
+	l.log(ctx, slog.LevelError, msg, keysAndValues)
+}
+
+func providerName(i int, p any) string {
+	return fmt.Sprintf("#%d %T", i, p)
+}
@@ -0,0 +1,262 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+func TestRedactKeysAndValues(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		input    []any
+		expected []any
+	}{
+		{
+			scenario: "nil",
+			expected: []any{},
+		},
+		{
+			scenario: "totp secret",
+			input:    []any{"value", otp.TOTPSecret("NBSWY3DP")},
+			expected: []any{"value", otp.Redacted},
+		},
+		{
+			scenario: "otp",
+			input:    []any{"value", otp.OTP("191882")},
+			expected: []any{"value", otp.Redacted},
+		},
+		{
+			scenario: "otpauth uri",
+			input:    []any{"uri", "otpauth://totp/john?secret=NBSWY3DP"},
+			expected: []any{"uri", otp.Redacted},
+		},
+		{
+			scenario: "sensitive keys",
+			input:    []any{"totp_secret", "NBSWY3DP", "api-token", "abc", "X-OTP", 191882, "account", "john"},
+			expected: []any{"totp_secret", otp.Redacted, "api-token", otp.Redacted, "X-OTP", otp.Redacted, "account", "john"},
+		},
+		{
+			scenario: "camel case keys",
+			input:    []any{"otpSecret", "NBSWY3DP", "totpSecret", "NBSWY3DP", "TOTPSecret", "NBSWY3DP", "apiToken", "abc", "accountName", "john"},
+			expected: []any{"otpSecret", otp.Redacted, "totpSecret", otp.Redacted, "TOTPSecret", otp.Redacted, "apiToken", otp.Redacted, "accountName", "john"},
+		},
+		{
+			scenario: "otpauth uri in a string",
+			input:    []any{"command", "echo otpauth://totp/john?secret=NBSWY3DP&digits=8 | qrencode"},
+			expected: []any{"command", "echo " + otp.Redacted + " | qrencode"},
+		},
+		{
+			scenario: "secret in a query string",
+			input:    []any{"body", "account=john&Secret=NBSWY3DP&digits=8"},
+			expected: []any{"body", "account=john&Secret=" + otp.Redacted + "&digits=8"},
+		},
+		{
+			scenario: "not a sensitive key",
+			input:    []any{"secretary", "john", "otpauthIssuer", "GitHub"},
+			expected: []any{"secretary", "john", "otpauthIssuer", "GitHub"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, otp.RedactKeysAndValues(tc.input))
+		})
+	}
+}
+
+func TestRedactKeysAndValues_Error(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("could not parse") //nolint: err113
+	err := fmt.Errorf(`parse "otpauth://totp/john?secret=NBSWY3DP": %w`, cause)
+
+	actual := otp.RedactKeysAndValues([]any{"error", err, "other", cause})
+
+	require.Len(t, actual, 4)
+
+	redacted, ok := actual[1].(error)
+	require.True(t, ok)
+
+	assert.EqualError(t, redacted, `parse "`+otp.Redacted+`": could not parse`)
+	assert.ErrorIs(t, redacted, cause)
+
+	// The errors without sensitive values are kept as is.
+	assert.Equal(t, cause, actual[3])
+}
+
+func TestRedactLogger(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	ctx := ctxd.AddFields(context.Background(), "secret", "NBSWY3DP", "account", "john")
+
+	rl := otp.RedactLogger(l)
+
+	assert.Equal(t, rl, otp.RedactLogger(rl))
+
+	rl.Debug(ctx, "debug", "value", otp.TOTPSecret("NBSWY3DP"))
+	rl.Info(ctx, "info", "value", otp.TOTPSecret("NBSWY3DP"))
+	rl.Important(ctx, "important", "value", otp.TOTPSecret("NBSWY3DP"))
+	rl.Warn(ctx, "warn", "value", otp.TOTPSecret("NBSWY3DP"))
+	rl.Error(ctx, "error", "value", otp.TOTPSecret("NBSWY3DP"))
+
+	require.Len(t, l.LoggedEntries, 5)
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+
+	for _, e := range l.LoggedEntries {
+		assert.Equal(t, map[string]any{"secret": otp.Redacted, "account": "john", "value": otp.Redacted}, e.Data)
+	}
+
+	// The fields of the original context are untouched.
+	assert.Equal(t, []any{"secret", "NBSWY3DP", "account", "john"}, ctxd.Fields(ctx))
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	l := otp.RedactLogger(otp.NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))))
+
+	ctx := ctxd.AddFields(context.Background(), "account", "john")
+
+	l.Debug(ctx, "debug", "secret", "NBSWY3DP")
+	l.Info(ctx, "info")
+	l.Important(ctx, "important")
+	l.Warn(ctx, "warn")
+	l.Error(ctx, "error", "value", otp.TOTPSecret("NBSWY3DP"))
+
+	expected := `level=DEBUG msg=debug account=john secret=[REDACTED]
+level=INFO msg=info account=john
+level=INFO msg=important account=john
+level=WARN msg=warn account=john
+level=ERROR msg=error account=john value=[REDACTED]
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWithLogger_TOTPGenerator(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+
+	_, err := otp.GenerateTOTP(context.Background(), otp.NoTOTPSecret, otp.WithLogger(l))
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "error", l.LoggedEntries[0].Level)
+	assert.Equal(t, "could not generate otp", l.LoggedEntries[0].Message)
+}
+
+func TestWithLogger_TOTPVerifier(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	c := clock.Fix(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	err := otp.VerifyTOTP(context.Background(), otp.TOTPSecret("NBSWY3DP"), "000000", otp.WithClock(c), otp.WithLogger(l))
+	require.ErrorIs(t, err, otp.ErrInvalidOTP)
+
+	err = otp.VerifyTOTP(context.Background(), otp.NoTOTPSecret, "000000", otp.WithClock(c), otp.WithLogger(l))
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+
+	require.Len(t, l.LoggedEntries, 2)
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+	assert.Equal(t, "invalid otp", l.LoggedEntries[0].Message)
+	assert.Equal(t, "error", l.LoggedEntries[1].Level)
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+}
+
+func TestWithLogger_MigrateTOTPSecrets(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+
+	report := otp.MigrateTOTPSecrets(context.Background(), []string{"john", "jane"},
+		func(account string) otp.TOTPSecretGetter {
+			if account == "jane" {
+				return otp.NoTOTPSecret
+			}
+
+			return otp.TOTPSecret("NBSWY3DP")
+		},
+		func(string) otp.TOTPSecretSetter {
+			return otp.ChainTOTPSecretProviders(otp.TOTPSecret(""))
+		},
+		otp.WithLogger(l),
+	)
+
+	require.NoError(t, report.Err())
+	require.Len(t, l.LoggedEntries, 2)
+
+	assert.Equal(t, "migrated totp secret", l.LoggedEntries[0].Message)
+	assert.Equal(t, "john", l.LoggedEntries[0].Data["account"])
+	assert.Equal(t, "skipped totp secret migration", l.LoggedEntries[1].Message)
+	assert.Equal(t, "jane", l.LoggedEntries[1].Data["account"])
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+}
+
+func TestTOTPSecretGetters_WithLogger(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	c := otp.ChainTOTPSecretGetters(otp.NoTOTPSecret, otp.TOTPSecret("NBSWY3DP")).WithLogger(l)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), c.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "found totp secret", l.LoggedEntries[0].Message)
+	assert.Equal(t, "#1 otp.TOTPSecret", l.LoggedEntries[0].Data["provider"])
+	assert.NotContains(t, l.String(), "NBSWY3DP")
+}
+
+func TestTOTPSecretProviders_WithLogger(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	c := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"), otp.TOTPSecret("JBSWY3DP")).
+		WithLogger(l).
+		WithReadStrategy(otp.ReadRequireAgreement)
+
+	s, err := c.ResolveTOTPSecret(context.Background())
+	require.ErrorIs(t, err, otp.ErrTOTPSecretConflict)
+	assert.Equal(t, otp.NoTOTPSecret, s)
+
+	err = otp.TOTPSecretProviders{otp.TOTPSecret("NBSWY3DP")}.WithLogger(l).SetTOTPSecret(context.Background(), "JBSWY3DP", "")
+	require.ErrorIs(t, err, otp.ErrTOTPSecretReadOnly)
+
+	require.Len(t, l.LoggedEntries, 2)
+	assert.Equal(t, "warn", l.LoggedEntries[0].Level)
+	assert.Equal(t, "error", l.LoggedEntries[1].Level)
+	assert.Equal(t, "#0 otp.TOTPSecret", l.LoggedEntries[1].Data["provider"])
+	assert.NotContains(t, l.String(), "BSWY3DP")
+}
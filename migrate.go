@@ -0,0 +1,234 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+)
+
+// ErrTOTPSecretMismatch indicates that the source and the destination do not generate the same code.
+var ErrTOTPSecretMismatch = errors.New("totp secret mismatch")
+
+// ErrUnverifiableMigration indicates that the source cannot be deleted because the destination cannot be read back to
+// verify the migrated TOTP secret.
+var ErrUnverifiableMigration = errors.New("migrated totp secret cannot be verified")
+
+// MigrationStatus is the outcome of the migration of a TOTP secret.
+type MigrationStatus string
+
+const (
+	// MigrationStatusMigrated means the TOTP secret is copied to the destination.
+	MigrationStatusMigrated MigrationStatus = "migrated"
+	// MigrationStatusDryRun means the TOTP secret would be copied to the destination.
+	MigrationStatusDryRun MigrationStatus = "dry-run"
+	// MigrationStatusSkipped means there is no TOTP secret in the source.
+	MigrationStatusSkipped MigrationStatus = "skipped"
+	// MigrationStatusFailed means the migration failed.
+	MigrationStatusFailed MigrationStatus = "failed"
+)
+
+type migrateConfig struct {
+	clock        clock.Clock
+	logger       ctxd.Logger
+	issuer       string
+	dryRun       bool
+	deleteSource bool
+}
+
+// MigrateTOTPSecret copies the TOTP secret from a provider to another.
+//
+// If the destination is also a TOTPSecretGetter, the secret is read back to verify that both providers generate the
+// same code. If WithDeleteSource is set, the secret is then deleted from the source if it is a TOTPSecretDeleter. In
+// dry-run mode, the source is only checked and nothing is written.
+//
+// It returns ErrNoTOTPSecret if there is no TOTP secret in the source, or ErrUnverifiableMigration, before writing
+// anything, if WithDeleteSource is set and the destination is not a TOTPSecretGetter.
+func MigrateTOTPSecret(ctx context.Context, from TOTPSecretGetter, to TOTPSecretSetter, opts ...MigrateOption) error {
+	cfg := newMigrateConfig(opts...)
+
+	status, err := migrateTOTPSecret(ctx, from, to, cfg)
+
+	logMigration(ctx, cfg.logger, status, err)
+
+	return err
+}
+
+func migrateTOTPSecret(ctx context.Context, from TOTPSecretGetter, to TOTPSecretSetter, cfg migrateConfig) (MigrationStatus, error) {
+	secret := from.TOTPSecret(ctx)
+	if secret == NoTOTPSecret {
+		return MigrationStatusSkipped, fmt.Errorf("could not migrate totp secret: %w", ErrNoTOTPSecret)
+	}
+
+	verifier, canVerify := to.(TOTPSecretGetter)
+	if cfg.deleteSource && !canVerify {
+		return MigrationStatusFailed, fmt.Errorf("could not migrate totp secret: %w", ErrUnverifiableMigration)
+	}
+
+	now := cfg.clock.Now()
+
+	expected, err := NewTOTPGenerator(secret, WithClock(clock.Fix(now))).GenerateOTP(ctx)
+	if err != nil {
+		return MigrationStatusFailed, fmt.Errorf("could not migrate totp secret: %w", err)
+	}
+
+	if cfg.dryRun {
+		return MigrationStatusDryRun, nil
+	}
+
+	if err := to.SetTOTPSecret(ctx, secret, cfg.issuer); err != nil {
+		return MigrationStatusFailed, fmt.Errorf("could not migrate totp secret: %w", err)
+	}
+
+	if canVerify {
+		actual, err := NewTOTPGenerator(verifier, WithClock(clock.Fix(now))).GenerateOTP(ctx)
+		if err != nil {
+			return MigrationStatusFailed, fmt.Errorf("could not verify migrated totp secret: %w", err)
+		}
+
+		if actual != expected {
+			return MigrationStatusFailed, fmt.Errorf("could not verify migrated totp secret: %w", ErrTOTPSecretMismatch)
+		}
+	}
+
+	if !cfg.deleteSource {
+		return MigrationStatusMigrated, nil
+	}
+
+	if d, ok := from.(TOTPSecretDeleter); ok {
+		if err := d.DeleteTOTPSecret(ctx); err != nil {
+			return MigrationStatusFailed, fmt.Errorf("could not delete migrated totp secret: %w", err)
+		}
+	}
+
+	return MigrationStatusMigrated, nil
+}
+
+// MigrationResult is the outcome of the migration of the TOTP secret of an account.
+type MigrationResult struct {
+	Account string
+	Status  MigrationStatus
+	Error   error
+}
+
+// MigrationReport is the outcome of a bulk migration, one result per account.
+type MigrationReport []MigrationResult
+
+// Err returns the errors of the failed migrations, or nil if there is none.
+func (r MigrationReport) Err() error {
+	var errs []error
+
+	for _, res := range r {
+		if res.Status == MigrationStatusFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Account, res.Error))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Count returns the number of accounts that have the given status.
+func (r MigrationReport) Count(status MigrationStatus) int {
+	var n int
+
+	for _, res := range r {
+		if res.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+// MigrateTOTPSecrets copies the TOTP secrets of the accounts from a registry to another. A registry returns the TOTP
+// secret provider of an account, such as keyring.TOTPSecretFromKeyring. A failure of an account does not stop the
+// migration of the others, see MigrateTOTPSecret for the details.
+func MigrateTOTPSecrets(
+	ctx context.Context,
+	accounts []string,
+	from func(account string) TOTPSecretGetter,
+	to func(account string) TOTPSecretSetter,
+	opts ...MigrateOption,
+) MigrationReport {
+	cfg := newMigrateConfig(opts...)
+	report := make(MigrationReport, 0, len(accounts))
+
+	for _, account := range accounts {
+		status, err := migrateTOTPSecret(ctx, from(account), to(account), cfg)
+
+		if status == MigrationStatusSkipped {
+			err = nil
+		}
+
+		logMigration(ctxd.AddFields(ctx, "account", account), cfg.logger, status, err)
+
+		report = append(report, MigrationResult{
+			Account: account,
+			Status:  status,
+			Error:   err,
+		})
+	}
+
+	return report
+}
+
+func logMigration(ctx context.Context, l ctxd.Logger, status MigrationStatus, err error) {
+	switch status {
+	case MigrationStatusFailed:
+		l.Error(ctx, "could not migrate totp secret", "error", err)
+
+	case MigrationStatusSkipped:
+		l.Debug(ctx, "skipped totp secret migration", "error", err)
+
+	default:
+		l.Info(ctx, "migrated totp secret", "status", status)
+	}
+}
+
+func newMigrateConfig(opts ...MigrateOption) migrateConfig {
+	cfg := migrateConfig{
+		clock:  clock.New(),
+		logger: ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyMigrateOption(&cfg)
+	}
+
+	return cfg
+}
+
+// MigrateOption is an option to configure MigrateTOTPSecret and MigrateTOTPSecrets.
+type MigrateOption interface {
+	applyMigrateOption(c *migrateConfig)
+}
+
+type migrateOptionFunc func(c *migrateConfig)
+
+func (f migrateOptionFunc) applyMigrateOption(c *migrateConfig) {
+	f(c)
+}
+
+// WithDryRun checks the source without writing to the destination or deleting the source.
+func WithDryRun() MigrateOption {
+	return migrateOptionFunc(func(c *migrateConfig) {
+		c.dryRun = true
+	})
+}
+
+// WithDeleteSource deletes the TOTP secret in the source after it is migrated and verified. The destination must be a
+// TOTPSecretGetter so that the migrated secret can be read back.
+func WithDeleteSource() MigrateOption {
+	return migrateOptionFunc(func(c *migrateConfig) {
+		c.deleteSource = true
+	})
+}
+
+// WithIssuer sets the issuer that is passed to the destination.
+func WithIssuer(issuer string) MigrateOption {
+	return migrateOptionFunc(func(c *migrateConfig) {
+		c.issuer = issuer
+	})
+}
@@ -0,0 +1,209 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+func TestMigrateTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	from := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	to := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	// Dry run.
+	err := otp.MigrateTOTPSecret(ctx, from, to, otp.WithDryRun(), otp.WithDeleteSource())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), from.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, to.TOTPSecret(ctx))
+
+	// Keep source.
+	err = otp.MigrateTOTPSecret(ctx, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), from.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), to.TOTPSecret(ctx))
+
+	// Delete source.
+	err = otp.MigrateTOTPSecret(ctx, from, to, otp.WithDeleteSource())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.NoTOTPSecret, from.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), to.TOTPSecret(ctx))
+
+	// No secret.
+	err = otp.MigrateTOTPSecret(ctx, from, to)
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+}
+
+func TestMigrateTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	testCases := []struct {
+		scenario      string
+		from          func(t *testing.T) otp.TOTPSecretGetter
+		to            func(t *testing.T) otp.TOTPSecretSetter
+		options       []otp.MigrateOption
+		expectedError string
+	}{
+		{
+			scenario: "invalid secret",
+			from: func(*testing.T) otp.TOTPSecretGetter {
+				return otp.TOTPSecret("secret")
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.NopTOTPSecretSetter(t)
+			},
+			expectedError: "could not migrate totp secret: could not generate otp: Decoding of secret as base32 failed.",
+		},
+		{
+			scenario: "could not set",
+			from: func(*testing.T) otp.TOTPSecretGetter {
+				return otp.TOTPSecret("NBSWY3DP")
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.MockTOTPSecretSetter(func(s *mock.TOTPSecretSetter) {
+					s.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "issuer").Return(assert.AnError)
+				})(t)
+			},
+			options:       []otp.MigrateOption{otp.WithIssuer("issuer")},
+			expectedError: "could not migrate totp secret: assert.AnError general error for testing",
+		},
+		{
+			scenario: "could not read back",
+			from: func(*testing.T) otp.TOTPSecretGetter {
+				return otp.TOTPSecret("NBSWY3DP")
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+					p.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "").Return(nil)
+					p.On("TOTPSecret", ctx).Return(otp.NoTOTPSecret)
+				})(t)
+			},
+			expectedError: "could not verify migrated totp secret: could not generate otp: no totp secret",
+		},
+		{
+			scenario: "mismatch",
+			from: func(*testing.T) otp.TOTPSecretGetter {
+				return otp.TOTPSecret("NBSWY3DP")
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+					p.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "").Return(nil)
+					p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("JBSWY3DPEHPK3PXP"))
+				})(t)
+			},
+			options:       []otp.MigrateOption{otp.WithDeleteSource()},
+			expectedError: "could not verify migrated totp secret: totp secret mismatch",
+		},
+		{
+			scenario: "could not delete source",
+			from: func(t *testing.T) otp.TOTPSecretGetter {
+				return mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+					p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+					p.On("DeleteTOTPSecret", ctx).Return(assert.AnError)
+				})(t)
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+					p.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "").Return(nil)
+					p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+				})(t)
+			},
+			options:       []otp.MigrateOption{otp.WithDeleteSource()},
+			expectedError: "could not delete migrated totp secret: assert.AnError general error for testing",
+		},
+		{
+			scenario: "cannot verify before deleting source",
+			from: func(t *testing.T) otp.TOTPSecretGetter {
+				// The source is neither written nor deleted.
+				return mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+					p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+				})(t)
+			},
+			to: func(t *testing.T) otp.TOTPSecretSetter {
+				return mock.NopTOTPSecretSetter(t)
+			},
+			options:       []otp.MigrateOption{otp.WithDeleteSource()},
+			expectedError: "could not migrate totp secret: migrated totp secret cannot be verified",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]otp.MigrateOption{otp.WithClock(c)}, tc.options...)
+
+			err := otp.MigrateTOTPSecret(ctx, tc.from(t), tc.to(t), opts...)
+
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestMigrateTOTPSecrets(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	from := map[string]otp.TOTPSecretProviders{
+		"john": otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP")),
+		"jane": otp.ChainTOTPSecretProviders(otp.NoTOTPSecret),
+		"jack": otp.ChainTOTPSecretProviders(otp.TOTPSecret("secret")),
+	}
+
+	to := map[string]otp.TOTPSecretProviders{
+		"john": otp.ChainTOTPSecretProviders(otp.NoTOTPSecret),
+		"jane": otp.ChainTOTPSecretProviders(otp.NoTOTPSecret),
+		"jack": otp.ChainTOTPSecretProviders(otp.NoTOTPSecret),
+	}
+
+	accounts := []string{"john", "jane", "jack"}
+	fromRegistry := func(account string) otp.TOTPSecretGetter { return from[account] }
+	toRegistry := func(account string) otp.TOTPSecretSetter { return to[account] }
+
+	report := otp.MigrateTOTPSecrets(ctx, accounts, fromRegistry, toRegistry, otp.WithDryRun())
+
+	assert.Equal(t, 1, report.Count(otp.MigrationStatusDryRun))
+	assert.Equal(t, otp.NoTOTPSecret, to["john"].TOTPSecret(ctx))
+
+	report = otp.MigrateTOTPSecrets(ctx, accounts, fromRegistry, toRegistry, otp.WithDeleteSource())
+
+	require.Len(t, report, 3)
+
+	assert.Equal(t, otp.MigrationResult{Account: "john", Status: otp.MigrationStatusMigrated}, report[0])
+	assert.Equal(t, otp.MigrationResult{Account: "jane", Status: otp.MigrationStatusSkipped}, report[1])
+	assert.Equal(t, "jack", report[2].Account)
+	assert.Equal(t, otp.MigrationStatusFailed, report[2].Status)
+	require.Error(t, report[2].Error)
+
+	assert.Equal(t, 1, report.Count(otp.MigrationStatusMigrated))
+	assert.Equal(t, 1, report.Count(otp.MigrationStatusSkipped))
+	assert.Equal(t, 1, report.Count(otp.MigrationStatusFailed))
+
+	require.EqualError(t, report.Err(), "jack: could not migrate totp secret: could not generate otp: Decoding of secret as base32 failed.")
+
+	assert.Equal(t, otp.NoTOTPSecret, from["john"].TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), to["john"].TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("secret"), from["jack"].TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, to["jack"].TOTPSecret(ctx))
+
+	require.NoError(t, otp.MigrationReport{}.Err())
+}
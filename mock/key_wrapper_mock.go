@@ -0,0 +1,24 @@
+package mock
+
+import "testing"
+
+// KeyWrapperMocker is KeyWrapper mocker.
+type KeyWrapperMocker func(tb testing.TB) *KeyWrapper
+
+// NopKeyWrapper is no mock KeyWrapper.
+var NopKeyWrapper = MockKeyWrapper()
+
+// MockKeyWrapper creates KeyWrapper mock with cleanup to ensure all the expectations are met.
+func MockKeyWrapper(mocks ...func(w *KeyWrapper)) KeyWrapperMocker { //nolint: revive
+	return func(tb testing.TB) *KeyWrapper {
+		tb.Helper()
+
+		w := NewKeyWrapper(tb)
+
+		for _, m := range mocks {
+			m(w)
+		}
+
+		return w
+	}
+}
@@ -0,0 +1,24 @@
+package mock
+
+import "testing"
+
+// VerifierMocker is Verifier mocker.
+type VerifierMocker func(tb testing.TB) *Verifier
+
+// NopVerifier is no mock Verifier.
+var NopVerifier = MockVerifier()
+
+// MockVerifier creates Verifier mock with cleanup to ensure all the expectations are met.
+func MockVerifier(mocks ...func(v *Verifier)) VerifierMocker { //nolint: revive
+	return func(tb testing.TB) *Verifier {
+		tb.Helper()
+
+		v := NewVerifier(tb)
+
+		for _, m := range mocks {
+			m(v)
+		}
+
+		return v
+	}
+}
@@ -1,21 +1,79 @@
 package otp
 
-import "go.nhat.io/clock"
+import (
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+)
 
 // Option configures the apis of the authenticator package.
 type Option interface {
 	TOTPGeneratorOption
+	TOTPVerifierOption
+	TOTPSecretRotationOption
+	MigrateOption
+	ExecTOTPSecretProviderOption
+	PromptTOTPSecretGetterOption
+	EncryptedTOTPSecretProviderOption
 }
 
 type option struct {
 	TOTPGeneratorOption
+	TOTPVerifierOption
+	TOTPSecretRotationOption
+	MigrateOption
+	ExecTOTPSecretProviderOption
+	PromptTOTPSecretGetterOption
+	EncryptedTOTPSecretProviderOption
 }
 
-// WithClock sets the clock of the TOTPGenerator.
+// WithClock sets the clock of the TOTPGenerator, the TOTPVerifier, the TOTPSecretRotation and the migration.
 func WithClock(c clock.Clock) Option {
 	return option{
 		TOTPGeneratorOption: totpGeneratorOptionFunc(func(g *TOTPGenerator) {
 			g.clock = c
 		}),
+		TOTPVerifierOption: totpVerifierOptionFunc(func(v *TOTPVerifier) {
+			v.clock = c
+		}),
+		TOTPSecretRotationOption: totpSecretRotationOptionFunc(func(r *TOTPSecretRotation) {
+			r.clock = c
+		}),
+		MigrateOption: migrateOptionFunc(func(m *migrateConfig) {
+			m.clock = c
+		}),
+		ExecTOTPSecretProviderOption:      execTOTPSecretProviderOptionFunc(func(*ExecTOTPSecretProvider) {}),
+		PromptTOTPSecretGetterOption:      promptTOTPSecretGetterOptionFunc(func(*PromptTOTPSecretGetter) {}),
+		EncryptedTOTPSecretProviderOption: encryptedTOTPSecretProviderOptionFunc(func(*EncryptedTOTPSecretProvider) {}),
+	}
+}
+
+// WithLogger sets the logger of the TOTPGenerator, the TOTPVerifier, the TOTPSecretRotation, the migration, the
+// ExecTOTPSecretProvider, the PromptTOTPSecretGetter and the EncryptedTOTPSecretProvider. The TOTP secrets, the codes
+// and the otpauth URIs are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	l = RedactLogger(l)
+
+	return option{
+		TOTPGeneratorOption: totpGeneratorOptionFunc(func(g *TOTPGenerator) {
+			g.logger = l
+		}),
+		TOTPVerifierOption: totpVerifierOptionFunc(func(v *TOTPVerifier) {
+			v.logger = l
+		}),
+		TOTPSecretRotationOption: totpSecretRotationOptionFunc(func(r *TOTPSecretRotation) {
+			r.logger = l
+		}),
+		MigrateOption: migrateOptionFunc(func(m *migrateConfig) {
+			m.logger = l
+		}),
+		ExecTOTPSecretProviderOption: execTOTPSecretProviderOptionFunc(func(p *ExecTOTPSecretProvider) {
+			p.logger = l
+		}),
+		PromptTOTPSecretGetterOption: promptTOTPSecretGetterOptionFunc(func(p *PromptTOTPSecretGetter) {
+			p.logger = l
+		}),
+		EncryptedTOTPSecretProviderOption: encryptedTOTPSecretProviderOptionFunc(func(p *EncryptedTOTPSecretProvider) {
+			p.logger = l
+		}),
 	}
 }
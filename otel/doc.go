@@ -0,0 +1,5 @@
+// Package otel provides OpenTelemetry tracing and metrics for the generators, the verifiers and the TOTP secret
+// providers.
+//
+// The secrets and the one-time passwords never appear in the span attributes nor in the metric attributes.
+package otel
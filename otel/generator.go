@@ -0,0 +1,64 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"go.nhat.io/otp"
+)
+
+type nextGenerator interface {
+	GenerateNextOTP(ctx context.Context) (otp.OTP, error)
+}
+
+var _ otp.Generator = (*Generator)(nil)
+
+// Generator is an otp.Generator that is traced and measured.
+type Generator struct {
+	upstream    otp.Generator
+	instruments *instruments
+}
+
+// GenerateOTP generates a one-time password.
+func (g *Generator) GenerateOTP(ctx context.Context) (otp.OTP, error) {
+	return g.generate(ctx, "otp.GenerateOTP", g.upstream.GenerateOTP)
+}
+
+// GenerateNextOTP generates the one-time password of the next time step if the upstream generator supports it, or a
+// new one-time password otherwise.
+func (g *Generator) GenerateNextOTP(ctx context.Context) (otp.OTP, error) {
+	if n, ok := g.upstream.(nextGenerator); ok {
+		return g.generate(ctx, "otp.GenerateNextOTP", n.GenerateNextOTP)
+	}
+
+	return g.GenerateOTP(ctx)
+}
+
+func (g *Generator) generate(ctx context.Context, name string, fn func(ctx context.Context) (otp.OTP, error)) (otp.OTP, error) {
+	ctx, span, start := g.instruments.start(ctx, name)
+
+	code, err := fn(ctx)
+
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+
+	attrs := metric.WithAttributes(attrResult.String(result))
+
+	g.instruments.generations.Add(ctx, 1, attrs)
+	g.instruments.generationDuration.Record(ctx, since(start), attrs)
+
+	end(span, result, err)
+
+	return code, err
+}
+
+// WrapGenerator traces and measures the generation of the one-time passwords.
+func WrapGenerator(g otp.Generator, opts ...Option) *Generator {
+	return &Generator{
+		upstream:    g,
+		instruments: newInstruments(opts...),
+	}
+}
@@ -0,0 +1,152 @@
+package otel
+
+import (
+	"context"
+	"time"
+
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go.nhat.io/otp"
+
+const (
+	attrProvider  = attribute.Key("otp.provider")
+	attrOperation = attribute.Key("otp.operation")
+	attrResult    = attribute.Key("otp.result")
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultInvalid = "invalid"
+	resultHit     = "hit"
+	resultMiss    = "miss"
+)
+
+type instruments struct {
+	tracer trace.Tracer
+
+	generations        metric.Int64Counter
+	generationDuration metric.Float64Histogram
+
+	verifications        metric.Int64Counter
+	verificationDuration metric.Float64Histogram
+
+	lookups        metric.Int64Counter
+	lookupDuration metric.Float64Histogram
+	providerErrors metric.Int64Counter
+}
+
+func newInstruments(opts ...Option) *instruments {
+	cfg := config{
+		tracerProvider: otelapi.GetTracerProvider(),
+		meterProvider:  otelapi.GetMeterProvider(),
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(&cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	i := &instruments{
+		tracer: cfg.tracerProvider.Tracer(instrumentationName),
+	}
+
+	i.generations = mustInstrument(meter.Int64Counter("otp.generations",
+		metric.WithDescription("The number of one-time password generations."),
+		metric.WithUnit("{generation}"),
+	))
+	i.generationDuration = mustInstrument(meter.Float64Histogram("otp.generation.duration",
+		metric.WithDescription("The duration of one-time password generations."),
+		metric.WithUnit("s"),
+	))
+	i.verifications = mustInstrument(meter.Int64Counter("otp.verifications",
+		metric.WithDescription("The number of one-time password verifications."),
+		metric.WithUnit("{verification}"),
+	))
+	i.verificationDuration = mustInstrument(meter.Float64Histogram("otp.verification.duration",
+		metric.WithDescription("The duration of one-time password verifications."),
+		metric.WithUnit("s"),
+	))
+	i.lookups = mustInstrument(meter.Int64Counter("otp.provider.lookups",
+		metric.WithDescription("The number of totp secret lookups."),
+		metric.WithUnit("{lookup}"),
+	))
+	i.lookupDuration = mustInstrument(meter.Float64Histogram("otp.provider.lookup.duration",
+		metric.WithDescription("The duration of totp secret lookups."),
+		metric.WithUnit("s"),
+	))
+	i.providerErrors = mustInstrument(meter.Int64Counter("otp.provider.errors",
+		metric.WithDescription("The number of failed totp secret changes."),
+		metric.WithUnit("{error}"),
+	))
+
+	return i
+}
+
+// mustInstrument reports the error to the global error handler. The instrument is still usable, it is a no-op.
+func mustInstrument[T any](i T, err error) T {
+	if err != nil {
+		otelapi.Handle(err)
+	}
+
+	return i
+}
+
+func (i *instruments) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span, time.Time) {
+	ctx, span := i.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+
+	return ctx, span, time.Now()
+}
+
+func end(span trace.Span, result string, err error) {
+	span.SetAttributes(attrResult.String(result))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures the instrumentation.
+type Option interface {
+	applyOption(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) applyOption(c *config) {
+	f(c)
+}
+
+// WithTracerProvider sets the tracer provider. Default is the global one.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return optionFunc(func(c *config) {
+		c.tracerProvider = tp
+	})
+}
+
+// WithMeterProvider sets the meter provider. Default is the global one.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return optionFunc(func(c *config) {
+		c.meterProvider = mp
+	})
+}
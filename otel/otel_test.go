@@ -0,0 +1,265 @@
+//go:build unit || !integration
+
+package otel_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+	"go.nhat.io/otp/otel"
+)
+
+const secret = otp.TOTPSecret("NBSWY3DP")
+
+type telemetry struct {
+	spans   *tracetest.SpanRecorder
+	metrics *sdkmetric.ManualReader
+	options []otel.Option
+}
+
+func newTelemetry(t *testing.T) *telemetry {
+	t.Helper()
+
+	spans := tracetest.NewSpanRecorder()
+	metrics := sdkmetric.NewManualReader()
+
+	return &telemetry{
+		spans:   spans,
+		metrics: metrics,
+		options: []otel.Option{
+			otel.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+			otel.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics))),
+		},
+	}
+}
+
+// counters returns the values of the counter by the attributes.
+func (tm *telemetry) counters(t *testing.T, name string) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+
+	require.NoError(t, tm.metrics.Collect(context.Background(), &rm))
+
+	result := make(map[string]int64)
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				result[encode(dp.Attributes.ToSlice())] = dp.Value
+			}
+		}
+	}
+
+	return result
+}
+
+// histograms returns the counts of the histogram by the attributes.
+func (tm *telemetry) histograms(t *testing.T, name string) map[string]uint64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+
+	require.NoError(t, tm.metrics.Collect(context.Background(), &rm))
+
+	result := make(map[string]uint64)
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				result[encode(dp.Attributes.ToSlice())] = dp.Count
+			}
+		}
+	}
+
+	return result
+}
+
+func encode(attrs []attribute.KeyValue) string {
+	parts := make([]string, 0, len(attrs))
+
+	for _, a := range attrs {
+		parts = append(parts, string(a.Key)+"="+a.Value.Emit())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func assertNoSecret(t *testing.T, spans []sdktrace.ReadOnlySpan, values ...string) {
+	t.Helper()
+
+	for _, s := range spans {
+		for _, a := range s.Attributes() {
+			for _, v := range values {
+				assert.NotContains(t, a.Value.Emit(), v, "span %q leaks %q", s.Name(), a.Key)
+			}
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tm := newTelemetry(t)
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	g := otel.WrapGenerator(otp.NewTOTPGenerator(secret, otp.WithClock(c)), tm.options...)
+
+	code, err := g.GenerateOTP(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, otp.OTP("191882"), code)
+
+	next, err := g.GenerateNextOTP(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, code, next)
+
+	_, err = otel.WrapGenerator(otp.NewTOTPGenerator(otp.NoTOTPSecret), tm.options...).GenerateOTP(ctx)
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+
+	spans := tm.spans.Ended()
+
+	require.Len(t, spans, 3)
+	assert.Equal(t, "otp.GenerateOTP", spans[0].Name())
+	assert.Equal(t, "otp.GenerateNextOTP", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+
+	assertNoSecret(t, spans, string(secret), code.String(), next.String())
+
+	assert.Equal(t, map[string]int64{"otp.result=success": 2, "otp.result=error": 1}, tm.counters(t, "otp.generations"))
+	assert.Equal(t, map[string]uint64{"otp.result=success": 2, "otp.result=error": 1}, tm.histograms(t, "otp.generation.duration"))
+}
+
+func TestGenerator_GenerateNextOTP_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry(t)
+
+	g := otel.WrapGenerator(mock.MockGenerator(func(g *mock.Generator) {
+		g.On("GenerateOTP", mock.Anything).Return(otp.OTP("123456"), nil)
+	})(t), tm.options...)
+
+	code, err := g.GenerateNextOTP(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, otp.OTP("123456"), code)
+
+	require.Len(t, tm.spans.Ended(), 1)
+	assert.Equal(t, "otp.GenerateOTP", tm.spans.Ended()[0].Name())
+}
+
+func TestVerifier(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tm := newTelemetry(t)
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	v := otel.WrapVerifier(otp.NewTOTPVerifier(secret, otp.WithClock(c)), tm.options...)
+
+	require.NoError(t, v.VerifyOTP(ctx, "191882"))
+	require.ErrorIs(t, v.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+
+	err := otel.WrapVerifier(otp.NewTOTPVerifier(otp.NoTOTPSecret), tm.options...).VerifyOTP(ctx, "191882")
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+
+	spans := tm.spans.Ended()
+
+	require.Len(t, spans, 3)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+
+	assertNoSecret(t, spans, string(secret), "191882", "000000")
+
+	expected := map[string]int64{"otp.result=success": 1, "otp.result=invalid": 1, "otp.result=error": 1}
+
+	assert.Equal(t, expected, tm.counters(t, "otp.verifications"))
+	assert.Len(t, tm.histograms(t, "otp.verification.duration"), 3)
+}
+
+func TestTOTPSecretProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tm := newTelemetry(t)
+	storage := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	p := otel.WrapTOTPSecretProvider(storage, "memory", tm.options...)
+
+	assert.Equal(t, p, p.TOTPSecretSetter())
+	assert.Equal(t, p, p.TOTPSecretDeleter())
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+
+	require.NoError(t, p.SetTOTPSecret(ctx, secret, "issuer"))
+
+	assert.Equal(t, secret, p.TOTPSecret(ctx))
+
+	require.NoError(t, p.DeleteTOTPSecret(ctx))
+
+	failing := otel.WrapTOTPSecretProvider(otp.TOTPSecret("readonly"), "const", tm.options...)
+
+	require.ErrorIs(t, failing.SetTOTPSecret(ctx, secret, ""), otp.ErrTOTPSecretReadOnly)
+	require.ErrorIs(t, failing.DeleteTOTPSecret(ctx), otp.ErrTOTPSecretReadOnly)
+
+	spans := tm.spans.Ended()
+
+	require.Len(t, spans, 6)
+	assertNoSecret(t, spans, string(secret), "readonly")
+
+	assert.Contains(t, spans[0].Attributes(), attribute.String("otp.provider", "memory"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("otp.result", "miss"))
+	assert.Equal(t, "otp.SetTOTPSecret", spans[1].Name())
+	assert.Contains(t, spans[2].Attributes(), attribute.String("otp.result", "hit"))
+	assert.Equal(t, "otp.DeleteTOTPSecret", spans[3].Name())
+	assert.Equal(t, codes.Error, spans[4].Status().Code)
+
+	expected := map[string]int64{
+		"otp.provider=memory,otp.result=hit":  1,
+		"otp.provider=memory,otp.result=miss": 1,
+	}
+
+	assert.Equal(t, expected, tm.counters(t, "otp.provider.lookups"))
+	assert.Len(t, tm.histograms(t, "otp.provider.lookup.duration"), 2)
+
+	expected = map[string]int64{
+		"otp.operation=delete,otp.provider=const": 1,
+		"otp.operation=set,otp.provider=const":    1,
+	}
+
+	assert.Equal(t, expected, tm.counters(t, "otp.provider.errors"))
+}
+
+func TestTOTPSecretGetter(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry(t)
+	g := otel.WrapTOTPSecretGetter(otp.TOTPSecretFromEnv("OTP_OTEL_TEST_UNSET"), "env", tm.options...)
+
+	assert.Equal(t, g, g.TOTPSecretGetter())
+	assert.Equal(t, otp.NoTOTPSecret, g.TOTPSecret(context.Background()))
+
+	assert.Equal(t, map[string]int64{"otp.provider=env,otp.result=miss": 1}, tm.counters(t, "otp.provider.lookups"))
+}
@@ -0,0 +1,114 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"go.nhat.io/otp"
+)
+
+var _ otp.TOTPSecretGetter = (*TOTPSecretGetter)(nil)
+
+// TOTPSecretGetter is an otp.TOTPSecretGetter that is traced and measured.
+type TOTPSecretGetter struct {
+	upstream    otp.TOTPSecretGetter
+	name        string
+	instruments *instruments
+}
+
+// TOTPSecret returns the TOTP secret of the upstream getter.
+func (g *TOTPSecretGetter) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	ctx, span, start := g.instruments.start(ctx, "otp.TOTPSecret", attrProvider.String(g.name))
+
+	s := g.upstream.TOTPSecret(ctx)
+
+	result := resultHit
+	if s == otp.NoTOTPSecret {
+		result = resultMiss
+	}
+
+	attrs := metric.WithAttributes(attrProvider.String(g.name), attrResult.String(result))
+
+	g.instruments.lookups.Add(ctx, 1, attrs)
+	g.instruments.lookupDuration.Record(ctx, since(start), attrs)
+
+	end(span, result, nil)
+
+	return s
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (g *TOTPSecretGetter) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return g
+}
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider is an otp.TOTPSecretProvider that is traced and measured.
+type TOTPSecretProvider struct {
+	TOTPSecretGetter
+
+	upstream otp.TOTPSecretProvider
+}
+
+// SetTOTPSecret sets the TOTP secret of the upstream provider.
+func (p *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, issuer string) error {
+	return p.change(ctx, "otp.SetTOTPSecret", "set", func(ctx context.Context) error {
+		return p.upstream.SetTOTPSecret(ctx, secret, issuer)
+	})
+}
+
+// DeleteTOTPSecret deletes the TOTP secret of the upstream provider.
+func (p *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	return p.change(ctx, "otp.DeleteTOTPSecret", "delete", p.upstream.DeleteTOTPSecret)
+}
+
+func (p *TOTPSecretProvider) change(ctx context.Context, name, operation string, fn func(ctx context.Context) error) error {
+	ctx, span, _ := p.instruments.start(ctx, name, attrProvider.String(p.name))
+
+	err := fn(ctx)
+
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+
+		p.instruments.providerErrors.Add(ctx, 1, metric.WithAttributes(
+			attrProvider.String(p.name),
+			attrOperation.String(operation),
+		))
+	}
+
+	end(span, result, err)
+
+	return err
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (p *TOTPSecretProvider) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return p
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (p *TOTPSecretProvider) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return p
+}
+
+// WrapTOTPSecretGetter traces and measures the lookups of the TOTP secret. The name identifies the getter in the
+// attributes, such as "env" or "keyring".
+func WrapTOTPSecretGetter(g otp.TOTPSecretGetter, name string, opts ...Option) *TOTPSecretGetter {
+	return &TOTPSecretGetter{
+		upstream:    g,
+		name:        name,
+		instruments: newInstruments(opts...),
+	}
+}
+
+// WrapTOTPSecretProvider traces and measures the lookups and the changes of the TOTP secret. The name identifies the
+// provider in the attributes, such as "env" or "keyring".
+func WrapTOTPSecretProvider(p otp.TOTPSecretProvider, name string, opts ...Option) *TOTPSecretProvider {
+	return &TOTPSecretProvider{
+		TOTPSecretGetter: *WrapTOTPSecretGetter(p, name, opts...),
+		upstream:         p,
+	}
+}
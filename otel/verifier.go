@@ -0,0 +1,57 @@
+package otel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/metric"
+
+	"go.nhat.io/otp"
+)
+
+var _ otp.Verifier = (*Verifier)(nil)
+
+// Verifier is an otp.Verifier that is traced and measured.
+type Verifier struct {
+	upstream    otp.Verifier
+	instruments *instruments
+}
+
+// VerifyOTP verifies a one-time password.
+func (v *Verifier) VerifyOTP(ctx context.Context, code otp.OTP) error {
+	ctx, span, start := v.instruments.start(ctx, "otp.VerifyOTP")
+
+	err := v.upstream.VerifyOTP(ctx, code)
+
+	result := resultSuccess
+
+	switch {
+	case errors.Is(err, otp.ErrInvalidOTP):
+		result = resultInvalid
+
+	case err != nil:
+		result = resultError
+	}
+
+	attrs := metric.WithAttributes(attrResult.String(result))
+
+	v.instruments.verifications.Add(ctx, 1, attrs)
+	v.instruments.verificationDuration.Record(ctx, since(start), attrs)
+
+	// An invalid code is an expected outcome, not a failure of the span.
+	if result == resultInvalid {
+		end(span, result, nil)
+	} else {
+		end(span, result, err)
+	}
+
+	return err
+}
+
+// WrapVerifier traces and measures the verification of the one-time passwords.
+func WrapVerifier(v otp.Verifier, opts ...Option) *Verifier {
+	return &Verifier{
+		upstream:    v,
+		instruments: newInstruments(opts...),
+	}
+}
@@ -0,0 +1,2 @@
+// Package otphttp provides an http.RoundTripper that injects one-time passwords into outgoing requests.
+package otphttp
@@ -0,0 +1,249 @@
+package otphttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.nhat.io/otp"
+)
+
+// DefaultHeader is the default header that carries the one-time password.
+const DefaultHeader = "X-OTP"
+
+const contentTypeForm = "application/x-www-form-urlencoded"
+
+// ErrUnsupportedContentType indicates that the one-time password could not be put into the request body.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+type placement int
+
+const (
+	placementHeader placement = iota
+	placementQuery
+	placementForm
+)
+
+// nextGenerator generates the one-time password of the next time step, such as otp.TOTPGenerator.
+type nextGenerator interface {
+	GenerateNextOTP(ctx context.Context) (otp.OTP, error)
+}
+
+// Transport is an http.RoundTripper that injects a fresh one-time password into every outgoing request.
+//
+// When the server responds with 401 Unauthorized, the request is retried once with the one-time password of the next
+// time step if the generator supports it, or with a newly generated one otherwise.
+type Transport struct {
+	base      http.RoundTripper
+	generator otp.Generator
+
+	placement placement
+	name      string
+	retry     bool
+}
+
+// RoundTrip executes a single HTTP transaction with a one-time password.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := t.readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := t.generator.GenerateOTP(req.Context())
+	if err != nil {
+		closeBody(req)
+
+		return nil, fmt.Errorf("could not inject otp: %w", err)
+	}
+
+	r, err := t.inject(req, body, code)
+	if err != nil {
+		closeBody(req)
+
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !t.retry || !t.canRetry(req) {
+		return resp, err //nolint: wrapcheck
+	}
+
+	code, err = t.nextOTP(req.Context())
+	if err != nil {
+		return resp, nil //nolint: nilerr
+	}
+
+	if r, err = t.inject(req, body, code); err != nil {
+		return resp, nil //nolint: nilerr
+	}
+
+	if req.Body != nil && t.placement != placementForm {
+		if r.Body, err = req.GetBody(); err != nil {
+			return resp, nil //nolint: nilerr
+		}
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body) //nolint: errcheck
+	_ = resp.Body.Close()                 //nolint: errcheck
+
+	return t.base.RoundTrip(r) //nolint: wrapcheck
+}
+
+func (t *Transport) readBody(req *http.Request) ([]byte, error) {
+	if t.placement != placementForm {
+		return nil, nil
+	}
+
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != contentTypeForm {
+			closeBody(req)
+
+			return nil, fmt.Errorf("could not inject otp: %w: %s", ErrUnsupportedContentType, ct)
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	defer req.Body.Close() //nolint: errcheck
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body: %w", err)
+	}
+
+	return body, nil
+}
+
+func (t *Transport) canRetry(req *http.Request) bool {
+	if t.placement == placementForm {
+		return true
+	}
+
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (t *Transport) nextOTP(ctx context.Context) (otp.OTP, error) {
+	if g, ok := t.generator.(nextGenerator); ok {
+		return g.GenerateNextOTP(ctx)
+	}
+
+	return t.generator.GenerateOTP(ctx)
+}
+
+func (t *Transport) inject(req *http.Request, body []byte, code otp.OTP) (*http.Request, error) {
+	r := req.Clone(req.Context())
+
+	switch t.placement {
+	case placementHeader:
+		r.Header.Set(t.name, code.String())
+
+	case placementQuery:
+		q := r.URL.Query()
+		q.Set(t.name, code.String())
+
+		r.URL.RawQuery = q.Encode()
+
+	case placementForm:
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse request body: %w", err)
+		}
+
+		form.Set(t.name, code.String())
+
+		data := []byte(form.Encode())
+
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		r.ContentLength = int64(len(data))
+
+		r.Header.Set("Content-Type", contentTypeForm)
+		r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+
+	return r, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close() //nolint: errcheck
+	}
+}
+
+// NewTransport initiates a new Transport.
+func NewTransport(generator otp.Generator, opts ...TransportOption) *Transport {
+	t := &Transport{
+		base:      http.DefaultTransport,
+		generator: generator,
+		placement: placementHeader,
+		name:      DefaultHeader,
+		retry:     true,
+	}
+
+	for _, opt := range opts {
+		opt.applyTransportOption(t)
+	}
+
+	return t
+}
+
+// TransportOption is an option to configure Transport.
+type TransportOption interface {
+	applyTransportOption(t *Transport)
+}
+
+type transportOptionFunc func(t *Transport)
+
+func (f transportOptionFunc) applyTransportOption(t *Transport) {
+	f(t)
+}
+
+// WithBaseTransport sets the underlying http.RoundTripper of the Transport. Default is http.DefaultTransport.
+func WithBaseTransport(base http.RoundTripper) TransportOption {
+	return transportOptionFunc(func(t *Transport) {
+		t.base = base
+	})
+}
+
+// WithHeader puts the one-time password in the given header. Default is X-OTP.
+func WithHeader(name string) TransportOption {
+	return transportOptionFunc(func(t *Transport) {
+		t.placement = placementHeader
+		t.name = name
+	})
+}
+
+// WithQueryParam puts the one-time password in the given query parameter.
+func WithQueryParam(name string) TransportOption {
+	return transportOptionFunc(func(t *Transport) {
+		t.placement = placementQuery
+		t.name = name
+	})
+}
+
+// WithFormField puts the one-time password in the given field of an application/x-www-form-urlencoded body.
+func WithFormField(name string) TransportOption {
+	return transportOptionFunc(func(t *Transport) {
+		t.placement = placementForm
+		t.name = name
+	})
+}
+
+// WithoutRetry disables the retry on 401 Unauthorized.
+func WithoutRetry() TransportOption {
+	return transportOptionFunc(func(t *Transport) {
+		t.retry = false
+	})
+}
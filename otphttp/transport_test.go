@@ -0,0 +1,305 @@
+//go:build unit || !integration
+
+package otphttp_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+	"go.nhat.io/otp/otphttp"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	accept   func(r *http.Request, body string) bool
+}
+
+func (rc *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body) //nolint: errcheck
+
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, string(body))
+	rc.mu.Unlock()
+
+	if rc.accept != nil && !rc.accept(r, string(body)) {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func newGenerator() *otp.TOTPGenerator {
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	return otp.NewTOTPGenerator(otp.TOTPSecret("NBSWY3DP"), otp.WithClock(c))
+}
+
+func nextOTP(t *testing.T) string {
+	t.Helper()
+
+	code, err := newGenerator().GenerateNextOTP(context.Background())
+	require.NoError(t, err)
+
+	return code.String()
+}
+
+func TestTransport_Header(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator())}
+
+	resp, err := c.Get(srv.URL) //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	require.Len(t, rc.requests, 1)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "191882", rc.requests[0].Header.Get(otphttp.DefaultHeader))
+}
+
+func TestTransport_QueryParam(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator(), otphttp.WithQueryParam("otp"))}
+
+	resp, err := c.Get(srv.URL + "?foo=bar") //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	require.Len(t, rc.requests, 1)
+	assert.Equal(t, "191882", rc.requests[0].URL.Query().Get("otp"))
+	assert.Equal(t, "bar", rc.requests[0].URL.Query().Get("foo"))
+	assert.Empty(t, rc.requests[0].Header.Get(otphttp.DefaultHeader))
+}
+
+func TestTransport_FormField(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator(), otphttp.WithFormField("otp"))}
+
+	resp, err := c.PostForm(srv.URL, url.Values{"foo": {"bar"}}) //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	require.Len(t, rc.bodies, 1)
+
+	form, err := url.ParseQuery(rc.bodies[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, url.Values{"foo": {"bar"}, "otp": {"191882"}}, form)
+}
+
+func TestTransport_FormField_UnsupportedContentType(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator(), otphttp.WithFormField("otp"))}
+
+	resp, err := c.Post(srv.URL, "application/json", strings.NewReader(`{}`)) //nolint: noctx
+	if resp != nil {
+		_ = resp.Body.Close() //nolint: errcheck
+	}
+
+	require.ErrorIs(t, err, otphttp.ErrUnsupportedContentType)
+	assert.Empty(t, rc.requests)
+}
+
+func TestTransport_GenerateError(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	g := mock.MockGenerator(func(g *mock.Generator) {
+		g.On("GenerateOTP", mock.Anything).
+			Return(otp.OTP(""), otp.ErrNoTOTPSecret)
+	})(t)
+
+	c := &http.Client{Transport: otphttp.NewTransport(g)}
+
+	resp, err := c.Get(srv.URL) //nolint: noctx
+	if resp != nil {
+		_ = resp.Body.Close() //nolint: errcheck
+	}
+
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+	assert.Empty(t, rc.requests)
+}
+
+func TestTransport_RetryWithNextOTP(t *testing.T) {
+	t.Parallel()
+
+	next := nextOTP(t)
+
+	testCases := []struct {
+		scenario string
+		options  []otphttp.TransportOption
+		send     func(c *http.Client, u string) (*http.Response, error)
+		accept   func(r *http.Request, body string) bool
+	}{
+		{
+			scenario: "header",
+			send: func(c *http.Client, u string) (*http.Response, error) {
+				return c.Post(u, "text/plain", strings.NewReader("payload")) //nolint: noctx
+			},
+			accept: func(r *http.Request, body string) bool {
+				return r.Header.Get(otphttp.DefaultHeader) == next && body == "payload"
+			},
+		},
+		{
+			scenario: "query",
+			options:  []otphttp.TransportOption{otphttp.WithQueryParam("otp")},
+			send: func(c *http.Client, u string) (*http.Response, error) {
+				return c.Get(u) //nolint: noctx
+			},
+			accept: func(r *http.Request, _ string) bool {
+				return r.URL.Query().Get("otp") == next
+			},
+		},
+		{
+			scenario: "form",
+			options:  []otphttp.TransportOption{otphttp.WithFormField("otp")},
+			send: func(c *http.Client, u string) (*http.Response, error) {
+				return c.PostForm(u, url.Values{"foo": {"bar"}}) //nolint: noctx
+			},
+			accept: func(_ *http.Request, body string) bool {
+				form, err := url.ParseQuery(body)
+
+				return err == nil && form.Get("otp") == next && form.Get("foo") == "bar"
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			rc := &recorder{accept: tc.accept}
+			srv := httptest.NewServer(rc)
+
+			t.Cleanup(srv.Close)
+
+			c := &http.Client{Transport: otphttp.NewTransport(newGenerator(), tc.options...)}
+
+			resp, err := tc.send(c, srv.URL)
+			require.NoError(t, err)
+
+			_ = resp.Body.Close() //nolint: errcheck
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Len(t, rc.requests, 2)
+		})
+	}
+}
+
+func TestTransport_RetryWithoutNextGenerator(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{accept: func(r *http.Request, _ string) bool {
+		return r.Header.Get("X-Code") == "222222"
+	}}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	g := mock.MockGenerator(func(g *mock.Generator) {
+		g.On("GenerateOTP", mock.Anything).
+			Return(otp.OTP("111111"), nil).Once()
+
+		g.On("GenerateOTP", mock.Anything).
+			Return(otp.OTP("222222"), nil).Once()
+	})(t)
+
+	c := &http.Client{Transport: otphttp.NewTransport(g, otphttp.WithHeader("X-Code"))}
+
+	resp, err := c.Get(srv.URL) //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, rc.requests, 2)
+}
+
+func TestTransport_WithoutRetry(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{accept: func(*http.Request, string) bool { return false }}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator(),
+		otphttp.WithoutRetry(),
+		otphttp.WithBaseTransport(http.DefaultTransport),
+	)}
+
+	resp, err := c.Get(srv.URL) //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Len(t, rc.requests, 1)
+}
+
+func TestTransport_RetryOnlyOnce(t *testing.T) {
+	t.Parallel()
+
+	rc := &recorder{accept: func(*http.Request, string) bool { return false }}
+	srv := httptest.NewServer(rc)
+
+	t.Cleanup(srv.Close)
+
+	c := &http.Client{Transport: otphttp.NewTransport(newGenerator())}
+
+	resp, err := c.Get(srv.URL) //nolint: noctx
+	require.NoError(t, err)
+
+	_ = resp.Body.Close() //nolint: errcheck
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Len(t, rc.requests, 2)
+}
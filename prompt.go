@@ -0,0 +1,321 @@
+package otp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+	"golang.org/x/term"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// ErrInvalidTOTPSecret indicates that the TOTP secret is not a valid base32 secret or otpauth URI.
+var ErrInvalidTOTPSecret = errors.New("invalid totp secret")
+
+// DefaultPromptMessage is the default message of a PromptTOTPSecretGetter.
+const DefaultPromptMessage = "Enter the TOTP secret or otpauth URI: "
+
+// DefaultPromptAttempts is the default number of attempts of a PromptTOTPSecretGetter.
+const DefaultPromptAttempts = 3
+
+var _ TOTPSecretGetter = (*PromptTOTPSecretGetter)(nil)
+
+// PromptTOTPSecretGetter is a TOTP secret getter that asks the user for the TOTP secret, or an otpauth URI. It reads
+// from the terminal with echo disabled, or line by line if the input is not a terminal. The user is asked again if the
+// input is invalid. The TOTP secret is asked once and is kept in memory, it may also be persisted through a
+// TOTPSecretSetter, such as the keyring.
+//
+// A blocked read does not hold the other callers: Load returns when the context is done, and the line that is read
+// later is used by the next call.
+//
+// It is meant to be the last of a chain, for example:
+//
+//	otp.ChainTOTPSecretGetters(
+//		keyring.TOTPSecretFromKeyring(account),
+//		otp.NewPromptTOTPSecretGetter(otp.WithPromptSetter(keyring.TOTPSecretFromKeyring(account), "GitHub")),
+//	)
+type PromptTOTPSecretGetter struct {
+	in       io.Reader
+	out      io.Writer
+	message  string
+	attempts int
+	setter   TOTPSecretSetter
+	issuer   string
+	logger   ctxd.Logger
+
+	mu     sync.Mutex
+	secret TOTPSecret
+
+	// prompting is held by the caller that prompts the user, so that the user is asked once at a time.
+	prompting chan struct{}
+	lines     *bufio.Reader
+	// pending is the read in progress, whose caller has given up. It is nil if there is none.
+	pending chan promptRead
+}
+
+// promptRead is the result of a read from the input.
+type promptRead struct {
+	in  []byte
+	err error
+}
+
+// TOTPSecret returns the TOTP secret that the user enters. See Load for the error.
+func (p *PromptTOTPSecretGetter) TOTPSecret(ctx context.Context) TOTPSecret {
+	s, err := p.Load(ctx)
+	if err != nil {
+		p.logger.Error(ctx, "could not get totp secret from prompt", "error", err)
+	}
+
+	return s
+}
+
+// Load asks the user for the TOTP secret until it is valid or the attempts are exhausted, and persists it if there is
+// a setter. It returns ErrInvalidTOTPSecret if the attempts are exhausted, or ErrNoTOTPSecret if the input ends. A
+// failure of the setter is logged and does not fail the prompt. It returns the error of the context if the context is
+// done while waiting for the input, or for another call that prompts the user.
+func (p *PromptTOTPSecretGetter) Load(ctx context.Context) (TOTPSecret, error) {
+	if s := p.loaded(); s != NoTOTPSecret {
+		return s, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not read totp secret: %w", err)
+	}
+
+	select {
+	case p.prompting <- struct{}{}:
+		defer func() { <-p.prompting }()
+
+	case <-ctx.Done():
+		return NoTOTPSecret, fmt.Errorf("could not read totp secret: %w", ctx.Err())
+	}
+
+	// The secret may have been entered while waiting.
+	if s := p.loaded(); s != NoTOTPSecret {
+		return s, nil
+	}
+
+	for attempt := 1; attempt <= p.attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return NoTOTPSecret, fmt.Errorf("could not read totp secret: %w", err)
+		}
+
+		// The user has already been asked if a read is in progress.
+		if p.pending == nil {
+			if _, err := io.WriteString(p.out, p.message); err != nil {
+				return NoTOTPSecret, fmt.Errorf("could not prompt totp secret: %w", err)
+			}
+		}
+
+		in, err := p.read(ctx)
+		if err != nil {
+			return NoTOTPSecret, fmt.Errorf("could not read totp secret: %w", err)
+		}
+
+		s, issuer, err := parsePromptedTOTPSecret(in)
+		zero.Bytes(in)
+
+		if err != nil {
+			p.logger.Debug(ctx, "invalid totp secret from prompt", "error", err, "attempt", attempt)
+
+			_, _ = fmt.Fprintln(p.out, "Invalid TOTP secret or otpauth URI, please try again.") //nolint: errcheck
+
+			continue
+		}
+
+		p.mu.Lock()
+		p.secret = s
+		p.mu.Unlock()
+
+		p.save(ctx, s, issuer)
+
+		return s, nil
+	}
+
+	return NoTOTPSecret, fmt.Errorf("could not read totp secret: %w", ErrInvalidTOTPSecret)
+}
+
+// loaded returns the TOTP secret that the user has entered, or NoTOTPSecret.
+func (p *PromptTOTPSecretGetter) loaded() TOTPSecret {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.secret
+}
+
+// read waits for a line from the input until the context is done. The input is read in a goroutine because the read
+// cannot be interrupted. If the context is done first, the read goes on and its line is returned by the next call.
+func (p *PromptTOTPSecretGetter) read(ctx context.Context) ([]byte, error) {
+	if p.pending == nil {
+		result := make(chan promptRead, 1)
+
+		go func() {
+			in, err := p.readLine()
+
+			result <- promptRead{in: in, err: err}
+		}()
+
+		p.pending = result
+	}
+
+	select {
+	case r := <-p.pending:
+		p.pending = nil
+
+		return r.in, r.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// readLine reads a line from the input. The echo is disabled if the input is a terminal.
+func (p *PromptTOTPSecretGetter) readLine() ([]byte, error) {
+	if f, ok := p.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) { //nolint: gosec
+		in, err := term.ReadPassword(int(f.Fd())) //nolint: gosec
+
+		// The line break is not echoed.
+		_, _ = fmt.Fprintln(p.out) //nolint: errcheck
+
+		return in, err
+	}
+
+	in, err := p.lines.ReadBytes('\n')
+
+	switch {
+	case errors.Is(err, io.EOF) && len(in) == 0:
+		return nil, ErrNoTOTPSecret
+
+	case err != nil && !errors.Is(err, io.EOF):
+		zero.Bytes(in)
+
+		return nil, err
+	}
+
+	return in, nil
+}
+
+func (p *PromptTOTPSecretGetter) save(ctx context.Context, s TOTPSecret, issuer string) {
+	if p.setter == nil {
+		return
+	}
+
+	if issuer == "" {
+		issuer = p.issuer
+	}
+
+	if err := p.setter.SetTOTPSecret(ctx, s, issuer); err != nil {
+		p.logger.Error(ctx, "could not persist totp secret from prompt", "error", err)
+
+		_, _ = fmt.Fprintln(p.out, "Warning: could not save the TOTP secret.") //nolint: errcheck
+	}
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (p *PromptTOTPSecretGetter) TOTPSecretGetter() TOTPSecretGetter {
+	return p
+}
+
+// parsePromptedTOTPSecret validates the input, which is either a bare secret or an otpauth URI, and returns it as is
+// so that the parameters of the URI are kept. The issuer is the one of the URI, if any.
+func parsePromptedTOTPSecret(in []byte) (TOTPSecret, string, error) {
+	s := TOTPSecret(strings.TrimSpace(string(in)))
+	if s == NoTOTPSecret {
+		return NoTOTPSecret, "", ErrNoTOTPSecret
+	}
+
+	secret, _, err := parseTOTPParams(s)
+	if err != nil {
+		return NoTOTPSecret, "", err
+	}
+
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return NoTOTPSecret, "", fmt.Errorf("%w: %w", ErrInvalidTOTPSecret, err)
+	}
+
+	defer key.Destroy()
+
+	if key.Len() == 0 {
+		return NoTOTPSecret, "", ErrInvalidTOTPSecret
+	}
+
+	if !IsOTPAuthURI(string(s)) {
+		return s, "", nil
+	}
+
+	k, _ := totpKeyFromURI(string(s)) //nolint: errcheck
+
+	return s, k.Issuer(), nil
+}
+
+// NewPromptTOTPSecretGetter initiates a new PromptTOTPSecretGetter. By default, it reads from the standard input,
+// writes to the standard error, and asks DefaultPromptAttempts times.
+func NewPromptTOTPSecretGetter(opts ...PromptTOTPSecretGetterOption) *PromptTOTPSecretGetter {
+	p := &PromptTOTPSecretGetter{
+		in:       os.Stdin,
+		out:      os.Stderr,
+		message:  DefaultPromptMessage,
+		attempts: DefaultPromptAttempts,
+		logger:   ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyPromptTOTPSecretGetterOption(p)
+	}
+
+	p.prompting = make(chan struct{}, 1)
+	p.lines = bufio.NewReader(p.in)
+
+	return p
+}
+
+// PromptTOTPSecretGetterOption is an option to configure PromptTOTPSecretGetter.
+type PromptTOTPSecretGetterOption interface {
+	applyPromptTOTPSecretGetterOption(p *PromptTOTPSecretGetter)
+}
+
+type promptTOTPSecretGetterOptionFunc func(p *PromptTOTPSecretGetter)
+
+func (f promptTOTPSecretGetterOptionFunc) applyPromptTOTPSecretGetterOption(p *PromptTOTPSecretGetter) {
+	f(p)
+}
+
+// WithPromptIO sets the input and the output of the prompt. The echo is disabled only if the input is a terminal.
+func WithPromptIO(in io.Reader, out io.Writer) PromptTOTPSecretGetterOption {
+	return promptTOTPSecretGetterOptionFunc(func(p *PromptTOTPSecretGetter) {
+		p.in = in
+		p.out = out
+	})
+}
+
+// WithPromptMessage sets the message of the prompt. Default is DefaultPromptMessage.
+func WithPromptMessage(message string) PromptTOTPSecretGetterOption {
+	return promptTOTPSecretGetterOptionFunc(func(p *PromptTOTPSecretGetter) {
+		p.message = message
+	})
+}
+
+// WithPromptAttempts sets the number of times the user is asked for a valid TOTP secret. Default is
+// DefaultPromptAttempts.
+func WithPromptAttempts(attempts int) PromptTOTPSecretGetterOption {
+	return promptTOTPSecretGetterOptionFunc(func(p *PromptTOTPSecretGetter) {
+		p.attempts = max(attempts, 1)
+	})
+}
+
+// WithPromptSetter persists the TOTP secret that the user enters, such as to the keyring. The issuer of the otpauth
+// URI takes precedence over the given one.
+func WithPromptSetter(setter TOTPSecretSetter, issuer string) PromptTOTPSecretGetterOption {
+	return promptTOTPSecretGetterOptionFunc(func(p *PromptTOTPSecretGetter) {
+		p.setter = setter
+		p.issuer = issuer
+	})
+}
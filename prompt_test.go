@@ -0,0 +1,352 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gokeyring "github.com/zalando/go-keyring"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/keyring"
+	"go.nhat.io/otp/mock"
+)
+
+func TestPromptTOTPSecretGetter_Load(t *testing.T) {
+	t.Parallel()
+
+	const (
+		prompt  = "Enter the TOTP secret or otpauth URI: "
+		invalid = "Invalid TOTP secret or otpauth URI, please try again.\n"
+	)
+
+	testCases := []struct {
+		scenario       string
+		input          string
+		options        []otp.PromptTOTPSecretGetterOption
+		expectedResult otp.TOTPSecret
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			scenario:       "bare secret",
+			input:          " nbswy3dp \n",
+			expectedResult: "nbswy3dp",
+			expectedOutput: prompt,
+		},
+		{
+			scenario:       "otpauth uri",
+			input:          "otpauth://totp/GitHub:john?secret=NBSWY3DP&digits=8\n",
+			expectedResult: "otpauth://totp/GitHub:john?secret=NBSWY3DP&digits=8",
+			expectedOutput: prompt,
+		},
+		{
+			scenario:       "no line break",
+			input:          "NBSWY3DP",
+			expectedResult: "NBSWY3DP",
+			expectedOutput: prompt,
+		},
+		{
+			scenario:       "windows line break",
+			input:          "NBSWY3DP\r\n",
+			expectedResult: "NBSWY3DP",
+			expectedOutput: prompt,
+		},
+		{
+			scenario:       "retry",
+			input:          "\nnot base32!\notpauth://totp/john?secret=NBSWY3DP&digits=4\nNBSWY3DP\n",
+			options:        []otp.PromptTOTPSecretGetterOption{otp.WithPromptAttempts(4)},
+			expectedResult: "NBSWY3DP",
+			expectedOutput: strings.Repeat(prompt+invalid, 3) + prompt,
+		},
+		{
+			scenario:       "attempts exhausted",
+			input:          "invalid!\n1890\notpauth://hotp/john?secret=NBSWY3DP\nNBSWY3DP\n",
+			expectedOutput: strings.Repeat(prompt+invalid, 3),
+			expectedError:  "could not read totp secret: invalid totp secret",
+		},
+		{
+			scenario:       "end of input",
+			input:          "invalid!\n",
+			expectedOutput: prompt + invalid + prompt,
+			expectedError:  "could not read totp secret: no totp secret",
+		},
+		{
+			scenario:       "message",
+			input:          "NBSWY3DP\n",
+			options:        []otp.PromptTOTPSecretGetterOption{otp.WithPromptMessage("GitHub TOTP: ")},
+			expectedResult: "NBSWY3DP",
+			expectedOutput: "GitHub TOTP: ",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+
+			opts := append([]otp.PromptTOTPSecretGetterOption{otp.WithPromptIO(strings.NewReader(tc.input), &out)}, tc.options...)
+
+			actual, err := otp.NewPromptTOTPSecretGetter(opts...).Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.expectedOutput, out.String())
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestPromptTOTPSecretGetter_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	l := &ctxd.LoggerMock{}
+	p := otp.NewPromptTOTPSecretGetter(
+		otp.WithPromptIO(strings.NewReader("NBSWY3DP\nJBSWY3DPEHPK3PXP\n"), &out),
+		otp.WithLogger(l),
+	)
+
+	// The secret is asked once.
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), p.TOTPSecret(context.Background()))
+	assert.Equal(t, otp.DefaultPromptMessage, out.String())
+	assert.Empty(t, l.LoggedEntries)
+	assert.Equal(t, p, p.TOTPSecretGetter())
+
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	result, err := otp.GenerateTOTP(context.Background(), otp.ChainTOTPSecretGetters(otp.NoTOTPSecret, p), otp.WithClock(c))
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.OTP("191882"), result)
+}
+
+func TestPromptTOTPSecretGetter_TOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	p := otp.NewPromptTOTPSecretGetter(
+		otp.WithPromptIO(strings.NewReader("otpauth://totp/john?secret=invalid!\n"), &bytes.Buffer{}),
+		otp.WithPromptAttempts(1),
+		otp.WithLogger(l),
+	)
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 2)
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+	assert.Equal(t, "invalid totp secret from prompt", l.LoggedEntries[0].Message)
+	assert.Equal(t, "error", l.LoggedEntries[1].Level)
+	assert.Equal(t, "could not get totp secret from prompt", l.LoggedEntries[1].Message)
+}
+
+func TestPromptTOTPSecretGetter_Canceled(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	actual, err := otp.NewPromptTOTPSecretGetter(otp.WithPromptIO(strings.NewReader("NBSWY3DP\n"), &out)).Load(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, otp.NoTOTPSecret, actual)
+	assert.Empty(t, out.String())
+}
+
+func TestPromptTOTPSecretGetter_CanceledWhileReading(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	in, w := io.Pipe()
+	p := otp.NewPromptTOTPSecretGetter(otp.WithPromptIO(in, &out))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The read is blocked until the context is done.
+	actual, err := p.Load(ctx)
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, otp.NoTOTPSecret, actual)
+
+	// The line of the pending read is used by the next call, without asking again.
+	go func() {
+		_, _ = io.WriteString(w, "NBSWY3DP\n") //nolint: errcheck
+	}()
+
+	actual, err = p.Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), actual)
+	assert.Equal(t, otp.DefaultPromptMessage, out.String())
+}
+
+func TestPromptTOTPSecretGetter_CanceledWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	in, w := io.Pipe()
+	p := otp.NewPromptTOTPSecretGetter(otp.WithPromptIO(in, io.Discard))
+
+	first := make(chan otp.TOTPSecret)
+
+	go func() {
+		s, _ := p.Load(context.Background()) //nolint: errcheck
+
+		first <- s
+	}()
+
+	// The second call does not wait for the first one to read.
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := p.Load(ctx)
+
+		return errors.Is(err, context.DeadlineExceeded)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := io.WriteString(w, "NBSWY3DP\n")
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), <-first)
+
+	actual, err := p.Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), actual)
+}
+
+func TestPromptTOTPSecretGetter_Keyring(t *testing.T) { //nolint: paralleltest
+	gokeyring.MockInit()
+
+	const (
+		account = "prompt@example.com"
+		uri     = "otpauth://totp/Acme:john?secret=NBSWY3DP&issuer=Acme&digits=8"
+	)
+
+	ctx := context.Background()
+
+	var out bytes.Buffer
+
+	p := otp.NewPromptTOTPSecretGetter(
+		otp.WithPromptIO(strings.NewReader(uri+"\n"), &out),
+		otp.WithPromptSetter(keyring.TOTPSecretFromKeyring(account), "GitHub"),
+	)
+
+	actual, err := p.Load(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret(uri), actual)
+	assert.Equal(t, otp.DefaultPromptMessage, out.String())
+
+	// The otpauth uri is persisted as is.
+	stored, err := gokeyring.Get("go.nhat.io/totp", account)
+	require.NoError(t, err)
+
+	assert.Equal(t, uri, stored)
+
+	// The next run reads the keyring and does not prompt.
+	out.Reset()
+
+	next := otp.ChainTOTPSecretGetters(
+		keyring.TOTPSecretFromKeyring(account),
+		otp.NewPromptTOTPSecretGetter(otp.WithPromptIO(strings.NewReader(""), &out)),
+	)
+
+	assert.Equal(t, otp.TOTPSecret(uri), next.TOTPSecret(ctx))
+	assert.Empty(t, out.String())
+
+	require.NoError(t, keyring.TOTPSecretFromKeyring(account).DeleteTOTPSecret(ctx))
+}
+
+func TestPromptTOTPSecretGetter_Setter(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		input          string
+		mockSetter     mock.TOTPSecretSetterMocker
+		expectedResult otp.TOTPSecret
+		expectedOutput string
+	}{
+		{
+			scenario: "bare secret",
+			input:    "NBSWY3DP\n",
+			mockSetter: mock.MockTOTPSecretSetter(func(s *mock.TOTPSecretSetter) {
+				s.On("SetTOTPSecret", context.Background(), otp.TOTPSecret("NBSWY3DP"), "GitHub").
+					Return(nil).Once()
+			}),
+			expectedResult: "NBSWY3DP",
+			expectedOutput: otp.DefaultPromptMessage,
+		},
+		{
+			scenario: "issuer of the uri",
+			input:    "otpauth://totp/Acme:john?secret=NBSWY3DP&issuer=Acme\n",
+			mockSetter: mock.MockTOTPSecretSetter(func(s *mock.TOTPSecretSetter) {
+				s.On("SetTOTPSecret", context.Background(), otp.TOTPSecret("otpauth://totp/Acme:john?secret=NBSWY3DP&issuer=Acme"), "Acme").
+					Return(nil).Once()
+			}),
+			expectedResult: "otpauth://totp/Acme:john?secret=NBSWY3DP&issuer=Acme",
+			expectedOutput: otp.DefaultPromptMessage,
+		},
+		{
+			scenario: "could not save",
+			input:    "NBSWY3DP\n",
+			mockSetter: mock.MockTOTPSecretSetter(func(s *mock.TOTPSecretSetter) {
+				s.On("SetTOTPSecret", context.Background(), otp.TOTPSecret("NBSWY3DP"), "GitHub").
+					Return(errors.New("keyring is locked")).Once() //nolint: err113
+			}),
+			expectedResult: "NBSWY3DP",
+			expectedOutput: otp.DefaultPromptMessage + "Warning: could not save the TOTP secret.\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+
+			p := otp.NewPromptTOTPSecretGetter(
+				otp.WithPromptIO(strings.NewReader(tc.input), &out),
+				otp.WithPromptSetter(tc.mockSetter(t), "GitHub"),
+			)
+
+			actual, err := p.Load(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.expectedOutput, out.String())
+
+			// The secret is saved once.
+			actual, err = p.Load(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedResult, actual)
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package otp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+)
+
+const (
+	pendingTOTPSecretPrefix = "rot:v1:"
+	totpSecretSize          = 20
+
+	defaultRotationGracePeriod = 24 * time.Hour
+)
+
+// ErrNoPendingTOTPSecret indicates that there is no rotation in progress.
+var ErrNoPendingTOTPSecret = errors.New("no pending totp secret")
+
+// ErrInvalidPendingTOTPSecret indicates that the state of the rotation is malformed.
+var ErrInvalidPendingTOTPSecret = errors.New("invalid pending totp secret")
+
+// GenerateTOTPSecret generates a random TOTP secret.
+func GenerateTOTPSecret() (TOTPSecret, error) {
+	b := make([]byte, totpSecretSize)
+
+	if _, err := rand.Read(b); err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not generate totp secret: %w", err)
+	}
+
+	return TOTPSecret(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+// PendingTOTPSecret is a TOTP secret that is waiting to replace the current one.
+type PendingTOTPSecret struct {
+	Secret    TOTPSecret `json:"secret"`
+	Issuer    string     `json:"issuer,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// pendingTOTPSecretRecord is the persisted form of PendingTOTPSecret, with the secret revealed.
+type pendingTOTPSecretRecord struct {
+	Secret    string    `json:"secret"`
+	Issuer    string    `json:"issuer,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s PendingTOTPSecret) encode() (TOTPSecret, error) {
+	data, err := json.Marshal(pendingTOTPSecretRecord{
+		Secret:    s.Secret.Reveal(),
+		Issuer:    s.Issuer,
+		ExpiresAt: s.ExpiresAt,
+	})
+	if err != nil {
+		return NoTOTPSecret, fmt.Errorf("could not encode pending totp secret: %w", err)
+	}
+
+	return TOTPSecret(pendingTOTPSecretPrefix + base64.RawURLEncoding.EncodeToString(data)), nil
+}
+
+func decodePendingTOTPSecret(s TOTPSecret) (PendingTOTPSecret, error) {
+	var (
+		result PendingTOTPSecret
+		record pendingTOTPSecretRecord
+	)
+
+	if !strings.HasPrefix(string(s), pendingTOTPSecretPrefix) {
+		return result, fmt.Errorf("could not decode pending totp secret: %w", ErrInvalidPendingTOTPSecret)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(string(s), pendingTOTPSecretPrefix))
+	if err != nil {
+		return result, fmt.Errorf("could not decode pending totp secret: %w: %w", ErrInvalidPendingTOTPSecret, err)
+	}
+
+	if err := json.Unmarshal(data, &record); err != nil {
+		return result, fmt.Errorf("could not decode pending totp secret: %w: %w", ErrInvalidPendingTOTPSecret, err)
+	}
+
+	result = PendingTOTPSecret{
+		Secret:    TOTPSecret(record.Secret),
+		Issuer:    record.Issuer,
+		ExpiresAt: record.ExpiresAt,
+	}
+
+	return result, nil
+}
+
+var _ Verifier = (*TOTPSecretRotation)(nil)
+
+// TOTPSecretRotation rotates a TOTP secret with a grace period.
+//
+// Start generates a new secret and keeps it as pending in a separate provider. Until the grace period ends, VerifyOTP
+// accepts the codes of both the current and the pending secret. The first code that matches the pending secret
+// promotes it: it replaces the current secret, and the pending one is deleted. If the grace period ends before that,
+// the rotation is abandoned and the pending secret is deleted.
+//
+// The state of the rotation is persisted in the pending provider, so it survives restarts.
+type TOTPSecretRotation struct {
+	current TOTPSecretProvider
+	pending TOTPSecretProvider
+
+	clock       clock.Clock
+	logger      ctxd.Logger
+	gracePeriod time.Duration
+}
+
+// Start generates a new TOTP secret and keeps it as pending. It replaces the rotation in progress if there is one.
+func (r *TOTPSecretRotation) Start(ctx context.Context, issuer string) (PendingTOTPSecret, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return PendingTOTPSecret{}, err
+	}
+
+	p := PendingTOTPSecret{
+		Secret:    secret,
+		Issuer:    issuer,
+		ExpiresAt: r.clock.Now().Add(r.gracePeriod).UTC(),
+	}
+
+	encoded, err := p.encode()
+	if err != nil {
+		return PendingTOTPSecret{}, err
+	}
+
+	if err := r.pending.SetTOTPSecret(ctx, encoded, issuer); err != nil {
+		r.logger.Error(ctx, "could not start totp secret rotation", "error", err)
+
+		return PendingTOTPSecret{}, fmt.Errorf("could not start totp secret rotation: %w", err)
+	}
+
+	r.logger.Info(ctx, "started totp secret rotation", "issuer", issuer, "expires_at", p.ExpiresAt)
+
+	return p, nil
+}
+
+// Pending returns the pending TOTP secret. It returns ErrNoPendingTOTPSecret if there is no rotation in progress or
+// if the grace period has ended.
+func (r *TOTPSecretRotation) Pending(ctx context.Context) (PendingTOTPSecret, error) {
+	p, err := r.loadPending(ctx)
+	if err != nil {
+		return PendingTOTPSecret{}, err
+	}
+
+	if r.expired(p) {
+		return PendingTOTPSecret{}, ErrNoPendingTOTPSecret
+	}
+
+	return p, nil
+}
+
+// loadPending returns the pending TOTP secret, even if the grace period has ended. It returns ErrNoPendingTOTPSecret if
+// there is no rotation in progress.
+func (r *TOTPSecretRotation) loadPending(ctx context.Context) (PendingTOTPSecret, error) {
+	s := r.pending.TOTPSecret(ctx)
+	if s == NoTOTPSecret {
+		return PendingTOTPSecret{}, ErrNoPendingTOTPSecret
+	}
+
+	return decodePendingTOTPSecret(s)
+}
+
+func (r *TOTPSecretRotation) expired(p PendingTOTPSecret) bool {
+	return !r.clock.Now().Before(p.ExpiresAt)
+}
+
+// Promote replaces the current TOTP secret with the pending one.
+func (r *TOTPSecretRotation) Promote(ctx context.Context) error {
+	p, err := r.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	return r.promote(ctx, p)
+}
+
+func (r *TOTPSecretRotation) promote(ctx context.Context, p PendingTOTPSecret) error {
+	if err := r.current.SetTOTPSecret(ctx, p.Secret, p.Issuer); err != nil {
+		r.logger.Error(ctx, "could not promote pending totp secret", "error", err)
+
+		return fmt.Errorf("could not promote pending totp secret: %w", err)
+	}
+
+	if err := r.deletePending(ctx); err != nil {
+		return err
+	}
+
+	r.logger.Info(ctx, "promoted pending totp secret", "issuer", p.Issuer)
+
+	return nil
+}
+
+// Cancel abandons the rotation in progress.
+func (r *TOTPSecretRotation) Cancel(ctx context.Context) error {
+	if r.pending.TOTPSecret(ctx) == NoTOTPSecret {
+		return nil
+	}
+
+	if err := r.deletePending(ctx); err != nil {
+		return err
+	}
+
+	r.logger.Info(ctx, "cancelled totp secret rotation")
+
+	return nil
+}
+
+func (r *TOTPSecretRotation) deletePending(ctx context.Context) error {
+	if err := r.pending.DeleteTOTPSecret(ctx); err != nil {
+		r.logger.Error(ctx, "could not delete pending totp secret", "error", err)
+
+		return fmt.Errorf("could not delete pending totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyOTP verifies the code against the pending TOTP secret, and promotes it if the code matches. Otherwise, it
+// verifies the code against the current TOTP secret. The pending TOTP secret is deleted once the grace period has
+// ended.
+func (r *TOTPSecretRotation) VerifyOTP(ctx context.Context, code OTP) error {
+	p, err := r.loadPending(ctx)
+
+	switch {
+	case errors.Is(err, ErrNoPendingTOTPSecret):
+		// There is no rotation in progress.
+
+	case err != nil:
+		return err
+
+	case r.expired(p):
+		if err := r.deletePending(ctx); err != nil {
+			return err
+		}
+
+		r.logger.Info(ctx, "abandoned expired totp secret rotation")
+
+	case r.verify(ctx, p.Secret, code) == nil:
+		return r.promote(ctx, p)
+	}
+
+	return r.verify(ctx, r.current, code)
+}
+
+func (r *TOTPSecretRotation) verify(ctx context.Context, secret TOTPSecretGetter, code OTP) error {
+	return NewTOTPVerifier(secret, WithClock(r.clock), WithLogger(r.logger)).VerifyOTP(ctx, code)
+}
+
+// NewTOTPSecretRotation initiates a new TOTPSecretRotation of the current secret. The pending secret and the state of
+// the rotation are kept in the pending provider. By default, the grace period is 24 hours.
+func NewTOTPSecretRotation(current, pending TOTPSecretProvider, opts ...TOTPSecretRotationOption) *TOTPSecretRotation {
+	r := &TOTPSecretRotation{
+		current: current,
+		pending: pending,
+
+		clock:       clock.New(),
+		logger:      ctxd.NoOpLogger{},
+		gracePeriod: defaultRotationGracePeriod,
+	}
+
+	for _, opt := range opts {
+		opt.applyTOTPSecretRotationOption(r)
+	}
+
+	return r
+}
+
+// TOTPSecretRotationOption is an option to configure TOTPSecretRotation.
+type TOTPSecretRotationOption interface {
+	applyTOTPSecretRotationOption(r *TOTPSecretRotation)
+}
+
+type totpSecretRotationOptionFunc func(r *TOTPSecretRotation)
+
+func (f totpSecretRotationOptionFunc) applyTOTPSecretRotationOption(r *TOTPSecretRotation) {
+	f(r)
+}
+
+// WithGracePeriod sets how long the pending TOTP secret is accepted before it is promoted.
+func WithGracePeriod(d time.Duration) TOTPSecretRotationOption {
+	return totpSecretRotationOptionFunc(func(r *TOTPSecretRotation) {
+		r.gracePeriod = d
+	})
+}
@@ -0,0 +1,318 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func generateTOTP(t *testing.T, c clock.Clock, secret otp.TOTPSecret) otp.OTP {
+	t.Helper()
+
+	code, err := otp.GenerateTOTP(context.Background(), secret, otp.WithClock(c))
+	require.NoError(t, err)
+
+	return code
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	s1, err := otp.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	s2, err := otp.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, s1, 32)
+	assert.NotEqual(t, s1, s2)
+
+	_, err = otp.GenerateTOTP(context.Background(), s1)
+	require.NoError(t, err)
+}
+
+func TestTOTPSecretRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newFakeClock()
+	current := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	pending := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	r := otp.NewTOTPSecretRotation(current, pending, otp.WithClock(c), otp.WithGracePeriod(time.Hour))
+
+	_, err := r.Pending(ctx)
+	require.ErrorIs(t, err, otp.ErrNoPendingTOTPSecret)
+	require.ErrorIs(t, r.Promote(ctx), otp.ErrNoPendingTOTPSecret)
+
+	// No rotation.
+	require.NoError(t, r.VerifyOTP(ctx, "191882"))
+	require.ErrorIs(t, r.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+
+	p, err := r.Start(ctx, "example.com")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, otp.TOTPSecret("NBSWY3DP"), p.Secret)
+	assert.Equal(t, "example.com", p.Issuer)
+	assert.Equal(t, c.Now().Add(time.Hour), p.ExpiresAt)
+
+	// The state survives restarts.
+	r = otp.NewTOTPSecretRotation(current, pending, otp.WithClock(c), otp.WithGracePeriod(time.Hour))
+
+	actual, err := r.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, p, actual)
+
+	// Both secrets are accepted during the grace period.
+	c.Add(30 * time.Minute)
+
+	require.NoError(t, r.VerifyOTP(ctx, generateTOTP(t, c, "NBSWY3DP")))
+	require.ErrorIs(t, r.VerifyOTP(ctx, "000000"), otp.ErrInvalidOTP)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), current.TOTPSecret(ctx))
+
+	require.NoError(t, r.VerifyOTP(ctx, generateTOTP(t, c, p.Secret)))
+
+	// The pending secret is promoted.
+	assert.Equal(t, p.Secret, current.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, pending.TOTPSecret(ctx))
+
+	c.Add(time.Minute)
+
+	require.ErrorIs(t, r.VerifyOTP(ctx, generateTOTP(t, c, "NBSWY3DP")), otp.ErrInvalidOTP)
+	require.NoError(t, r.VerifyOTP(ctx, generateTOTP(t, c, p.Secret)))
+}
+
+func TestTOTPSecretRotation_Expired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newFakeClock()
+	current := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	pending := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	r := otp.NewTOTPSecretRotation(current, pending, otp.WithClock(c), otp.WithGracePeriod(time.Hour))
+
+	p, err := r.Start(ctx, "")
+	require.NoError(t, err)
+
+	c.Add(time.Hour)
+
+	_, err = r.Pending(ctx)
+	require.ErrorIs(t, err, otp.ErrNoPendingTOTPSecret)
+
+	require.ErrorIs(t, r.VerifyOTP(ctx, generateTOTP(t, c, p.Secret)), otp.ErrInvalidOTP)
+	require.NoError(t, r.VerifyOTP(ctx, generateTOTP(t, c, "NBSWY3DP")))
+
+	// The rotation is abandoned.
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), current.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, pending.TOTPSecret(ctx))
+}
+
+func TestTOTPSecretRotation_NoPending(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newFakeClock()
+
+	// The pending TOTP secret is read once, and nothing is deleted.
+	pending := mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("TOTPSecret", ctx).Return(otp.NoTOTPSecret).Once()
+	})(t)
+
+	r := otp.NewTOTPSecretRotation(otp.TOTPSecret("NBSWY3DP"), pending, otp.WithClock(c))
+
+	require.NoError(t, r.VerifyOTP(ctx, generateTOTP(t, c, "NBSWY3DP")))
+}
+
+func TestTOTPSecretRotation_PromoteAndCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	current := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	pending := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	r := otp.NewTOTPSecretRotation(current, pending)
+
+	require.NoError(t, r.Cancel(ctx))
+
+	_, err := r.Start(ctx, "")
+	require.NoError(t, err)
+
+	require.NoError(t, r.Cancel(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, pending.TOTPSecret(ctx))
+
+	p, err := r.Start(ctx, "")
+	require.NoError(t, err)
+
+	require.NoError(t, r.Promote(ctx))
+	assert.Equal(t, p.Secret, current.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, pending.TOTPSecret(ctx))
+}
+
+func TestTOTPSecretRotation_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+	pending := otp.EncryptTOTPSecretProvider(storage, newLocalKeyWrapper(t))
+
+	r := otp.NewTOTPSecretRotation(otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP")), pending)
+
+	p, err := r.Start(ctx, "")
+	require.NoError(t, err)
+
+	assert.True(t, otp.IsEncryptedTOTPSecret(storage.TOTPSecret(ctx)))
+
+	actual, err := r.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, p, actual)
+}
+
+func TestTOTPSecretRotation_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testCases := []struct {
+		scenario      string
+		mockCurrent   mock.TOTPSecretProviderMocker
+		mockPending   mock.TOTPSecretProviderMocker
+		run           func(r *otp.TOTPSecretRotation) error
+		expectedError string
+	}{
+		{
+			scenario:    "could not start",
+			mockCurrent: mock.NopTOTPSecretProvider,
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("SetTOTPSecret", ctx, mock.Anything, "").Return(assert.AnError)
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				_, err := r.Start(ctx, "")
+
+				return err
+			},
+			expectedError: "could not start totp secret rotation: assert.AnError general error for testing",
+		},
+		{
+			scenario:    "invalid state",
+			mockCurrent: mock.NopTOTPSecretProvider,
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				return r.VerifyOTP(ctx, "191882")
+			},
+			expectedError: "could not decode pending totp secret: invalid pending totp secret",
+		},
+		{
+			scenario:    "invalid encoding",
+			mockCurrent: mock.NopTOTPSecretProvider,
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("rot:v1:!"))
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				return r.Promote(ctx)
+			},
+			expectedError: "could not decode pending totp secret: invalid pending totp secret: illegal base64 data at input byte 0",
+		},
+		{
+			scenario:    "empty state",
+			mockCurrent: mock.NopTOTPSecretProvider,
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("rot:v1:e30K"))
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				_, err := r.Pending(ctx)
+
+				return err
+			},
+			expectedError: "no pending totp secret",
+		},
+		{
+			scenario: "could not promote",
+			mockCurrent: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("SetTOTPSecret", ctx, mock.Anything, "").Return(assert.AnError)
+			}),
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("rot:v1:eyJzZWNyZXQiOiJOQlNXWTNEUCIsImV4cGlyZXNfYXQiOiIyMTAwLTAxLTAxVDAwOjAwOjAwWiJ9"))
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				return r.Promote(ctx)
+			},
+			expectedError: "could not promote pending totp secret: assert.AnError general error for testing",
+		},
+		{
+			scenario: "could not delete pending secret after promotion",
+			mockCurrent: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "").Return(nil)
+			}),
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("rot:v1:eyJzZWNyZXQiOiJOQlNXWTNEUCIsImV4cGlyZXNfYXQiOiIyMTAwLTAxLTAxVDAwOjAwOjAwWiJ9"))
+				p.On("DeleteTOTPSecret", ctx).Return(assert.AnError)
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				return r.Promote(ctx)
+			},
+			expectedError: "could not delete pending totp secret: assert.AnError general error for testing",
+		},
+		{
+			scenario:    "could not cancel",
+			mockCurrent: mock.NopTOTPSecretProvider,
+			mockPending: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+				p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("rot:v1:eyJzZWNyZXQiOiJOQlNXWTNEUCIsImV4cGlyZXNfYXQiOiIyMDAwLTAxLTAxVDAwOjAwOjAwWiJ9"))
+				p.On("DeleteTOTPSecret", ctx).Return(assert.AnError)
+			}),
+			run: func(r *otp.TOTPSecretRotation) error {
+				return r.VerifyOTP(ctx, "191882")
+			},
+			expectedError: "could not delete pending totp secret: assert.AnError general error for testing",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			r := otp.NewTOTPSecretRotation(tc.mockCurrent(t), tc.mockPending(t))
+
+			err := tc.run(r)
+
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package otp
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"go.nhat.io/otp/internal/zero"
+)
+
+// ErrSecretBufferDestroyed indicates that the secret buffer has been destroyed.
+var ErrSecretBufferDestroyed = errors.New("secret buffer is destroyed")
+
+// SecretBuffer is a byte-backed container of a secret. On Linux, the memory is allocated outside the Go heap, locked
+// so it is not swapped to disk, excluded from core dumps, and surrounded by guard pages. On the other platforms, it
+// is an ordinary byte slice. In both cases, the memory is zeroed when the buffer is destroyed.
+//
+// The buffer is not copied by the garbage collector, but the bytes that are passed to other functions may be. The
+// memory is only reachable through Use, so it is never accessed after the buffer is destroyed. Destroy the buffer as
+// soon as the secret is no longer needed.
+type SecretBuffer struct {
+	mu     sync.Mutex
+	mem    *secretMemory
+	data   []byte
+	locked bool
+}
+
+// Use calls fn with the secret and returns its error. The buffer is locked while fn runs, so it cannot be destroyed
+// meanwhile. The slice must not be kept or used after fn returns, and fn must not call the other methods of the buffer.
+// It returns ErrSecretBufferDestroyed if the buffer has been destroyed.
+func (b *SecretBuffer) Use(fn func(secret []byte) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mem == nil {
+		return ErrSecretBufferDestroyed
+	}
+
+	return fn(b.data)
+}
+
+// Len returns the length of the secret.
+func (b *SecretBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.data)
+}
+
+// Locked returns true if the memory is locked and will not be swapped to disk.
+func (b *SecretBuffer) Locked() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.locked
+}
+
+// IsDestroyed returns true if the buffer has been destroyed.
+func (b *SecretBuffer) IsDestroyed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.mem == nil
+}
+
+// Destroy zeroes the secret and releases the memory. It is safe to call Destroy more than once.
+func (b *SecretBuffer) Destroy() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mem == nil {
+		return
+	}
+
+	zero.Bytes(b.data)
+
+	b.mem.free()
+	b.mem = nil
+	b.data = nil
+	b.locked = false
+}
+
+// truncate shortens the secret to n bytes and zeroes the rest.
+func (b *SecretBuffer) truncate(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n < 0 || n >= len(b.data) {
+		return
+	}
+
+	zero.Bytes(b.data[n:])
+
+	b.data = b.data[:n]
+}
+
+// NewSecretBuffer allocates a zeroed secret buffer of the given size. It is destroyed when it is garbage collected,
+// but it should be destroyed explicitly with Destroy.
+func NewSecretBuffer(size int) (*SecretBuffer, error) {
+	mem, err := allocSecretMemory(size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &SecretBuffer{
+		mem:    mem,
+		data:   mem.data,
+		locked: mem.locked,
+	}
+
+	runtime.SetFinalizer(b, (*SecretBuffer).Destroy)
+
+	return b, nil
+}
+
+// NewSecretBufferFromBytes moves the secret into a new secret buffer. The source is zeroed.
+func NewSecretBufferFromBytes(src []byte) (*SecretBuffer, error) {
+	b, err := NewSecretBuffer(len(src))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(b.data, src)
+	zero.Bytes(src)
+
+	return b, nil
+}
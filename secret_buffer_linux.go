@@ -0,0 +1,71 @@
+//go:build linux
+
+package otp
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// secretMemory is an anonymous mapping with a guard page on each side. The secret is placed at the end of the inner
+// pages so an overflow hits the guard page.
+type secretMemory struct {
+	region []byte
+	inner  []byte
+	data   []byte
+	locked bool
+}
+
+func (m *secretMemory) free() {
+	if m.locked {
+		_ = unix.Munlock(m.inner) //nolint: errcheck
+	}
+
+	_ = unix.Munmap(m.region) //nolint: errcheck
+}
+
+func allocSecretMemory(size int) (*secretMemory, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("could not allocate secret memory: invalid size %d", size)
+	}
+
+	page := os.Getpagesize()
+	innerSize := (size + page - 1) / page * page
+
+	if innerSize == 0 {
+		innerSize = page
+	}
+
+	region, err := unix.Mmap(-1, 0, innerSize+2*page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, fmt.Errorf("could not allocate secret memory: %w", err)
+	}
+
+	m := &secretMemory{
+		region: region,
+		inner:  region[page : page+innerSize],
+	}
+
+	if err := unix.Mprotect(region[:page], unix.PROT_NONE); err != nil {
+		m.free()
+
+		return nil, fmt.Errorf("could not protect secret memory: %w", err)
+	}
+
+	if err := unix.Mprotect(region[page+innerSize:], unix.PROT_NONE); err != nil {
+		m.free()
+
+		return nil, fmt.Errorf("could not protect secret memory: %w", err)
+	}
+
+	// Locking may fail if the limit of locked memory (RLIMIT_MEMLOCK) is reached, the secret is still usable.
+	m.locked = unix.Mlock(m.inner) == nil
+
+	_ = unix.Madvise(m.inner, unix.MADV_DONTDUMP) //nolint: errcheck
+
+	m.data = m.inner[innerSize-size:]
+
+	return m, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package otp
+
+import "fmt"
+
+type secretMemory struct {
+	data   []byte
+	locked bool
+}
+
+func (m *secretMemory) free() {}
+
+func allocSecretMemory(size int) (*secretMemory, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("could not allocate secret memory: invalid size %d", size)
+	}
+
+	return &secretMemory{data: make([]byte, size)}, nil
+}
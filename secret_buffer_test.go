@@ -0,0 +1,148 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+// secretBufferBytes returns a copy of the secret in the buffer.
+func secretBufferBytes(t *testing.T, b *otp.SecretBuffer) []byte {
+	t.Helper()
+
+	var out []byte
+
+	err := b.Use(func(secret []byte) error {
+		out = append([]byte{}, secret...)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return out
+}
+
+func TestNewSecretBuffer(t *testing.T) {
+	t.Parallel()
+
+	b, err := otp.NewSecretBuffer(20)
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, b.Len())
+	assert.Equal(t, make([]byte, 20), secretBufferBytes(t, b))
+	assert.False(t, b.IsDestroyed())
+
+	err = b.Use(func(secret []byte) error {
+		copy(secret, "secret")
+
+		return assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+
+	b.Destroy()
+	b.Destroy()
+
+	assert.True(t, b.IsDestroyed())
+	assert.False(t, b.Locked())
+	assert.Zero(t, b.Len())
+
+	// The memory is not reachable after the buffer is destroyed.
+	err = b.Use(func([]byte) error {
+		t.Fatal("the secret is used after the buffer is destroyed")
+
+		return nil
+	})
+	require.ErrorIs(t, err, otp.ErrSecretBufferDestroyed)
+}
+
+func TestNewSecretBuffer_Empty(t *testing.T) {
+	t.Parallel()
+
+	b, err := otp.NewSecretBuffer(0)
+	require.NoError(t, err)
+
+	defer b.Destroy()
+
+	assert.Empty(t, secretBufferBytes(t, b))
+}
+
+func TestNewSecretBuffer_InvalidSize(t *testing.T) {
+	t.Parallel()
+
+	b, err := otp.NewSecretBuffer(-1)
+	require.EqualError(t, err, "could not allocate secret memory: invalid size -1")
+
+	assert.Nil(t, b)
+}
+
+func TestNewSecretBufferFromBytes(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("NBSWY3DP")
+
+	b, err := otp.NewSecretBufferFromBytes(src)
+	require.NoError(t, err)
+
+	defer b.Destroy()
+
+	assert.Equal(t, []byte("NBSWY3DP"), secretBufferBytes(t, b))
+	assert.Equal(t, make([]byte, 8), src)
+}
+
+func TestTOTPGenerator_GenerateOTP_SameAsReference(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		secret   otp.TOTPSecret
+		time     time.Time
+		expected otp.OTP
+	}{
+		{
+			scenario: "no padding",
+			secret:   "NBSWY3DP",
+			time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: "191882",
+		},
+		{
+			scenario: "lower case and spaces",
+			secret:   " nbswy3dp ",
+			time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: "191882",
+		},
+		{
+			// RFC 6238, Appendix B.
+			scenario: "rfc 6238",
+			secret:   "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			time:     time.Unix(1111111109, 0),
+			expected: "081804",
+		},
+		{
+			scenario: "rfc 6238 2603",
+			secret:   "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			time:     time.Unix(20000000000, 0),
+			expected: "353130",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := otp.GenerateTOTP(context.Background(), tc.secret, otp.WithClock(clock.Fix(tc.time)))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package secretfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidAccount indicates that the account is not a valid file name.
+var ErrInvalidAccount = errors.New("invalid account")
+
+// Dir is a directory of secret files keyed by account, such as a Kubernetes secret mounted as a volume. The file of
+// an account is named after the account.
+type Dir struct {
+	config
+
+	path string
+
+	mu    sync.Mutex
+	files map[string]*File
+}
+
+// Path returns the path of the directory.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// File returns the secret file of the account. It returns ErrInvalidAccount if the account is not a valid file name.
+func (d *Dir) File(account string) (*File, error) {
+	if !validAccount(account) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAccount, account)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if f, ok := d.files[account]; ok {
+		return f, nil
+	}
+
+	f := newFile(filepath.Join(d.path, account), d.config)
+	d.files[account] = f
+
+	return f, nil
+}
+
+// Accounts returns the accounts that have a secret file in the directory, sorted by name. The hidden files, such as
+// the "..data" symlink of kubelet, are ignored.
+func (d *Dir) Accounts() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not list totp secret files: %w", err)
+	}
+
+	accounts := make([]string, 0, len(entries))
+
+	for _, e := range entries {
+		if !validAccount(e.Name()) {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(d.path, e.Name()))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		accounts = append(accounts, e.Name())
+	}
+
+	sort.Strings(accounts)
+
+	return accounts, nil
+}
+
+// NewDir returns a directory of secret files keyed by account.
+func NewDir(path string, opts ...Option) *Dir {
+	return &Dir{
+		config: newConfig(opts...),
+		path:   path,
+		files:  make(map[string]*File),
+	}
+}
+
+func validAccount(account string) bool {
+	return account != "" &&
+		!strings.HasPrefix(account, ".") &&
+		!strings.ContainsAny(account, `/\`) &&
+		!strings.ContainsRune(account, 0)
+}
@@ -0,0 +1,86 @@
+//go:build unit || !integration
+
+package secretfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+)
+
+func TestDir_File(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "john.doe@example.com"), "NBSWY3DP\n", 0o400)
+
+	d := secretfile.NewDir(dir)
+
+	assert.Equal(t, dir, d.Path())
+
+	f, err := d.File("john.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+
+	same, err := d.File("john.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Same(t, f, same)
+
+	f, err = d.File("jane.doe@example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+}
+
+func TestDir_File_InvalidAccount(t *testing.T) {
+	t.Parallel()
+
+	d := secretfile.NewDir(t.TempDir())
+
+	for _, account := range []string{"", ".", "..", "..data", "../etc/passwd", `a\b`, "a/b"} {
+		f, err := d.File(account)
+
+		require.ErrorIs(t, err, secretfile.ErrInvalidAccount, account)
+		assert.Nil(t, f)
+	}
+}
+
+func TestDir_Accounts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..2024_01_01"), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o755))
+	require.NoError(t, os.Symlink("..2024_01_01", filepath.Join(dir, "..data")))
+
+	writeFile(t, filepath.Join(dir, "..2024_01_01", "john"), "NBSWY3DP", 0o400)
+	writeFile(t, filepath.Join(dir, "jane"), "NBSWY3DP", 0o400)
+	writeFile(t, filepath.Join(dir, ".hidden"), "NBSWY3DP", 0o400)
+
+	require.NoError(t, os.Symlink(filepath.Join("..data", "john"), filepath.Join(dir, "john")))
+
+	actual, err := secretfile.NewDir(dir).Accounts()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"jane", "john"}, actual)
+}
+
+func TestDir_Accounts_Error(t *testing.T) {
+	t.Parallel()
+
+	actual, err := secretfile.NewDir(filepath.Join(t.TempDir(), "missing")).Accounts()
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.Nil(t, actual)
+}
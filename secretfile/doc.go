@@ -0,0 +1,2 @@
+// Package secretfile provides totp secrets from files, such as the secrets mounted by Docker and Kubernetes.
+package secretfile
@@ -0,0 +1,64 @@
+package secretfile
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"go.nhat.io/otp"
+)
+
+// FileSuffix is the suffix of the environment variable that contains the path to the secret file.
+const FileSuffix = "_FILE"
+
+var _ otp.TOTPSecretGetter = (*Env)(nil)
+
+// Env is a TOTP secret getter that follows the _FILE convention of the Docker images. If the environment variable with
+// the _FILE suffix is set, such as OTP_SECRET_FILE, the TOTP secret is read from the file at that path. Otherwise, it
+// is read from the environment variable itself, such as OTP_SECRET.
+type Env struct {
+	config
+
+	env string
+
+	mu   sync.Mutex
+	file *File
+}
+
+// TOTPSecret returns the TOTP secret from the file or the environment variable.
+func (e *Env) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	path, ok := os.LookupEnv(e.env + FileSuffix)
+	if !ok || path == "" {
+		return otp.TOTPSecret(os.Getenv(e.env))
+	}
+
+	return e.fileAt(path).TOTPSecret(ctx)
+}
+
+func (e *Env) fileAt(path string) *File {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil || e.file.path != path {
+		if e.file != nil {
+			e.file.Destroy()
+		}
+
+		e.file = newFile(path, e.config)
+	}
+
+	return e.file
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (e *Env) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return e
+}
+
+// TOTPSecretFromEnv returns a TOTP secret getter that follows the _FILE convention for the environment variable.
+func TOTPSecretFromEnv(env string, opts ...Option) *Env {
+	return &Env{
+		config: newConfig(opts...),
+		env:    env,
+	}
+}
@@ -0,0 +1,60 @@
+//go:build unit || !integration
+
+package secretfile_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+)
+
+func TestEnv_TOTPSecret(t *testing.T) { //nolint: paralleltest
+	dir := t.TempDir()
+	env := "OTP_SECRET_" + t.Name()
+
+	writeFile(t, filepath.Join(dir, "a"), "NBSWY3DP\n", 0o400)
+	writeFile(t, filepath.Join(dir, "b"), "JBSWY3DP\n", 0o400)
+
+	g := secretfile.TOTPSecretFromEnv(env)
+
+	assert.Equal(t, otp.NoTOTPSecret, g.TOTPSecret(context.Background()))
+
+	t.Setenv(env, "GEZDGNBV")
+
+	assert.Equal(t, otp.TOTPSecret("GEZDGNBV"), g.TOTPSecret(context.Background()))
+
+	t.Setenv(env+"_FILE", filepath.Join(dir, "a"))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), g.TOTPSecret(context.Background()))
+
+	t.Setenv(env+"_FILE", filepath.Join(dir, "b"))
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DP"), g.TOTPSecret(context.Background()))
+
+	t.Setenv(env+"_FILE", filepath.Join(dir, "missing"))
+
+	assert.Equal(t, otp.NoTOTPSecret, g.TOTPSecret(context.Background()))
+
+	t.Setenv(env+"_FILE", "")
+
+	assert.Equal(t, otp.TOTPSecret("GEZDGNBV"), g.TOTPSecret(context.Background()))
+	assert.Equal(t, g, g.TOTPSecretGetter())
+}
+
+func TestEnv_ChainTOTPSecretGetters(t *testing.T) { //nolint: paralleltest
+	dir := t.TempDir()
+	env := "OTP_SECRET_" + t.Name()
+
+	writeFile(t, filepath.Join(dir, "otp_secret"), "NBSWY3DP", 0o400)
+
+	t.Setenv(env+"_FILE", filepath.Join(dir, "otp_secret"))
+
+	g := otp.ChainTOTPSecretGetters(secretfile.TOTPSecretFromEnv(env), otp.TOTPSecret("JBSWY3DP"))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), g.TOTPSecret(context.Background()))
+}
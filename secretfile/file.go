@@ -0,0 +1,198 @@
+package secretfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/zero"
+)
+
+// ErrUnsafePermissions indicates that the secret file has unsafe permissions.
+var ErrUnsafePermissions = errors.New("unsafe permissions")
+
+// ErrNotRegularFile indicates that the secret file is not a regular file.
+var ErrNotRegularFile = errors.New("not a regular file")
+
+// UnsafePermissionsError is returned when the secret file has more permissions than allowed.
+type UnsafePermissionsError struct {
+	Path    string
+	Mode    os.FileMode
+	Allowed os.FileMode
+}
+
+// Error returns the error message.
+func (e *UnsafePermissionsError) Error() string {
+	return fmt.Sprintf("%s: %s has mode %#o, allowed %#o", ErrUnsafePermissions, e.Path, e.Mode.Perm(), e.Allowed)
+}
+
+// Unwrap returns ErrUnsafePermissions.
+func (e *UnsafePermissionsError) Unwrap() error {
+	return ErrUnsafePermissions
+}
+
+var _ otp.TOTPSecretGetter = (*File)(nil)
+
+// File is a TOTP secret getter that reads the TOTP secret from a file, and trims the spaces around it. The file is read
+// again when it changes on disk, including when the symlink to it is swapped, as done by kubelet for the mounted
+// secrets. The secret is kept in an otp.SecretBuffer between the reads.
+type File struct {
+	config
+
+	path string
+
+	mu     sync.Mutex
+	info   os.FileInfo
+	secret *otp.SecretBuffer
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// TOTPSecret returns the TOTP secret from the file. It returns otp.NoTOTPSecret if the file does not exist or could
+// not be read, see Load for the error.
+func (f *File) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := f.Load(ctx)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		f.logger.Debug(ctx, "totp secret file does not exist", "path", f.path)
+
+	case err != nil:
+		f.logger.Error(ctx, "could not read totp secret file", "error", err, "path", f.path)
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret from the file. It returns an UnsafePermissionsError if the file has more permissions
+// than allowed, or an error that wraps os.ErrNotExist if the file does not exist.
+func (f *File) Load(_ context.Context) (otp.TOTPSecret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The file is opened once, so that the checked file is the one that is read. It is opened without blocking in case
+	// it is a named pipe, which is refused.
+	file, err := os.OpenFile(f.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		f.reset()
+
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	defer file.Close() //nolint: errcheck
+
+	info, err := file.Stat()
+	if err != nil {
+		f.reset()
+
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	if f.secret != nil && sameFile(f.info, info) {
+		return f.reveal()
+	}
+
+	f.reset()
+
+	if err := f.check(info); err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	data := make([]byte, info.Size())
+
+	n, err := io.ReadFull(file, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		zero.Bytes(data)
+
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	secret, err := otp.NewSecretBufferFromBytes(bytes.TrimSpace(data[:n]))
+	zero.Bytes(data)
+
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	f.info = info
+	f.secret = secret
+
+	return f.reveal()
+}
+
+// reveal returns a copy of the TOTP secret kept in memory.
+func (f *File) reveal() (otp.TOTPSecret, error) {
+	var secret otp.TOTPSecret
+
+	if err := f.secret.Use(func(b []byte) error {
+		secret = otp.TOTPSecret(b)
+
+		return nil
+	}); err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read totp secret file: %w", err)
+	}
+
+	return secret, nil
+}
+
+func (f *File) check(info os.FileInfo) error {
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s", ErrNotRegularFile, f.path)
+	}
+
+	if perm := info.Mode().Perm(); perm&^f.permissions != 0 {
+		return &UnsafePermissionsError{Path: f.path, Mode: perm, Allowed: f.permissions}
+	}
+
+	return nil
+}
+
+func (f *File) reset() {
+	if f.secret != nil {
+		f.secret.Destroy()
+	}
+
+	f.info = nil
+	f.secret = nil
+}
+
+// Destroy zeroes the TOTP secret kept in memory. The file is read again on the next call.
+func (f *File) Destroy() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reset()
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (f *File) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return f
+}
+
+// TOTPSecretFromFile returns a TOTP secret getter that reads the TOTP secret from the file.
+func TOTPSecretFromFile(path string, opts ...Option) *File {
+	return newFile(path, newConfig(opts...))
+}
+
+func newFile(path string, cfg config) *File {
+	return &File{
+		config: cfg,
+		path:   path,
+	}
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return a != nil && os.SameFile(a, b) &&
+		a.Size() == b.Size() &&
+		a.ModTime().Equal(b.ModTime()) &&
+		a.Mode() == b.Mode()
+}
@@ -0,0 +1,213 @@
+//go:build unit || !integration
+
+package secretfile_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+)
+
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), perm))
+	require.NoError(t, os.Chmod(path, perm))
+}
+
+func TestFile_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		content       string
+		perm          os.FileMode
+		opts          []secretfile.Option
+		expected      otp.TOTPSecret
+		expectedError string
+	}{
+		{
+			scenario: "trimmed",
+			content:  " NBSWY3DP\n",
+			perm:     0o400,
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "empty",
+			content:  "\n",
+			perm:     0o600,
+			expected: otp.NoTOTPSecret,
+		},
+		{
+			scenario: "mounted by docker",
+			content:  "NBSWY3DP",
+			perm:     0o444,
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario: "mounted by kubernetes",
+			content:  "NBSWY3DP",
+			perm:     0o644,
+			expected: "NBSWY3DP",
+		},
+		{
+			scenario:      "writable by others",
+			content:       "NBSWY3DP",
+			perm:          0o666,
+			expected:      otp.NoTOTPSecret,
+			expectedError: "could not read totp secret file: unsafe permissions: %s has mode 0666, allowed 0744",
+		},
+		{
+			scenario:      "writable by group",
+			content:       "NBSWY3DP",
+			perm:          0o664,
+			expected:      otp.NoTOTPSecret,
+			expectedError: "could not read totp secret file: unsafe permissions: %s has mode 0664, allowed 0744",
+		},
+		{
+			scenario:      "executable by others",
+			content:       "NBSWY3DP",
+			perm:          0o645,
+			expected:      otp.NoTOTPSecret,
+			expectedError: "could not read totp secret file: unsafe permissions: %s has mode 0645, allowed 0744",
+		},
+		{
+			scenario:      "readable by others if strict",
+			content:       "NBSWY3DP",
+			perm:          0o644,
+			opts:          []secretfile.Option{secretfile.WithPermissions(secretfile.StrictPermissions)},
+			expected:      otp.NoTOTPSecret,
+			expectedError: "could not read totp secret file: unsafe permissions: %s has mode 0644, allowed 0600",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "otp_secret")
+
+			writeFile(t, path, tc.content, tc.perm)
+
+			f := secretfile.TOTPSecretFromFile(path, tc.opts...)
+
+			assert.Equal(t, path, f.Path())
+			assert.Equal(t, tc.expected, f.TOTPSecret(context.Background()))
+
+			_, err := f.Load(context.Background())
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, fmt.Sprintf(tc.expectedError, path))
+				require.ErrorIs(t, err, secretfile.ErrUnsafePermissions)
+			}
+		})
+	}
+}
+
+func TestFile_TOTPSecret_NotExist(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	f := secretfile.TOTPSecretFromFile(filepath.Join(t.TempDir(), "otp_secret"), secretfile.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+
+	_, err := f.Load(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+}
+
+func TestFile_TOTPSecret_NotRegularFile(t *testing.T) {
+	t.Parallel()
+
+	l := &ctxd.LoggerMock{}
+	f := secretfile.TOTPSecretFromFile(t.TempDir(), secretfile.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+
+	_, err := f.Load(context.Background())
+	require.ErrorIs(t, err, secretfile.ErrNotRegularFile)
+
+	require.Len(t, l.LoggedEntries, 1)
+	assert.Equal(t, "error", l.LoggedEntries[0].Level)
+}
+
+func TestFile_TOTPSecret_Changed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "otp_secret")
+	f := secretfile.TOTPSecretFromFile(path)
+
+	writeFile(t, path, "NBSWY3DP", 0o600)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+
+	writeFile(t, path, "JBSWY3DPEHPK3PXP", 0o600)
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), f.TOTPSecret(context.Background()))
+
+	require.NoError(t, os.Remove(path))
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+}
+
+// TestFile_TOTPSecret_SymlinkSwap mimics the atomic update of the mounted secrets by kubelet: the files are written to
+// a new timestamped directory, then the "..data" symlink is swapped to it.
+func TestFile_TOTPSecret_SymlinkSwap(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	publish := func(version, secret string) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o755))
+		writeFile(t, filepath.Join(dir, version, "otp_secret"), secret, 0o400)
+
+		require.NoError(t, os.Symlink(version, filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+
+	publish("..2024_01_01", "NBSWY3DP")
+
+	require.NoError(t, os.Symlink(filepath.Join("..data", "otp_secret"), filepath.Join(dir, "otp_secret")))
+
+	f := secretfile.TOTPSecretFromFile(filepath.Join(dir, "otp_secret"))
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+
+	// Same size, the swap is detected because the file is different.
+	publish("..2024_01_02", "JBSWY3DP")
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DP"), f.TOTPSecret(context.Background()))
+}
+
+func TestFile_Destroy(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "otp_secret")
+
+	writeFile(t, path, "NBSWY3DP", 0o600)
+
+	f := secretfile.TOTPSecretFromFile(path)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+
+	f.Destroy()
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+	assert.Equal(t, f, f.TOTPSecretGetter())
+}
@@ -0,0 +1,63 @@
+package secretfile
+
+import (
+	"os"
+
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp"
+)
+
+// DefaultPermissions is the default permissions that a secret file may have. The files that are writable or
+// executable by the group or the others are refused, the files that are readable by them are accepted, such as the
+// secrets mounted by Docker with the mode 0444, or by Kubernetes with the mode 0644.
+const DefaultPermissions os.FileMode = 0o744
+
+// StrictPermissions refuses the files that are accessible by the group or the others, or executable, see
+// WithPermissions.
+const StrictPermissions os.FileMode = 0o600
+
+type config struct {
+	logger      ctxd.Logger
+	permissions os.FileMode
+}
+
+func newConfig(opts ...Option) config {
+	c := config{
+		logger:      ctxd.NoOpLogger{},
+		permissions: DefaultPermissions,
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(&c)
+	}
+
+	return c
+}
+
+// Option configures the services provided by the secretfile package.
+type Option interface {
+	applyOption(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) applyOption(c *config) {
+	f(c)
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.logger = otp.RedactLogger(l)
+	})
+}
+
+// WithPermissions sets the permissions that a secret file may have. A file that has any other permission bit is
+// refused. Default is DefaultPermissions, use StrictPermissions to also refuse the files that are readable by the group
+// or the others, or 0o400 to only accept the files that are read-only.
+func WithPermissions(perm os.FileMode) Option {
+	return optionFunc(func(c *config) {
+		c.permissions = perm.Perm()
+	})
+}
@@ -0,0 +1,207 @@
+package sops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/atomicfile"
+	"go.nhat.io/otp/internal/zero"
+)
+
+var _ otp.TOTPSecretProvider = (*AgeFile)(nil)
+
+// AgeFile is a TOTP secret provider that stores the TOTP secret, or an otpauth URI, in an age-encrypted file. The file
+// may be armored or binary.
+type AgeFile struct {
+	config
+
+	path string
+}
+
+// Path returns the path of the file.
+func (f *AgeFile) Path() string {
+	return f.path
+}
+
+// TOTPSecret returns the TOTP secret from the file. It returns otp.NoTOTPSecret if the file does not exist or could not
+// be decrypted, see Load for the error.
+func (f *AgeFile) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := f.Load(ctx)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		f.logger.Debug(ctx, "age-encrypted totp secret file does not exist", "path", f.path)
+
+	case err != nil:
+		f.logger.Error(ctx, "could not get totp secret from age-encrypted file", "error", err, "path", f.path)
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret from the file. It returns an error that wraps os.ErrNotExist if the file does not exist.
+func (f *AgeFile) Load(ctx context.Context) (otp.TOTPSecret, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not read age-encrypted file: %w", err)
+	}
+
+	ids, err := f.identities(ctx)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	plain, err := decryptAge(data, ids)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	defer zero.Bytes(plain)
+
+	return otp.ParseTOTPSecret(string(plain))
+}
+
+// SetTOTPSecret encrypts the TOTP secret to the recipients, and replaces the file atomically. An existing binary file
+// stays binary, the new files are armored.
+func (f *AgeFile) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	if err := f.set(ctx, secret); err != nil {
+		f.logger.Error(ctx, "could not persist totp secret to age-encrypted file", "error", err, "path", f.path)
+
+		return fmt.Errorf("could not persist totp secret to age-encrypted file: %w", err)
+	}
+
+	return nil
+}
+
+func (f *AgeFile) set(ctx context.Context, secret otp.TOTPSecret) error {
+	armored := true
+
+	if data, err := os.ReadFile(f.path); err == nil {
+		armored = isArmored(data)
+	}
+
+	ids, err := f.identities(ctx)
+	if err != nil && (!errors.Is(err, ErrNoIdentity) || len(f.recipients) == 0) {
+		return err
+	}
+
+	rs, err := f.ageRecipients(ids)
+	if err != nil {
+		return err
+	}
+
+	data, err := encryptAge([]byte(secret.Reveal()), rs, armored)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(f.path, data, 0o600)
+}
+
+// DeleteTOTPSecret deletes the file.
+func (f *AgeFile) DeleteTOTPSecret(ctx context.Context) error {
+	if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		f.logger.Error(ctx, "could not delete age-encrypted totp secret file", "error", err, "path", f.path)
+
+		return fmt.Errorf("could not delete age-encrypted totp secret file: %w", err)
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (f *AgeFile) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return f
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (f *AgeFile) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return f
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (f *AgeFile) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return f
+}
+
+// TOTPSecretFromAgeFile returns a TOTP secret provider that stores the TOTP secret in an age-encrypted file.
+func TOTPSecretFromAgeFile(path string, opts ...Option) *AgeFile {
+	return &AgeFile{
+		config: newConfig(opts...),
+		path:   path,
+	}
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header))
+}
+
+func decryptAge(data []byte, ids []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+
+	if isArmored(data) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt age-encrypted data: %w", err)
+	}
+
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt age-encrypted data: %w", err)
+	}
+
+	return plain, nil
+}
+
+func encryptAge(plain []byte, rs []age.Recipient, armored bool) ([]byte, error) {
+	var (
+		out bytes.Buffer
+		dst io.WriteCloser = nopCloser{&out}
+	)
+
+	if armored {
+		dst = armor.NewWriter(&out)
+	}
+
+	w, err := age.Encrypt(dst, rs...)
+	if err != nil {
+		return nil, fmt.Errorf("could not encrypt age data: %w", err)
+	}
+
+	if _, err := w.Write(plain); err != nil {
+		return nil, fmt.Errorf("could not encrypt age data: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("could not encrypt age data: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return nil, fmt.Errorf("could not encrypt age data: %w", err)
+	}
+
+	if armored {
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes(), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,233 @@
+//go:build unit || !integration
+
+package sops_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/sops"
+)
+
+func newIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	return id
+}
+
+func encryptAge(t *testing.T, plain string, armored bool, rs ...age.Recipient) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		dst io.Writer = &buf
+		aw  io.WriteCloser
+	)
+
+	if armored {
+		aw = armor.NewWriter(&buf)
+		dst = aw
+	}
+
+	w, err := age.Encrypt(dst, rs...)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte(plain))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	if aw != nil {
+		require.NoError(t, aw.Close())
+	}
+
+	return buf.Bytes()
+}
+
+func TestAgeFile_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	id := newIdentity(t)
+
+	testCases := []struct {
+		scenario       string
+		content        []byte
+		identity       otp.TOTPSecretGetter
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "armored",
+			content:        encryptAge(t, "NBSWY3DP\n", true, id.Recipient()),
+			identity:       otp.TOTPSecret(id.String()),
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "binary",
+			content:        encryptAge(t, "NBSWY3DP", false, id.Recipient()),
+			identity:       otp.TOTPSecret(id.String()),
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			content:        encryptAge(t, "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME", true, id.Recipient()),
+			identity:       otp.TOTPSecret("# created: 2024-01-01T00:00:00Z\n" + id.String() + "\n"),
+			expectedResult: "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME",
+		},
+		{
+			scenario:       "no identity",
+			content:        encryptAge(t, "NBSWY3DP", true, id.Recipient()),
+			identity:       otp.NoTOTPSecret,
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrNoIdentity,
+		},
+		{
+			scenario:       "wrong identity",
+			content:        encryptAge(t, "NBSWY3DP", true, id.Recipient()),
+			identity:       otp.TOTPSecret(newIdentity(t).String()),
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  &age.NoIdentityMatchError{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "secret.age")
+
+			require.NoError(t, os.WriteFile(path, tc.content, 0o600))
+
+			f := sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(tc.identity))
+
+			assert.Equal(t, path, f.Path())
+			assert.Equal(t, tc.expectedResult, f.TOTPSecret(context.Background()))
+
+			actual, err := f.Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorAs(t, err, &tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestAgeFile_FileNotFound(t *testing.T) {
+	t.Parallel()
+
+	f := sops.TOTPSecretFromAgeFile(filepath.Join(t.TempDir(), "secret.age"), sops.WithIdentity(otp.TOTPSecret(newIdentity(t).String())))
+
+	assert.Equal(t, otp.NoTOTPSecret, f.TOTPSecret(context.Background()))
+
+	_, err := f.Load(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+}
+
+func TestAgeFile_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	id := newIdentity(t)
+	path := filepath.Join(t.TempDir(), "secret.age")
+
+	f := sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(otp.TOTPSecret(id.String())))
+
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte(armor.Header)))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), f.TOTPSecret(context.Background()))
+
+	// The binary files stay binary.
+	require.NoError(t, os.WriteFile(path, encryptAge(t, "NBSWY3DP", false, id.Recipient()), 0o640))
+	require.NoError(t, os.Chmod(path, 0o640))
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte("age-encryption.org/v1\n")))
+
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), f.TOTPSecret(context.Background()))
+
+	require.NoError(t, f.DeleteTOTPSecret(context.Background()))
+
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestAgeFile_SetTOTPSecret_Recipients(t *testing.T) {
+	t.Parallel()
+
+	id := newIdentity(t)
+	path := filepath.Join(t.TempDir(), "secret.age")
+
+	// The file is encrypted to the recipients, without any identity.
+	f := sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(otp.NoTOTPSecret), sops.WithRecipients(id.Recipient().String()))
+
+	require.NoError(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"))
+
+	actual, err := sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(otp.TOTPSecret(id.String()))).Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), actual)
+
+	f = sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(otp.NoTOTPSecret))
+
+	require.ErrorIs(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), sops.ErrNoIdentity)
+
+	f = sops.TOTPSecretFromAgeFile(path, sops.WithIdentity(otp.NoTOTPSecret), sops.WithRecipients("invalid"))
+
+	require.ErrorContains(t, f.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), "could not parse age recipients")
+}
+
+func TestAgeFile_IdentityFromEnv(t *testing.T) { //nolint: paralleltest
+	id := newIdentity(t)
+	path := filepath.Join(t.TempDir(), "secret.age")
+
+	require.NoError(t, os.WriteFile(path, encryptAge(t, "NBSWY3DP", true, id.Recipient()), 0o600))
+
+	t.Setenv(sops.EnvAgeKey, id.String())
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), sops.TOTPSecretFromAgeFile(path).TOTPSecret(context.Background()))
+
+	keys := filepath.Join(t.TempDir(), "keys.txt")
+
+	require.NoError(t, os.WriteFile(keys, []byte(id.String()), 0o600))
+
+	t.Setenv(sops.EnvAgeKey, "")
+	t.Setenv(sops.EnvAgeKey+"_FILE", keys)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), sops.TOTPSecretFromAgeFile(path).TOTPSecret(context.Background()))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), sops.TOTPSecretFromAgeFile(path, sops.WithIdentityFile(keys)).TOTPSecret(context.Background()))
+}
@@ -0,0 +1,169 @@
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"filippo.io/age"
+)
+
+// The types of the encrypted values.
+const (
+	typeString  = "str"
+	typeInt     = "int"
+	typeFloat   = "float"
+	typeBool    = "bool"
+	typeBytes   = "bytes"
+	typeComment = "comment"
+)
+
+const (
+	dataKeySize = 32
+	ivSize      = 32
+)
+
+// ErrInvalidValue indicates that an encrypted value is not in the format of SOPS.
+var ErrInvalidValue = errors.New("invalid sops encrypted value")
+
+// ErrNoDataKey indicates that the data key of the SOPS document could not be decrypted with the age identities.
+var ErrNoDataKey = errors.New("could not decrypt sops data key")
+
+var encPattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// decryptValue decrypts a value in the format ENC[AES256_GCM,data:...,iv:...,tag:...,type:...]. The additional data is
+// the path of the value. It returns the plaintext and its type.
+func decryptValue(key []byte, value, aad string) (string, string, error) {
+	if value == "" {
+		return "", typeString, nil
+	}
+
+	m := encPattern.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", ErrInvalidValue
+	}
+
+	data, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	tag, err := base64.StdEncoding.DecodeString(m[3])
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+
+	gcm, err := newGCM(key, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(aad))
+	if err != nil {
+		return "", "", fmt.Errorf("could not decrypt sops value: %w", err)
+	}
+
+	return string(plain), m[4], nil
+}
+
+// encryptValue encrypts a value in the format of SOPS. The empty values are not encrypted.
+func encryptValue(key []byte, plain, typ, aad string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+
+	iv := make([]byte, ivSize)
+
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key, ivSize)
+	if err != nil {
+		return "", err
+	}
+
+	out := gcm.Seal(nil, iv, []byte(plain), []byte(aad))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		typ,
+	), nil
+}
+
+func newGCM(key []byte, nonceSize int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create sops cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	if err != nil {
+		return nil, fmt.Errorf("could not create sops cipher: %w", err)
+	}
+
+	return gcm, nil
+}
+
+// canonical returns the bytes of a value that are used for the MAC, the same way SOPS does.
+func canonical(typ, value string) string {
+	switch typ {
+	case typeInt:
+		if v, err := strconv.ParseInt(value, 0, 64); err == nil {
+			return strconv.FormatInt(v, 10)
+		}
+
+	case typeFloat:
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+
+	case typeBool:
+		if v, err := strconv.ParseBool(value); err == nil {
+			if v {
+				return "True"
+			}
+
+			return "False"
+		}
+	}
+
+	return value
+}
+
+// decryptDataKey decrypts the data key of the document with the first age key that matches the identities.
+func decryptDataKey(keys []string, ids []age.Identity) ([]byte, error) {
+	errs := []error{ErrNoDataKey}
+
+	for _, k := range keys {
+		plain, err := decryptAge([]byte(k), ids)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if len(plain) != dataKeySize {
+			errs = append(errs, fmt.Errorf("%w: invalid data key size", ErrInvalidValue))
+
+			continue
+		}
+
+		return plain, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,2 @@
+// Package sops provides totp secret storage using age-encrypted files and SOPS-encrypted YAML and JSON documents.
+package sops
@@ -0,0 +1,369 @@
+package sops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/internal/atomicfile"
+	"go.nhat.io/otp/internal/zero"
+)
+
+// Format is the format of a SOPS document.
+type Format string
+
+// The formats of the SOPS documents.
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+var _ otp.TOTPSecretProvider = (*Document)(nil)
+
+// Document is a TOTP secret provider that stores the TOTP secret, or an otpauth URI, in a SOPS-encrypted YAML or JSON
+// document, at a dotted key path such as "vendors.acme.totp". The data key of the document is decrypted with the age
+// identities. Only the value at the key path is re-encrypted on write, the other values and the metadata are kept.
+type Document struct {
+	config
+
+	path    string
+	keyPath []string
+
+	mu sync.Mutex
+}
+
+// document is a decoded SOPS document.
+type document struct {
+	root   *yaml.Node
+	data   *yaml.Node
+	md     *metadata
+	format Format
+	indent string
+}
+
+// Path returns the path of the document.
+func (d *Document) Path() string {
+	return d.path
+}
+
+// KeyPath returns the key path of the TOTP secret in the document.
+func (d *Document) KeyPath() string {
+	return strings.Join(d.keyPath, ".")
+}
+
+// TOTPSecret returns the TOTP secret from the document. It returns otp.NoTOTPSecret if the document or the key does not
+// exist, or if the document could not be decrypted, see Load for the error.
+func (d *Document) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := d.Load(ctx)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, ErrKeyNotFound):
+		d.logger.Debug(ctx, "totp secret does not exist in sops document", "path", d.path, "key", d.KeyPath())
+
+	case err != nil:
+		d.logger.Error(ctx, "could not get totp secret from sops document", "error", err, "path", d.path, "key", d.KeyPath())
+	}
+
+	return s
+}
+
+// Load decrypts the document and returns the TOTP secret at the key path. It returns ErrKeyNotFound if the key does not
+// exist, and ErrMACMismatch if the document has been tampered with.
+func (d *Document) Load(ctx context.Context) (otp.TOTPSecret, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, key, err := d.open(ctx)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	defer zero.Bytes(key)
+
+	n, i, err := lookup(doc.data, d.keyPath, false)
+	if err != nil {
+		return otp.NoTOTPSecret, err
+	}
+
+	if i < 0 {
+		return otp.NoTOTPSecret, ErrKeyNotFound
+	}
+
+	v := n.Content[i+1]
+	if v.Kind != yaml.ScalarNode {
+		return otp.NoTOTPSecret, ErrInvalidKeyPath
+	}
+
+	value := v.Value
+
+	if doc.md.shouldEncrypt(d.keyPath) {
+		if value, _, err = decryptValue(key, value, aad(d.keyPath)); err != nil {
+			return otp.NoTOTPSecret, fmt.Errorf("could not decrypt %q: %w", d.KeyPath(), err)
+		}
+	}
+
+	return otp.ParseTOTPSecret(value)
+}
+
+// SetTOTPSecret encrypts the TOTP secret with the data key of the document, and writes it at the key path. The maps on
+// the way are created if they do not exist. The document must exist.
+func (d *Document) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, _ string) error {
+	err := d.update(ctx, func(doc *document, key []byte) error {
+		n, i, err := lookup(doc.data, d.keyPath, true)
+		if err != nil {
+			return err
+		}
+
+		value := secret.Reveal()
+
+		if doc.md.shouldEncrypt(d.keyPath) {
+			if value, err = encryptValue(key, value, typeString, aad(d.keyPath)); err != nil {
+				return err
+			}
+		}
+
+		if i < 0 {
+			n.Content = append(n.Content, strNode(d.keyPath[len(d.keyPath)-1]), strNode(value))
+
+			return nil
+		}
+
+		v := n.Content[i+1]
+		if v.Kind != yaml.ScalarNode {
+			return ErrInvalidKeyPath
+		}
+
+		v.Tag = "!!str"
+		v.Value = value
+
+		return nil
+	})
+	if err != nil {
+		d.logger.Error(ctx, "could not persist totp secret to sops document", "error", err, "path", d.path, "key", d.KeyPath())
+
+		return fmt.Errorf("could not persist totp secret to sops document: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret removes the key from the document. It does nothing if the document or the key does not exist.
+func (d *Document) DeleteTOTPSecret(ctx context.Context) error {
+	err := d.update(ctx, func(doc *document, _ []byte) error {
+		n, i, err := lookup(doc.data, d.keyPath, false)
+		if err != nil {
+			return err
+		}
+
+		if i < 0 {
+			return ErrKeyNotFound
+		}
+
+		n.Content = append(n.Content[:i], n.Content[i+2:]...)
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrKeyNotFound) {
+		d.logger.Error(ctx, "could not delete totp secret in sops document", "error", err, "path", d.path, "key", d.KeyPath())
+
+		return fmt.Errorf("could not delete totp secret in sops document: %w", err)
+	}
+
+	return nil
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (d *Document) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return d
+}
+
+// TOTPSecretSetter returns otp.TOTPSecretSetter.
+func (d *Document) TOTPSecretSetter() otp.TOTPSecretSetter {
+	return d
+}
+
+// TOTPSecretDeleter returns otp.TOTPSecretDeleter.
+func (d *Document) TOTPSecretDeleter() otp.TOTPSecretDeleter {
+	return d
+}
+
+// open reads and decrypts the document, and verifies its MAC.
+func (d *Document) open(ctx context.Context) (*document, []byte, error) {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read sops document: %w", err)
+	}
+
+	doc, err := decodeDocument(data, d.formatOf())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids, err := d.identities(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := decryptDataKey(doc.md.ageKeys, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifyMAC(doc, key); err != nil {
+		zero.Bytes(key)
+
+		return nil, nil, err
+	}
+
+	return doc, key, nil
+}
+
+// update changes the document, then updates its MAC and its last modified time, and writes it.
+func (d *Document) update(ctx context.Context, fn func(doc *document, key []byte) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	doc, key, err := d.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer zero.Bytes(key)
+
+	if err := fn(doc, key); err != nil {
+		return err
+	}
+
+	mac, err := computeMAC(doc.data, doc.md, key)
+	if err != nil {
+		return err
+	}
+
+	lastModified := d.clock.Now().UTC().Format(time.RFC3339)
+
+	if mac, err = encryptValue(key, mac, typeString, lastModified); err != nil {
+		return err
+	}
+
+	doc.md.set("lastmodified", lastModified)
+	doc.md.set("mac", mac)
+
+	data, err := doc.encode()
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(d.path, data, 0o600)
+}
+
+func (d *Document) formatOf() Format {
+	if d.format != "" {
+		return d.format
+	}
+
+	if strings.EqualFold(filepath.Ext(d.path), ".json") {
+		return FormatJSON
+	}
+
+	return FormatYAML
+}
+
+func decodeDocument(data []byte, f Format) (*document, error) {
+	doc := &document{format: f}
+
+	if f == FormatJSON {
+		n, err := decodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+
+		doc.root = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}}
+		doc.indent = jsonIndent(data)
+	} else {
+		var root yaml.Node
+
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("could not decode yaml: %w", err)
+		}
+
+		doc.root = &root
+	}
+
+	if len(doc.root.Content) == 0 || doc.root.Content[0].Kind != yaml.MappingNode {
+		return nil, ErrNotSOPSDocument
+	}
+
+	doc.data = doc.root.Content[0]
+
+	md, err := parseMetadata(doc.data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.md = md
+
+	return doc, nil
+}
+
+func (doc *document) encode() ([]byte, error) {
+	if doc.format == FormatJSON {
+		data, err := encodeJSON(doc.data, doc.indent)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+
+	if err := enc.Encode(doc.root); err != nil {
+		return nil, fmt.Errorf("could not encode yaml: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("could not encode yaml: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func verifyMAC(doc *document, key []byte) error {
+	expected, _, err := decryptValue(key, doc.md.mac, doc.md.lastModified)
+	if err != nil {
+		return fmt.Errorf("could not decrypt sops mac: %w", err)
+	}
+
+	actual, err := computeMAC(doc.data, doc.md, key)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(expected, actual) {
+		return ErrMACMismatch
+	}
+
+	return nil
+}
+
+// TOTPSecretFromDocument returns a TOTP secret provider that stores the TOTP secret in a SOPS-encrypted document, at a
+// dotted key path such as "vendors.acme.totp".
+func TOTPSecretFromDocument(path, keyPath string, opts ...Option) *Document {
+	return &Document{
+		config:  newConfig(opts...),
+		path:    path,
+		keyPath: strings.Split(keyPath, "."),
+	}
+}
@@ -0,0 +1,541 @@
+//go:build unit || !integration
+
+package sops_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+	"gopkg.in/yaml.v3"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/sops"
+)
+
+const (
+	uri          = "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+	lastModified = "2023-06-01T10:00:00Z"
+)
+
+// sopsFixture encrypts the documents the way SOPS does. The comments are not part of the MAC.
+type sopsFixture struct {
+	id  *age.X25519Identity
+	key []byte
+}
+
+func newSOPSFixture(t *testing.T) *sopsFixture {
+	t.Helper()
+
+	key := make([]byte, 32)
+
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	return &sopsFixture{id: newIdentity(t), key: key}
+}
+
+func (f *sopsFixture) gcm(t *testing.T) cipher.AEAD {
+	t.Helper()
+
+	block, err := aes.NewCipher(f.key)
+	require.NoError(t, err)
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, 32)
+	require.NoError(t, err)
+
+	return gcm
+}
+
+func (f *sopsFixture) encrypt(t *testing.T, plain, typ, aad string) string {
+	t.Helper()
+
+	iv := make([]byte, 32)
+
+	_, err := rand.Read(iv)
+	require.NoError(t, err)
+
+	out := f.gcm(t).Seal(nil, iv, []byte(plain), []byte(aad))
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(out[:len(out)-16]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[len(out)-16:]),
+		typ,
+	)
+}
+
+var encPattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+func (f *sopsFixture) decrypt(t *testing.T, value, aad string) string {
+	t.Helper()
+
+	m := encPattern.FindStringSubmatch(value)
+	require.NotNil(t, m, "not encrypted: %s", value)
+
+	var parts [3][]byte
+
+	for i := range parts {
+		var err error
+
+		parts[i], err = base64.StdEncoding.DecodeString(m[i+1])
+		require.NoError(t, err)
+	}
+
+	plain, err := f.gcm(t).Open(nil, parts[1], append(parts[0], parts[2]...), []byte(aad))
+	require.NoError(t, err)
+
+	return string(plain)
+}
+
+func (f *sopsFixture) mac(t *testing.T, lastModified string, values ...string) string {
+	t.Helper()
+
+	h := sha512.New()
+
+	for _, v := range values {
+		h.Write([]byte(v))
+	}
+
+	return f.encrypt(t, fmt.Sprintf("%X", h.Sum(nil)), "str", lastModified)
+}
+
+func (f *sopsFixture) dataKey(t *testing.T) string {
+	t.Helper()
+
+	return string(encryptAge(t, string(f.key), true, f.id.Recipient()))
+}
+
+func (f *sopsFixture) identity() sops.Option {
+	return sops.WithIdentity(otp.TOTPSecret(f.id.String()))
+}
+
+func (f *sopsFixture) yaml(t *testing.T) string {
+	t.Helper()
+
+	enc := strings.TrimSuffix(strings.ReplaceAll("\n"+f.dataKey(t), "\n", "\n            "), "            ")
+
+	return fmt.Sprintf(`# The vendors.
+vendors:
+    acme:
+        totp: %s
+        uri: %s
+    port_unencrypted: 8080
+sops:
+    age:
+        - recipient: %s
+          enc: |%s
+    lastmodified: "%s"
+    mac: %s
+    unencrypted_suffix: _unencrypted
+    version: 3.9.0
+`,
+		f.encrypt(t, "NBSWY3DP", "str", "vendors:acme:totp:"),
+		f.encrypt(t, uri, "str", "vendors:acme:uri:"),
+		f.id.Recipient(),
+		enc,
+		lastModified,
+		f.mac(t, lastModified, "NBSWY3DP", uri, "8080"),
+	)
+}
+
+func (f *sopsFixture) json(t *testing.T) string {
+	t.Helper()
+
+	q := func(s string) string {
+		b, err := json.Marshal(s)
+		require.NoError(t, err)
+
+		return string(b)
+	}
+
+	return fmt.Sprintf(`{
+  "vendors": {
+    "acme": {
+      "totp": %s,
+      "enabled": %s
+    }
+  },
+  "sops": {
+    "age": [
+      {
+        "recipient": %s,
+        "enc": %s
+      }
+    ],
+    "lastmodified": %s,
+    "mac": %s,
+    "unencrypted_suffix": "_unencrypted",
+    "version": "3.9.0"
+  }
+}
+`,
+		q(f.encrypt(t, "NBSWY3DP", "str", "vendors:acme:totp:")),
+		q(f.encrypt(t, "True", "bool", "vendors:acme:enabled:")),
+		q(f.id.Recipient().String()),
+		q(f.dataKey(t)),
+		q(lastModified),
+		q(f.mac(t, lastModified, "NBSWY3DP", "True")),
+	)
+}
+
+func writeDocument(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestDocument_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+
+	yamlDoc := f.yaml(t)
+	jsonDoc := f.json(t)
+
+	testCases := []struct {
+		scenario       string
+		file           string
+		content        string
+		keyPath        string
+		opts           []sops.Option
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "yaml",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.acme.uri",
+			expectedResult: uri,
+		},
+		{
+			scenario:       "json",
+			file:           "secrets.json",
+			content:        jsonDoc,
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "json with format",
+			file:           "secrets.enc",
+			content:        jsonDoc,
+			keyPath:        "vendors.acme.totp",
+			opts:           []sops.Option{sops.WithFormat(sops.FormatJSON)},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "unencrypted value",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.port_unencrypted",
+			expectedResult: "8080",
+		},
+		{
+			scenario:       "key not found",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.globex.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrKeyNotFound,
+		},
+		{
+			scenario:       "key path through a value",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.port_unencrypted.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrInvalidKeyPath,
+		},
+		{
+			scenario:       "key path to a map",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.acme",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrInvalidKeyPath,
+		},
+		{
+			scenario:       "key path to the metadata",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "sops.mac",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrInvalidKeyPath,
+		},
+		{
+			scenario:       "no identity",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.acme.totp",
+			opts:           []sops.Option{sops.WithIdentity(otp.NoTOTPSecret)},
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrNoIdentity,
+		},
+		{
+			scenario:       "wrong identity",
+			file:           "secrets.yaml",
+			content:        yamlDoc,
+			keyPath:        "vendors.acme.totp",
+			opts:           []sops.Option{sops.WithIdentity(otp.TOTPSecret(newIdentity(t).String()))},
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrNoDataKey,
+		},
+		{
+			scenario:       "tampered",
+			file:           "secrets.yaml",
+			content:        strings.Replace(yamlDoc, "port_unencrypted: 8080", "port_unencrypted: 8081", 1),
+			keyPath:        "vendors.acme.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrMACMismatch,
+		},
+		{
+			scenario:       "not a sops document",
+			file:           "config.yaml",
+			content:        "vendors:\n  acme:\n    totp: NBSWY3DP\n",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrNotSOPSDocument,
+		},
+		{
+			scenario:       "not a json object",
+			file:           "secrets.json",
+			content:        `["NBSWY3DP"]`,
+			keyPath:        "vendors.acme.totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrNotSOPSDocument,
+		},
+		{
+			scenario:       "shamir secret sharing",
+			file:           "secrets.yaml",
+			content:        "totp: NBSWY3DP\nsops:\n    shamir_threshold: 2\n    key_groups:\n        - age: []\n        - age: []\n",
+			keyPath:        "totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrUnsupportedDocument,
+		},
+		{
+			scenario:       "encrypted comment regex",
+			file:           "secrets.yaml",
+			content:        "totp: NBSWY3DP\nsops:\n    encrypted_comment_regex: sops:enc\n",
+			keyPath:        "totp",
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrUnsupportedDocument,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			d := sops.TOTPSecretFromDocument(writeDocument(t, tc.file, tc.content), tc.keyPath, append([]sops.Option{f.identity()}, tc.opts...)...)
+
+			assert.Equal(t, tc.keyPath, d.KeyPath())
+			assert.Equal(t, tc.expectedResult, d.TOTPSecret(context.Background()))
+
+			actual, err := d.Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestDocument_FileNotFound(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+	l := &ctxd.LoggerMock{}
+	d := sops.TOTPSecretFromDocument(filepath.Join(t.TempDir(), "secrets.yaml"), "totp", f.identity(), sops.WithLogger(l))
+
+	assert.Equal(t, otp.NoTOTPSecret, d.TOTPSecret(context.Background()))
+
+	_, err := d.Load(context.Background())
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, d.DeleteTOTPSecret(context.Background()))
+	require.ErrorIs(t, d.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), os.ErrNotExist)
+
+	require.Len(t, l.LoggedEntries, 2)
+
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+	assert.Equal(t, "totp secret does not exist in sops document", l.LoggedEntries[0].Message)
+	assert.Equal(t, "error", l.LoggedEntries[1].Level)
+	assert.Equal(t, "could not persist totp secret to sops document", l.LoggedEntries[1].Message)
+}
+
+func TestDocument_SetTOTPSecret_YAML(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+	path := writeDocument(t, "secrets.yaml", f.yaml(t))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.Fix(now)
+
+	d := sops.TOTPSecretFromDocument(path, "vendors.acme.totp", f.identity(), sops.WithClock(c))
+
+	require.NoError(t, d.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), d.TOTPSecret(context.Background()))
+
+	d = sops.TOTPSecretFromDocument(path, "vendors.globex.totp", f.identity(), sops.WithClock(c))
+
+	require.NoError(t, d.SetTOTPSecret(context.Background(), otp.TOTPSecret(uri), "ACME"))
+	assert.Equal(t, otp.TOTPSecret(uri), d.TOTPSecret(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	content := string(data)
+
+	assert.True(t, strings.HasPrefix(content, "# The vendors.\nvendors:\n    acme:\n        totp: ENC["), content)
+	assert.Contains(t, content, "    port_unencrypted: 8080\n")
+	assert.Contains(t, content, "    lastmodified: \"2024-01-01T00:00:00Z\"\n")
+	assert.Contains(t, content, "    unencrypted_suffix: _unencrypted\n    version: 3.9.0\n")
+	assert.Contains(t, content, "        - recipient: "+f.id.Recipient().String()+"\n")
+
+	// Verify the document the way SOPS does.
+	var doc struct {
+		Vendors struct {
+			Acme struct {
+				TOTP string `yaml:"totp"`
+				URI  string `yaml:"uri"`
+			} `yaml:"acme"`
+			Globex struct {
+				TOTP string `yaml:"totp"`
+			} `yaml:"globex"`
+		} `yaml:"vendors"`
+		SOPS struct {
+			LastModified string `yaml:"lastmodified"`
+			MAC          string `yaml:"mac"`
+		} `yaml:"sops"`
+	}
+
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", f.decrypt(t, doc.Vendors.Acme.TOTP, "vendors:acme:totp:"))
+	assert.Equal(t, uri, f.decrypt(t, doc.Vendors.Acme.URI, "vendors:acme:uri:"))
+	assert.Equal(t, uri, f.decrypt(t, doc.Vendors.Globex.TOTP, "vendors:globex:totp:"))
+
+	expected := f.decrypt(t, f.mac(t, doc.SOPS.LastModified, "JBSWY3DPEHPK3PXP", uri, "8080", uri), doc.SOPS.LastModified)
+
+	assert.Equal(t, expected, f.decrypt(t, doc.SOPS.MAC, doc.SOPS.LastModified))
+}
+
+func TestDocument_SetTOTPSecret_JSON(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+	path := writeDocument(t, "secrets.json", f.json(t))
+
+	d := sops.TOTPSecretFromDocument(path, "vendors.acme.totp", f.identity())
+
+	require.NoError(t, d.SetTOTPSecret(context.Background(), "JBSWY3DPEHPK3PXP", "ACME"))
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DPEHPK3PXP"), d.TOTPSecret(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(data), "{\n  \"vendors\": {\n    \"acme\": {\n      \"totp\": \"ENC["), string(data))
+	assert.True(t, strings.HasSuffix(string(data), "    \"unencrypted_suffix\": \"_unencrypted\",\n    \"version\": \"3.9.0\"\n  }\n}\n"), string(data))
+
+	var doc struct {
+		Vendors struct {
+			Acme struct {
+				TOTP string `json:"totp"`
+			} `json:"acme"`
+		} `json:"vendors"`
+		SOPS struct {
+			LastModified string `json:"lastmodified"`
+			MAC          string `json:"mac"`
+		} `json:"sops"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", f.decrypt(t, doc.Vendors.Acme.TOTP, "vendors:acme:totp:"))
+
+	expected := f.decrypt(t, f.mac(t, doc.SOPS.LastModified, "JBSWY3DPEHPK3PXP", "True"), doc.SOPS.LastModified)
+
+	assert.Equal(t, expected, f.decrypt(t, doc.SOPS.MAC, doc.SOPS.LastModified))
+}
+
+func TestDocument_SetTOTPSecret_Error(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+	path := writeDocument(t, "secrets.yaml", f.yaml(t))
+
+	d := sops.TOTPSecretFromDocument(path, "vendors.port_unencrypted.totp", f.identity())
+
+	require.ErrorIs(t, d.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), sops.ErrInvalidKeyPath)
+	require.ErrorIs(t, d.DeleteTOTPSecret(context.Background()), sops.ErrInvalidKeyPath)
+
+	d = sops.TOTPSecretFromDocument(path, "vendors.acme", f.identity())
+
+	require.ErrorIs(t, d.SetTOTPSecret(context.Background(), "NBSWY3DP", "ACME"), sops.ErrInvalidKeyPath)
+}
+
+func TestDocument_DeleteTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	f := newSOPSFixture(t)
+	path := writeDocument(t, "secrets.yaml", f.yaml(t))
+
+	d := sops.TOTPSecretFromDocument(path, "vendors.acme.totp", f.identity())
+
+	require.NoError(t, d.DeleteTOTPSecret(context.Background()))
+
+	_, err := d.Load(context.Background())
+	require.ErrorIs(t, err, sops.ErrKeyNotFound)
+
+	// The other values are kept, and the mac is still valid.
+	actual, err := sops.TOTPSecretFromDocument(path, "vendors.acme.uri", f.identity()).Load(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPSecret(uri), actual)
+
+	// Deleting a missing key does nothing.
+	require.NoError(t, d.DeleteTOTPSecret(context.Background()))
+}
+
+func TestDocument_IdentityFromEnv(t *testing.T) { //nolint: paralleltest
+	f := newSOPSFixture(t)
+	path := writeDocument(t, "secrets.yaml", f.yaml(t))
+
+	t.Setenv(sops.EnvAgeKey, f.id.String())
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), sops.TOTPSecretFromDocument(path, "vendors.acme.totp").TOTPSecret(context.Background()))
+}
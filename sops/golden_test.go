@@ -0,0 +1,227 @@
+//go:build unit || !integration
+
+package sops_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/sops"
+)
+
+// The golden files in testdata are encrypted by the sops CLI, version 3.9.0, with the identity in testdata/age.key:
+//
+//	sops -e --age <recipient> secrets.yaml
+//	sops -e --age <recipient> secrets.json
+//	sops -e --age <recipient> --encrypted-regex '^totp$' encrypted_regex.yaml
+//	sops -e --age <recipient> --encrypted-regex '^totp$' --mac-only-encrypted mac_only_encrypted.yaml
+
+func goldenIdentity(t *testing.T) sops.Option {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/age.key")
+	require.NoError(t, err)
+
+	return sops.WithIdentity(otp.TOTPSecret(data))
+}
+
+func copyGolden(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	return writeDocument(t, name, string(data))
+}
+
+func TestDocument_Golden(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		file           string
+		keyPath        string
+		tamper         [2]string
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:       "yaml",
+			file:           "secrets.yaml",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "yaml otpauth uri",
+			file:           "secrets.yaml",
+			keyPath:        "vendors.acme.uri",
+			expectedResult: "otpauth://totp/ACME:john?secret=JBSWY3DPEHPK3PXP&issuer=ACME&digits=8&algorithm=SHA256",
+		},
+		{
+			scenario:       "yaml unencrypted suffix",
+			file:           "secrets.yaml",
+			keyPath:        "vendors.port_unencrypted",
+			expectedResult: "8080",
+		},
+		{
+			scenario:       "json",
+			file:           "secrets.json",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "encrypted regex",
+			file:           "encrypted_regex.yaml",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "encrypted regex unencrypted value",
+			file:           "encrypted_regex.yaml",
+			keyPath:        "vendors.acme.username",
+			expectedResult: "john",
+		},
+		{
+			scenario:       "encrypted regex tampered",
+			file:           "encrypted_regex.yaml",
+			keyPath:        "vendors.acme.totp",
+			tamper:         [2]string{"username: john", "username: jane"},
+			expectedResult: otp.NoTOTPSecret,
+			expectedError:  sops.ErrMACMismatch,
+		},
+		{
+			scenario:       "mac only encrypted",
+			file:           "mac_only_encrypted.yaml",
+			keyPath:        "vendors.acme.totp",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "mac only encrypted unencrypted value changed",
+			file:           "mac_only_encrypted.yaml",
+			keyPath:        "vendors.acme.username",
+			tamper:         [2]string{"username: john", "username: jane"},
+			expectedResult: "jane",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := copyGolden(t, tc.file)
+
+			if tc.tamper[0] != "" {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.Contains(t, string(data), tc.tamper[0])
+
+				require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), tc.tamper[0], tc.tamper[1], 1)), 0o600))
+			}
+
+			actual, err := sops.TOTPSecretFromDocument(path, tc.keyPath, goldenIdentity(t)).Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestDocument_SetTOTPSecret_Golden(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		file     string
+		keyPath  string
+		expected string
+	}{
+		{
+			scenario: "yaml",
+			file:     "secrets.yaml",
+			keyPath:  "vendors.acme.totp",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "json",
+			file:     "secrets.json",
+			keyPath:  "vendors.acme.totp",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "encrypted regex",
+			file:     "encrypted_regex.yaml",
+			keyPath:  "vendors.acme.totp",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+		{
+			scenario: "mac only encrypted",
+			file:     "mac_only_encrypted.yaml",
+			keyPath:  "vendors.acme.totp",
+			expected: "JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := copyGolden(t, tc.file)
+			d := sops.TOTPSecretFromDocument(path, tc.keyPath, goldenIdentity(t))
+
+			require.NoError(t, d.SetTOTPSecret(context.Background(), otp.TOTPSecret(tc.expected), "ACME"))
+
+			actual, err := d.Load(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, otp.TOTPSecret(tc.expected), actual)
+
+			assertSOPSDecrypt(t, path, tc.keyPath, tc.expected)
+		})
+	}
+}
+
+// assertSOPSDecrypt checks that the sops CLI decrypts the document. The check is skipped if the sops CLI is not
+// installed.
+func assertSOPSDecrypt(t *testing.T, path, keyPath, expected string) {
+	t.Helper()
+
+	bin, err := exec.LookPath("sops")
+	if err != nil {
+		t.Log("sops is not installed, skipping the decryption with sops")
+
+		return
+	}
+
+	key, err := filepath.Abs("testdata/age.key")
+	require.NoError(t, err)
+
+	extract := ""
+	for _, k := range strings.Split(keyPath, ".") {
+		extract += `["` + k + `"]`
+	}
+
+	cmd := exec.Command(bin, "--disable-version-check", "-d", "--extract", extract, path) //nolint: gosec
+	cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+key)
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	assert.Equal(t, expected, strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,58 @@
+package sops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"filippo.io/age"
+
+	"go.nhat.io/otp"
+)
+
+// ErrNoIdentity indicates that there is no age identity.
+var ErrNoIdentity = errors.New("no age identity")
+
+// ErrNoRecipients indicates that there is no age recipient to encrypt the file to.
+var ErrNoRecipients = errors.New("no age recipients")
+
+func (c config) identities(ctx context.Context) ([]age.Identity, error) {
+	s := c.identity.TOTPSecret(ctx)
+	if s == otp.NoTOTPSecret {
+		return nil, ErrNoIdentity
+	}
+
+	ids, err := age.ParseIdentities(strings.NewReader(s.Reveal()))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse age identities: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ageRecipients returns the configured recipients, or the recipients of the X25519 identities.
+func (c config) ageRecipients(ids []age.Identity) ([]age.Recipient, error) {
+	if len(c.recipients) > 0 {
+		rs, err := age.ParseRecipients(strings.NewReader(strings.Join(c.recipients, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse age recipients: %w", err)
+		}
+
+		return rs, nil
+	}
+
+	var rs []age.Recipient
+
+	for _, id := range ids {
+		if id, ok := id.(*age.X25519Identity); ok {
+			rs = append(rs, id.Recipient())
+		}
+	}
+
+	if len(rs) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	return rs, nil
+}
@@ -0,0 +1,184 @@
+package sops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultJSONIndent = "\t"
+
+// decodeJSON decodes a JSON object into a yaml node, so the documents of both formats are handled the same way. The
+// order of the keys is kept.
+func decodeJSON(data []byte) (*yaml.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	n, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode json: %w", err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("could not decode json: unexpected data after the top-level value") //nolint: err113
+	}
+
+	if n.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: not a json object", ErrNotSOPSDocument)
+	}
+
+	return n, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if t == '[' {
+			n = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+
+		for dec.More() {
+			if n.Kind == yaml.MappingNode {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				n.Content = append(n.Content, strNode(k.(string))) //nolint: forcetypeassert
+			}
+
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			n.Content = append(n.Content, v)
+		}
+
+		// The closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return n, nil
+
+	case string:
+		return strNode(t), nil
+
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: t.String()}, nil
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: t.String()}, nil
+
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(t)}, nil
+
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// encodeJSON encodes a yaml node decoded by decodeJSON.
+func encodeJSON(n *yaml.Node, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := writeJSON(&buf, n, indent, 0); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, n *yaml.Node, indent string, depth int) error {
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, closing, step := "{", "}", 2
+		if n.Kind == yaml.SequenceNode {
+			open, closing, step = "[", "]", 1
+		}
+
+		buf.WriteString(open)
+
+		for i := 0; i < len(n.Content); i += step {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(indent, depth+1))
+
+			if step == 2 {
+				if err := writeJSONString(buf, n.Content[i].Value); err != nil {
+					return err
+				}
+
+				buf.WriteString(": ")
+			}
+
+			if err := writeJSON(buf, n.Content[i+step-1], indent, depth+1); err != nil {
+				return err
+			}
+		}
+
+		if len(n.Content) > 0 {
+			buf.WriteByte('\n')
+			buf.WriteString(strings.Repeat(indent, depth))
+		}
+
+		buf.WriteString(closing)
+
+	case yaml.ScalarNode:
+		if n.ShortTag() == "!!str" {
+			return writeJSONString(buf, n.Value)
+		}
+
+		buf.WriteString(n.Value)
+
+	default:
+		return fmt.Errorf("could not encode json: unsupported node kind %d", n.Kind) //nolint: err113
+	}
+
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	var out bytes.Buffer
+
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+
+	buf.Write(bytes.TrimSuffix(out.Bytes(), []byte("\n")))
+
+	return nil
+}
+
+// jsonIndent returns the indentation of a JSON document.
+func jsonIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+
+	return defaultJSONIndent
+}
@@ -0,0 +1,117 @@
+package sops
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+)
+
+// EnvAgeKey is the environment variable that contains the age identities, as used by SOPS. The identities are read from
+// the file at EnvAgeKey + "_FILE" if it is set.
+const EnvAgeKey = "SOPS_AGE_KEY"
+
+type config struct {
+	identity     otp.TOTPSecretGetter
+	identityFile string
+	recipients   []string
+	format       Format
+
+	clock  clock.Clock
+	logger ctxd.Logger
+}
+
+func newConfig(opts ...Option) config {
+	cfg := config{
+		clock:  clock.New(),
+		logger: ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyOption(&cfg)
+	}
+
+	if cfg.identity == nil && cfg.identityFile != "" {
+		cfg.identity = secretfile.TOTPSecretFromFile(cfg.identityFile, secretfile.WithLogger(cfg.logger))
+	}
+
+	if cfg.identity == nil {
+		cfg.identity = defaultIdentity(cfg.logger)
+	}
+
+	return cfg
+}
+
+// defaultIdentity reads the age identities like SOPS does, from the SOPS_AGE_KEY or SOPS_AGE_KEY_FILE environment
+// variables, then from the sops/age/keys.txt file in the user config directory.
+func defaultIdentity(l ctxd.Logger) otp.TOTPSecretGetter {
+	getters := []otp.TOTPSecretGetter{secretfile.TOTPSecretFromEnv(EnvAgeKey, secretfile.WithLogger(l))}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		getters = append(getters, secretfile.TOTPSecretFromFile(filepath.Join(dir, "sops", "age", "keys.txt"), secretfile.WithLogger(l)))
+	}
+
+	return otp.ChainTOTPSecretGetters(getters...)
+}
+
+// Option configures the providers.
+type Option interface {
+	applyOption(c *config)
+}
+
+type optionFunc func(c *config)
+
+func (f optionFunc) applyOption(c *config) {
+	f(c)
+}
+
+// WithIdentity sets the source of the age identities, one per line, such as an otp.TOTPSecretFromEnv or a
+// keyring.TOTPSecretFromKeyring. By default, the identities are read from SOPS_AGE_KEY, SOPS_AGE_KEY_FILE, or the
+// sops/age/keys.txt file in the user config directory.
+func WithIdentity(g otp.TOTPSecretGetter) Option {
+	return optionFunc(func(c *config) {
+		c.identity = g
+		c.identityFile = ""
+	})
+}
+
+// WithIdentityFile reads the age identities from a file.
+func WithIdentityFile(path string) Option {
+	return optionFunc(func(c *config) {
+		c.identity = nil
+		c.identityFile = path
+	})
+}
+
+// WithRecipients sets the age recipients of the age-encrypted files. By default, the files are encrypted to the
+// recipients of the X25519 identities. The recipients of the SOPS documents are kept as they are.
+func WithRecipients(recipients ...string) Option {
+	return optionFunc(func(c *config) {
+		c.recipients = append(c.recipients, recipients...)
+	})
+}
+
+// WithFormat sets the format of the SOPS document. By default, it is detected from the extension of the file.
+func WithFormat(f Format) Option {
+	return optionFunc(func(c *config) {
+		c.format = f
+	})
+}
+
+// WithClock sets the clock that is used for the last modified time of the SOPS documents.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.clock = c
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.logger = otp.RedactLogger(l)
+	})
+}
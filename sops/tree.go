@@ -0,0 +1,345 @@
+package sops
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// metadataKey is the key of the SOPS metadata in the document.
+const metadataKey = "sops"
+
+const defaultUnencryptedSuffix = "_unencrypted"
+
+// ErrNotSOPSDocument indicates that the document has no SOPS metadata.
+var ErrNotSOPSDocument = errors.New("not a sops document")
+
+// ErrUnsupportedDocument indicates that the SOPS document uses a feature that is not supported, such as the Shamir
+// secret sharing between several key groups, or the encryption rules based on the comments.
+var ErrUnsupportedDocument = errors.New("unsupported sops document")
+
+// ErrMACMismatch indicates that the MAC of the SOPS document does not match its content.
+var ErrMACMismatch = errors.New("sops mac mismatch")
+
+// ErrKeyNotFound indicates that the key path does not exist in the document.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrInvalidKeyPath indicates that the key path does not lead to a value, or goes through a value that is not a map.
+var ErrInvalidKeyPath = errors.New("invalid key path")
+
+// metadata is the SOPS metadata of a document.
+type metadata struct {
+	node *yaml.Node
+
+	ageKeys          []string
+	lastModified     string
+	mac              string
+	macOnlyEncrypted bool
+
+	unencryptedSuffix string
+	encryptedSuffix   string
+	unencryptedRegex  *regexp.Regexp
+	encryptedRegex    *regexp.Regexp
+}
+
+func parseMetadata(root *yaml.Node) (*metadata, error) {
+	_, n := mappingValue(root, metadataKey)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, ErrNotSOPSDocument
+	}
+
+	md := &metadata{node: n}
+
+	var err error
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i].Value, n.Content[i+1]
+
+		switch k {
+		case "age":
+			md.ageKeys = append(md.ageKeys, ageKeys(v)...)
+
+		case "key_groups":
+			if len(v.Content) > 1 {
+				if _, t := mappingValue(n, "shamir_threshold"); t != nil && t.Value != "1" {
+					return nil, fmt.Errorf("%w: shamir secret sharing", ErrUnsupportedDocument)
+				}
+			}
+
+			for _, g := range v.Content {
+				_, a := mappingValue(g, "age")
+				md.ageKeys = append(md.ageKeys, ageKeys(a)...)
+			}
+
+		case "lastmodified":
+			md.lastModified = v.Value
+
+		case "mac":
+			md.mac = v.Value
+
+		case "mac_only_encrypted":
+			md.macOnlyEncrypted, _ = strconv.ParseBool(v.Value) //nolint: errcheck
+
+		case "unencrypted_suffix":
+			md.unencryptedSuffix = v.Value
+
+		case "encrypted_suffix":
+			md.encryptedSuffix = v.Value
+
+		case "unencrypted_regex":
+			md.unencryptedRegex, err = regexp.Compile(v.Value)
+
+		case "encrypted_regex":
+			md.encryptedRegex, err = regexp.Compile(v.Value)
+
+		case "encrypted_comment_regex", "unencrypted_comment_regex":
+			if v.Value != "" {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocument, k)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotSOPSDocument, err)
+		}
+	}
+
+	if md.unencryptedSuffix == "" && md.encryptedSuffix == "" && md.unencryptedRegex == nil && md.encryptedRegex == nil {
+		md.unencryptedSuffix = defaultUnencryptedSuffix
+	}
+
+	return md, nil
+}
+
+func ageKeys(n *yaml.Node) []string {
+	if n == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(n.Content))
+
+	for _, k := range n.Content {
+		if _, enc := mappingValue(k, "enc"); enc != nil {
+			keys = append(keys, enc.Value)
+		}
+	}
+
+	return keys
+}
+
+// shouldEncrypt tells whether the value at the path is encrypted, following the rules in the metadata.
+func (md *metadata) shouldEncrypt(path []string) bool {
+	switch {
+	case md.unencryptedSuffix != "":
+		return !anyPath(path, func(k string) bool { return strings.HasSuffix(k, md.unencryptedSuffix) })
+
+	case md.encryptedSuffix != "":
+		return anyPath(path, func(k string) bool { return strings.HasSuffix(k, md.encryptedSuffix) })
+
+	case md.unencryptedRegex != nil:
+		return !anyPath(path, md.unencryptedRegex.MatchString)
+
+	case md.encryptedRegex != nil:
+		return anyPath(path, md.encryptedRegex.MatchString)
+	}
+
+	return true
+}
+
+// set sets a value of the metadata, or adds it if it does not exist.
+func (md *metadata) set(key, value string) {
+	if _, n := mappingValue(md.node, key); n != nil {
+		n.Value = value
+
+		return
+	}
+
+	md.node.Content = append(md.node.Content, strNode(key), strNode(value))
+}
+
+func anyPath(path []string, fn func(k string) bool) bool {
+	for _, k := range path {
+		if fn(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// macOnlyEncryptedInitialization is written first in the MAC of the documents with mac_only_encrypted, so that their
+// MAC is different from the MAC of the same values without the setting.
+var macOnlyEncryptedInitialization = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+// macWalker computes the MAC of a document, in the same order as SOPS walks its tree. The comments are not part of the
+// MAC.
+type macWalker struct {
+	md   *metadata
+	key  []byte
+	hash hash.Hash
+}
+
+func computeMAC(root *yaml.Node, md *metadata, key []byte) (string, error) {
+	w := macWalker{md: md, key: key, hash: sha512.New()}
+
+	if md.macOnlyEncrypted {
+		_, _ = w.hash.Write(macOnlyEncryptedInitialization) //nolint: errcheck
+	}
+
+	if err := w.mapping(root, nil); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%X", w.hash.Sum(nil)), nil
+}
+
+func (w *macWalker) write(value string, encrypted bool) {
+	if !w.md.macOnlyEncrypted || encrypted {
+		_, _ = w.hash.Write([]byte(value)) //nolint: errcheck
+	}
+}
+
+func (w *macWalker) mapping(n *yaml.Node, path []string) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+
+		if len(path) == 0 && k.Value == metadataKey {
+			continue
+		}
+
+		if err := w.value(v, appendPath(path, k.Value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *macWalker) value(n *yaml.Node, path []string) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return w.mapping(n, path)
+
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if err := w.value(item, path); err != nil {
+				return err
+			}
+		}
+
+	case yaml.AliasNode:
+		return w.value(n.Alias, path)
+
+	case yaml.ScalarNode:
+		return w.scalar(n, path)
+	}
+
+	return nil
+}
+
+func (w *macWalker) scalar(n *yaml.Node, path []string) error {
+	if n.ShortTag() == "!!null" {
+		return nil
+	}
+
+	if !w.md.shouldEncrypt(path) {
+		w.write(canonical(scalarType(n), n.Value), false)
+
+		return nil
+	}
+
+	plain, typ, err := decryptValue(w.key, n.Value, aad(path))
+	if err != nil {
+		return fmt.Errorf("could not decrypt %q: %w", strings.Join(path, "."), err)
+	}
+
+	w.write(canonical(typ, plain), true)
+
+	return nil
+}
+
+// scalarType returns the type of an unencrypted scalar.
+func scalarType(n *yaml.Node) string {
+	switch n.ShortTag() {
+	case "!!int":
+		return typeInt
+
+	case "!!float":
+		return typeFloat
+
+	case "!!bool":
+		return typeBool
+	}
+
+	return typeString
+}
+
+// aad returns the additional data of the value at the path.
+func aad(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+func appendPath(path []string, key string) []string {
+	return append(path[:len(path):len(path)], key)
+}
+
+// mappingValue returns the index of the key and its value in a mapping.
+func mappingValue(n *yaml.Node, key string) (int, *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return -1, nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i, n.Content[i+1]
+		}
+	}
+
+	return -1, nil
+}
+
+// lookup returns the mapping that holds the last key of the path, and the index of the key in the mapping. The index is
+// -1 if the key does not exist. The mappings on the way are created if create is true.
+func lookup(root *yaml.Node, path []string, create bool) (*yaml.Node, int, error) {
+	if len(path) == 0 || path[0] == metadataKey {
+		return nil, -1, ErrInvalidKeyPath
+	}
+
+	n := root
+
+	for _, k := range path[:len(path)-1] {
+		_, v := mappingValue(n, k)
+
+		switch {
+		case v != nil && v.Kind == yaml.MappingNode:
+			n = v
+
+		case v != nil:
+			return nil, -1, ErrInvalidKeyPath
+
+		case !create:
+			return nil, -1, ErrKeyNotFound
+
+		default:
+			v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			n.Content = append(n.Content, strNode(k), v)
+			n = v
+		}
+	}
+
+	i, _ := mappingValue(n, path[len(path)-1])
+
+	return n, i, nil
+}
+
+func strNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
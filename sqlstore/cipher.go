@@ -0,0 +1,69 @@
+package sqlstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCiphertext indicates that the encrypted secret could not be decrypted.
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Cipher encrypts and decrypts the secret column. The associated data binds the ciphertext to its row and column, so
+// that a ciphertext copied to another row does not decrypt.
+type Cipher interface {
+	Encrypt(plaintext, associatedData []byte) ([]byte, error)
+	Decrypt(ciphertext, associatedData []byte) ([]byte, error)
+}
+
+var _ Cipher = (*AESGCMCipher)(nil)
+
+// AESGCMCipher is a Cipher that uses AES-GCM. The nonce is prepended to the ciphertext.
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// Encrypt encrypts and authenticates the plaintext, and authenticates the associated data.
+func (c *AESGCMCipher) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// Decrypt decrypts the ciphertext. It returns ErrInvalidCiphertext if the ciphertext or the associated data has been
+// tampered with.
+func (c *AESGCMCipher) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, data := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, data, associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+// NewAESGCMCipher initiates a new AESGCMCipher. The key must be 16, 24 or 32 bytes long.
+func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+
+	return &AESGCMCipher{aead: aead}, nil
+}
@@ -0,0 +1,42 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL dialect of the database.
+type Dialect int
+
+const (
+	// DialectSQLite uses "?" placeholders.
+	DialectSQLite Dialect = iota
+	// DialectPostgres uses "$n" placeholders.
+	DialectPostgres
+)
+
+// rebind replaces the "?" placeholders in the query with the ones of the dialect.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var (
+		sb strings.Builder
+		n  int
+	)
+
+	for _, r := range query {
+		if r != '?' {
+			sb.WriteRune(r)
+
+			continue
+		}
+
+		n++
+
+		sb.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return sb.String()
+}
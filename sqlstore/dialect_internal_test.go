@@ -0,0 +1,18 @@
+//go:build unit || !integration
+
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect_Rebind(t *testing.T) {
+	t.Parallel()
+
+	q := `SELECT a FROM t WHERE b = ? AND c = ?`
+
+	assert.Equal(t, q, DialectSQLite.rebind(q))
+	assert.Equal(t, `SELECT a FROM t WHERE b = $1 AND c = $2`, DialectPostgres.rebind(q))
+}
@@ -0,0 +1,2 @@
+// Package sqlstore provides totp secret storage for multi-user services using database/sql.
+package sqlstore
@@ -0,0 +1,118 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.nhat.io/otp/limiter"
+)
+
+var _ limiter.Store = (*LimiterStore)(nil)
+
+// LimiterStore is a limiter.Store that keeps the failed attempts in the database.
+type LimiterStore struct {
+	store *Store
+}
+
+// LoadState returns the state of the account.
+func (s *LimiterStore) LoadState(ctx context.Context, account string) (limiter.State, error) {
+	var (
+		state       limiter.State
+		lockedUntil int64
+	)
+
+	err := s.store.db.QueryRowContext(ctx,
+		s.store.query(`SELECT failures, locked_until FROM %s WHERE account = ?`, s.store.table("attempts")),
+		account,
+	).Scan(&state.Failures, &lockedUntil)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return limiter.State{}, nil
+	}
+
+	if err != nil {
+		return limiter.State{}, fmt.Errorf("could not get limiter state: %w", err)
+	}
+
+	if lockedUntil != 0 {
+		state.LockedUntil = time.Unix(0, lockedUntil).UTC()
+	}
+
+	return state, nil
+}
+
+// SaveState persists the state of the account.
+func (s *LimiterStore) SaveState(ctx context.Context, account string, state limiter.State) error {
+	_, err := s.store.db.ExecContext(ctx,
+		s.store.query(`INSERT INTO %s (account, failures, locked_until) VALUES (?, ?, ?)
+ON CONFLICT (account) DO UPDATE SET failures = excluded.failures, locked_until = excluded.locked_until`, s.store.table("attempts")),
+		account, state.Failures, unixNano(state.LockedUntil),
+	)
+	if err != nil {
+		return fmt.Errorf("could not save limiter state: %w", err)
+	}
+
+	return nil
+}
+
+// CompareAndSwapState replaces the state of the account if it has not changed, in a single statement so that it is
+// atomic across the instances that share the database.
+func (s *LimiterStore) CompareAndSwapState(ctx context.Context, account string, old, next limiter.State) (bool, error) {
+	table := s.store.table("attempts")
+
+	var (
+		res sql.Result
+		err error
+	)
+
+	if old.IsZero() {
+		res, err = s.store.db.ExecContext(ctx,
+			s.store.query(`INSERT INTO %s (account, failures, locked_until) VALUES (?, ?, ?)
+ON CONFLICT (account) DO UPDATE SET failures = excluded.failures, locked_until = excluded.locked_until
+WHERE %s.failures = 0 AND %s.locked_until = 0`, table, table, table),
+			account, next.Failures, unixNano(next.LockedUntil),
+		)
+	} else {
+		res, err = s.store.db.ExecContext(ctx,
+			s.store.query(`UPDATE %s SET failures = ?, locked_until = ? WHERE account = ? AND failures = ? AND locked_until = ?`, table),
+			next.Failures, unixNano(next.LockedUntil), account, old.Failures, unixNano(old.LockedUntil),
+		)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not save limiter state: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not save limiter state: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// DeleteState deletes the state of the account.
+func (s *LimiterStore) DeleteState(ctx context.Context, account string) error {
+	_, err := s.store.db.ExecContext(ctx, s.store.query(`DELETE FROM %s WHERE account = ?`, s.store.table("attempts")), account)
+	if err != nil {
+		return fmt.Errorf("could not delete limiter state: %w", err)
+	}
+
+	return nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+// LimiterStore returns a limiter.Store that keeps the failed attempts in the same database.
+func (s *Store) LimiterStore() *LimiterStore {
+	return &LimiterStore{store: s}
+}
@@ -0,0 +1,88 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// migrations are the schema changes, applied in order. Never change a released migration, append a new one instead.
+var migrations = []func(s *Store) []string{
+	// 1: secrets.
+	func(s *Store) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE %s (
+	user_id VARCHAR(255) NOT NULL PRIMARY KEY,
+	secret TEXT NOT NULL,
+	issuer VARCHAR(255) NOT NULL DEFAULT '',
+	digits INTEGER NOT NULL DEFAULT 6,
+	period INTEGER NOT NULL DEFAULT 30,
+	algorithm VARCHAR(16) NOT NULL DEFAULT 'SHA1',
+	updated_at BIGINT NOT NULL DEFAULT 0
+)`, s.table("secrets")),
+		}
+	},
+	// 2: failed attempts of the limiter.
+	func(s *Store) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE %s (
+	account VARCHAR(255) NOT NULL PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	locked_until BIGINT NOT NULL DEFAULT 0
+)`, s.table("attempts")),
+		}
+	},
+}
+
+// Migrate creates or upgrades the schema to the latest version.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL PRIMARY KEY)`, s.table("schema_migrations")))
+	if err != nil {
+		return fmt.Errorf("could not migrate schema: %w", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for v := current + 1; v <= len(migrations); v++ {
+		if err := s.migrate(ctx, v); err != nil {
+			return fmt.Errorf("could not migrate schema to version %d: %w", v, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) migrate(ctx context.Context, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	defer tx.Rollback() //nolint: errcheck
+
+	for _, stmt := range migrations[version-1](s) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err //nolint: wrapcheck
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO %s (version) VALUES (?)`, s.table("schema_migrations")), version); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	return tx.Commit() //nolint: wrapcheck
+}
+
+// SchemaVersion returns the current version of the schema, 0 if it has not been migrated.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s`, s.table("schema_migrations"))).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("could not get schema version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,54 @@
+package sqlstore
+
+import (
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+// Option configures the Store.
+type Option interface {
+	applyStoreOption(s *Store)
+}
+
+type optionFunc func(s *Store)
+
+func (f optionFunc) applyStoreOption(s *Store) {
+	f(s)
+}
+
+// WithDialect sets the SQL dialect. Default is DialectSQLite.
+func WithDialect(d Dialect) Option {
+	return optionFunc(func(s *Store) {
+		s.dialect = d
+	})
+}
+
+// WithTablePrefix sets the prefix of the tables. Default is "totp_".
+func WithTablePrefix(prefix string) Option {
+	return optionFunc(func(s *Store) {
+		s.prefix = prefix
+	})
+}
+
+// WithCipher encrypts the secret column with the cipher.
+func WithCipher(c Cipher) Option {
+	return optionFunc(func(s *Store) {
+		s.cipher = c
+	})
+}
+
+// WithClock sets the clock of the Store.
+func WithClock(c clock.Clock) Option {
+	return optionFunc(func(s *Store) {
+		s.clock = c
+	})
+}
+
+// WithLogger sets the logger of the Store. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(s *Store) {
+		s.logger = otp.RedactLogger(l)
+	})
+}
@@ -0,0 +1,63 @@
+package sqlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.nhat.io/otp"
+)
+
+var _ otp.TOTPSecretProvider = (*TOTPSecretProvider)(nil)
+
+// TOTPSecretProvider manages the TOTP secret of a user in the Store.
+type TOTPSecretProvider struct {
+	store  *Store
+	userID string
+}
+
+// TOTPSecret returns the TOTP secret of the user as an otpauth URI, so that the digits, the period and the algorithm of
+// the record are used to generate and verify the codes.
+func (p *TOTPSecretProvider) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	r, err := p.store.Get(ctx, p.userID)
+	if errors.Is(err, otp.ErrNoTOTPSecret) {
+		return otp.NoTOTPSecret
+	}
+
+	if err != nil {
+		p.store.logger.Error(ctx, "could not get totp secret from database", "error", err, "user_id", p.userID)
+
+		return otp.NoTOTPSecret
+	}
+
+	return otp.TOTPURI{
+		Issuer:      r.Issuer,
+		AccountName: p.userID,
+		Secret:      r.Secret,
+		Digits:      r.Digits,
+		Period:      time.Duration(r.Period) * time.Second,
+		Algorithm:   r.Algorithm,
+	}.Encode()
+}
+
+// SetTOTPSecret persists the TOTP secret of the user.
+func (p *TOTPSecretProvider) SetTOTPSecret(ctx context.Context, secret otp.TOTPSecret, issuer string) error {
+	if err := p.store.setSecret(ctx, p.userID, secret, issuer); err != nil {
+		p.store.logger.Error(ctx, "could not persist totp secret to database", "error", err, "user_id", p.userID)
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteTOTPSecret deletes the TOTP secret of the user.
+func (p *TOTPSecretProvider) DeleteTOTPSecret(ctx context.Context) error {
+	if err := p.store.Delete(ctx, p.userID); err != nil {
+		p.store.logger.Error(ctx, "could not delete totp secret in database", "error", err, "user_id", p.userID)
+
+		return err
+	}
+
+	return nil
+}
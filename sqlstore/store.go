@@ -0,0 +1,249 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+)
+
+const (
+	defaultTablePrefix = "totp_"
+
+	defaultDigits    = 6
+	defaultPeriod    = 30
+	defaultAlgorithm = "SHA1"
+)
+
+// Record is the TOTP secret of a user and its parameters.
+type Record struct {
+	UserID    string
+	Secret    otp.TOTPSecret
+	Issuer    string
+	Digits    int
+	Period    int
+	Algorithm string
+}
+
+func (r Record) withDefaults() Record {
+	if r.Digits == 0 {
+		r.Digits = defaultDigits
+	}
+
+	if r.Period == 0 {
+		r.Period = defaultPeriod
+	}
+
+	if r.Algorithm == "" {
+		r.Algorithm = defaultAlgorithm
+	}
+
+	return r
+}
+
+// Store keeps the TOTP secrets of the users in a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	prefix  string
+	cipher  Cipher
+	clock   clock.Clock
+	logger  ctxd.Logger
+}
+
+func (s *Store) table(name string) string {
+	return s.prefix + name
+}
+
+func (s *Store) query(q string, tables ...any) string {
+	return s.dialect.rebind(fmt.Sprintf(q, tables...))
+}
+
+// associatedData binds the ciphertext of the secret column to the user.
+func associatedData(userID string) []byte {
+	return []byte("secret\x00" + userID)
+}
+
+func (s *Store) encrypt(userID string, secret otp.TOTPSecret) (string, error) {
+	if s.cipher == nil {
+		return string(secret), nil
+	}
+
+	ciphertext, err := s.cipher.Encrypt([]byte(secret), associatedData(userID))
+	if err != nil {
+		return "", fmt.Errorf("could not encrypt totp secret: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *Store) decrypt(userID, value string) (otp.TOTPSecret, error) {
+	if s.cipher == nil {
+		return otp.TOTPSecret(value), nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w: %w", ErrInvalidCiphertext, err)
+	}
+
+	plaintext, err := s.cipher.Decrypt(ciphertext, associatedData(userID))
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not decrypt totp secret: %w", err)
+	}
+
+	return otp.TOTPSecret(plaintext), nil
+}
+
+// Get returns the record of the user. It returns otp.ErrNoTOTPSecret if there is none.
+func (s *Store) Get(ctx context.Context, userID string) (Record, error) {
+	var (
+		r     = Record{UserID: userID}
+		value string
+	)
+
+	err := s.db.QueryRowContext(ctx,
+		s.query(`SELECT secret, issuer, digits, period, algorithm FROM %s WHERE user_id = ?`, s.table("secrets")),
+		userID,
+	).Scan(&value, &r.Issuer, &r.Digits, &r.Period, &r.Algorithm)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, otp.ErrNoTOTPSecret
+	}
+
+	if err != nil {
+		return Record{}, fmt.Errorf("could not get totp secret: %w", err)
+	}
+
+	if r.Secret, err = s.decrypt(userID, value); err != nil {
+		return Record{}, err
+	}
+
+	return r, nil
+}
+
+// Set creates or updates the record of the user.
+func (s *Store) Set(ctx context.Context, r Record) error {
+	r = r.withDefaults()
+
+	value, err := s.encrypt(r.UserID, r.Secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		s.query(`INSERT INTO %s (user_id, secret, issuer, digits, period, algorithm, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE SET
+	secret = excluded.secret,
+	issuer = excluded.issuer,
+	digits = excluded.digits,
+	period = excluded.period,
+	algorithm = excluded.algorithm,
+	updated_at = excluded.updated_at`, s.table("secrets")),
+		r.UserID, value, r.Issuer, r.Digits, r.Period, r.Algorithm, s.clock.Now().UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("could not set totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// setSecret creates or updates the secret and the issuer of the user. If the secret is an otpauth URI, its parameters
+// are stored too, otherwise the parameters are reset to the defaults, so that they do not outlive the secret they were
+// set for.
+func (s *Store) setSecret(ctx context.Context, userID string, secret otp.TOTPSecret, issuer string) error {
+	if !otp.IsOTPAuthURI(strings.TrimSpace(secret.Reveal())) {
+		return s.Set(ctx, Record{UserID: userID, Secret: secret, Issuer: issuer})
+	}
+
+	u, err := otp.ParseTOTPURI(secret.Reveal())
+	if err != nil {
+		return fmt.Errorf("could not set totp secret: %w", err)
+	}
+
+	if u.Issuer != "" {
+		issuer = u.Issuer
+	}
+
+	return s.Set(ctx, Record{
+		UserID:    userID,
+		Secret:    u.Secret,
+		Issuer:    issuer,
+		Digits:    u.Digits,
+		Period:    int(u.Period / time.Second),
+		Algorithm: u.Algorithm,
+	})
+}
+
+// Delete deletes the record of the user.
+func (s *Store) Delete(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`DELETE FROM %s WHERE user_id = ?`, s.table("secrets")), userID)
+	if err != nil {
+		return fmt.Errorf("could not delete totp secret: %w", err)
+	}
+
+	return nil
+}
+
+// UserIDs returns the ids of all the users that have a TOTP secret.
+func (s *Store) UserIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT user_id FROM %s ORDER BY user_id`, s.table("secrets")))
+	if err != nil {
+		return nil, fmt.Errorf("could not list users: %w", err)
+	}
+
+	defer rows.Close() //nolint: errcheck
+
+	var result []string
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("could not list users: %w", err)
+		}
+
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not list users: %w", err)
+	}
+
+	return result, nil
+}
+
+// TOTPSecretProvider returns a view of the store that manages the TOTP secret of the user.
+func (s *Store) TOTPSecretProvider(userID string) *TOTPSecretProvider {
+	return &TOTPSecretProvider{
+		store:  s,
+		userID: userID,
+	}
+}
+
+// New initiates a new Store. Call Migrate to create or upgrade the schema before using it.
+func New(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:      db,
+		dialect: DialectSQLite,
+		prefix:  defaultTablePrefix,
+		clock:   clock.New(),
+		logger:  ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyStoreOption(s)
+	}
+
+	return s
+}
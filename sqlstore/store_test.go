@@ -0,0 +1,356 @@
+//go:build unit || !integration
+
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+	_ "modernc.org/sqlite"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/limiter"
+	"go.nhat.io/otp/sqlstore"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "otp.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close() //nolint: errcheck
+	})
+
+	return db
+}
+
+func newStore(t *testing.T, db *sql.DB, opts ...sqlstore.Option) *sqlstore.Store {
+	t.Helper()
+
+	s := sqlstore.New(db, opts...)
+
+	require.NoError(t, s.Migrate(context.Background()))
+
+	return s
+}
+
+func TestStore_Migrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := sqlstore.New(openDB(t), sqlstore.WithTablePrefix("app_totp_"))
+
+	_, err := s.SchemaVersion(ctx)
+	require.Error(t, err)
+
+	require.NoError(t, s.Migrate(ctx))
+
+	v, err := s.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	// Migrate is idempotent.
+	require.NoError(t, s.Migrate(ctx))
+
+	v, err = s.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestStore_CRUD(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newStore(t, openDB(t))
+
+	_, err := s.Get(ctx, "john")
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+
+	err = s.Set(ctx, sqlstore.Record{UserID: "john", Secret: "NBSWY3DP", Issuer: "example.com"})
+	require.NoError(t, err)
+
+	err = s.Set(ctx, sqlstore.Record{UserID: "jane", Secret: "JBSWY3DPEHPK3PXP", Digits: 8, Period: 60, Algorithm: "SHA256"})
+	require.NoError(t, err)
+
+	actual, err := s.Get(ctx, "john")
+	require.NoError(t, err)
+
+	expected := sqlstore.Record{UserID: "john", Secret: "NBSWY3DP", Issuer: "example.com", Digits: 6, Period: 30, Algorithm: "SHA1"}
+
+	assert.Equal(t, expected, actual)
+
+	actual, err = s.Get(ctx, "jane")
+	require.NoError(t, err)
+
+	expected = sqlstore.Record{UserID: "jane", Secret: "JBSWY3DPEHPK3PXP", Digits: 8, Period: 60, Algorithm: "SHA256"}
+
+	assert.Equal(t, expected, actual)
+
+	ids, err := s.UserIDs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jane", "john"}, ids)
+
+	require.NoError(t, s.Delete(ctx, "john"))
+
+	_, err = s.Get(ctx, "john")
+	require.ErrorIs(t, err, otp.ErrNoTOTPSecret)
+}
+
+func TestStore_TOTPSecretProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newStore(t, openDB(t))
+
+	err := s.Set(ctx, sqlstore.Record{UserID: "jane", Secret: "JBSWY3DPEHPK3PXP", Digits: 8})
+	require.NoError(t, err)
+
+	john := s.TOTPSecretProvider("john")
+	jane := s.TOTPSecretProvider("jane")
+
+	assert.Equal(t, otp.NoTOTPSecret, john.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("otpauth://totp/jane?algorithm=SHA1&digits=8&period=30&secret=JBSWY3DPEHPK3PXP"), jane.TOTPSecret(ctx))
+
+	require.NoError(t, john.SetTOTPSecret(ctx, "NBSWY3DP", "example.com"))
+	require.NoError(t, jane.SetTOTPSecret(ctx, "NBSWY3DP", "example.org"))
+
+	assert.Equal(t, otp.TOTPSecret("otpauth://totp/example.com:john?algorithm=SHA1&digits=6&issuer=example.com&period=30&secret=NBSWY3DP"), john.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("otpauth://totp/example.org:jane?algorithm=SHA1&digits=6&issuer=example.org&period=30&secret=NBSWY3DP"), jane.TOTPSecret(ctx))
+
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	code, err := otp.GenerateTOTP(ctx, john, otp.WithClock(c))
+	require.NoError(t, err)
+	assert.Equal(t, otp.OTP("191882"), code)
+
+	// The parameters of the record are used to generate the codes.
+	require.NoError(t, s.Set(ctx, sqlstore.Record{UserID: "jane", Secret: "NBSWY3DP", Digits: 8}))
+
+	code, err = otp.GenerateTOTP(ctx, jane, otp.WithClock(c))
+	require.NoError(t, err)
+	assert.Len(t, code, 8)
+	assert.Equal(t, otp.OTP("191882"), code[2:])
+
+	require.NoError(t, john.DeleteTOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, john.TOTPSecret(ctx))
+}
+
+func TestStore_TOTPSecretProvider_URI(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newStore(t, openDB(t))
+	p := s.TOTPSecretProvider("john")
+
+	uri := otp.TOTPSecret("otpauth://totp/Acme:john?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA&issuer=Acme&digits=8&algorithm=SHA256&period=60")
+
+	require.NoError(t, p.SetTOTPSecret(ctx, uri, "ignored"))
+
+	r, err := s.Get(ctx, "john")
+	require.NoError(t, err)
+
+	assert.Equal(t, sqlstore.Record{
+		UserID:    "john",
+		Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA",
+		Issuer:    "Acme",
+		Digits:    8,
+		Period:    60,
+		Algorithm: "SHA256",
+	}, r)
+
+	code, err := otp.GenerateTOTP(ctx, p, otp.WithClock(clock.Fix(time.Unix(119, 0))))
+	require.NoError(t, err)
+	assert.Equal(t, otp.OTP("46119246"), code)
+
+	require.ErrorIs(t, p.SetTOTPSecret(ctx, "otpauth://hotp/john?secret=NBSWY3DP", ""), otp.ErrInvalidOTPAuthURI)
+
+	// The parameters of the URI do not outlive its secret when it is rotated to a bare secret.
+	require.NoError(t, p.SetTOTPSecret(ctx, "NBSWY3DP", "Acme"))
+
+	r, err = s.Get(ctx, "john")
+	require.NoError(t, err)
+
+	assert.Equal(t, sqlstore.Record{
+		UserID:    "john",
+		Secret:    "NBSWY3DP",
+		Issuer:    "Acme",
+		Digits:    6,
+		Period:    30,
+		Algorithm: "SHA1",
+	}, r)
+
+	code, err = otp.GenerateTOTP(ctx, p, otp.WithClock(clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))))
+	require.NoError(t, err)
+	assert.Equal(t, otp.OTP("191882"), code)
+}
+
+func TestStore_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openDB(t)
+	s := sqlstore.New(db)
+
+	// The schema has not been migrated.
+	p := s.TOTPSecretProvider("john")
+
+	assert.Equal(t, otp.NoTOTPSecret, p.TOTPSecret(ctx))
+	require.ErrorContains(t, p.SetTOTPSecret(ctx, "NBSWY3DP", ""), "could not set totp secret")
+	require.ErrorContains(t, p.DeleteTOTPSecret(ctx), "could not delete totp secret")
+	require.ErrorContains(t, s.Set(ctx, sqlstore.Record{UserID: "john"}), "could not set totp secret")
+
+	_, err := s.UserIDs(ctx)
+	require.ErrorContains(t, err, "could not list users")
+}
+
+func TestStore_Cipher(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	db := openDB(t)
+
+	c, err := sqlstore.NewAESGCMCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	s := newStore(t, db, sqlstore.WithCipher(c))
+
+	require.NoError(t, s.TOTPSecretProvider("john").SetTOTPSecret(ctx, "NBSWY3DP", ""))
+
+	r, err := s.Get(ctx, "john")
+	require.NoError(t, err)
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), r.Secret)
+
+	var raw string
+
+	err = db.QueryRowContext(ctx, `SELECT secret FROM totp_secrets WHERE user_id = 'john'`).Scan(&raw)
+	require.NoError(t, err)
+	assert.NotContains(t, raw, "NBSWY3DP")
+
+	// Wrong key.
+	other, err := sqlstore.NewAESGCMCipher([]byte("fedcba9876543210"))
+	require.NoError(t, err)
+
+	_, err = sqlstore.New(db, sqlstore.WithCipher(other)).Get(ctx, "john")
+	require.ErrorIs(t, err, sqlstore.ErrInvalidCiphertext)
+
+	// Plaintext.
+	require.NoError(t, sqlstore.New(db).Set(ctx, sqlstore.Record{UserID: "jane", Secret: "NBSWY3DP"}))
+
+	_, err = s.Get(ctx, "jane")
+	require.ErrorIs(t, err, sqlstore.ErrInvalidCiphertext)
+
+	// A ciphertext copied to the row of another user does not decrypt.
+	_, err = db.ExecContext(ctx, `UPDATE totp_secrets SET secret = ? WHERE user_id = 'jane'`, raw)
+	require.NoError(t, err)
+
+	_, err = s.Get(ctx, "jane")
+	require.ErrorIs(t, err, sqlstore.ErrInvalidCiphertext)
+}
+
+func TestNewAESGCMCipher_InvalidKey(t *testing.T) {
+	t.Parallel()
+
+	_, err := sqlstore.NewAESGCMCipher([]byte("short"))
+	require.ErrorContains(t, err, "could not create cipher")
+}
+
+func TestAESGCMCipher_Decrypt_Short(t *testing.T) {
+	t.Parallel()
+
+	c, err := sqlstore.NewAESGCMCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	_, err = c.Decrypt([]byte("short"), nil)
+	require.ErrorIs(t, err, sqlstore.ErrInvalidCiphertext)
+}
+
+func TestLimiterStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ls := newStore(t, openDB(t)).LimiterStore()
+
+	state, err := ls.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, state.IsZero())
+
+	expected := limiter.State{Failures: 3, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}
+
+	require.NoError(t, ls.SaveState(ctx, "john", expected))
+	require.NoError(t, ls.SaveState(ctx, "jane", limiter.State{Failures: 1}))
+
+	state, err = ls.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.Equal(t, expected, state)
+
+	state, err = ls.LoadState(ctx, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, limiter.State{Failures: 1}, state)
+
+	require.NoError(t, ls.DeleteState(ctx, "john"))
+
+	state, err = ls.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.True(t, state.IsZero())
+}
+
+func TestLimiterStore_CompareAndSwapState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ls := newStore(t, openDB(t)).LimiterStore()
+	first := limiter.State{Failures: 1, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 1, 0, time.UTC)}
+	second := limiter.State{Failures: 2, LockedUntil: time.Date(2024, time.January, 1, 0, 0, 2, 0, time.UTC)}
+
+	swapped, err := ls.CompareAndSwapState(ctx, "john", limiter.State{}, first)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	// Another instance has recorded a failure in the meantime.
+	swapped, err = ls.CompareAndSwapState(ctx, "john", limiter.State{}, first)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = ls.CompareAndSwapState(ctx, "john", second, first)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = ls.CompareAndSwapState(ctx, "john", first, second)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	state, err := ls.LoadState(ctx, "john")
+	require.NoError(t, err)
+	assert.Equal(t, second, state)
+
+	// A zero state left in the table is matched by a zero old state.
+	require.NoError(t, ls.SaveState(ctx, "jane", limiter.State{}))
+
+	swapped, err = ls.CompareAndSwapState(ctx, "jane", limiter.State{}, first)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+}
+
+func TestLimiterStore_Error(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ls := sqlstore.New(openDB(t)).LimiterStore()
+
+	_, err := ls.LoadState(ctx, "john")
+	require.ErrorContains(t, err, "could not get limiter state")
+
+	require.ErrorContains(t, ls.SaveState(ctx, "john", limiter.State{}), "could not save limiter state")
+
+	_, err = ls.CompareAndSwapState(ctx, "john", limiter.State{}, limiter.State{Failures: 1})
+	require.ErrorContains(t, err, "could not save limiter state")
+	require.ErrorContains(t, ls.DeleteState(ctx, "john"), "could not delete limiter state")
+}
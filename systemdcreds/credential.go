@@ -0,0 +1,178 @@
+package systemdcreds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+)
+
+// EnvCredentialsDirectory is the environment variable that systemd sets to the directory of the credentials.
+const EnvCredentialsDirectory = "CREDENTIALS_DIRECTORY"
+
+// ErrNoCredentials indicates that the unit was not started with credentials, $CREDENTIALS_DIRECTORY is not set.
+var ErrNoCredentials = errors.New("unit was not started with credentials")
+
+// ErrInvalidCredentialName indicates that the name of the credential is not valid.
+var ErrInvalidCredentialName = errors.New("invalid credential name")
+
+// ErrCredentialNotFound indicates that the unit does not have the credential.
+var ErrCredentialNotFound = errors.New("credential not found")
+
+var _ otp.TOTPSecretGetter = (*Credential)(nil)
+
+// Credential is a TOTP secret getter that reads the TOTP secret from a credential that is passed to the unit with
+// LoadCredential= or LoadCredentialEncrypted=. For example, with:
+//
+//	[Service]
+//	LoadCredentialEncrypted=otp-secret:/etc/credstore.encrypted/otp-secret
+//
+// the TOTP secret is read from $CREDENTIALS_DIRECTORY/otp-secret.
+type Credential struct {
+	name   string
+	dir    string
+	logger ctxd.Logger
+	opts   []secretfile.Option
+
+	mu   sync.Mutex
+	file *secretfile.File
+}
+
+// Name returns the name of the credential.
+func (c *Credential) Name() string {
+	return c.name
+}
+
+// TOTPSecret returns the TOTP secret from the credential. It returns otp.NoTOTPSecret if the unit was not started
+// with the credential, so the next getter in a chain is used. See Load for the error.
+func (c *Credential) TOTPSecret(ctx context.Context) otp.TOTPSecret {
+	s, err := c.Load(ctx)
+
+	switch {
+	case errors.Is(err, ErrNoCredentials), errors.Is(err, ErrCredentialNotFound):
+		c.logger.Debug(ctx, "no totp secret in systemd credentials", "error", err, "credential", c.name)
+
+	case err != nil:
+		c.logger.Error(ctx, "could not get totp secret from systemd credentials", "error", err, "credential", c.name)
+	}
+
+	return s
+}
+
+// Load returns the TOTP secret from the credential. It returns ErrNoCredentials if the unit was not started with
+// credentials, or ErrCredentialNotFound if the unit does not have the credential.
+func (c *Credential) Load(ctx context.Context) (otp.TOTPSecret, error) {
+	if !validName(c.name) {
+		return otp.NoTOTPSecret, fmt.Errorf("could not load systemd credential: %w: %q", ErrInvalidCredentialName, c.name)
+	}
+
+	dir := c.dir
+	if dir == "" {
+		dir = os.Getenv(EnvCredentialsDirectory)
+	}
+
+	if dir == "" {
+		return otp.NoTOTPSecret, fmt.Errorf("could not load systemd credential %q: %w", c.name, ErrNoCredentials)
+	}
+
+	s, err := c.fileIn(dir).Load(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		return otp.NoTOTPSecret, fmt.Errorf("could not load systemd credential %q: %w", c.name, ErrCredentialNotFound)
+	}
+
+	if err != nil {
+		return otp.NoTOTPSecret, fmt.Errorf("could not load systemd credential %q: %w", c.name, err)
+	}
+
+	return s, nil
+}
+
+func (c *Credential) fileIn(dir string) *secretfile.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := filepath.Join(dir, c.name)
+
+	if c.file == nil || c.file.Path() != path {
+		if c.file != nil {
+			c.file.Destroy()
+		}
+
+		c.file = secretfile.TOTPSecretFromFile(path, c.opts...)
+	}
+
+	return c.file
+}
+
+// Destroy zeroes the TOTP secret kept in memory.
+func (c *Credential) Destroy() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file != nil {
+		c.file.Destroy()
+	}
+}
+
+// TOTPSecretGetter returns otp.TOTPSecretGetter.
+func (c *Credential) TOTPSecretGetter() otp.TOTPSecretGetter {
+	return c
+}
+
+// TOTPSecretFromCredential returns a TOTP secret getter that reads the TOTP secret from the systemd credential.
+func TOTPSecretFromCredential(name string, opts ...Option) *Credential {
+	c := &Credential{
+		name:   name,
+		logger: ctxd.NoOpLogger{},
+	}
+
+	for _, opt := range opts {
+		opt.applyCredentialOption(c)
+	}
+
+	return c
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
+
+// Option configures Credential.
+type Option interface {
+	applyCredentialOption(c *Credential)
+}
+
+type optionFunc func(c *Credential)
+
+func (f optionFunc) applyCredentialOption(c *Credential) {
+	f(c)
+}
+
+// WithDirectory sets the directory of the credentials instead of $CREDENTIALS_DIRECTORY.
+func WithDirectory(dir string) Option {
+	return optionFunc(func(c *Credential) {
+		c.dir = dir
+	})
+}
+
+// WithLogger sets the logger. The TOTP secrets are redacted from the logs.
+func WithLogger(l ctxd.Logger) Option {
+	return optionFunc(func(c *Credential) {
+		c.logger = otp.RedactLogger(l)
+	})
+}
+
+// WithPermissions sets the permissions that the credential file may have, see secretfile.WithPermissions.
+func WithPermissions(perm os.FileMode) Option {
+	return optionFunc(func(c *Credential) {
+		c.opts = append(c.opts, secretfile.WithPermissions(perm))
+	})
+}
@@ -0,0 +1,147 @@
+//go:build unit || !integration
+
+package systemdcreds_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/secretfile"
+	"go.nhat.io/otp/systemdcreds"
+)
+
+func writeCredential(t *testing.T, dir, name, content string, perm os.FileMode) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	require.NoError(t, os.WriteFile(path, []byte(content), perm))
+	require.NoError(t, os.Chmod(path, perm))
+}
+
+func TestCredential_Load(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeCredential(t, dir, "otp-secret", "NBSWY3DP\n", 0o400)
+	writeCredential(t, dir, "unsafe", "NBSWY3DP\n", 0o666)
+
+	testCases := []struct {
+		scenario       string
+		name           string
+		opts           []systemdcreds.Option
+		expectedResult otp.TOTPSecret
+		expectedError  error
+	}{
+		{
+			scenario:      "no credentials",
+			name:          "otp-secret",
+			expectedError: systemdcreds.ErrNoCredentials,
+		},
+		{
+			scenario:      "invalid name",
+			name:          "../otp-secret",
+			opts:          []systemdcreds.Option{systemdcreds.WithDirectory(dir)},
+			expectedError: systemdcreds.ErrInvalidCredentialName,
+		},
+		{
+			scenario:      "not found",
+			name:          "missing",
+			opts:          []systemdcreds.Option{systemdcreds.WithDirectory(dir)},
+			expectedError: systemdcreds.ErrCredentialNotFound,
+		},
+		{
+			scenario:      "unsafe permissions",
+			name:          "unsafe",
+			opts:          []systemdcreds.Option{systemdcreds.WithDirectory(dir)},
+			expectedError: secretfile.ErrUnsafePermissions,
+		},
+		{
+			scenario:       "strict permissions",
+			name:           "otp-secret",
+			opts:           []systemdcreds.Option{systemdcreds.WithDirectory(dir), systemdcreds.WithPermissions(0o400)},
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "found",
+			name:           "otp-secret",
+			opts:           []systemdcreds.Option{systemdcreds.WithDirectory(dir)},
+			expectedResult: "NBSWY3DP",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := systemdcreds.TOTPSecretFromCredential(tc.name, tc.opts...)
+
+			actual, err := c.Load(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+			assert.Equal(t, tc.name, c.Name())
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestCredential_TOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeCredential(t, dir, "unsafe", "NBSWY3DP\n", 0o666)
+
+	l := &ctxd.LoggerMock{}
+
+	assert.Equal(t, otp.NoTOTPSecret, systemdcreds.TOTPSecretFromCredential("missing",
+		systemdcreds.WithDirectory(dir),
+		systemdcreds.WithLogger(l),
+	).TOTPSecret(context.Background()))
+
+	assert.Equal(t, otp.NoTOTPSecret, systemdcreds.TOTPSecretFromCredential("unsafe",
+		systemdcreds.WithDirectory(dir),
+		systemdcreds.WithLogger(l),
+	).TOTPSecret(context.Background()))
+
+	require.Len(t, l.LoggedEntries, 2)
+	assert.Equal(t, "debug", l.LoggedEntries[0].Level)
+	assert.Equal(t, "error", l.LoggedEntries[1].Level)
+}
+
+func TestCredential_ChainTOTPSecretGetters(t *testing.T) { //nolint: paralleltest
+	dir := t.TempDir()
+
+	writeCredential(t, dir, "otp-secret", "NBSWY3DP\n", 0o400)
+
+	c := systemdcreds.TOTPSecretFromCredential("otp-secret")
+	g := otp.ChainTOTPSecretGetters(c, otp.TOTPSecret("JBSWY3DP"))
+
+	t.Setenv(systemdcreds.EnvCredentialsDirectory, "")
+
+	assert.Equal(t, otp.TOTPSecret("JBSWY3DP"), g.TOTPSecret(context.Background()))
+
+	t.Setenv(systemdcreds.EnvCredentialsDirectory, dir)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), g.TOTPSecret(context.Background()))
+
+	c.Destroy()
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), c.TOTPSecret(context.Background()))
+	assert.Equal(t, c, c.TOTPSecretGetter())
+}
@@ -0,0 +1,2 @@
+// Package systemdcreds provides totp secrets from the systemd credentials, see systemd.exec(5).
+package systemdcreds
@@ -1,13 +1,24 @@
 package otp
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/pquerna/otp/totp"
+	"github.com/bool64/ctxd"
+	"github.com/pquerna/otp"
 	"go.nhat.io/clock"
+
+	"go.nhat.io/otp/internal/zero"
 )
 
 // ErrTOTPSecretReadOnly indicates that the TOTP secret is read-only.
@@ -16,27 +27,75 @@ var ErrTOTPSecretReadOnly = errors.New("totp secret is read-only")
 // ErrNoTOTPSecret indicates that the user has not configured the TOTP secret.
 var ErrNoTOTPSecret = errors.New("no totp secret")
 
+// ErrRedactedTOTPSecret indicates that the TOTP secret to unmarshal has been redacted.
+var ErrRedactedTOTPSecret = errors.New("totp secret is redacted")
+
+// TOTPPeriod is the time step of a TOTP.
+const TOTPPeriod = 30 * time.Second
+
 // NoTOTPSecret is a TOTP secret that is empty.
 const NoTOTPSecret = TOTPSecret("")
 
-// TOTPSecret is a TOTP secret.
+// TOTPSecret is a TOTP secret. It is redacted when it is formatted, logged or marshaled, use Reveal to get the
+// secret.
 type TOTPSecret string
 
-// MarshalText returns the TOTP secret as text.
+// Reveal returns the TOTP secret as is.
+func (s TOTPSecret) Reveal() string {
+	return string(s)
+}
+
+// MarshalText returns the redacted TOTP secret as text. Use ExportedTOTPSecret to marshal the secret as is.
 func (s TOTPSecret) MarshalText() ([]byte, error) { //nolint: unparam
-	return []byte(s), nil
+	return []byte(s.String()), nil
 }
 
-// UnmarshalText unmarshals the TOTP secret from text.
-func (s *TOTPSecret) UnmarshalText(text []byte) error { //nolint: unparam
+// MarshalJSON returns the redacted TOTP secret as a JSON string. Use ExportedTOTPSecret to marshal the secret as is.
+func (s TOTPSecret) MarshalJSON() ([]byte, error) {
+	text, _ := s.MarshalText() //nolint: errcheck
+
+	return json.Marshal(string(text))
+}
+
+// UnmarshalText unmarshals the TOTP secret from text. It returns ErrRedactedTOTPSecret if the text has been redacted.
+func (s *TOTPSecret) UnmarshalText(text []byte) error {
+	if string(text) == Redacted {
+		return ErrRedactedTOTPSecret
+	}
+
 	*s = TOTPSecret(text)
 
 	return nil
 }
 
-// String returns the TOTP secret as a string.
+// String returns the redacted TOTP secret, or an empty string if there is no secret.
 func (s TOTPSecret) String() string {
-	return string(s)
+	if s == NoTOTPSecret {
+		return ""
+	}
+
+	return Redacted
+}
+
+// GoString returns the redacted TOTP secret in Go syntax.
+func (s TOTPSecret) GoString() string {
+	return fmt.Sprintf("otp.TOTPSecret(%q)", s.String())
+}
+
+// Format formats the redacted TOTP secret.
+func (s TOTPSecret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = fmt.Fprint(f, s.GoString())
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), s.String())
+}
+
+// LogValue returns the redacted TOTP secret for log/slog.
+func (s TOTPSecret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
 }
 
 // TOTPSecret returns the TOTP secret.
@@ -59,6 +118,61 @@ func (s TOTPSecret) TOTPSecretGetter() TOTPSecretGetter {
 	return s
 }
 
+// ExportedTOTPSecret is a TOTP secret that is marshaled as is, as text, JSON or YAML, so that a config or a storage
+// that holds it round-trips. It is still redacted when it is formatted or logged.
+type ExportedTOTPSecret TOTPSecret
+
+// Reveal returns the TOTP secret as is.
+func (s ExportedTOTPSecret) Reveal() string {
+	return string(s)
+}
+
+// MarshalText returns the TOTP secret as text, as is.
+func (s ExportedTOTPSecret) MarshalText() ([]byte, error) { //nolint: unparam
+	return []byte(s), nil
+}
+
+// MarshalJSON returns the TOTP secret as a JSON string, as is.
+func (s ExportedTOTPSecret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+// UnmarshalText unmarshals the TOTP secret from text. It returns ErrRedactedTOTPSecret if the text has been redacted.
+func (s *ExportedTOTPSecret) UnmarshalText(text []byte) error {
+	return (*TOTPSecret)(s).UnmarshalText(text)
+}
+
+// String returns the redacted TOTP secret, or an empty string if there is no secret.
+func (s ExportedTOTPSecret) String() string {
+	return TOTPSecret(s).String()
+}
+
+// GoString returns the redacted TOTP secret in Go syntax.
+func (s ExportedTOTPSecret) GoString() string {
+	return fmt.Sprintf("otp.ExportedTOTPSecret(%q)", s.String())
+}
+
+// Format formats the redacted TOTP secret.
+func (s ExportedTOTPSecret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = fmt.Fprint(f, s.GoString())
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), s.String())
+}
+
+// LogValue returns the redacted TOTP secret for log/slog.
+func (s ExportedTOTPSecret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// TOTPSecret returns the TOTP secret.
+func (s ExportedTOTPSecret) TOTPSecret(context.Context) TOTPSecret {
+	return TOTPSecret(s)
+}
+
 type totpSecreteSurrogate struct {
 	secret TOTPSecret
 }
@@ -123,6 +237,18 @@ func (p TOTPSecretGetters) TOTPSecretGetter() TOTPSecretGetter {
 	return p
 }
 
+// WithLogger returns a ProviderChain that reads the getters in the same order, and logs which one supplied the TOTP
+// secret.
+func (p TOTPSecretGetters) WithLogger(l ctxd.Logger) *ProviderChain {
+	c := NewProviderChain().WithLogger(l)
+
+	for _, g := range p {
+		c.ReadOnly(g)
+	}
+
+	return c
+}
+
 // ChainTOTPSecretGetters chains the TOTP secret getters.
 func ChainTOTPSecretGetters(getters ...TOTPSecretGetter) TOTPSecretGetters {
 	result := make(TOTPSecretGetters, 0, len(getters))
@@ -191,6 +317,18 @@ func (ps TOTPSecretProviders) TOTPSecretDeleter() TOTPSecretDeleter {
 	return ps
 }
 
+// WithLogger returns a ProviderChain that reads and writes the providers in the same order, and logs which one
+// supplied the TOTP secret.
+func (ps TOTPSecretProviders) WithLogger(l ctxd.Logger) *ProviderChain {
+	c := NewProviderChain().WithLogger(l)
+
+	for _, p := range ps {
+		c.ReadWrite(p)
+	}
+
+	return c
+}
+
 // ChainTOTPSecretProviders chains the TOTP secret providers.
 func ChainTOTPSecretProviders(providers ...TOTPSecretProvider) TOTPSecretProviders {
 	result := make(TOTPSecretProviders, 0, len(providers))
@@ -217,6 +355,11 @@ type EnvTOTPSecret struct {
 	env string
 }
 
+// Env returns the name of the environment variable.
+func (e EnvTOTPSecret) Env() string {
+	return e.env
+}
+
 // TOTPSecret returns the TOTP secret from the environment.
 func (e EnvTOTPSecret) TOTPSecret(_ context.Context) TOTPSecret {
 	return TOTPSecret(os.Getenv(e.env))
@@ -247,7 +390,8 @@ func (e EnvTOTPSecret) TOTPSecretDeleter() TOTPSecretDeleter {
 	return e
 }
 
-// TOTPSecretFromEnv returns a TOTP secret getter that gets the TOTP secret from the environment.
+// TOTPSecretFromEnv returns a TOTP secret getter that gets the TOTP secret from the environment. The variable may hold
+// a bare secret or an otpauth URI.
 func TOTPSecretFromEnv(env string) EnvTOTPSecret {
 	return EnvTOTPSecret{
 		env: env,
@@ -260,21 +404,126 @@ var _ Generator = (*TOTPGenerator)(nil)
 type TOTPGenerator struct {
 	secretGetter TOTPSecretGetter
 	clock        clock.Clock
+	logger       ctxd.Logger
 }
 
 // GenerateOTP generates a TOTP.
 func (g *TOTPGenerator) GenerateOTP(ctx context.Context) (OTP, error) {
+	return g.generateOTP(ctx, 0)
+}
+
+// GenerateNextOTP generates the TOTP of the next time step.
+func (g *TOTPGenerator) GenerateNextOTP(ctx context.Context) (OTP, error) {
+	return g.generateOTP(ctx, 1)
+}
+
+// generateOTP generates the TOTP of the current time step plus the given number of steps. The TOTP secret may be an
+// otpauth URI, its digits, period, algorithm and encoder are honored.
+func (g *TOTPGenerator) generateOTP(ctx context.Context, steps int) (OTP, error) {
 	s := g.secretGetter.TOTPSecret(ctx)
 	if s == NoTOTPSecret {
+		g.logger.Error(ctx, "could not generate otp", "error", ErrNoTOTPSecret)
+
 		return "", fmt.Errorf("could not generate otp: %w", ErrNoTOTPSecret)
 	}
 
-	code, err := totp.GenerateCode(string(s), g.clock.Now())
+	s, params, err := parseTOTPParams(s)
 	if err != nil {
+		g.logger.Error(ctx, "could not generate otp", "error", err)
+
 		return "", fmt.Errorf("could not generate otp: %w", err)
 	}
 
-	return OTP(code), nil
+	key, err := decodeTOTPSecret(s)
+	if err != nil {
+		g.logger.Error(ctx, "could not generate otp", "error", err)
+
+		return "", fmt.Errorf("could not generate otp: %w", err)
+	}
+
+	defer key.Destroy()
+
+	var code OTP
+
+	_ = key.Use(func(key []byte) error { //nolint: errcheck
+		code = totpCode(key, g.clock.Now().Add(time.Duration(steps)*params.period), params)
+
+		return nil
+	})
+
+	return code, nil
+}
+
+// decodeTOTPSecret decodes the HMAC key of the TOTP secret into a SecretBuffer.
+func decodeTOTPSecret(s TOTPSecret) (*SecretBuffer, error) {
+	encoded := []byte(strings.ToUpper(strings.TrimSpace(string(s))))
+	defer zero.Bytes(encoded)
+
+	if n := len(encoded) % 8; n != 0 {
+		encoded = append(encoded, bytes.Repeat([]byte{'='}, 8-n)...)
+	}
+
+	key, err := NewSecretBuffer(base32.StdEncoding.DecodedLen(len(encoded)))
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+
+	err = key.Use(func(key []byte) error {
+		n, err = base32.StdEncoding.Decode(key, encoded)
+
+		return err
+	})
+	if err != nil {
+		key.Destroy()
+
+		return nil, otp.ErrValidateSecretInvalidBase32
+	}
+
+	key.truncate(n)
+
+	return key, nil
+}
+
+// totpCode generates a TOTP (RFC 6238).
+func totpCode(key []byte, t time.Time, params totpParams) OTP {
+	var counter [8]byte
+
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(params.period.Seconds()))) //nolint: gosec
+
+	mac := hmac.New(params.algorithm.Hash, key)
+	_, _ = mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	if params.encoder == otp.EncoderSteam {
+		return steamCode(value, params.digits.Length())
+	}
+
+	mod := uint32(1)
+	for range params.digits.Length() {
+		mod *= 10
+	}
+
+	return OTP(params.digits.Format(int32(value % mod))) //nolint: gosec
+}
+
+// steamAlphabet is the alphabet of the Steam Guard codes.
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// steamCode encodes the truncated HMAC as a Steam Guard code.
+func steamCode(value uint32, length int) OTP {
+	code := make([]byte, length)
+
+	for i := range code {
+		code[i] = steamAlphabet[value%uint32(len(steamAlphabet))]
+		value /= uint32(len(steamAlphabet))
+	}
+
+	return OTP(code)
 }
 
 // NewTOTPGenerator initiates a new .TOTPGenerator.
@@ -282,6 +531,7 @@ func NewTOTPGenerator(secretGetter TOTPSecretGetter, opts ...TOTPGeneratorOption
 	g := &TOTPGenerator{
 		secretGetter: secretGetter,
 		clock:        clock.New(),
+		logger:       ctxd.NoOpLogger{},
 	}
 
 	for _, opt := range opts {
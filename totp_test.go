@@ -3,13 +3,18 @@
 package otp_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.nhat.io/clock"
+	"gopkg.in/yaml.v3"
 
 	"go.nhat.io/otp"
 	"go.nhat.io/otp/mock"
@@ -23,12 +28,116 @@ func TestTOTPSecret_MarshalText(t *testing.T) {
 	data, err := s.MarshalText()
 	require.NoError(t, err)
 
+	assert.Equal(t, otp.Redacted, string(data))
+
 	var s2 otp.TOTPSecret
 
 	err = s2.UnmarshalText(data)
+	require.ErrorIs(t, err, otp.ErrRedactedTOTPSecret)
+
+	assert.Empty(t, s2)
+}
+
+func TestTOTPSecret_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Account string         `json:"account"`
+		Secret  otp.TOTPSecret `json:"secret"`
+		Empty   otp.TOTPSecret `json:"empty"`
+	}
+
+	data, err := json.Marshal(config{Account: "john", Secret: "secret"})
 	require.NoError(t, err)
 
-	assert.Equal(t, s, s2)
+	assert.JSONEq(t, `{"account":"john","secret":"[REDACTED]","empty":""}`, string(data))
+
+	var c config
+
+	err = json.Unmarshal(data, &c)
+	require.ErrorIs(t, err, otp.ErrRedactedTOTPSecret)
+}
+
+func TestExportedTOTPSecret_MarshalText(t *testing.T) {
+	t.Parallel()
+
+	s := otp.ExportedTOTPSecret("secret")
+
+	data, err := s.MarshalText()
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret", string(data))
+
+	var s2 otp.ExportedTOTPSecret
+
+	err = s2.UnmarshalText(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret", s2.Reveal())
+	assert.Equal(t, otp.TOTPSecret("secret"), s2.TOTPSecret(context.Background()))
+
+	err = s2.UnmarshalText([]byte(otp.Redacted))
+	require.ErrorIs(t, err, otp.ErrRedactedTOTPSecret)
+}
+
+func TestExportedTOTPSecret_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Account string                 `json:"account" yaml:"account"`
+		Secret  otp.ExportedTOTPSecret `json:"secret" yaml:"secret"`
+		Empty   otp.ExportedTOTPSecret `json:"empty" yaml:"empty"`
+	}
+
+	expected := config{Account: "john", Secret: "NBSWY3DP"}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		data, err := json.Marshal(expected)
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{"account":"john","secret":"NBSWY3DP","empty":""}`, string(data))
+
+		var actual config
+
+		err = json.Unmarshal(data, &actual)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.Secret.Reveal(), actual.Secret.Reveal())
+		assert.Equal(t, expected.Account, actual.Account)
+		assert.Empty(t, actual.Empty.Reveal())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		t.Parallel()
+
+		data, err := yaml.Marshal(expected)
+		require.NoError(t, err)
+
+		assert.YAMLEq(t, "account: john\nsecret: NBSWY3DP\nempty: \"\"\n", string(data))
+
+		var actual config
+
+		err = yaml.Unmarshal(data, &actual)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.Secret.Reveal(), actual.Secret.Reveal())
+		assert.Equal(t, expected.Account, actual.Account)
+		assert.Empty(t, actual.Empty.Reveal())
+	})
+}
+
+func TestExportedTOTPSecret_String(t *testing.T) {
+	t.Parallel()
+
+	s := otp.ExportedTOTPSecret("secret")
+
+	assert.Equal(t, otp.Redacted, s.String())
+	assert.Equal(t, otp.Redacted, fmt.Sprintf("%v", s))
+	assert.Equal(t, `"[REDACTED]"`, fmt.Sprintf("%q", s))
+	assert.Equal(t, `otp.ExportedTOTPSecret("[REDACTED]")`, fmt.Sprintf("%#v", s))
+	assert.Equal(t, otp.Redacted, s.LogValue().String())
 }
 
 func TestTOTPSecret_String(t *testing.T) {
@@ -36,7 +145,59 @@ func TestTOTPSecret_String(t *testing.T) {
 
 	s := otp.TOTPSecret("secret")
 
-	assert.Equal(t, "secret", s.String())
+	assert.Equal(t, otp.Redacted, s.String())
+	assert.Empty(t, otp.NoTOTPSecret.String())
+	assert.Equal(t, "secret", s.Reveal())
+}
+
+func TestTOTPSecret_Format(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		Account string
+		Secret  otp.TOTPSecret
+	}
+
+	s := otp.TOTPSecret("secret")
+	c := config{Account: "john", Secret: s}
+
+	testCases := []struct {
+		format   string
+		value    any
+		expected string
+	}{
+		{format: "%s", value: s, expected: "[REDACTED]"},
+		{format: "%v", value: s, expected: "[REDACTED]"},
+		{format: "%q", value: s, expected: `"[REDACTED]"`},
+		{format: "%12s|", value: s, expected: "  [REDACTED]|"},
+		{format: "%#v", value: s, expected: `otp.TOTPSecret("[REDACTED]")`},
+		{format: "%v", value: c, expected: "{john [REDACTED]}"},
+		{format: "%+v", value: c, expected: "{Account:john Secret:[REDACTED]}"},
+		{format: "%#v", value: c, expected: `otp_test.config{Account:"john", Secret:otp.TOTPSecret("[REDACTED]")}`},
+		{format: "%x", value: s, expected: "5b52454441435445445d"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, fmt.Sprintf(tc.format, tc.value))
+		})
+	}
+}
+
+func TestTOTPSecret_LogValue(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	l := slog.New(slog.NewJSONHandler(buf, nil))
+
+	l.Info("config", "secret", otp.TOTPSecret("secret"), "account", "john")
+
+	assert.Contains(t, buf.String(), `"secret":"[REDACTED]","account":"john"`)
+	assert.NotContains(t, buf.String(), `"secret":"secret"`)
 }
 
 func TestTOTPSecret_TOTPSecret(t *testing.T) {
@@ -366,3 +527,129 @@ func TestGenerateTOTP(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, otp.OTP("191882"), result)
 }
+
+func TestTOTPGenerator_GenerateNextOTP(t *testing.T) {
+	t.Parallel()
+
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	g := otp.NewTOTPGenerator(otp.TOTPSecret("NBSWY3DP"), otp.WithClock(c))
+
+	result, err := g.GenerateNextOTP(context.Background())
+	require.NoError(t, err)
+
+	expected, err := otp.GenerateTOTP(context.Background(), otp.TOTPSecret("NBSWY3DP"),
+		otp.WithClock(clock.Fix(c.Now().Add(otp.TOTPPeriod))),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, result)
+	assert.NotEqual(t, otp.OTP("191882"), result)
+}
+
+func TestTOTPGenerator_GenerateOTP_URI(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sha1Secret   = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		sha256Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"
+		sha512Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA"
+	)
+
+	// The test vectors of RFC 6238.
+	testCases := []struct {
+		scenario       string
+		secret         otp.TOTPSecret
+		time           time.Time
+		expectedResult otp.OTP
+		expectedError  string
+	}{
+		{
+			scenario:       "default parameters",
+			secret:         "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example",
+			time:           time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			expectedResult: "191882",
+		},
+		{
+			scenario:       "sha1",
+			secret:         "otpauth://totp/john?secret=" + sha1Secret + "&digits=8&algorithm=SHA1",
+			time:           time.Unix(59, 0),
+			expectedResult: "94287082",
+		},
+		{
+			scenario:       "sha256",
+			secret:         "otpauth://totp/john?secret=" + sha256Secret + "&digits=8&algorithm=SHA256",
+			time:           time.Unix(1111111109, 0),
+			expectedResult: "68084774",
+		},
+		{
+			scenario:       "sha512",
+			secret:         "otpauth://totp/john?secret=" + sha512Secret + "&digits=8&algorithm=SHA512",
+			time:           time.Unix(1234567890, 0),
+			expectedResult: "93441116",
+		},
+		{
+			scenario:       "period",
+			secret:         "otpauth://totp/john?secret=" + sha1Secret + "&digits=8&period=60",
+			time:           time.Unix(119, 0),
+			expectedResult: "94287082",
+		},
+		{
+			scenario:       "steam",
+			secret:         "otpauth://totp/Steam:john?secret=NBSWY3DP&issuer=Steam&digits=5&encoder=steam",
+			time:           time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+			expectedResult: "485B8",
+		},
+		{
+			scenario:      "unsupported digits",
+			secret:        "otpauth://totp/john?secret=NBSWY3DP&digits=10",
+			time:          time.Unix(59, 0),
+			expectedError: "could not generate otp: invalid otpauth uri: unsupported digits 10",
+		},
+		{
+			scenario:      "unsupported period",
+			secret:        "otpauth://totp/john?secret=NBSWY3DP&period=0",
+			time:          time.Unix(59, 0),
+			expectedError: "could not generate otp: invalid otpauth uri: unsupported period 0",
+		},
+		{
+			scenario:      "hotp",
+			secret:        "otpauth://hotp/john?secret=NBSWY3DP&counter=1",
+			time:          time.Unix(59, 0),
+			expectedError: `could not generate otp: invalid otpauth uri: unsupported type "hotp"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := otp.GenerateTOTP(context.Background(), tc.secret, otp.WithClock(clock.Fix(tc.time)))
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+
+			assert.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func TestTOTPGenerator_GenerateNextOTP_URI(t *testing.T) {
+	t.Parallel()
+
+	secret := otp.TOTPSecret("otpauth://totp/john?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8&period=60")
+	g := otp.NewTOTPGenerator(secret, otp.WithClock(clock.Fix(time.Unix(59, 0))))
+
+	result, err := g.GenerateNextOTP(context.Background())
+	require.NoError(t, err)
+
+	expected, err := otp.GenerateTOTP(context.Background(), secret, otp.WithClock(clock.Fix(time.Unix(119, 0))))
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, result)
+	assert.Equal(t, otp.OTP("94287082"), result)
+}
@@ -0,0 +1,227 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrTOTPSecretRolledBack indicates that the change of a provider was rolled back.
+var ErrTOTPSecretRolledBack = errors.New("rolled back")
+
+// ErrTOTPSecretNotRolledBack indicates that the change of a provider was not rolled back because its previous value
+// could not be read.
+var ErrTOTPSecretNotRolledBack = errors.New("not rolled back")
+
+// ErrTOTPSecretNotAttempted indicates that the provider was not changed because an earlier one failed.
+var ErrTOTPSecretNotAttempted = errors.New("not attempted")
+
+// TOTPSecretProviderError is the outcome of a provider in a list of TOTP secret providers.
+type TOTPSecretProviderError struct {
+	Index int
+	Err   error
+}
+
+// Error returns the error message.
+func (e *TOTPSecretProviderError) Error() string {
+	return fmt.Sprintf("provider #%d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the provider.
+func (e *TOTPSecretProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Transactional returns the providers with transactional semantics.
+func (ps TOTPSecretProviders) Transactional() TransactionalTOTPSecretProviders {
+	return TransactionalTOTPSecretProviders(ps)
+}
+
+// BestEffort returns the providers with best-effort semantics.
+func (ps TOTPSecretProviders) BestEffort() BestEffortTOTPSecretProviders {
+	return BestEffortTOTPSecretProviders(ps)
+}
+
+var _ TOTPSecretProvider = (TransactionalTOTPSecretProviders)(nil)
+
+// TransactionalTOTPSecretProviders is a list of TOTP secret providers that are changed all together or not at all.
+//
+// Before a change, the previous value of each provider is captured. If a provider fails, the providers that were
+// already changed are restored and the returned error lists the outcome of every provider.
+//
+// The previous value is read with the Load(ctx) (TOTPSecret, error) method of the provider if it has one, such as
+// EncryptedTOTPSecretProvider. If it could not be read, the provider is not restored and its outcome is
+// ErrTOTPSecretNotRolledBack, so that an existing TOTP secret is never deleted by mistake. The providers that do not
+// have a Load method, such as the keyring, cannot tell an absent TOTP secret from one that could not be read, so they
+// are only restored if they had a TOTP secret.
+type TransactionalTOTPSecretProviders TOTPSecretProviders
+
+// TOTPSecret returns the first non-empty TOTP secret that it finds from the list of TOTP secret providers.
+func (ps TransactionalTOTPSecretProviders) TOTPSecret(ctx context.Context) TOTPSecret {
+	return TOTPSecretProviders(ps).TOTPSecret(ctx)
+}
+
+// SetTOTPSecret sets the TOTP secret to all the providers, or to none of them.
+func (ps TransactionalTOTPSecretProviders) SetTOTPSecret(ctx context.Context, secret TOTPSecret, issuer string) error {
+	return ps.apply(ctx, issuer, func(p TOTPSecretProvider) error {
+		return p.SetTOTPSecret(ctx, secret, issuer)
+	})
+}
+
+// DeleteTOTPSecret deletes the TOTP secret in all the providers, or in none of them. The issuer is not restored on
+// rollback.
+func (ps TransactionalTOTPSecretProviders) DeleteTOTPSecret(ctx context.Context) error {
+	return ps.apply(ctx, "", func(p TOTPSecretProvider) error {
+		return p.DeleteTOTPSecret(ctx)
+	})
+}
+
+func (ps TransactionalTOTPSecretProviders) apply(ctx context.Context, issuer string, change func(p TOTPSecretProvider) error) error {
+	previous := make([]previousTOTPSecret, len(ps))
+
+	for i, p := range ps {
+		previous[i] = readPreviousTOTPSecret(ctx, p)
+
+		err := change(p)
+		if err == nil {
+			continue
+		}
+
+		errs := make([]error, 0, len(ps))
+
+		for j := range i {
+			errs = append(errs, &TOTPSecretProviderError{Index: j, Err: rollback(ctx, ps[j], previous[j], issuer)})
+		}
+
+		errs = append(errs, &TOTPSecretProviderError{Index: i, Err: err})
+
+		for j := i + 1; j < len(ps); j++ {
+			errs = append(errs, &TOTPSecretProviderError{Index: j, Err: ErrTOTPSecretNotAttempted})
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// errUnknownTOTPSecret indicates that the provider returned no TOTP secret but cannot tell whether it is absent.
+var errUnknownTOTPSecret = errors.New("provider cannot tell whether the totp secret is absent")
+
+// totpSecretLoader is a TOTP secret provider that reports why its TOTP secret could not be read.
+type totpSecretLoader interface {
+	Load(ctx context.Context) (TOTPSecret, error)
+}
+
+// previousTOTPSecret is the value of a provider before a change. The value is unknown if err is not nil.
+type previousTOTPSecret struct {
+	secret TOTPSecret
+	err    error
+}
+
+func readPreviousTOTPSecret(ctx context.Context, p TOTPSecretProvider) previousTOTPSecret {
+	l, ok := p.(totpSecretLoader)
+	if !ok {
+		s := p.TOTPSecret(ctx)
+		if s == NoTOTPSecret {
+			return previousTOTPSecret{err: errUnknownTOTPSecret}
+		}
+
+		return previousTOTPSecret{secret: s}
+	}
+
+	s, err := l.Load(ctx)
+	if errors.Is(err, ErrNoTOTPSecret) || errors.Is(err, fs.ErrNotExist) {
+		return previousTOTPSecret{secret: NoTOTPSecret}
+	}
+
+	return previousTOTPSecret{secret: s, err: err}
+}
+
+func rollback(ctx context.Context, p TOTPSecretProvider, previous previousTOTPSecret, issuer string) error {
+	if previous.err != nil {
+		return fmt.Errorf("%w: could not read the previous totp secret: %w", ErrTOTPSecretNotRolledBack, previous.err)
+	}
+
+	var err error
+
+	if previous.secret == NoTOTPSecret {
+		err = p.DeleteTOTPSecret(ctx)
+	} else {
+		err = p.SetTOTPSecret(ctx, previous.secret, issuer)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not roll back: %w", err)
+	}
+
+	return ErrTOTPSecretRolledBack
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (ps TransactionalTOTPSecretProviders) TOTPSecretGetter() TOTPSecretGetter {
+	return ps
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (ps TransactionalTOTPSecretProviders) TOTPSecretSetter() TOTPSecretSetter {
+	return ps
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (ps TransactionalTOTPSecretProviders) TOTPSecretDeleter() TOTPSecretDeleter {
+	return ps
+}
+
+var _ TOTPSecretProvider = (BestEffortTOTPSecretProviders)(nil)
+
+// BestEffortTOTPSecretProviders is a list of TOTP secret providers that are all changed even if some of them fail.
+// The errors of the failed providers are joined.
+type BestEffortTOTPSecretProviders TOTPSecretProviders
+
+// TOTPSecret returns the first non-empty TOTP secret that it finds from the list of TOTP secret providers.
+func (ps BestEffortTOTPSecretProviders) TOTPSecret(ctx context.Context) TOTPSecret {
+	return TOTPSecretProviders(ps).TOTPSecret(ctx)
+}
+
+// SetTOTPSecret sets the TOTP secret to all the providers.
+func (ps BestEffortTOTPSecretProviders) SetTOTPSecret(ctx context.Context, secret TOTPSecret, issuer string) error {
+	return ps.apply(func(p TOTPSecretProvider) error {
+		return p.SetTOTPSecret(ctx, secret, issuer)
+	})
+}
+
+// DeleteTOTPSecret deletes the TOTP secret in all the providers.
+func (ps BestEffortTOTPSecretProviders) DeleteTOTPSecret(ctx context.Context) error {
+	return ps.apply(func(p TOTPSecretProvider) error {
+		return p.DeleteTOTPSecret(ctx)
+	})
+}
+
+func (ps BestEffortTOTPSecretProviders) apply(change func(p TOTPSecretProvider) error) error {
+	var errs []error
+
+	for i, p := range ps {
+		if err := change(p); err != nil {
+			errs = append(errs, &TOTPSecretProviderError{Index: i, Err: err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// TOTPSecretGetter returns TOTPSecretGetter.
+func (ps BestEffortTOTPSecretProviders) TOTPSecretGetter() TOTPSecretGetter {
+	return ps
+}
+
+// TOTPSecretSetter returns TOTPSecretSetter.
+func (ps BestEffortTOTPSecretProviders) TOTPSecretSetter() TOTPSecretSetter {
+	return ps
+}
+
+// TOTPSecretDeleter returns TOTPSecretDeleter.
+func (ps BestEffortTOTPSecretProviders) TOTPSecretDeleter() TOTPSecretDeleter {
+	return ps
+}
@@ -0,0 +1,186 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+func TestTransactionalTOTPSecretProviders_SetTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	first := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	second := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	failing := mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("TOTPSecret", ctx).Return(otp.NoTOTPSecret)
+		p.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "issuer").Return(assert.AnError)
+	})(t)
+
+	ps := otp.ChainTOTPSecretProviders(first, second, failing, otp.NoTOTPSecret).Transactional()
+
+	assert.Equal(t, ps, ps.TOTPSecretGetter())
+	assert.Equal(t, ps, ps.TOTPSecretSetter())
+	assert.Equal(t, ps, ps.TOTPSecretDeleter())
+
+	err := ps.SetTOTPSecret(ctx, "changed", "issuer")
+
+	require.ErrorIs(t, err, assert.AnError)
+	require.ErrorIs(t, err, otp.ErrTOTPSecretRolledBack)
+	require.ErrorIs(t, err, otp.ErrTOTPSecretNotAttempted)
+
+	require.ErrorIs(t, err, otp.ErrTOTPSecretNotRolledBack)
+
+	// The second provider is not restored because it cannot tell whether its TOTP secret was absent.
+	expected := `provider #0: rolled back
+provider #1: not rolled back: could not read the previous totp secret: provider cannot tell whether the totp secret is absent
+provider #2: assert.AnError general error for testing
+provider #3: not attempted`
+
+	require.EqualError(t, err, expected)
+
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), first.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), second.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("NBSWY3DP"), ps.TOTPSecret(ctx))
+}
+
+func TestTransactionalTOTPSecretProviders_RollbackError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	ps := otp.ChainTOTPSecretProviders(
+		mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+			p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+			p.On("DeleteTOTPSecret", ctx).Return(nil).Once()
+			p.On("SetTOTPSecret", ctx, otp.TOTPSecret("NBSWY3DP"), "").Return(assert.AnError)
+		})(t),
+		mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+			p.On("TOTPSecret", ctx).Return(otp.TOTPSecret("NBSWY3DP"))
+			p.On("DeleteTOTPSecret", ctx).Return(otp.ErrTOTPSecretReadOnly)
+		})(t),
+	).Transactional()
+
+	err := ps.DeleteTOTPSecret(ctx)
+
+	expected := `provider #0: could not roll back: assert.AnError general error for testing
+provider #1: totp secret is read-only`
+
+	require.EqualError(t, err, expected)
+	require.ErrorIs(t, err, otp.ErrTOTPSecretReadOnly)
+}
+
+// loadingTOTPSecretProvider is a TOTP secret provider that reports why its TOTP secret could not be read.
+type loadingTOTPSecretProvider struct {
+	otp.TOTPSecretProvider
+
+	err error
+}
+
+func (p loadingTOTPSecretProvider) Load(context.Context) (otp.TOTPSecret, error) {
+	return otp.NoTOTPSecret, p.err
+}
+
+func TestTransactionalTOTPSecretProviders_UnknownPreviousTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// The TOTP secret is not deleted on rollback because it could not be read before the change.
+	unreadable := loadingTOTPSecretProvider{
+		TOTPSecretProvider: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+			p.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "").Return(nil).Once()
+		})(t),
+		err: assert.AnError,
+	}
+
+	absent := loadingTOTPSecretProvider{
+		TOTPSecretProvider: mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+			p.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "").Return(nil).Once()
+			p.On("DeleteTOTPSecret", ctx).Return(nil).Once()
+		})(t),
+		err: otp.ErrNoTOTPSecret,
+	}
+
+	failing := mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("TOTPSecret", ctx).Return(otp.NoTOTPSecret)
+		p.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "").Return(otp.ErrTOTPSecretReadOnly)
+	})(t)
+
+	err := otp.ChainTOTPSecretProviders(unreadable, absent, failing).Transactional().SetTOTPSecret(ctx, "changed", "")
+
+	expected := `provider #0: not rolled back: could not read the previous totp secret: assert.AnError general error for testing
+provider #1: rolled back
+provider #2: totp secret is read-only`
+
+	require.EqualError(t, err, expected)
+	require.ErrorIs(t, err, otp.ErrTOTPSecretNotRolledBack)
+}
+
+func TestTransactionalTOTPSecretProviders_Success(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	first := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	second := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	ps := otp.ChainTOTPSecretProviders(first, second).Transactional()
+
+	require.NoError(t, ps.SetTOTPSecret(ctx, "changed", ""))
+
+	assert.Equal(t, otp.TOTPSecret("changed"), first.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), second.TOTPSecret(ctx))
+
+	require.NoError(t, ps.DeleteTOTPSecret(ctx))
+
+	assert.Equal(t, otp.NoTOTPSecret, first.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, second.TOTPSecret(ctx))
+}
+
+func TestBestEffortTOTPSecretProviders(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	first := otp.ChainTOTPSecretProviders(otp.TOTPSecret("NBSWY3DP"))
+	last := otp.ChainTOTPSecretProviders(otp.NoTOTPSecret)
+
+	failing := mock.MockTOTPSecretProvider(func(p *mock.TOTPSecretProvider) {
+		p.On("TOTPSecret", ctx).Return(otp.NoTOTPSecret).Maybe()
+		p.On("SetTOTPSecret", ctx, otp.TOTPSecret("changed"), "").Return(assert.AnError)
+		p.On("DeleteTOTPSecret", ctx).Return(otp.ErrTOTPSecretReadOnly)
+	})(t)
+
+	ps := otp.ChainTOTPSecretProviders(first, failing, last).BestEffort()
+
+	assert.Equal(t, ps, ps.TOTPSecretGetter())
+	assert.Equal(t, ps, ps.TOTPSecretSetter())
+	assert.Equal(t, ps, ps.TOTPSecretDeleter())
+
+	err := ps.SetTOTPSecret(ctx, "changed", "")
+	require.EqualError(t, err, "provider #1: assert.AnError general error for testing")
+
+	var pErr *otp.TOTPSecretProviderError
+
+	require.ErrorAs(t, err, &pErr)
+	assert.Equal(t, 1, pErr.Index)
+
+	assert.Equal(t, otp.TOTPSecret("changed"), first.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), last.TOTPSecret(ctx))
+	assert.Equal(t, otp.TOTPSecret("changed"), ps.TOTPSecret(ctx))
+
+	err = ps.DeleteTOTPSecret(ctx)
+	require.ErrorIs(t, err, otp.ErrTOTPSecretReadOnly)
+
+	assert.Equal(t, otp.NoTOTPSecret, first.TOTPSecret(ctx))
+	assert.Equal(t, otp.NoTOTPSecret, last.TOTPSecret(ctx))
+
+	require.NoError(t, otp.ChainTOTPSecretProviders(first, last).BestEffort().SetTOTPSecret(ctx, "changed", ""))
+}
@@ -0,0 +1,189 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrInvalidOTPAuthURI indicates that the otpauth URI is invalid or is not a TOTP URI.
+var ErrInvalidOTPAuthURI = errors.New("invalid otpauth uri")
+
+// IsOTPAuthURI returns true if the value is an otpauth URI.
+func IsOTPAuthURI(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "otpauth://")
+}
+
+// TOTPSecretFromURI checks that the value is an otpauth URI of a TOTP secret, such as
+// "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example", and returns it as is, so that its
+// parameters, such as the digits, the period and the algorithm, are used to generate the codes. Use ParseTOTPURI to
+// get the bare secret.
+func TOTPSecretFromURI(uri string) (TOTPSecret, error) {
+	s := TOTPSecret(strings.TrimSpace(uri))
+
+	if _, _, err := parseTOTPParams(s); err != nil {
+		return NoTOTPSecret, err
+	}
+
+	return s, nil
+}
+
+func totpKeyFromURI(uri string) (*otp.Key, error) {
+	key, err := otp.NewKeyFromURL(uri)
+	if err != nil {
+		// The parse error has the URI, and so the secret.
+		return nil, fmt.Errorf("%w: malformed uri", ErrInvalidOTPAuthURI)
+	}
+
+	if key.Type() != "totp" {
+		return nil, fmt.Errorf("%w: unsupported type %q", ErrInvalidOTPAuthURI, key.Type())
+	}
+
+	if key.Secret() == "" {
+		return nil, fmt.Errorf("%w: missing secret", ErrInvalidOTPAuthURI)
+	}
+
+	return key, nil
+}
+
+// ParseTOTPSecret returns the TOTP secret of a value that is either a bare secret or an otpauth URI. The spaces around
+// the value are trimmed. An otpauth URI is checked and returned as is, see TOTPSecretFromURI.
+func ParseTOTPSecret(s string) (TOTPSecret, error) {
+	s = strings.TrimSpace(s)
+
+	if IsOTPAuthURI(s) {
+		return TOTPSecretFromURI(s)
+	}
+
+	return TOTPSecret(s), nil
+}
+
+// TOTPURI is an otpauth URI of a TOTP secret and its parameters.
+type TOTPURI struct {
+	Issuer      string
+	AccountName string
+	Secret      TOTPSecret
+	// Digits is the number of digits of the codes. Zero means 6.
+	Digits int
+	// Period is the time step of the codes. Zero means 30 seconds.
+	Period time.Duration
+	// Algorithm is the HMAC algorithm, such as "SHA1", "SHA256" or "SHA512". Empty means SHA1.
+	Algorithm string
+	// Encoder is the encoder of the codes, such as "steam". Empty means digits.
+	Encoder string
+}
+
+// Encode returns the otpauth URI, such as
+// "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example". The zero parameters are omitted.
+func (u TOTPURI) Encode() TOTPSecret {
+	label := u.AccountName
+	if u.Issuer != "" {
+		label = u.Issuer + ":" + u.AccountName
+	}
+
+	q := url.Values{}
+	q.Set("secret", u.Secret.Reveal())
+
+	if u.Issuer != "" {
+		q.Set("issuer", u.Issuer)
+	}
+
+	if u.Digits != 0 {
+		q.Set("digits", strconv.Itoa(u.Digits))
+	}
+
+	if u.Period != 0 {
+		q.Set("period", strconv.Itoa(int(u.Period/time.Second)))
+	}
+
+	if u.Algorithm != "" {
+		q.Set("algorithm", strings.ToUpper(u.Algorithm))
+	}
+
+	if u.Encoder != "" {
+		q.Set("encoder", strings.ToLower(u.Encoder))
+	}
+
+	uri := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+
+	return TOTPSecret(uri.String())
+}
+
+// ParseTOTPURI parses an otpauth URI of a TOTP secret. The missing parameters are set to their defaults: 6 digits, 30
+// seconds and SHA1.
+func ParseTOTPURI(uri string) (TOTPURI, error) {
+	key, err := totpKeyFromURI(strings.TrimSpace(uri))
+	if err != nil {
+		return TOTPURI{}, err
+	}
+
+	u := TOTPURI{
+		Issuer:      key.Issuer(),
+		AccountName: key.AccountName(),
+		Secret:      TOTPSecret(key.Secret()),
+		Digits:      key.Digits().Length(),
+		Period:      time.Duration(key.Period()) * time.Second, //nolint: gosec
+		Algorithm:   key.Algorithm().String(),
+	}
+
+	if key.Encoder() == otp.EncoderSteam {
+		u.Encoder = string(otp.EncoderSteam)
+	}
+
+	return u, nil
+}
+
+// steamDigits is the number of characters of the Steam Guard codes.
+const steamDigits = 5
+
+// totpParams are the parameters of a TOTP.
+type totpParams struct {
+	digits    otp.Digits
+	period    time.Duration
+	algorithm otp.Algorithm
+	encoder   otp.Encoder
+}
+
+// parseTOTPParams returns the TOTP secret and the parameters of a value that is either a bare secret or an otpauth
+// URI. A bare secret uses 6 digits, a period of 30 seconds and HMAC-SHA1. The parameters that are missing in the URI
+// use the same defaults. The Steam Guard codes, with the "steam" encoder, always have 5 characters.
+func parseTOTPParams(s TOTPSecret) (TOTPSecret, totpParams, error) {
+	params := totpParams{
+		digits:    otp.DigitsSix,
+		period:    TOTPPeriod,
+		algorithm: otp.AlgorithmSHA1,
+	}
+
+	uri := strings.TrimSpace(string(s))
+	if !IsOTPAuthURI(uri) {
+		return s, params, nil
+	}
+
+	key, err := totpKeyFromURI(uri)
+	if err != nil {
+		return NoTOTPSecret, params, err
+	}
+
+	params.digits = key.Digits()
+	params.encoder = key.Encoder()
+
+	if params.encoder == otp.EncoderSteam {
+		params.digits = steamDigits
+	} else if d := params.digits; d < 6 || d > 8 {
+		return NoTOTPSecret, params, fmt.Errorf("%w: unsupported digits %d", ErrInvalidOTPAuthURI, d)
+	}
+
+	if p := key.Period(); p == 0 || p > 3600 {
+		return NoTOTPSecret, params, fmt.Errorf("%w: unsupported period %d", ErrInvalidOTPAuthURI, p)
+	}
+
+	params.period = time.Duration(key.Period()) * time.Second //nolint: gosec
+	params.algorithm = key.Algorithm()
+
+	return TOTPSecret(key.Secret()), params, nil
+}
@@ -0,0 +1,127 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+)
+
+func TestParseTOTPSecret(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		input          string
+		expectedResult otp.TOTPSecret
+		expectedError  string
+	}{
+		{
+			scenario: "empty",
+		},
+		{
+			scenario:       "bare secret",
+			input:          " NBSWY3DP\n",
+			expectedResult: "NBSWY3DP",
+		},
+		{
+			scenario:       "otpauth uri",
+			input:          "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example",
+			expectedResult: "otpauth://totp/Example:john@example.com?secret=NBSWY3DP&issuer=Example",
+		},
+		{
+			scenario:       "otpauth uri with parameters",
+			input:          " otpauth://totp/john?secret=NBSWY3DP&digits=8&algorithm=SHA256\n",
+			expectedResult: "otpauth://totp/john?secret=NBSWY3DP&digits=8&algorithm=SHA256",
+		},
+		{
+			scenario:       "upper case scheme",
+			input:          "OTPAUTH://totp/john?secret=NBSWY3DP",
+			expectedResult: "OTPAUTH://totp/john?secret=NBSWY3DP",
+		},
+		{
+			scenario:      "unsupported digits",
+			input:         "otpauth://totp/john?secret=NBSWY3DP&digits=4",
+			expectedError: "invalid otpauth uri: unsupported digits 4",
+		},
+		{
+			scenario:      "hotp",
+			input:         "otpauth://hotp/john?secret=NBSWY3DP&counter=1",
+			expectedError: `invalid otpauth uri: unsupported type "hotp"`,
+		},
+		{
+			scenario:      "missing secret",
+			input:         "otpauth://totp/john?issuer=Example",
+			expectedError: "invalid otpauth uri: missing secret",
+		},
+		{
+			scenario:      "invalid uri",
+			input:         "otpauth://totp/%zz",
+			expectedError: "invalid otpauth uri: malformed uri",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := otp.ParseTOTPSecret(tc.input)
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+				require.ErrorIs(t, err, otp.ErrInvalidOTPAuthURI)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	t.Parallel()
+
+	u := otp.TOTPURI{
+		Issuer:      "Acme Co",
+		AccountName: "john@example.com",
+		Secret:      "NBSWY3DP",
+		Digits:      8,
+		Period:      60 * time.Second,
+		Algorithm:   "sha256",
+	}
+
+	uri := u.Encode()
+
+	assert.Equal(t, otp.TOTPSecret("otpauth://totp/Acme%20Co:john@example.com?algorithm=SHA256&digits=8&issuer=Acme+Co&period=60&secret=NBSWY3DP"), uri)
+
+	actual, err := otp.ParseTOTPURI(uri.Reveal())
+	require.NoError(t, err)
+
+	u.Algorithm = "SHA256"
+
+	assert.Equal(t, u, actual)
+
+	// The missing parameters are set to their defaults.
+	actual, err = otp.ParseTOTPURI(otp.TOTPURI{AccountName: "john", Secret: "NBSWY3DP", Encoder: "Steam"}.Encode().Reveal())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.TOTPURI{
+		AccountName: "john",
+		Secret:      "NBSWY3DP",
+		Digits:      6,
+		Period:      30 * time.Second,
+		Algorithm:   "SHA1",
+		Encoder:     "steam",
+	}, actual)
+
+	_, err = otp.ParseTOTPURI("otpauth://hotp/john?secret=NBSWY3DP")
+	require.ErrorIs(t, err, otp.ErrInvalidOTPAuthURI)
+}
@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoToken indicates that there is no token to authenticate with.
+var ErrNoToken = errors.New("no vault token")
+
+// Auth is an authentication method of Vault.
+type Auth interface {
+	login(ctx context.Context, c *Client) (token string, ttl time.Duration, err error)
+	renewable() bool
+}
+
+type tokenAuth string
+
+func (a tokenAuth) login(context.Context, *Client) (string, time.Duration, error) {
+	if a == "" {
+		return "", 0, ErrNoToken
+	}
+
+	return string(a), 0, nil
+}
+
+func (a tokenAuth) renewable() bool {
+	return false
+}
+
+// TokenAuth authenticates with a token.
+func TokenAuth(token string) Auth {
+	return tokenAuth(token)
+}
+
+type appRoleAuth struct {
+	mount    string
+	roleID   string
+	secretID string
+}
+
+func (a appRoleAuth) login(ctx context.Context, c *Client) (string, time.Duration, error) {
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+
+	in := map[string]string{"role_id": a.roleID}
+
+	if a.secretID != "" {
+		in["secret_id"] = a.secretID
+	}
+
+	if err := c.do(ctx, http.MethodPost, "auth/"+a.mount+"/login", "", in, &resp); err != nil {
+		return "", 0, err
+	}
+
+	if resp.Auth.ClientToken == "" {
+		return "", 0, ErrNoToken
+	}
+
+	return resp.Auth.ClientToken, time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+func (a appRoleAuth) renewable() bool {
+	return true
+}
+
+// AppRoleAuth authenticates with the AppRole method mounted at "approle". The token is renewed when it expires or is
+// rejected. The secret ID may be empty if the role does not require it.
+func AppRoleAuth(roleID, secretID string) Auth {
+	return AppRoleAuthAt("approle", roleID, secretID)
+}
+
+// AppRoleAuthAt authenticates with the AppRole method mounted at the path.
+func AppRoleAuthAt(mount, roleID, secretID string) Auth {
+	return appRoleAuth{
+		mount:    strings.Trim(mount, "/"),
+		roleID:   roleID,
+		secretID: secretID,
+	}
+}
@@ -0,0 +1,293 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMount is the default path of the TOTP secrets engine.
+const DefaultMount = "totp"
+
+// ErrPermissionDenied indicates that the token is not allowed to call the api.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrUnsupportedTransport indicates that the TLS options could not be set because the transport of the http client is
+// not a *http.Transport.
+var ErrUnsupportedTransport = errors.New("unsupported transport")
+
+// ErrInvalidCACert indicates that the CA certificate file does not contain any PEM-encoded certificate.
+var ErrInvalidCACert = errors.New("invalid ca cert")
+
+// APIError is an error returned by the Vault api.
+type APIError struct {
+	StatusCode int
+	Errors     []string
+}
+
+// Error returns the error message.
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault: unexpected status code %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("vault: %s (status code %d)", strings.Join(e.Errors, ", "), e.StatusCode)
+}
+
+// Unwrap returns ErrPermissionDenied if the status code is 403.
+func (e *APIError) Unwrap() error {
+	if e.StatusCode == http.StatusForbidden {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// codeAlreadyUsed returns true if the engine rejects a code because it has been used, to prevent replay attacks.
+func (e *APIError) codeAlreadyUsed() bool {
+	for _, msg := range e.Errors {
+		if strings.Contains(msg, "code already used") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Client is a client of the Vault api.
+type Client struct {
+	address    string
+	namespace  string
+	mount      string
+	auth       Auth
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && (c.tokenExpiry.IsZero() || time.Now().Before(c.tokenExpiry)) {
+		return c.token, nil
+	}
+
+	token, ttl, err := c.auth.login(ctx, c)
+	if err != nil {
+		return "", fmt.Errorf("could not login to vault: %w", err)
+	}
+
+	c.token = token
+	c.tokenExpiry = time.Time{}
+
+	if ttl > 0 {
+		// Renew the token a bit before it expires.
+		c.tokenExpiry = time.Now().Add(ttl * 9 / 10)
+	}
+
+	return token, nil
+}
+
+func (c *Client) resetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// call calls the api with a token. If the token is rejected, it logs in again and retries once.
+func (c *Client) call(ctx context.Context, method, path string, in, out any) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.do(ctx, method, path, token, in, out)
+	if !errors.Is(err, ErrPermissionDenied) || !c.auth.renewable() {
+		return err
+	}
+
+	c.resetToken(token)
+
+	if token, err = c.getToken(ctx); err != nil {
+		return err
+	}
+
+	return c.do(ctx, method, path, token, in, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path, token string, in, out any) error {
+	var body io.Reader
+
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("could not encode vault request: %w", err)
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.address+"/v1/"+path, body)
+	if err != nil {
+		return fmt.Errorf("could not create vault request: %w", err)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call vault: %w", err)
+	}
+
+	defer resp.Body.Close() //nolint: errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+
+		_ = json.NewDecoder(resp.Body).Decode(apiErr) //nolint: errcheck
+
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode vault response: %w", err)
+	}
+
+	return nil
+}
+
+// NewClient initiates a new Client of the Vault server at the address, such as "https://vault.example.com:8200". By
+// default, it uses the token in the VAULT_TOKEN environment variable, and the TOTP secrets engine at "totp".
+func NewClient(address string, opts ...Option) (*Client, error) {
+	if _, err := url.Parse(address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w", err)
+	}
+
+	c := &Client{
+		address:    strings.TrimSuffix(address, "/"),
+		mount:      DefaultMount,
+		auth:       TokenAuth(os.Getenv("VAULT_TOKEN")),
+		httpClient: &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}, //nolint: forcetypeassert
+	}
+
+	for _, opt := range opts {
+		if err := opt.applyOption(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Option configures the Client.
+type Option interface {
+	applyOption(c *Client) error
+}
+
+type optionFunc func(c *Client) error
+
+func (f optionFunc) applyOption(c *Client) error {
+	return f(c)
+}
+
+// WithAuth sets the authentication method, see TokenAuth and AppRoleAuth.
+func WithAuth(a Auth) Option {
+	return optionFunc(func(c *Client) error {
+		c.auth = a
+
+		return nil
+	})
+}
+
+// WithNamespace sets the namespace of Vault Enterprise.
+func WithNamespace(ns string) Option {
+	return optionFunc(func(c *Client) error {
+		c.namespace = strings.Trim(ns, "/")
+
+		return nil
+	})
+}
+
+// WithMount sets the path of the TOTP secrets engine. Default is DefaultMount.
+func WithMount(mount string) Option {
+	return optionFunc(func(c *Client) error {
+		c.mount = strings.Trim(mount, "/")
+
+		return nil
+	})
+}
+
+// WithHTTPClient sets the http client. It overrides the TLS options that are set before.
+func WithHTTPClient(hc *http.Client) Option {
+	return optionFunc(func(c *Client) error {
+		c.httpClient = hc
+
+		return nil
+	})
+}
+
+// WithTLSConfig sets the TLS configuration of the http client.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return optionFunc(func(c *Client) error {
+		t, ok := c.httpClient.Transport.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("could not set tls config: %w: %T", ErrUnsupportedTransport, c.httpClient.Transport)
+		}
+
+		t.TLSClientConfig = cfg
+
+		return nil
+	})
+}
+
+// WithCACertFile trusts the PEM-encoded CA certificates in the file, in addition to the system ones.
+func WithCACertFile(path string) Option {
+	return optionFunc(func(c *Client) error {
+		data, err := os.ReadFile(path) //nolint: gosec
+		if err != nil {
+			return fmt.Errorf("could not read ca cert: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("could not read ca cert: %w: %s", ErrInvalidCACert, path)
+		}
+
+		return WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}).applyOption(c)
+	})
+}
@@ -0,0 +1,3 @@
+// Package vault provides one-time password generation and verification using the TOTP secrets engine of HashiCorp
+// Vault.
+package vault
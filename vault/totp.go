@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.nhat.io/otp"
+)
+
+var _ otp.Generator = (*Generator)(nil)
+
+// Generator generates the TOTPs of a key of the TOTP secrets engine. The secret never leaves Vault.
+type Generator struct {
+	client *Client
+	name   string
+}
+
+// GenerateOTP generates a TOTP by reading totp/code/:name.
+func (g *Generator) GenerateOTP(ctx context.Context) (otp.OTP, error) {
+	var resp struct {
+		Data struct {
+			Code string `json:"code"`
+		} `json:"data"`
+	}
+
+	if err := g.client.call(ctx, http.MethodGet, codePath(g.client, g.name), nil, &resp); err != nil {
+		return "", fmt.Errorf("could not generate otp: %w", err)
+	}
+
+	if resp.Data.Code == "" {
+		return "", fmt.Errorf("could not generate otp: %w", otp.ErrNoTOTPSecret)
+	}
+
+	return otp.OTP(resp.Data.Code), nil
+}
+
+// NewGenerator initiates a new Generator of the key.
+func NewGenerator(c *Client, name string) *Generator {
+	return &Generator{client: c, name: name}
+}
+
+var _ otp.Verifier = (*Verifier)(nil)
+
+// Verifier verifies the TOTPs of a key of the TOTP secrets engine.
+type Verifier struct {
+	client *Client
+	name   string
+}
+
+// VerifyOTP verifies a TOTP by writing it to totp/code/:name. It returns otp.ErrInvalidOTP if the code does not match.
+func (v *Verifier) VerifyOTP(ctx context.Context, code otp.OTP) error {
+	var resp struct {
+		Data struct {
+			Valid bool `json:"valid"`
+		} `json:"data"`
+	}
+
+	in := map[string]string{"code": string(code)}
+
+	err := v.client.call(ctx, http.MethodPost, codePath(v.client, v.name), in, &resp)
+
+	var apiErr *APIError
+
+	switch {
+	case errors.As(err, &apiErr) && apiErr.codeAlreadyUsed():
+		return otp.ErrInvalidOTP
+
+	case err != nil:
+		return fmt.Errorf("could not verify otp: %w", err)
+	}
+
+	if !resp.Data.Valid {
+		return otp.ErrInvalidOTP
+	}
+
+	return nil
+}
+
+// NewVerifier initiates a new Verifier of the key.
+func NewVerifier(c *Client, name string) *Verifier {
+	return &Verifier{client: c, name: name}
+}
+
+func codePath(c *Client, name string) string {
+	return c.mount + "/code/" + url.PathEscape(name)
+}
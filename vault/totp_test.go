@@ -0,0 +1,411 @@
+//go:build unit || !integration
+
+package vault_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/vault"
+)
+
+// engine mimics the api of the TOTP secrets engine and the AppRole auth method.
+type engine struct {
+	namespace string
+	codes     map[string]string
+
+	mu     sync.Mutex
+	tokens map[string]bool
+	used   map[string]bool
+	logins atomic.Int32
+}
+
+func newEngine(tokens ...string) *engine {
+	e := &engine{
+		codes:  map[string]string{"github": "191882"},
+		tokens: make(map[string]bool),
+		used:   make(map[string]bool),
+	}
+
+	for _, t := range tokens {
+		e.tokens[t] = true
+	}
+
+	return e
+}
+
+func (e *engine) revoke(token string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.tokens, token)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v) //nolint: errcheck
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...string) {
+	writeJSON(w, status, map[string]any{"errors": errs})
+}
+
+func (e *engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Namespace") != e.namespace {
+		writeErrors(w, http.StatusNotFound, "no handler for route")
+
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		e.login(w, r)
+
+		return
+	}
+
+	e.mu.Lock()
+	ok := e.tokens[r.Header.Get("X-Vault-Token")]
+	e.mu.Unlock()
+
+	if !ok {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+
+		return
+	}
+
+	name, found := strings.CutPrefix(r.URL.Path, "/v1/totp/code/")
+	if !found {
+		writeErrors(w, http.StatusNotFound, "no handler for route")
+
+		return
+	}
+
+	code, ok := e.codes[name]
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "unknown key: "+name)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"code": code}})
+
+	case http.MethodPost:
+		var in struct {
+			Code string `json:"code"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&in) //nolint: errcheck
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if e.used[in.Code] {
+			writeErrors(w, http.StatusBadRequest, "code already used; wait until the next time period")
+
+			return
+		}
+
+		valid := in.Code == code
+		if valid {
+			e.used[in.Code] = true
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"valid": valid}})
+
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "unsupported operation")
+	}
+}
+
+func (e *engine) login(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		RoleID   string `json:"role_id"`
+		SecretID string `json:"secret_id"`
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&in) //nolint: errcheck
+
+	if in.RoleID != "role" || in.SecretID != "secret" {
+		writeErrors(w, http.StatusBadRequest, "invalid role or secret ID")
+
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	token := fmt.Sprintf("s.approle%d", e.logins.Add(1))
+	e.tokens[token] = true
+
+	writeJSON(w, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": token, "lease_duration": 3600}})
+}
+
+func TestGenerator_GenerateOTP(t *testing.T) {
+	t.Parallel()
+
+	e := newEngine("root")
+	srv := httptest.NewServer(e)
+
+	t.Cleanup(srv.Close)
+
+	testCases := []struct {
+		scenario       string
+		name           string
+		opts           []vault.Option
+		expectedResult otp.OTP
+		expectedError  string
+	}{
+		{
+			scenario:       "token",
+			name:           "github",
+			opts:           []vault.Option{vault.WithAuth(vault.TokenAuth("root"))},
+			expectedResult: "191882",
+		},
+		{
+			scenario:      "no token",
+			name:          "github",
+			opts:          []vault.Option{vault.WithAuth(vault.TokenAuth(""))},
+			expectedError: "could not generate otp: could not login to vault: no vault token",
+		},
+		{
+			scenario:      "invalid token",
+			name:          "github",
+			opts:          []vault.Option{vault.WithAuth(vault.TokenAuth("invalid"))},
+			expectedError: "could not generate otp: vault: permission denied (status code 403)",
+		},
+		{
+			scenario:      "unknown key",
+			name:          "gitlab",
+			opts:          []vault.Option{vault.WithAuth(vault.TokenAuth("root"))},
+			expectedError: "could not generate otp: vault: unknown key: gitlab (status code 400)",
+		},
+		{
+			scenario:       "approle",
+			name:           "github",
+			opts:           []vault.Option{vault.WithAuth(vault.AppRoleAuth("role", "secret"))},
+			expectedResult: "191882",
+		},
+		{
+			scenario:      "approle invalid secret",
+			name:          "github",
+			opts:          []vault.Option{vault.WithAuth(vault.AppRoleAuth("role", "invalid"))},
+			expectedError: "could not generate otp: could not login to vault: vault: invalid role or secret ID (status code 400)",
+		},
+		{
+			scenario:      "wrong namespace",
+			name:          "github",
+			opts:          []vault.Option{vault.WithAuth(vault.TokenAuth("root")), vault.WithNamespace("team")},
+			expectedError: "could not generate otp: vault: no handler for route (status code 404)",
+		},
+		{
+			scenario:      "wrong mount",
+			name:          "github",
+			opts:          []vault.Option{vault.WithAuth(vault.TokenAuth("root")), vault.WithMount("/otp/")},
+			expectedError: "could not generate otp: vault: no handler for route (status code 404)",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := vault.NewClient(srv.URL, tc.opts...)
+			require.NoError(t, err)
+
+			actual, err := vault.NewGenerator(c, tc.name).GenerateOTP(context.Background())
+
+			assert.Equal(t, tc.expectedResult, actual)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyOTP(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(newEngine("root"))
+
+	t.Cleanup(srv.Close)
+
+	c, err := vault.NewClient(srv.URL, vault.WithAuth(vault.TokenAuth("root")))
+	require.NoError(t, err)
+
+	v := vault.NewVerifier(c, "github")
+
+	require.ErrorIs(t, v.VerifyOTP(context.Background(), "000000"), otp.ErrInvalidOTP)
+	require.NoError(t, v.VerifyOTP(context.Background(), "191882"))
+
+	// The engine rejects the codes that have been used.
+	require.ErrorIs(t, v.VerifyOTP(context.Background(), "191882"), otp.ErrInvalidOTP)
+
+	err = vault.NewVerifier(c, "gitlab").VerifyOTP(context.Background(), "191882")
+	require.EqualError(t, err, "could not verify otp: vault: unknown key: gitlab (status code 400)")
+}
+
+func TestClient_AppRole_Relogin(t *testing.T) {
+	t.Parallel()
+
+	e := newEngine()
+	srv := httptest.NewServer(e)
+
+	t.Cleanup(srv.Close)
+
+	c, err := vault.NewClient(srv.URL, vault.WithAuth(vault.AppRoleAuth("role", "secret")))
+	require.NoError(t, err)
+
+	g := vault.NewGenerator(c, "github")
+
+	_, err = g.GenerateOTP(context.Background())
+	require.NoError(t, err)
+
+	_, err = g.GenerateOTP(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), e.logins.Load())
+
+	// The token is revoked, the client logs in again.
+	e.revoke("s.approle1")
+
+	_, err = g.GenerateOTP(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), e.logins.Load())
+}
+
+func TestClient_Namespace(t *testing.T) {
+	t.Parallel()
+
+	e := newEngine("root")
+	e.namespace = "team/a"
+
+	srv := httptest.NewServer(e)
+
+	t.Cleanup(srv.Close)
+
+	c, err := vault.NewClient(srv.URL+"/", vault.WithAuth(vault.TokenAuth("root")), vault.WithNamespace("/team/a/"))
+	require.NoError(t, err)
+
+	actual, err := vault.NewGenerator(c, "github").GenerateOTP(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.OTP("191882"), actual)
+}
+
+func TestClient_TokenFromEnv(t *testing.T) { //nolint: paralleltest
+	srv := httptest.NewServer(newEngine("root"))
+
+	t.Cleanup(srv.Close)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	c, err := vault.NewClient(srv.URL)
+	require.NoError(t, err)
+
+	actual, err := vault.NewGenerator(c, "github").GenerateOTP(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, otp.OTP("191882"), actual)
+}
+
+func TestClient_TLS(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(newEngine("root"))
+
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	testCases := []struct {
+		scenario      string
+		opts          []vault.Option
+		expectedError string
+	}{
+		{
+			scenario:      "untrusted",
+			expectedError: "could not generate otp: could not call vault: ",
+		},
+		{
+			scenario: "tls config",
+			opts:     []vault.Option{vault.WithTLSConfig(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12})},
+		},
+		{
+			scenario: "ca cert file",
+			opts:     []vault.Option{vault.WithCACertFile(caFile)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := vault.NewClient(srv.URL, append(tc.opts, vault.WithAuth(vault.TokenAuth("root")))...)
+			require.NoError(t, err)
+
+			_, err = vault.NewGenerator(c, "github").GenerateOTP(context.Background())
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewClient_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := vault.NewClient("http://localhost", vault.WithCACertFile(filepath.Join(t.TempDir(), "missing.pem")))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	_, err = vault.NewClient("http://localhost", vault.WithCACertFile(empty))
+	require.ErrorIs(t, err, vault.ErrInvalidCACert)
+
+	_, err = vault.NewClient("http://localhost",
+		vault.WithHTTPClient(&http.Client{Transport: http.NewFileTransport(http.Dir("."))}),
+		vault.WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+	)
+	require.ErrorIs(t, err, vault.ErrUnsupportedTransport)
+
+	_, err = vault.NewClient("http://[::1")
+	require.ErrorContains(t, err, "invalid vault address")
+}
@@ -0,0 +1,110 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bool64/ctxd"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"go.nhat.io/clock"
+)
+
+// ErrInvalidOTP indicates that the one-time password is invalid.
+var ErrInvalidOTP = errors.New("invalid otp")
+
+// Verifier is a one-time password verifier.
+type Verifier interface {
+	VerifyOTP(ctx context.Context, code OTP) error
+}
+
+var _ Verifier = (*TOTPVerifier)(nil)
+
+// TOTPVerifier verifies TOTPs.
+type TOTPVerifier struct {
+	secretGetter TOTPSecretGetter
+	clock        clock.Clock
+	logger       ctxd.Logger
+	skew         uint
+}
+
+// VerifyOTP verifies a TOTP. It returns ErrInvalidOTP if the code does not match. The TOTP secret may be an otpauth
+// URI, its digits, period, algorithm and encoder are honored.
+func (v *TOTPVerifier) VerifyOTP(ctx context.Context, code OTP) error {
+	s := v.secretGetter.TOTPSecret(ctx)
+	if s == NoTOTPSecret {
+		v.logger.Error(ctx, "could not verify otp", "error", ErrNoTOTPSecret)
+
+		return fmt.Errorf("could not verify otp: %w", ErrNoTOTPSecret)
+	}
+
+	s, params, err := parseTOTPParams(s)
+	if err != nil {
+		v.logger.Error(ctx, "could not verify otp", "error", err)
+
+		return fmt.Errorf("could not verify otp: %w", err)
+	}
+
+	ok, err := totp.ValidateCustom(string(code), string(s), v.clock.Now(), totp.ValidateOpts{
+		Period:    uint(params.period.Seconds()),
+		Skew:      v.skew,
+		Digits:    params.digits,
+		Algorithm: params.algorithm,
+		Encoder:   params.encoder,
+	})
+
+	switch {
+	case errors.Is(err, otp.ErrValidateInputInvalidLength), err == nil && !ok:
+		v.logger.Debug(ctx, "invalid otp")
+
+		return ErrInvalidOTP
+
+	case err != nil:
+		v.logger.Error(ctx, "could not verify otp", "error", err)
+
+		return fmt.Errorf("could not verify otp: %w", err)
+	}
+
+	return nil
+}
+
+// NewTOTPVerifier initiates a new TOTPVerifier. By default, it accepts the codes of the previous and the next time step
+// to tolerate clock drift.
+func NewTOTPVerifier(secretGetter TOTPSecretGetter, opts ...TOTPVerifierOption) *TOTPVerifier {
+	v := &TOTPVerifier{
+		secretGetter: secretGetter,
+		clock:        clock.New(),
+		logger:       ctxd.NoOpLogger{},
+		skew:         1,
+	}
+
+	for _, opt := range opts {
+		opt.applyTOTPVerifierOption(v)
+	}
+
+	return v
+}
+
+// VerifyTOTP verifies a TOTP.
+func VerifyTOTP(ctx context.Context, secret TOTPSecretGetter, code OTP, opts ...TOTPVerifierOption) error {
+	return NewTOTPVerifier(secret, opts...).VerifyOTP(ctx, code)
+}
+
+// TOTPVerifierOption is an option to configure TOTPVerifier.
+type TOTPVerifierOption interface {
+	applyTOTPVerifierOption(v *TOTPVerifier)
+}
+
+type totpVerifierOptionFunc func(v *TOTPVerifier)
+
+func (f totpVerifierOptionFunc) applyTOTPVerifierOption(v *TOTPVerifier) {
+	f(v)
+}
+
+// WithSkew sets the number of time steps before and after the current one that are accepted. Default is 1.
+func WithSkew(skew uint) TOTPVerifierOption {
+	return totpVerifierOptionFunc(func(v *TOTPVerifier) {
+		v.skew = skew
+	})
+}
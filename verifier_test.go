@@ -0,0 +1,145 @@
+//go:build unit || !integration
+
+package otp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.nhat.io/clock"
+
+	"go.nhat.io/otp"
+	"go.nhat.io/otp/mock"
+)
+
+func TestTOTPVerifier_VerifyOTP(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario         string
+		mockSecretGetter mock.TOTPSecretGetterMocker
+		code             otp.OTP
+		options          []otp.TOTPVerifierOption
+		expectedError    string
+	}{
+		{
+			scenario: "no totp secret",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.NoTOTPSecret)
+			}),
+			code:          "191882",
+			expectedError: "could not verify otp: no totp secret",
+		},
+		{
+			scenario: "invalid secret",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("secret"))
+			}),
+			code:          "191882",
+			expectedError: "could not verify otp: Decoding of secret as base32 failed.",
+		},
+		{
+			scenario: "invalid length",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			code:          "1918",
+			expectedError: "invalid otp",
+		},
+		{
+			scenario: "mismatch",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			code:          "000000",
+			expectedError: "invalid otp",
+		},
+		{
+			scenario: "success",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			code: "191882",
+		},
+		{
+			scenario: "previous time step",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			code:    "191882",
+			options: []otp.TOTPVerifierOption{otp.WithClock(clock.Fix(time.Date(2024, time.January, 1, 0, 0, 30, 0, time.UTC)))},
+		},
+		{
+			scenario: "previous time step without skew",
+			mockSecretGetter: mock.MockTOTPSecretGetter(func(g *mock.TOTPSecretGetter) {
+				g.On("TOTPSecret", context.Background()).
+					Return(otp.TOTPSecret("NBSWY3DP"))
+			}),
+			code: "191882",
+			options: []otp.TOTPVerifierOption{
+				otp.WithClock(clock.Fix(time.Date(2024, time.January, 1, 0, 0, 30, 0, time.UTC))),
+				otp.WithSkew(0),
+			},
+			expectedError: "invalid otp",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+			opts := append([]otp.TOTPVerifierOption{otp.WithClock(c)}, tc.options...)
+
+			err := otp.NewTOTPVerifier(tc.mockSecretGetter(t), opts...).VerifyOTP(context.Background(), tc.code)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	t.Parallel()
+
+	c := clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	err := otp.VerifyTOTP(context.Background(), otp.TOTPSecret("NBSWY3DP"), "191882", otp.WithClock(c))
+	require.NoError(t, err)
+
+	err = otp.VerifyTOTP(context.Background(), otp.TOTPSecret("NBSWY3DP"), "123456", otp.WithClock(c))
+	require.ErrorIs(t, err, otp.ErrInvalidOTP)
+}
+
+func TestVerifyTOTP_URI(t *testing.T) {
+	t.Parallel()
+
+	secret := otp.TOTPSecret("otpauth://totp/john?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA&digits=8&algorithm=SHA256&period=60")
+	c := clock.Fix(time.Unix(119, 0))
+
+	err := otp.VerifyTOTP(context.Background(), secret, "46119246", otp.WithClock(c))
+	require.NoError(t, err)
+
+	err = otp.VerifyTOTP(context.Background(), secret, "191882", otp.WithClock(c))
+	require.ErrorIs(t, err, otp.ErrInvalidOTP)
+
+	steam := otp.TOTPSecret("otpauth://totp/Steam:john?secret=NBSWY3DP&issuer=Steam&encoder=steam")
+
+	err = otp.VerifyTOTP(context.Background(), steam, "485B8", otp.WithClock(clock.Fix(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))))
+	require.NoError(t, err)
+
+	err = otp.VerifyTOTP(context.Background(), otp.TOTPSecret("otpauth://totp/john?secret=NBSWY3DP&digits=4"), "1918", otp.WithClock(c))
+	require.EqualError(t, err, "could not verify otp: invalid otpauth uri: unsupported digits 4")
+}